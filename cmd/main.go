@@ -5,17 +5,29 @@ import (
 	"os"
 
 	"kfzteile24/waflogs/pkg/cmd"
+	"kfzteile24/waflogs/pkg/data"
 
 	"github.com/urfave/cli/v2"
 )
 
 func main() {
+	if err := cmd.LoadReportDefinitions(); err != nil {
+		log.Fatalf("[!] Error loading report definitions: %s", err)
+	}
+
 	var cmds []*cli.Command
 	cmds = append(cmds, cmd.MakeLoadCmd())
 	cmds = append(cmds, cmd.MakeReportCmd())
+	cmds = append(cmds, cmd.MakeGenCmd())
+	cmds = append(cmds, cmd.MakeLocalCmd())
+	cmds = append(cmds, cmd.MakeDataCmd())
+	cmds = append(cmds, cmd.MakeCloudWatchCmd())
+	cmds = append(cmds, cmd.MakeTailCmd())
+	cmds = append(cmds, cmd.MakeListReportsCmd())
 
 	app := &cli.App{
 		Commands: cmds,
+		Version:  data.ToolVersion,
 	}
 
 	if err := app.Run(os.Args); err != nil {
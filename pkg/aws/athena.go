@@ -3,8 +3,8 @@ package aws
 import (
 	"context"
 	"encoding/csv"
-	"errors"
 	"fmt"
+	"kfzteile24/waflogs/pkg/query"
 	"math"
 	"os"
 	"time"
@@ -15,9 +15,8 @@ import (
 )
 
 type AthenaClient struct {
-	Profile      string
-	ctx          context.Context
-	skipExisting bool // to skip queries we have results for already
+	Profile string
+	ctx     context.Context
 
 	Catalog        string
 	Database       string
@@ -26,16 +25,15 @@ type AthenaClient struct {
 	AWS *athena.Client
 }
 
-func NewAthenaClient(ctx context.Context, profile string, region string, skipExisting bool) (*AthenaClient, error) {
+func NewAthenaClient(ctx context.Context, profile string, region string) (*AthenaClient, error) {
 	cfg, err := loadConfig(ctx, profile, region)
 	if err != nil {
 		return nil, fmt.Errorf("creating AWS config: %s", err)
 	}
 
 	return &AthenaClient{
-		Profile:      profile,
-		ctx:          ctx,
-		skipExisting: skipExisting,
+		Profile: profile,
+		ctx:     ctx,
 
 		Catalog:        "AwsDataCatalog",
 		Database:       "waflogs",
@@ -44,27 +42,25 @@ func NewAthenaClient(ctx context.Context, profile string, region string, skipExi
 	}, nil
 }
 
-// Query executes an sql statement and stores the result locally at dstPath
-func (c *AthenaClient) Query(sql string, dstPath string) error {
-	if c.skipExisting && fileExists(dstPath) {
-		return nil // assume the existing file contains the result
-	}
-
+// Query executes an sql statement, stores the result locally at dstPath and
+// returns the execution of the query
+func (c *AthenaClient) Query(sql string, dstPath string) (*query.Execution, error) {
 	qid, err := c.startQueryExecution(sql)
 	if err != nil {
-		return fmt.Errorf("starting query: %s", err)
+		return nil, fmt.Errorf("starting query: %s", err)
 	}
 
 	fmt.Printf("[+] Query %s started\n", qid)
 
+	var bytesScanned int64
 	for i := 0; i < 10; i++ {
 		select {
 		case <-c.ctx.Done():
 			// cancel in-flight query, no need to pay for it
 			if err := c.stopQueryExecution(qid); err != nil {
-				return fmt.Errorf("cancelling query %s: %s", qid, err)
+				return nil, fmt.Errorf("cancelling query %s: %s", qid, err)
 			}
-			return nil
+			return nil, fmt.Errorf("query %s cancelled", qid)
 		case <-time.After(time.Duration(i) * 3 * time.Second):
 			// wait a few seconds for results
 		}
@@ -74,7 +70,7 @@ func (c *AthenaClient) Query(sql string, dstPath string) error {
 		if err != nil {
 			nerr += 1
 			if nerr > 3 {
-				return fmt.Errorf("getting query status failed too often, last error: %s", err)
+				return nil, fmt.Errorf("getting query status failed too often, last error: %s", err)
 			}
 		}
 
@@ -84,19 +80,21 @@ func (c *AthenaClient) Query(sql string, dstPath string) error {
 		}
 
 		if e.State == QueryFailed {
-			return fmt.Errorf("query execution failed (Reason: %s)", e.Reason)
+			return nil, fmt.Errorf("query execution failed (Reason: %s)", e.Reason)
 		}
 
 		// success
-		fmt.Printf("[+] Query %s finished successfully, scanned %s (~%s)\n", qid, bytesToHuman(e.BytesScanned), estimatedQueryCost(e.BytesScanned))
+		bytesScanned = e.BytesScanned
+		fmt.Printf("[+] Query %s finished successfully, scanned %s (~%s)\n", qid, BytesToHuman(e.BytesScanned), EstimatedQueryCost(e.BytesScanned))
 		break
 	}
 
-	if err := c.getQueryResults(qid, dstPath); err != nil {
-		return fmt.Errorf("getting query results: %s", err)
+	cols, err := c.getQueryResults(qid, dstPath)
+	if err != nil {
+		return nil, fmt.Errorf("getting query results: %s", err)
 	}
 
-	return nil
+	return &query.Execution{ID: qid, BytesScanned: bytesScanned, Columns: cols}, nil
 }
 
 func (c *AthenaClient) startQueryExecution(sql string) (string, error) {
@@ -193,16 +191,17 @@ func (c *AthenaClient) getQueryExecution(qid string) (*QueryStatus, error) {
 	}, nil
 }
 
-func (c *AthenaClient) getQueryResults(qid string, dstPath string) error {
+func (c *AthenaClient) getQueryResults(qid string, dstPath string) ([]query.Column, error) {
 	f, err := os.OpenFile(dstPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
 	if err != nil {
-		return fmt.Errorf("opening destination file path: %s", err)
+		return nil, fmt.Errorf("opening destination file path: %s", err)
 	}
 	defer f.Close()
 
 	wFile := csv.NewWriter(f)
 	wStdOut := csv.NewWriter(os.Stdout)
 
+	var cols []query.Column
 	var next *string
 	var numRowsPreview int
 	for {
@@ -215,11 +214,17 @@ func (c *AthenaClient) getQueryResults(qid string, dstPath string) error {
 			},
 		)
 		if err != nil {
-			return fmt.Errorf("describing images: %s", err)
+			return nil, fmt.Errorf("describing images: %s", err)
 		}
 
 		if resp.ResultSet == nil {
-			return fmt.Errorf("no result set returned")
+			return nil, fmt.Errorf("no result set returned")
+		}
+
+		if cols == nil && resp.ResultSet.ResultSetMetadata != nil {
+			for _, info := range resp.ResultSet.ResultSetMetadata.ColumnInfo {
+				cols = append(cols, query.Column{Name: safeString(info.Name), Type: safeString(info.Type)})
+			}
 		}
 
 		for _, row := range resp.ResultSet.Rows {
@@ -249,7 +254,7 @@ func (c *AthenaClient) getQueryResults(qid string, dstPath string) error {
 
 	wFile.Flush()
 
-	return nil
+	return cols, nil
 }
 
 func (c *AthenaClient) stopQueryExecution(qid string) error {
@@ -280,7 +285,8 @@ func safeInt64(i *int64) int64 {
 	return *i
 }
 
-func bytesToHuman(b int64) string {
+// BytesToHuman formats a number of bytes, e.g. 1.5GB
+func BytesToHuman(b int64) string {
 	bf := float64(b)
 	for _, unit := range []string{"", "K", "M", "G", "T", "P", "E", "Z"} {
 		if math.Abs(bf) < 1024.0 {
@@ -294,13 +300,6 @@ func bytesToHuman(b int64) string {
 const TB = 1099511627776 // number of bytes of a TB
 
 // price is 5 USD per TB scanned
-func estimatedQueryCost(bytesScanned int64) string {
+func EstimatedQueryCost(bytesScanned int64) string {
 	return fmt.Sprintf("%.2f USD", 5.0*(float64(bytesScanned)/float64(TB)))
 }
-
-func fileExists(path string) bool {
-	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-		return false
-	}
-	return true
-}
@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"kfzteile24/waflogs/pkg/waflog"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// LogsAPI is the part of the CloudWatch Logs API used to run Logs Insights
+// queries, implemented by cloudwatchlogs.Client and local stand-ins in tests
+type LogsAPI interface {
+	StartQuery(ctx context.Context, params *cloudwatchlogs.StartQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error)
+	GetQueryResults(ctx context.Context, params *cloudwatchlogs.GetQueryResultsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error)
+	StopQuery(ctx context.Context, params *cloudwatchlogs.StopQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error)
+}
+
+// InsightsLimit is the maximum number of events a Logs Insights query returns
+const InsightsLimit = 10000
+
+// insightsQuery selects the raw WAF log records of the events
+const insightsQuery = "fields @timestamp, @message | sort @timestamp asc"
+
+// CloudWatchLogsClient reads the WAF log records of web ACLs logging to a
+// CloudWatch Logs log group with Logs Insights queries
+type CloudWatchLogsClient struct {
+	ctx      context.Context
+	LogGroup string
+	Poll     time.Duration // interval to poll for the results of queries
+
+	BytesScanned int64 // by all queries so far
+
+	AWS LogsAPI
+}
+
+func NewCloudWatchLogsClient(ctx context.Context, profile string, region string, logGroup string) (*CloudWatchLogsClient, error) {
+	cfg, err := loadConfig(ctx, profile, region)
+	if err != nil {
+		return nil, fmt.Errorf("creating AWS config: %s", err)
+	}
+
+	return NewCloudWatchLogsClientWithAPI(ctx, cloudwatchlogs.NewFromConfig(cfg), logGroup), nil
+}
+
+// NewCloudWatchLogsClientWithAPI creates a client querying the log group
+// through api, e.g. a local stand-in
+func NewCloudWatchLogsClientWithAPI(ctx context.Context, api LogsAPI, logGroup string) *CloudWatchLogsClient {
+	return &CloudWatchLogsClient{
+		ctx:      ctx,
+		LogGroup: logGroup,
+		Poll:     time.Second,
+		AWS:      api,
+	}
+}
+
+// Records calls fn for each record logged from start to end, in order of
+// time. Logs Insights returns at most InsightsLimit events per query, so the
+// time range is queried per hour and split further where an hour has more
+// events.
+func (c *CloudWatchLogsClient) Records(start time.Time, end time.Time, fn func(*waflog.Record) error) error {
+	for hour := start.Truncate(time.Hour); hour.Before(end); hour = hour.Add(time.Hour) {
+		from, to := hour, hour.Add(time.Hour)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+
+		// the time range of queries is inclusive and in seconds
+		if err := c.queryRange(from.Unix(), to.Unix()-1, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryRange calls fn for the records of the events from second first to
+// last, splitting the range while queries return the maximum of events
+func (c *CloudWatchLogsClient) queryRange(first int64, last int64, fn func(*waflog.Record) error) error {
+	results, err := c.query(first, last)
+	if err != nil {
+		return err
+	}
+
+	if len(results) >= InsightsLimit {
+		if first == last {
+			return fmt.Errorf("more than %d events logged at %s, can't query them all", InsightsLimit, time.Unix(first, 0).UTC())
+		}
+
+		mid := first + (last-first)/2
+		if err := c.queryRange(first, mid, fn); err != nil {
+			return err
+		}
+		return c.queryRange(mid+1, last, fn)
+	}
+
+	for _, fields := range results {
+		for _, f := range fields {
+			if safeString(f.Field) != "@message" {
+				continue
+			}
+			if err := waflog.Decode(strings.NewReader(safeString(f.Value)), fn); err != nil {
+				return fmt.Errorf("decoding event: %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// query runs the Logs Insights query for the events from second first to
+// last and returns its results
+func (c *CloudWatchLogsClient) query(first int64, last int64) ([][]types.ResultField, error) {
+	resp, err := c.AWS.StartQuery(c.ctx, &cloudwatchlogs.StartQueryInput{
+		LogGroupName: awssdk.String(c.LogGroup),
+		QueryString:  awssdk.String(insightsQuery),
+		StartTime:    awssdk.Int64(first),
+		EndTime:      awssdk.Int64(last),
+		Limit:        awssdk.Int32(InsightsLimit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("starting query: %s", err)
+	}
+	if resp.QueryId == nil {
+		return nil, fmt.Errorf("no query id returned")
+	}
+	qid := *resp.QueryId
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			// cancel in-flight query, no need to pay for it
+			if _, err := c.AWS.StopQuery(context.Background(), &cloudwatchlogs.StopQueryInput{QueryId: awssdk.String(qid)}); err != nil {
+				return nil, fmt.Errorf("cancelling query %s: %s", qid, err)
+			}
+			return nil, fmt.Errorf("query %s cancelled", qid)
+		case <-time.After(c.Poll):
+			// wait for results
+		}
+
+		out, err := c.AWS.GetQueryResults(c.ctx, &cloudwatchlogs.GetQueryResultsInput{QueryId: awssdk.String(qid)})
+		if err != nil {
+			return nil, fmt.Errorf("getting results of query %s: %s", qid, err)
+		}
+
+		switch out.Status {
+		case types.QueryStatusScheduled, types.QueryStatusRunning:
+			continue
+		case types.QueryStatusComplete:
+			var bytes int64
+			if out.Statistics != nil {
+				bytes = int64(out.Statistics.BytesScanned)
+			}
+			c.BytesScanned += bytes
+			fmt.Printf("[+] Query %s from %s returned %d events, scanned %s\n", qid, time.Unix(first, 0).UTC().Format("2006-01-02 15:04:05"), len(out.Results), BytesToHuman(bytes))
+			return out.Results, nil
+		default:
+			return nil, fmt.Errorf("query %s ended with status %s", qid, out.Status)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kfzteile24/waflogs/pkg/waflog"
+	"sort"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// localLogs is a local stand-in for the Logs Insights API of one log group,
+// answering every query with the messages of the events in its time range
+type localLogs struct {
+	logGroup string
+	events   []*waflog.Record // ordered by time
+
+	queries []*cloudwatchlogs.StartQueryInput
+	polls   map[string]int // of each query, results are ready on the second poll
+}
+
+func (l *localLogs) StartQuery(ctx context.Context, params *cloudwatchlogs.StartQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+	if awssdk.ToString(params.LogGroupName) != l.logGroup {
+		return nil, fmt.Errorf("log group %s not found", awssdk.ToString(params.LogGroupName))
+	}
+
+	l.queries = append(l.queries, params)
+	return &cloudwatchlogs.StartQueryOutput{QueryId: awssdk.String(fmt.Sprintf("q-%d", len(l.queries)))}, nil
+}
+
+func (l *localLogs) GetQueryResults(ctx context.Context, params *cloudwatchlogs.GetQueryResultsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	var i int
+	if _, err := fmt.Sscanf(awssdk.ToString(params.QueryId), "q-%d", &i); err != nil || i < 1 || i > len(l.queries) {
+		return nil, fmt.Errorf("query %s not found", awssdk.ToString(params.QueryId))
+	}
+
+	l.polls[*params.QueryId]++
+	if l.polls[*params.QueryId] < 2 {
+		return &cloudwatchlogs.GetQueryResultsOutput{Status: types.QueryStatusRunning}, nil
+	}
+
+	q := l.queries[i-1]
+	var results [][]types.ResultField
+	for _, r := range l.events {
+		// the time range of queries is inclusive and in seconds
+		if s := r.Timestamp / 1000; s < *q.StartTime || s > *q.EndTime {
+			continue
+		}
+		if len(results) == int(*q.Limit) {
+			break
+		}
+
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, []types.ResultField{
+			{Field: awssdk.String("@timestamp"), Value: awssdk.String(r.Time().Format("2006-01-02 15:04:05.000"))},
+			{Field: awssdk.String("@message"), Value: awssdk.String(string(b))},
+		})
+	}
+
+	return &cloudwatchlogs.GetQueryResultsOutput{
+		Status:     types.QueryStatusComplete,
+		Results:    results,
+		Statistics: &types.QueryStatistics{BytesScanned: float64(100 * len(results))},
+	}, nil
+}
+
+func (l *localLogs) StopQuery(ctx context.Context, params *cloudwatchlogs.StopQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error) {
+	return &cloudwatchlogs.StopQueryOutput{Success: true}, nil
+}
+
+func TestCloudWatchLogsRecords(t *testing.T) {
+	day := time.Date(2023, 2, 21, 0, 0, 0, 0, time.UTC)
+
+	logs := &localLogs{logGroup: "aws-waf-logs-bc", polls: map[string]int{}}
+	add := func(at time.Time, ip string) {
+		logs.events = append(logs.events, &waflog.Record{
+			Timestamp:   at.UnixMilli(),
+			Action:      "ALLOW",
+			HTTPRequest: waflog.HTTPRequest{ClientIP: ip, URI: "/"},
+		})
+	}
+	add(day.Add(-time.Millisecond), "0.0.0.0") // previous day
+	for i := 0; i < InsightsLimit+2000; i++ {  // more events within an hour than a query returns
+		add(day.Add(10*time.Hour+time.Duration(i)*200*time.Millisecond), "1.2.3.4")
+	}
+	add(day.Add(23*time.Hour+59*time.Minute+59*time.Second+999*time.Millisecond), "5.6.7.8")
+	add(day.Add(24*time.Hour), "9.9.9.9") // next day
+	sort.Slice(logs.events, func(i, j int) bool { return logs.events[i].Timestamp < logs.events[j].Timestamp })
+
+	c := NewCloudWatchLogsClientWithAPI(context.Background(), logs, "aws-waf-logs-bc")
+	c.Poll = 0
+
+	var got []*waflog.Record
+	err := c.Records(day, day.Add(24*time.Hour), func(r *waflog.Record) error {
+		got = append(got, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading records: %s", err)
+	}
+
+	if len(got) != InsightsLimit+2001 {
+		t.Fatalf("got %d records, want %d", len(got), InsightsLimit+2001)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Timestamp < got[i-1].Timestamp {
+			t.Fatalf("record %d out of order", i)
+		}
+	}
+	if first, last := got[0].HTTPRequest.ClientIP, got[len(got)-1].HTTPRequest.ClientIP; first != "1.2.3.4" || last != "5.6.7.8" {
+		t.Errorf("got records from %s to %s, want the day only", first, last)
+	}
+
+	// 24 hours and two more queries for splitting the busy hour
+	if len(logs.queries) != 26 {
+		t.Errorf("ran %d queries, want 26", len(logs.queries))
+	}
+	if c.BytesScanned != int64(100*len(got)+100*InsightsLimit) {
+		t.Errorf("scanned %d bytes", c.BytesScanned)
+	}
+
+	if err := NewCloudWatchLogsClientWithAPI(context.Background(), logs, "other").Records(day, day.Add(time.Hour), func(r *waflog.Record) error { return nil }); err == nil {
+		t.Errorf("read records of unknown log group")
+	}
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"kfzteile24/waflogs/pkg/aws"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"log"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// producer names the Firehose objects written from CloudWatch Logs. Fetching
+// an hour again replaces its object, the objects of imported files are named
+// after a digest of their content too, so importing a file again replaces
+// its objects and files covering the same hour are kept side by side.
+const producer = "cloudwatch"
+
+func MakeCloudWatchCmd() *cli.Command {
+
+	return &cli.Command{
+		Name:    "cloudwatch",
+		Aliases: []string{"cw"},
+		Usage:   "copy WAF logs of web ACLs logging to CloudWatch Logs to a local directory, to load reports with --source",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "fetch",
+				Usage: "read the logs of a log group with Logs Insights queries",
+				Flags: append(makeCloudWatchFlags(),
+					&cli.TimestampFlag{
+						Name:    "timestamp",
+						Aliases: []string{"t"},
+						Usage:   "first day to fetch logs for, e.g., 2023-02-21",
+						Layout:  "2006-01-02",
+						Action: func(ctx *cli.Context, v *time.Time) error {
+							if v != nil {
+								t = *v
+							}
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name:        "hours",
+						Value:       24,
+						Usage:       "number of hours to fetch logs for",
+						Destination: &hours,
+					},
+					&cli.StringFlag{
+						Name:        "log-group",
+						Aliases:     []string{"g"},
+						Usage:       "log group the web ACL logs to, e.g. aws-waf-logs-bc",
+						Required:    true,
+						Destination: &logGroup,
+					},
+					&cli.StringFlag{
+						Name:        "profile",
+						Aliases:     []string{"p"},
+						Value:       "k24SecruityRule-433833759926",
+						Usage:       "AWS profile for the account of the log group",
+						Destination: &profile,
+					},
+					&cli.StringFlag{
+						Name:        "region",
+						Aliases:     []string{"r"},
+						Value:       "eu-central-1",
+						Usage:       "AWS region of the log group",
+						Destination: &region,
+					},
+				),
+				Action: func(cCtx *cli.Context) error {
+					ctx := watchSignals()
+					start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+					end := start.Add(time.Duration(hours) * time.Hour)
+
+					log.Printf("[+] Fetching WAF logs from CloudWatch Logs\n")
+					log.Printf("    Params: time = %s, hours = %d, waf = %s, log group = %s, profile = %s region = %s out = %s\n", start.Format("2006-01-02"), hours, waf, logGroup, profile, region, out)
+
+					c, err := aws.NewCloudWatchLogsClient(ctx, profile, region, logGroup)
+					if err != nil {
+						log.Fatalf("Error making CloudWatch Logs client: %s", err)
+					}
+
+					w := offline.NewWriter(out, waf, producer)
+					if err := c.Records(start, end, w.Write); err != nil {
+						log.Fatalf("Error fetching logs of %s: %s", logGroup, err)
+					}
+
+					closeCloudWatchWriter(w)
+					log.Printf("[+] Logs Insights scanned %s\n", aws.BytesToHuman(c.BytesScanned))
+
+					return nil
+				},
+			},
+			{
+				Name:      "import",
+				Usage:     "read log files exported from a log group to S3, optionally gzipped",
+				ArgsUsage: "FILE...",
+				Flags:     makeCloudWatchFlags(),
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.NArg() == 0 {
+						return fmt.Errorf("no exported log files given")
+					}
+
+					log.Printf("[+] Importing WAF logs exported from CloudWatch Logs\n")
+					log.Printf("    Params: files = %d, waf = %s, out = %s\n", cCtx.NArg(), waf, out)
+
+					for _, path := range cCtx.Args().Slice() {
+						digest, err := fileDigest(path)
+						if err != nil {
+							log.Fatalf("Error reading %s: %s", path, err)
+						}
+
+						w := offline.NewWriter(out, waf, producer+"-"+digest)
+						if err := waflog.ReadCloudWatchExport(path, w.Write); err != nil {
+							log.Fatalf("Error reading %s: %s", path, err)
+						}
+						closeCloudWatchWriter(w)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// closeCloudWatchWriter writes the last hour of the records written to the
+// output directory in the Firehose layout the local and offline backends read
+func closeCloudWatchWriter(w *offline.Writer) {
+	if err := w.Close(); err != nil {
+		log.Fatalf("Error writing logs of %s: %s", waf, err)
+	}
+
+	log.Printf("[+] %s: %d records in %d objects below %s\n", waf, w.Records, len(w.Paths), out)
+}
+
+// fileDigest returns the start of the hex SHA-256 digest of the content of
+// the file
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+func makeCloudWatchFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "waf",
+			Aliases: []string{"w"},
+			Value:   "BC",
+			Usage:   "WAF the logs are of (BC or ECP)",
+			Action: func(ctx *cli.Context, v string) error {
+				switch v {
+				case "BC", "bc":
+					waf = query.WafBC
+				case "ECP", "ecp":
+					waf = query.WafECP
+				default:
+					return fmt.Errorf("WAF %s unknown, must be one of 'BC', 'ECP'", v)
+				}
+
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:        "out",
+			Aliases:     []string{"o"},
+			Value:       "./logs",
+			Usage:       "directory to write the logs to",
+			Destination: &out,
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// writeExport writes a log file exported from CloudWatch Logs with a record
+// per minute of the minutes after 10:00, ignoring the order of hours
+func writeExport(t *testing.T, path string, ip string, minutes ...int) {
+	t.Helper()
+
+	var lines []string
+	for _, m := range minutes {
+		at := time.Date(2023, 2, 21, 10, m, 0, 0, time.UTC)
+		b, err := json.Marshal(&waflog.Record{
+			Timestamp:         at.UnixMilli(),
+			TerminatingRuleID: "Default_Action",
+			Action:            "ALLOW",
+			HTTPRequest:       waflog.HTTPRequest{ClientIP: ip, Country: "DE", URI: "/"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s\n", at.Format("2006-01-02T15:04:05.000Z"), b))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloudWatchImport(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "000000"), filepath.Join(dir, "000001")
+	writeExport(t, first, "1.2.3.4", 0, 1, 61, 2)
+	writeExport(t, second, "5.6.7.8", 30, 90)
+
+	logs := filepath.Join(dir, "logs")
+	importLogs := func(files ...string) {
+		t.Helper()
+		app := &cli.App{Commands: []*cli.Command{MakeCloudWatchCmd()}}
+		if err := app.Run(append([]string{"waflogs", "cloudwatch", "import", "--out", logs}, files...)); err != nil {
+			t.Fatalf("importing %v: %s", files, err)
+		}
+	}
+
+	importLogs(first)
+	importLogs(second)
+	importLogs(first) // again, replacing its objects
+
+	ips := map[string]int{}
+	objects := map[string]bool{}
+	err := waflog.ReadDir(logs, func(r *waflog.Record) error {
+		ips[r.HTTPRequest.ClientIP]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filepath.WalkDir(logs, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			objects[path] = true
+		}
+		return err
+	})
+
+	if ips["1.2.3.4"] != 4 || ips["5.6.7.8"] != 2 {
+		t.Errorf("got records per IP %v, want 4 of 1.2.3.4 and 2 of 5.6.7.8", ips)
+	}
+	if len(objects) != 4 {
+		t.Errorf("got %d objects, want one per file and hour", len(objects))
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/aws"
+	"kfzteile24/waflogs/pkg/data"
+	"log"
+	"strconv"
+
+	"github.com/urfave/cli/v2"
+)
+
+func MakeDataCmd() *cli.Command {
+
+	return &cli.Command{
+		Name:  "data",
+		Usage: "manage the data directory report loaders write their runs to",
+		Flags: []cli.Flag{makeDataFlag()},
+		Subcommands: []*cli.Command{
+			{
+				Name:  "runs",
+				Usage: "list the runs of report loaders from their manifests",
+				Flags: []cli.Flag{makeDataFlag()},
+				Action: func(cCtx *cli.Context) error {
+					d := data.NewDir(dataDir)
+
+					v, err := d.Version()
+					if err != nil {
+						log.Fatalf("Error reading layout version: %s", err)
+					}
+					if v > 0 && v < data.LayoutVersion {
+						log.Printf("[!] Data directory %s has layout version %d, run 'waflogs data migrate' to list all runs\n", d.Root, v)
+					}
+
+					runs, err := d.Runs()
+					if err != nil {
+						log.Fatalf("Error listing runs: %s", err)
+					}
+
+					records := [][]string{{"day", "waf", "report", "status", "steps", "rows", "scanned", "backend", "version", "finished"}}
+					for _, m := range runs {
+						finished := ""
+						if m.Finished != nil {
+							finished = m.Finished.Format("2006-01-02 15:04:05")
+						}
+						records = append(records, []string{
+							m.Day, m.WAF, m.Report, m.State(),
+							strconv.Itoa(len(m.Steps)), strconv.Itoa(m.Rows()), aws.BytesToHuman(m.BytesScanned()),
+							m.Backend, m.ToolVersion, finished,
+						})
+					}
+
+					printRecords(records)
+
+					return nil
+				},
+			},
+			{
+				Name:  "migrate",
+				Usage: fmt.Sprintf("upgrade the data directory to layout version %d", data.LayoutVersion),
+				Flags: []cli.Flag{makeDataFlag()},
+				Action: func(cCtx *cli.Context) error {
+					d := data.NewDir(dataDir)
+
+					v, err := d.Version()
+					if err != nil {
+						log.Fatalf("Error reading layout version: %s", err)
+					}
+					if v == data.LayoutVersion {
+						log.Printf("[+] Data directory %s has layout version %d already\n", d.Root, v)
+						return nil
+					}
+
+					n, err := d.Migrate()
+					if err != nil {
+						log.Fatalf("Error migrating %s: %s", d.Root, err)
+					}
+					log.Printf("[+] Migrated %s from layout version %d to %d, %d runs changed\n", d.Root, v, data.LayoutVersion, n)
+
+					return nil
+				},
+			},
+		},
+	}
+}
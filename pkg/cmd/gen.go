@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/gen"
+	"kfzteile24/waflogs/pkg/query"
+	"log"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func MakeGenCmd() *cli.Command {
+
+	return &cli.Command{
+		Name:  "gen",
+		Usage: "generate synthetic WAF logs in the Firehose layout, e.g. as --source of load",
+		Flags: makeGenFlags(),
+		Action: func(cCtx *cli.Context) error {
+			genConfig.Start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			genConfig.End = genConfig.Start.Add(time.Duration(hours) * time.Hour)
+
+			log.Printf("[+] Generating WAF logs\n")
+			log.Printf("    Params: time = %s, hours = %d, wafs = %v, seed = %d, out = %s\n", t.Format("2006-01-02"), hours, genConfig.WAFs, genConfig.Seed, out)
+
+			wafs, err := gen.Generate(genConfig)
+			if err != nil {
+				log.Fatalf("Error generating logs: %s", err)
+			}
+
+			for _, w := range genConfig.WAFs {
+				paths, err := gen.Write(out, w, wafs[w])
+				if err != nil {
+					log.Fatalf("Error writing logs of %s: %s", w, err)
+				}
+				log.Printf("[+] %s: %d records in %d objects\n", w, len(wafs[w]), len(paths))
+			}
+
+			return nil
+		},
+	}
+}
+
+func makeGenFlags() []cli.Flag {
+	defaults := gen.DefaultConfig(time.Time{})
+
+	return []cli.Flag{
+		&cli.TimestampFlag{
+			Name:    "timestamp",
+			Aliases: []string{"t"},
+			Usage:   "first day to generate logs for, e.g., 2023-02-21",
+			Layout:  "2006-01-02",
+			Action: func(ctx *cli.Context, v *time.Time) error {
+				if v != nil {
+					t = *v
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:        "hours",
+			Value:       24,
+			Usage:       "number of hours to generate logs for",
+			Destination: &hours,
+		},
+		&cli.StringSliceFlag{
+			Name:    "waf",
+			Aliases: []string{"w"},
+			Value:   cli.NewStringSlice("BC", "ECP"),
+			Usage:   "WAFs to generate logs for (BC, ECP), can be repeated",
+			Action: func(ctx *cli.Context, v []string) error {
+				genConfig.WAFs = nil
+				for _, s := range v {
+					switch s {
+					case "BC", "bc":
+						genConfig.WAFs = append(genConfig.WAFs, query.WafBC)
+					case "ECP", "ecp":
+						genConfig.WAFs = append(genConfig.WAFs, query.WafECP)
+					default:
+						return fmt.Errorf("WAF %s unknown, must be one of 'BC', 'ECP'", s)
+					}
+				}
+
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:        "out",
+			Aliases:     []string{"o"},
+			Value:       "./logs",
+			Usage:       "directory to write the logs to",
+			Destination: &out,
+		},
+		&cli.Int64Flag{
+			Name:        "seed",
+			Value:       defaults.Seed,
+			Usage:       "seed of the random traffic, the same seed generates the same logs",
+			Destination: &genConfig.Seed,
+		},
+		&cli.IntFlag{
+			Name:        "rpm",
+			Value:       defaults.RequestsPerMinute,
+			Usage:       "browser requests per minute and WAF at the daily peak",
+			Destination: &genConfig.RequestsPerMinute,
+		},
+		&cli.IntFlag{
+			Name:        "app-rpm",
+			Value:       defaults.AppRequestsPerMinute,
+			Usage:       "mobile app requests per minute and WAF at the daily peak",
+			Destination: &genConfig.AppRequestsPerMinute,
+		},
+		&cli.IntFlag{
+			Name:        "bot-rph",
+			Value:       defaults.BotRequestsPerHour,
+			Usage:       "requests of verified search engine bots per hour and WAF",
+			Destination: &genConfig.BotRequestsPerHour,
+		},
+		&cli.IntFlag{
+			Name:        "floods",
+			Value:       defaults.Floods,
+			Usage:       "number of clients flooding each WAF",
+			Destination: &genConfig.Floods,
+		},
+		&cli.IntFlag{
+			Name:        "flood-requests",
+			Value:       defaults.FloodRequests,
+			Usage:       "requests per flood, sent within 10 minutes",
+			Destination: &genConfig.FloodRequests,
+		},
+		&cli.IntFlag{
+			Name:        "scrapers",
+			Value:       defaults.Scrapers,
+			Usage:       "number of APC1 like scraper sessions per WAF",
+			Destination: &genConfig.Scrapers,
+		},
+		&cli.IntFlag{
+			Name:        "scraper-requests",
+			Value:       defaults.ScraperRequests,
+			Usage:       "requests per scraper session",
+			Destination: &genConfig.ScraperRequests,
+		},
+		&cli.IntFlag{
+			Name:        "rate-limit",
+			Value:       defaults.RateLimit,
+			Usage:       "requests per IP in 5 minutes before the rate limit rule blocks",
+			Destination: &genConfig.RateLimit,
+		},
+	}
+}
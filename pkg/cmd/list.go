@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"kfzteile24/waflogs/pkg/report"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+func MakeListReportsCmd() *cli.Command {
+
+	return &cli.Command{
+		Name:  "list-reports",
+		Usage: "list the reports that can be loaded and printed",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "csv",
+				Usage:       "print the list as CSV instead of a table",
+				Destination: &printCSV,
+			},
+		},
+		Action: func(cCtx *cli.Context) error {
+			records := [][]string{{"report", "aliases", "printer", "steps", "source", "description"}}
+			for _, r := range report.Reports() {
+				steps, err := report.Steps(r)
+				if err != nil {
+					return err
+				}
+
+				printer := "no"
+				if r.NewPrinter(reportOptions()) != nil {
+					printer = "yes"
+				}
+
+				records = append(records, []string{
+					r.Name(),
+					strings.Join(r.Aliases(), ", "),
+					printer,
+					strings.Join(steps, ", "),
+					report.Source(r),
+					r.Description(),
+				})
+			}
+
+			printRecords(records)
+
+			return nil
+		},
+	}
+}
@@ -1,75 +1,132 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"kfzteile24/waflogs/pkg/aws"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/local"
+	"kfzteile24/waflogs/pkg/offline"
 	"kfzteile24/waflogs/pkg/query"
 	"kfzteile24/waflogs/pkg/report"
 	"log"
+	"os"
 	"time"
 
 	"github.com/urfave/cli/v2"
 )
 
 func MakeLoadCmd() *cli.Command {
+	var subcommands []*cli.Command
+	for _, r := range report.Reports() {
+		subcommands = append(subcommands, makeLoadSubcommand(r))
+	}
 
 	return &cli.Command{
-		Name:    "load",
-		Aliases: []string{"l"},
-		Usage:   "load data from Athena",
-		Flags:   makeLoadFlags(),
-		Subcommands: []*cli.Command{
-			{
-				Name:    "rate-limit-report",
-				Aliases: []string{"r"},
-				Usage:   "for the rate limit report",
-				Flags:   makeLoadFlags(),
-				Action: func(cCtx *cli.Context) error {
-					ctx := watchSignals()
-					log.Printf("[+] Loading data for the rate limit report\n")
-					log.Printf("    Params: time = %s, waf = %s, profile = %s region = %s force = %t\n", t.Format("2006-01-02"), waf, profile, region, force > 0)
-
-					athena, err := aws.NewAthenaClient(ctx, profile, region, force > 0)
-					if err != nil {
-						log.Fatalf("Error making Athena client: %s", err)
-					}
-
-					r := report.NewRateLimitReportLoader(athena, query.WafBC, t)
-					if err := r.Run(); err != nil {
-						log.Fatalf("Error running rate limit report: %s", err)
-					}
+		Name:        "load",
+		Aliases:     []string{"l"},
+		Usage:       "load data from Athena",
+		Flags:       makeLoadFlags(),
+		Subcommands: subcommands,
+	}
+}
 
-					return nil
-				},
-			},
-			{
-				Name:  "apc1",
-				Usage: "for the APC1 report",
-				Flags: makeLoadFlags(),
-				Action: func(cCtx *cli.Context) error {
-					ctx := watchSignals()
-					log.Printf("[+] Loading data for the APC1 report\n")
-					log.Printf("    Params: time = %s, waf = %s, profile = %s region = %s force = %t\n", t.Format("2006-01-02"), waf, profile, region, force > 0)
-
-					athena, err := aws.NewAthenaClient(ctx, profile, region, force > 0)
-					if err != nil {
-						log.Fatalf("Error making Athena client: %s", err)
-					}
-
-					r := report.NewAPC1ReportLoader(athena, query.WafBC, t)
-					if err := r.Run(); err != nil {
-						log.Fatalf("Error running APC1 report: %s", err)
-					}
+// makeLoadSubcommand returns the command loading the report r
+func makeLoadSubcommand(r report.Report) *cli.Command {
+	return &cli.Command{
+		Name:    r.Name(),
+		Aliases: r.Aliases(),
+		Usage:   "for the report on " + r.Description(),
+		Flags:   append(makeLoadFlags(), r.Flags()...),
+		Action: func(cCtx *cli.Context) error {
+			ctx := watchSignals()
+			log.Printf("[+] Loading data for the %s report\n", r.Name())
+			log.Printf("    Params: time = %s, waf = %s, profile = %s region = %s force = %t dry-run = %t backend = %s source = %s\n", t.Format("2006-01-02"), waf, profile, region, force > 0, dryRun, backend, source)
 
-					return nil
-				},
-			},
+			b := makeBackend(ctx)
+
+			l := report.NewLoader(r, b, reportOptions())
+			if err := l.Run(); err != nil {
+				log.Fatalf("Error running %s report: %s", r.Name(), err)
+			}
+
+			if dryRun {
+				l.Plan().Print(os.Stdout, partitionSize*gb)
+			} else {
+				syncStore()
+			}
+
+			return nil
 		},
 	}
 }
 
+const gb = 1024 * 1024 * 1024
+
+const (
+	backendAthena  = "athena"
+	backendLocal   = "local"
+	backendOffline = "offline"
+)
+
+// makeBackend returns the backend selected by the flags, or nil for dry runs
+func makeBackend(ctx context.Context) report.Backend {
+	if dryRun {
+		return nil
+	}
+
+	if backend == "" {
+		backend = backendAthena
+		if source != "" {
+			backend = backendOffline
+		}
+	}
+	if backend != backendAthena && source == "" {
+		log.Fatalf("Backend %s needs the raw logs to query, set --source", backend)
+	}
+
+	switch backend {
+	case backendOffline:
+		return report.NewOfflineBackend(offline.NewSource(source))
+	case backendLocal:
+		engine, err := local.NewEngine(ctx, source)
+		if err != nil {
+			log.Fatalf("Error making local query engine: %s", err)
+		}
+		return report.NewSQLBackend(engine)
+	default:
+		athena, err := aws.NewAthenaClient(ctx, profile, region)
+		if err != nil {
+			log.Fatalf("Error making Athena client: %s", err)
+		}
+		return report.NewSQLBackend(athena)
+	}
+}
+
+// syncStore ingests the results of a loader into the store of the data
+// directory, if the build has one. The results are loaded already, a failing
+// sync is only reported, the next sync catches up.
+func syncStore() {
+	d := data.NewDir(dataDir)
+	s, err := local.OpenStore(d.StorePath())
+	if errors.Is(err, local.ErrNoDuckDB) {
+		return
+	}
+	if err != nil {
+		log.Printf("[!] Warning: opening store: %s\n", err)
+		return
+	}
+	defer s.Close()
+
+	if _, err := s.Sync(d.Root); err != nil {
+		log.Printf("[!] Warning: syncing store: %s\n", err)
+	}
+}
+
 func makeLoadFlags() []cli.Flag {
 	return []cli.Flag{
+		makeDataFlag(),
 		&cli.TimestampFlag{
 			Name:    "timestamp",
 			Aliases: []string{"t"},
@@ -124,5 +181,53 @@ func makeLoadFlags() []cli.Flag {
 			Usage:   "force execution of queries for which results are already on disk",
 			Count:   &force,
 		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Aliases:     []string{"n"},
+			Value:       false,
+			Usage:       "only render queries to a temporary directory and print the plan, without connecting to AWS or touching the data directory",
+			Destination: &dryRun,
+		},
+		&cli.StringFlag{
+			Name:    "backend",
+			Aliases: []string{"b"},
+			Usage:   "where to run queries: athena, local (embedded DuckDB on --source, in builds with -tags duckdb) or offline (Go on --source), defaults to offline if --source is set and athena otherwise",
+			Action: func(ctx *cli.Context, v string) error {
+				switch v {
+				case backendAthena, backendLocal, backendOffline:
+					backend = v
+				default:
+					return fmt.Errorf("backend %s unknown, must be one of '%s', '%s', '%s'", v, backendAthena, backendLocal, backendOffline)
+				}
+
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:        "source",
+			Aliases:     []string{"s"},
+			Usage:       "directory of raw WAF logs (gzip Firehose objects, optionally in a sub directory per WAF) for the local and offline backends, or Parquet copies of them for the local backend",
+			Destination: &source,
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "csv",
+			Usage: "files to write results to: csv, parquet (typed by the column types of the backend) or both",
+			Action: func(ctx *cli.Context, v string) error {
+				f, err := report.ParseFormat(v)
+				if err != nil {
+					return err
+				}
+
+				resultFormat = f
+				return nil
+			},
+		},
+		&cli.Int64Flag{
+			Name:        "partition-size",
+			Value:       30,
+			Usage:       "estimated size of one day of WAF logs in GB, for the cost estimate of dry runs",
+			Destination: &partitionSize,
+		},
 	}
 }
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestLoadForce(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2023, 2, 21, 10, 0, 0, 0, time.UTC)
+	records := []*waflog.Record{{
+		Timestamp:         day.UnixMilli(),
+		TerminatingRuleID: "rate-limit",
+		Action:            "BLOCK",
+		HTTPRequest:       waflog.HTTPRequest{ClientIP: "1.2.3.4", Country: "DE", URI: "/"},
+	}}
+	if _, err := offline.Write(filepath.Join(dir, "logs"), query.WafBC, "test", records); err != nil {
+		t.Fatal(err)
+	}
+
+	// load runs the rate limit report and returns whether its first step kept
+	// the result of the run before
+	load := func(args ...string) bool {
+		t.Helper()
+		force = 0
+		app := &cli.App{Commands: []*cli.Command{MakeLoadCmd()}}
+		args = append([]string{"waflogs", "load", "rate-limit", "--data", filepath.Join(dir, "data"), "--source", filepath.Join(dir, "logs"), "--timestamp", "2023-02-21"}, args...)
+		if err := app.Run(args); err != nil {
+			t.Fatalf("%v: %s", args, err)
+		}
+		m, err := data.ReadManifest(filepath.Join(dir, "data", "BC", "rate-limit-report", "2023-02-21"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Steps[0].Reused
+	}
+
+	if load() {
+		t.Errorf("first run reused results")
+	}
+	if !load() {
+		t.Errorf("results on disk not reused")
+	}
+	if load("--force") {
+		t.Errorf("--force reused results")
+	}
+}
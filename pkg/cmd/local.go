@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/local"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+)
+
+func MakeLocalCmd() *cli.Command {
+
+	return &cli.Command{
+		Name:  "local",
+		Usage: "query the results of all loaded reports across days and WAFs",
+		Flags: makeLocalFlags(),
+		Subcommands: []*cli.Command{
+			{
+				Name:      "query",
+				Aliases:   []string{"q"},
+				Usage:     "run SQL on the store, e.g. SELECT day FROM rate_limit_report.fastest_ips_not_black_or_whitelisted WHERE client_ip = '1.2.3.4'",
+				ArgsUsage: "SQL",
+				Flags: append(makeLocalFlags(), &cli.BoolFlag{
+					Name:        "csv",
+					Usage:       "print the result as CSV instead of a table",
+					Destination: &printCSV,
+				}),
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.NArg() == 0 {
+						return fmt.Errorf("no SQL given")
+					}
+
+					s := openStore()
+					defer s.Close()
+
+					records, err := s.Query(strings.Join(cCtx.Args().Slice(), " "))
+					if err != nil {
+						log.Fatalf("Error querying store: %s", err)
+					}
+
+					printRecords(records)
+
+					return nil
+				},
+			},
+			{
+				Name:  "sync",
+				Usage: "ingest results loaded or changed since the last sync, done by load and query too",
+				Flags: makeLocalFlags(),
+				Action: func(cCtx *cli.Context) error {
+					s := openStore() // syncs
+					defer s.Close()
+
+					return nil
+				},
+			},
+			{
+				Name:  "tables",
+				Usage: "list the results in the store",
+				Flags: makeLocalFlags(),
+				Action: func(cCtx *cli.Context) error {
+					s := openStore()
+					defer s.Close()
+
+					records, err := s.Query(`
+						SELECT "table",
+						       LIST(DISTINCT waf ORDER BY waf) AS wafs,
+						       COUNT(DISTINCT day) AS days,
+						       MIN(day) AS first_day,
+						       MAX(day) AS last_day,
+						       SUM(num_rows) AS num_rows
+						FROM main.results
+						GROUP BY ALL
+						ORDER BY 1`)
+					if err != nil {
+						log.Fatalf("Error listing tables: %s", err)
+					}
+
+					printRecords(records)
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// openStore opens the store and ingests new results of the data directory
+func openStore() *local.Store {
+	d := data.NewDir(dataDir)
+	if storePath == "" {
+		storePath = d.StorePath()
+	}
+
+	s, err := local.OpenStore(storePath)
+	if err != nil {
+		log.Fatalf("Error opening store: %s", err)
+	}
+
+	n, err := s.Sync(d.Root)
+	if err != nil {
+		log.Fatalf("Error syncing store with %s: %s", d.Root, err)
+	}
+	if n > 0 {
+		log.Printf("[+] Ingested %d results into %s\n", n, storePath)
+	}
+
+	return s
+}
+
+func printRecords(records [][]string) {
+	if printCSV {
+		w := csv.NewWriter(os.Stdout)
+		w.WriteAll(records)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range records {
+		fmt.Fprintln(w, strings.Join(r, "\t"))
+	}
+	w.Flush()
+}
+
+func makeLocalFlags() []cli.Flag {
+	return []cli.Flag{
+		makeDataFlag(),
+		&cli.StringFlag{
+			Name:        "store",
+			Usage:       "path of the store, defaults to store.duckdb in the data directory",
+			Destination: &storePath,
+		},
+	}
+}
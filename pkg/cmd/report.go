@@ -3,43 +3,53 @@ package cmd
 import (
 	"fmt"
 	"kfzteile24/waflogs/pkg/query"
-	"kfzteile24/waflogs/pkg/report/printer"
+	"kfzteile24/waflogs/pkg/report"
 	"log"
 
 	"github.com/urfave/cli/v2"
 )
 
 func MakeReportCmd() *cli.Command {
+	var subcommands []*cli.Command
+	for _, r := range report.Reports() {
+		if r.NewPrinter(reportOptions()) == nil {
+			continue
+		}
+		subcommands = append(subcommands, makeReportSubcommand(r))
+	}
 
 	return &cli.Command{
-		Name:    "report",
-		Aliases: []string{"r"},
-		Usage:   "print summary reports",
-		Flags:   makeReportFlags(),
-		Subcommands: []*cli.Command{
-			{
-				Name:    "rate-limit",
-				Aliases: []string{"r"},
-				Usage:   "for the rate limit report",
-				Flags:   makeReportFlags(),
-				Action: func(cCtx *cli.Context) error {
-					log.Printf("[+] Rate rate limit\n")
-					log.Printf("    Params: time = %s, waf = %s, profile = %s region = %s force = %t\n", t.Format("2006-01-02"), waf, profile, region, force > 0)
-
-					rp := printer.NewRateLimitReportPrinter(waf)
-					if err := rp.Print(); err != nil {
-						log.Fatalf("[!] Error printing rate limit report: %s", err)
-					}
-
-					return nil
-				},
-			},
+		Name:        "report",
+		Aliases:     []string{"r"},
+		Usage:       "print summary reports",
+		Flags:       makeReportFlags(),
+		Subcommands: subcommands,
+	}
+}
+
+// makeReportSubcommand returns the command printing the report r
+func makeReportSubcommand(r report.Report) *cli.Command {
+	return &cli.Command{
+		Name:    r.Name(),
+		Aliases: r.Aliases(),
+		Usage:   "for the report on " + r.Description(),
+		Flags:   append(makeReportFlags(), r.Flags()...),
+		Action: func(cCtx *cli.Context) error {
+			log.Printf("[+] Printing the %s report\n", r.Name())
+			log.Printf("    Params: waf = %s, data = %s\n", waf, dataDir)
+
+			if err := r.NewPrinter(reportOptions()).Print(); err != nil {
+				log.Fatalf("[!] Error printing %s report: %s", r.Name(), err)
+			}
+
+			return nil
 		},
 	}
 }
 
 func makeReportFlags() []cli.Flag {
 	return []cli.Flag{
+		makeDataFlag(),
 		&cli.StringFlag{
 			Name:    "waf",
 			Aliases: []string{"w"},
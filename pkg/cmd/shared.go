@@ -3,11 +3,16 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/gen"
 	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/urfave/cli/v2"
 )
 
 // variables for the flags
@@ -16,6 +21,18 @@ var waf query.WAF = query.WafBC
 var profile string
 var region string
 var force int
+var dryRun bool
+var partitionSize int64
+var backend string
+var source string
+var resultFormat report.Format
+var dataDir string
+var storePath string
+var printCSV bool
+var hours int
+var out string
+var logGroup string
+var genConfig = gen.DefaultConfig(time.Time{})
 
 func watchSignals() context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -31,3 +48,38 @@ func watchSignals() context.Context {
 
 	return ctx
 }
+
+// makeDataFlag returns the flag for the data directory shared by all commands
+func makeDataFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:        "data",
+		Value:       data.DefaultRoot,
+		EnvVars:     []string{"WAFLOGS_DATA"},
+		Usage:       "data directory report loaders write their runs to",
+		Destination: &dataDir,
+	}
+}
+
+// reportOptions returns the options of reports set by the flags
+func reportOptions() report.Options {
+	return report.Options{
+		Waf:          waf,
+		Day:          t,
+		Format:       resultFormat,
+		SkipExisting: force == 0,
+		Data:         data.NewDir(dataDir),
+	}
+}
+
+// LoadReportDefinitions registers the reports defined in the YAML files of
+// the directory in WAFLOGS_REPORTS, ./reports by default. It has to run
+// before the commands are made, so the directory can't be a flag.
+func LoadReportDefinitions() error {
+	dir := os.Getenv("WAFLOGS_REPORTS")
+	if dir == "" {
+		dir = "reports"
+	}
+
+	_, err := report.LoadDefinitions(dir)
+	return err
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"kfzteile24/waflogs/pkg/live"
+	"kfzteile24/waflogs/pkg/waflog"
+	"log"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// variables for the flags of tail
+var tailMinutes int
+var tailTop int
+var tailRefresh time.Duration
+var tailPoll time.Duration
+var tailFromStart bool
+
+func MakeTailCmd() *cli.Command {
+
+	return &cli.Command{
+		Name:      "tail",
+		Usage:     "show the fastest IPs and User-Agents and the rate limit blocks of the last minutes while WAF logs arrive",
+		ArgsUsage: "[FILE | DIR | -]",
+		Description: "Reads WAF log records from stdin (no argument or -), a log file being appended to, or a\n" +
+			"directory new log files land in, e.g. Firehose objects synced from S3 or fetched from CloudWatch Logs.",
+		Flags: makeTailFlags(),
+		Action: func(cCtx *cli.Context) error {
+			ctx := watchSignals()
+
+			src := cCtx.Args().First()
+			follow, err := makeFollower(src)
+			if err != nil {
+				return err
+			}
+
+			records := make(chan *waflog.Record, 1024)
+			done := make(chan error, 1)
+			go func() {
+				done <- follow(ctx, func(r *waflog.Record) error {
+					select {
+					case records <- r:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+			}()
+
+			stats := live.NewStats(tailMinutes)
+			ticker := time.NewTicker(tailRefresh)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case r := <-records:
+					stats.Add(r)
+				case <-ticker.C:
+					drawStats(stats)
+				case <-ctx.Done():
+					drawStats(stats)
+					return nil
+				case err := <-done:
+					for len(records) > 0 {
+						stats.Add(<-records)
+					}
+					drawStats(stats)
+					if err != nil && ctx.Err() == nil {
+						log.Fatalf("Error reading logs: %s", err)
+					}
+					return nil
+				}
+			}
+		},
+	}
+}
+
+// makeFollower returns a function reading the records of the source until
+// ctx is done or, for stdin, the input ends
+func makeFollower(src string) (func(ctx context.Context, fn func(*waflog.Record) error) error, error) {
+	if src == "" || src == "-" {
+		return func(ctx context.Context, fn func(*waflog.Record) error) error {
+			return live.FollowReader(ctx, os.Stdin, fn)
+		}, nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %s", src, err)
+	}
+
+	if info.IsDir() {
+		return func(ctx context.Context, fn func(*waflog.Record) error) error {
+			return live.FollowDir(ctx, src, tailFromStart, tailPoll, fn)
+		}, nil
+	}
+
+	return func(ctx context.Context, fn func(*waflog.Record) error) error {
+		return live.FollowFile(ctx, src, tailFromStart, tailPoll, fn)
+	}, nil
+}
+
+// drawStats prints the stats, replacing the previous ones on terminals
+func drawStats(stats *live.Stats) {
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Print("\033[H\033[2J") // clear screen
+	} else {
+		fmt.Println()
+	}
+
+	stats.Print(os.Stdout, tailTop)
+}
+
+func makeTailFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "minutes",
+			Aliases:     []string{"m"},
+			Value:       5,
+			Usage:       "number of minutes up to the latest request to count requests in",
+			Destination: &tailMinutes,
+			Action: func(ctx *cli.Context, v int) error {
+				if v < 1 {
+					return fmt.Errorf("minutes must be at least 1")
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:        "top",
+			Aliases:     []string{"n"},
+			Value:       10,
+			Usage:       "number of IPs and User-Agents to show",
+			Destination: &tailTop,
+			Action: func(ctx *cli.Context, v int) error {
+				if v < 0 {
+					return fmt.Errorf("top must not be negative")
+				}
+				return nil
+			},
+		},
+		&cli.DurationFlag{
+			Name:        "refresh",
+			Value:       2 * time.Second,
+			Usage:       "interval to redraw the statistics in",
+			Destination: &tailRefresh,
+			Action: func(ctx *cli.Context, v time.Duration) error {
+				if v <= 0 {
+					return fmt.Errorf("refresh must be positive")
+				}
+				return nil
+			},
+		},
+		&cli.DurationFlag{
+			Name:        "poll",
+			Value:       time.Second,
+			Usage:       "interval to check files and directories for new records in",
+			Destination: &tailPoll,
+			Action: func(ctx *cli.Context, v time.Duration) error {
+				if v <= 0 {
+					return fmt.Errorf("poll must be positive")
+				}
+				return nil
+			},
+		},
+		&cli.BoolFlag{
+			Name:        "from-start",
+			Usage:       "count the records already in the file or directory, not only new ones",
+			Destination: &tailFromStart,
+		},
+	}
+}
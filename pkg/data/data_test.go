@@ -0,0 +1,118 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	d := NewDir(filepath.Join(t.TempDir(), "data"))
+
+	if v, err := d.Version(); err != nil || v != 0 {
+		t.Fatalf("version of missing directory is %d, error %v, want 0", v, err)
+	}
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.Version(); err != nil || v != LayoutVersion {
+		t.Fatalf("version of new directory is %d, error %v, want %d", v, err, LayoutVersion)
+	}
+
+	if err := d.setVersion(LayoutVersion + 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Open(); err == nil {
+		t.Errorf("opened directory of a newer layout")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	d := NewDir(t.TempDir())
+
+	// a run of layout version 1, without manifest
+	dir := d.RunDir("rate-limit-report", query.Scope{Waf: query.WafBC, Year: 2023, Month: 2, Day: 21})
+	files := map[string]string{
+		"ips-blocked-by-rate-limit.sql": "SELECT 1",
+		"ips-blocked-by-rate-limit.csv": "client_ip,num_requests\n1.2.3.4,50\n\"5.6.7.8\",\"40\"\n",
+		"user-agents-blocked.sql":       "SELECT 2",
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if v, err := d.Version(); err != nil || v != 1 {
+		t.Fatalf("version of unversioned directory is %d, error %v, want 1", v, err)
+	}
+	if err := d.Open(); err == nil {
+		t.Fatalf("opened directory of layout version 1 without migration")
+	}
+
+	if n, err := d.Migrate(); err != nil || n != 1 {
+		t.Fatalf("migration changed %d runs, error %v, want 1", n, err)
+	}
+	if err := d.Open(); err != nil {
+		t.Fatalf("opening migrated directory: %s", err)
+	}
+
+	runs, err := d.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(runs))
+	}
+
+	m := runs[0]
+	if m.WAF != "BC" || m.Report != "rate-limit-report" || m.Day != "2023-02-21" {
+		t.Errorf("got run of %s/%s/%s", m.WAF, m.Report, m.Day)
+	}
+	if m.Complete() || m.State() != string(StatusMigrated) {
+		t.Errorf("migrated run has state %s", m.State())
+	}
+	if len(m.Steps) != 2 || m.Rows() != 2 {
+		t.Errorf("got %d steps with %d rows, want 2 steps with 2 rows", len(m.Steps), m.Rows())
+	}
+
+	// migrating again changes nothing
+	if n, err := d.Migrate(); err != nil || n != 0 {
+		t.Errorf("second migration changed %d runs, error %v, want 0", n, err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	m := NewManifest("apc1", "ECP", "2023-02-21")
+	m.Steps = append(m.Steps, StepRun{Name: "scraped-urls", QueryID: "q-1", BytesScanned: 1024, Rows: 3})
+	m.Steps = append(m.Steps, StepRun{Name: "scraped-products", QueryID: "q-2", BytesScanned: 512, Rows: 1})
+	m.Finish(errors.New("query failed"))
+	if err := m.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusFailed || got.Error != "query failed" || got.Finished == nil {
+		t.Errorf("got status %s, error %q", got.Status, got.Error)
+	}
+	if s := fmt.Sprintf("%d %d %s", got.BytesScanned(), got.Rows(), got.Steps[1].QueryID); s != "1536 4 q-2" {
+		t.Errorf("got bytes scanned, rows and query ID %s", s)
+	}
+
+	got.Status = StatusComplete
+	got.Layout = LayoutVersion - 1
+	if got.Complete() || got.State() != "outdated" {
+		t.Errorf("complete run of older layout has state %s", got.State())
+	}
+}
@@ -0,0 +1,198 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"sort"
+)
+
+// DefaultRoot is the data directory used unless another one is configured
+const DefaultRoot = "./data"
+
+// LayoutVersion is the version of the layout of data directories written by
+// this version of waflogs:
+//
+//	1: <root>/<WAF>/<report>/<yyyy-mm-dd>/<step>.sql and results of the step
+//	2: like 1, with a manifest.json per run and the version in layout.json
+const LayoutVersion = 2
+
+// LayoutFile is the name of the file in the root recording the layout version
+const LayoutFile = "layout.json"
+
+// StoreFile is the name of the local store in the root
+const StoreFile = "store.duckdb"
+
+// ToolVersion is the version of waflogs recorded in manifests, set at build
+// time with -ldflags "-X kfzteile24/waflogs/pkg/data.ToolVersion=v1.2.3"
+var ToolVersion = "dev"
+
+func init() {
+	if ToolVersion != "dev" {
+		return
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+			ToolVersion = "dev-" + s.Value[:12]
+		}
+	}
+}
+
+// Dir is the data directory report loaders write their runs to
+type Dir struct {
+	Root string
+}
+
+func NewDir(root string) *Dir {
+	if root == "" {
+		root = DefaultRoot
+	}
+
+	return &Dir{Root: root}
+}
+
+// RunDir returns the directory of the run of a report for the WAF and day of
+// the scope
+func (d *Dir) RunDir(report string, scope query.Scope) string {
+	return filepath.Join(d.Root, scope.Waf.String(), report, fmt.Sprintf("%04d-%02d-%02d", scope.Year, scope.Month, scope.Day))
+}
+
+// StorePath returns the path of the local store of the results
+func (d *Dir) StorePath() string {
+	return filepath.Join(d.Root, StoreFile)
+}
+
+type layout struct {
+	Version int `json:"version"`
+}
+
+// Version returns the layout version of the directory, 0 if it is empty or
+// doesn't exist yet and 1 if it was written before layouts were versioned
+func (d *Dir) Version() (int, error) {
+	b, err := os.ReadFile(filepath.Join(d.Root, LayoutFile))
+	if err == nil {
+		var l layout
+		if err := json.Unmarshal(b, &l); err != nil {
+			return 0, fmt.Errorf("parsing %s: %s", LayoutFile, err)
+		}
+		return l.Version, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("reading %s: %s", LayoutFile, err)
+	}
+
+	entries, err := os.ReadDir(d.Root)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading data directory: %s", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// Open prepares the directory for writing runs. It creates new directories
+// with the current layout and fails for directories of other layouts, which
+// have to be migrated first.
+func (d *Dir) Open() error {
+	v, err := d.Version()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case v == 0:
+		if err := os.MkdirAll(d.Root, 0755); err != nil {
+			return fmt.Errorf("creating data directory: %s", err)
+		}
+		return d.setVersion(LayoutVersion)
+	case v < LayoutVersion:
+		return fmt.Errorf("data directory %s has layout version %d, run 'waflogs data migrate' to upgrade it to version %d", d.Root, v, LayoutVersion)
+	case v > LayoutVersion:
+		return fmt.Errorf("data directory %s has layout version %d, written by a newer version of waflogs", d.Root, v)
+	}
+
+	return nil
+}
+
+func (d *Dir) setVersion(v int) error {
+	b, err := json.Marshal(layout{Version: v})
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(d.Root, LayoutFile), append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing %s: %s", LayoutFile, err)
+	}
+	return nil
+}
+
+var reDay = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// runDirs returns the directories of all runs below the root
+func (d *Dir) runDirs() ([]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(d.Root, "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() || !reDay.MatchString(filepath.Base(dir)) {
+			continue
+		}
+		out = append(out, dir)
+	}
+
+	return out, nil
+}
+
+// Runs returns the manifests of all runs below the root, ordered by day, WAF
+// and report
+func (d *Dir) Runs() ([]*Manifest, error) {
+	dirs, err := d.runDirs()
+	if err != nil {
+		return nil, fmt.Errorf("listing runs: %s", err)
+	}
+
+	var out []*Manifest
+	for _, dir := range dirs {
+		m, err := ReadManifest(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // not migrated yet
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest of %s: %s", dir, err)
+		}
+		out = append(out, m)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.WAF != b.WAF {
+			return a.WAF < b.WAF
+		}
+		return a.Report < b.Report
+	})
+
+	return out, nil
+}
@@ -0,0 +1,154 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestFile is the name of the manifest in the directory of a report run
+const ManifestFile = "manifest.json"
+
+// Status is the state of a report run
+type Status string
+
+const (
+	StatusRunning  Status = "running"  // still running, or aborted without a trace
+	StatusComplete Status = "complete" // all steps finished
+	StatusFailed   Status = "failed"   // a step failed, later steps didn't run
+	StatusMigrated Status = "migrated" // loaded by an old version, completeness unknown
+)
+
+// Manifest describes the run of a report loader for a WAF and day, written
+// next to the queries and results of the run
+type Manifest struct {
+	Layout      int               `json:"layout"` // version of the layout the run was written with
+	Report      string            `json:"report"`
+	WAF         string            `json:"waf"`
+	Day         string            `json:"day"`
+	Params      map[string]string `json:"params,omitempty"`
+	ToolVersion string            `json:"tool_version,omitempty"`
+	Backend     string            `json:"backend,omitempty"`
+	Format      string            `json:"format,omitempty"`
+	Status      Status            `json:"status"`
+	Error       string            `json:"error,omitempty"`
+	Started     time.Time         `json:"started"`
+	Finished    *time.Time        `json:"finished,omitempty"`
+	Steps       []StepRun         `json:"steps"`
+}
+
+// StepRun describes a step of a report run
+type StepRun struct {
+	Name         string     `json:"name"`
+	QueryID      string     `json:"query_id,omitempty"`
+	Started      time.Time  `json:"started"`
+	Finished     *time.Time `json:"finished,omitempty"`
+	BytesScanned int64      `json:"bytes_scanned"`
+	Rows         int        `json:"rows"`
+	Reused       bool       `json:"reused,omitempty"` // result of an earlier run kept
+	Files        []string   `json:"files"`            // relative to the directory of the run
+	Error        string     `json:"error,omitempty"`
+}
+
+// NewManifest returns the manifest of a run started now
+func NewManifest(report string, waf string, day string) *Manifest {
+	return &Manifest{
+		Layout:      LayoutVersion,
+		Report:      report,
+		WAF:         waf,
+		Day:         day,
+		ToolVersion: ToolVersion,
+		Status:      StatusRunning,
+		Started:     now(),
+	}
+}
+
+// Complete reports if all steps of the run finished with the current layout
+func (m *Manifest) Complete() bool {
+	return m.Status == StatusComplete && !m.Outdated()
+}
+
+// Outdated reports if the run was written with an older layout
+func (m *Manifest) Outdated() bool {
+	return m.Layout < LayoutVersion
+}
+
+// Migrated reports if the manifest of the run was reconstructed from the
+// files of a version without manifests
+func (m *Manifest) Migrated() bool {
+	return m.Status == StatusMigrated
+}
+
+// State returns the status of the run, "outdated" for complete runs written
+// with an older layout
+func (m *Manifest) State() string {
+	if m.Status == StatusComplete && m.Outdated() {
+		return "outdated"
+	}
+	return string(m.Status)
+}
+
+// BytesScanned returns the bytes scanned by all steps
+func (m *Manifest) BytesScanned() int64 {
+	var n int64
+	for _, s := range m.Steps {
+		n += s.BytesScanned
+	}
+	return n
+}
+
+// Rows returns the rows of the results of all steps
+func (m *Manifest) Rows() int {
+	var n int
+	for _, s := range m.Steps {
+		n += s.Rows
+	}
+	return n
+}
+
+// Finish sets the status of the run by the error of its last step
+func (m *Manifest) Finish(err error) {
+	t := now()
+	m.Finished = &t
+	m.Status = StatusComplete
+	if err != nil {
+		m.Status = StatusFailed
+		m.Error = err.Error()
+	}
+}
+
+// ReadManifest reads the manifest of the run in dir
+func ReadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %s", err)
+	}
+
+	return &m, nil
+}
+
+// Write writes the manifest to dir, replacing an earlier version atomically
+func (m *Manifest) Write(dir string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %s", err)
+	}
+
+	tmp := filepath.Join(dir, ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing manifest: %s", err)
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, ManifestFile))
+}
+
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
@@ -0,0 +1,190 @@
+package data
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// migrations upgrade a data directory from the layout version of the key to
+// the next one and return the number of runs they changed
+var migrations = map[int]func(d *Dir) (int, error){
+	1: migrateV1,
+}
+
+// Migrate upgrades the directory to the current layout version and returns
+// the number of runs changed
+func (d *Dir) Migrate() (int, error) {
+	v, err := d.Version()
+	if err != nil {
+		return 0, err
+	}
+	if v == 0 {
+		return 0, d.Open() // nothing to migrate
+	}
+	if v > LayoutVersion {
+		return 0, fmt.Errorf("data directory %s has layout version %d, written by a newer version of waflogs", d.Root, v)
+	}
+
+	var n int
+	for ; v < LayoutVersion; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return n, fmt.Errorf("no migration from layout version %d", v)
+		}
+
+		changed, err := migrate(d)
+		n += changed
+		if err != nil {
+			return n, fmt.Errorf("migrating from layout version %d: %s", v, err)
+		}
+
+		if err := d.setVersion(v + 1); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// migrateV1 writes manifests for the runs of version 1, reconstructed from
+// the files of the runs. The steps are ordered by when their queries were
+// written, their status is unknown.
+func migrateV1(d *Dir) (int, error) {
+	dirs, err := d.runDirs()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+			continue
+		}
+
+		m, err := reconstructManifest(dir)
+		if err != nil {
+			return n, fmt.Errorf("reconstructing manifest of %s: %s", dir, err)
+		}
+		if err := m.Write(dir); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+func reconstructManifest(dir string) (*Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(filepath.Dir(filepath.Dir(dir))), dir)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	m := &Manifest{
+		Layout: 1,
+		WAF:    parts[0],
+		Report: parts[1],
+		Day:    parts[2],
+		Status: StatusMigrated,
+	}
+
+	steps := map[string]*StepRun{}
+	var first, last time.Time
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".sql" && ext != ".csv" && ext != ".parquet") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		modified := info.ModTime().UTC().Truncate(time.Millisecond)
+
+		name := strings.TrimSuffix(e.Name(), ext)
+		s, ok := steps[name]
+		if !ok {
+			s = &StepRun{Name: name, Started: modified}
+			steps[name] = s
+		}
+		s.Files = append(s.Files, e.Name())
+
+		if modified.Before(s.Started) {
+			s.Started = modified
+		}
+		if s.Finished == nil || modified.After(*s.Finished) {
+			s.Finished = &modified
+		}
+		if ext == ".csv" {
+			if s.Rows, err = countRows(filepath.Join(dir, e.Name())); err != nil {
+				return nil, fmt.Errorf("counting rows of %s: %s", e.Name(), err)
+			}
+		}
+
+		if first.IsZero() || modified.Before(first) {
+			first = modified
+		}
+		if modified.After(last) {
+			last = modified
+		}
+	}
+
+	for _, s := range steps {
+		m.Steps = append(m.Steps, *s)
+	}
+	sort.Slice(m.Steps, func(i, j int) bool {
+		if !m.Steps[i].Started.Equal(m.Steps[j].Started) {
+			return m.Steps[i].Started.Before(m.Steps[j].Started)
+		}
+		return m.Steps[i].Name < m.Steps[j].Name
+	})
+
+	m.Started = first
+	if !last.IsZero() {
+		m.Finished = &last
+	}
+
+	return m, nil
+}
+
+// countRows returns the number of records of a CSV result without header
+func countRows(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+
+	var n int
+	for {
+		_, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	return n - 1, nil
+}
@@ -0,0 +1,87 @@
+package gen
+
+import "kfzteile24/waflogs/pkg/query"
+
+const (
+	labelNonBrowser  = "awswaf:managed:aws:bot-control:signal:non_browser_user_agent"
+	labelVerified    = "awswaf:managed:aws:bot-control:bot:verified"
+	labelBotName     = "awswaf:managed:aws:bot-control:bot:name:"
+	labelBotCategory = "awswaf:managed:aws:bot-control:bot:category:"
+)
+
+var webACLs = map[query.WAF]string{
+	query.WafBC:  "arn:aws:wafv2:us-east-1:123456789012:global/webacl/waf-bc/00000000-0000-0000-0000-000000000001",
+	query.WafECP: "arn:aws:wafv2:us-east-1:123456789012:global/webacl/waf-ecp/00000000-0000-0000-0000-000000000002",
+}
+
+var hosts = map[query.WAF]string{
+	query.WafBC:  "www.example-shop.de",
+	query.WafECP: "shop.example-partner.de",
+}
+
+// first two octets of the networks clients are drawn from, all in private
+// or benchmarking ranges
+var (
+	residentialNets = []string{"10.20", "10.21", "10.22", "172.16", "172.17"}
+	mobileNets      = []string{"10.80", "10.81"}
+	datacenterNets  = []string{"198.18", "198.19"}
+)
+
+var browserUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (Linux; Android 14; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+}
+
+var appUserAgents = []string{
+	"ios-de-1.0.0",
+	"android-de-1.0.0",
+}
+
+var appURIs = []string{
+	"/api/v1/cart",
+	"/api/v1/products",
+	"/api/v1/vehicles",
+	"/api/v1/search",
+}
+
+// verifiedBots crawl from the documentation networks 192.0.2.0/24 and
+// 203.0.113.0/24
+var verifiedBots = []struct {
+	name      string
+	userAgent string
+	net       string // first three octets
+}{
+	{"googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "192.0.2"},
+	{"bingbot", "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", "203.0.113"},
+}
+
+var httpLibraries = []struct {
+	name      string
+	userAgent string
+}{
+	{"python_requests", "python-requests/2.31.0"},
+	{"curl", "curl/8.4.0"},
+	{"go_http", "Go-http-client/1.1"},
+}
+
+var categories = []string{
+	"bremsen/bremsbelaege",
+	"bremsen/bremsscheiben",
+	"filter/oelfilter",
+	"filter/luftfilter",
+	"zuendung/zuendkerzen",
+	"fahrwerk/stossdaempfer",
+}
+
+var pages = []string{
+	"/",
+	"/warenkorb",
+	"/kasse",
+	"/konto/login",
+	"/static/app.js",
+	"/static/app.css",
+}
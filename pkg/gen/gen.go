@@ -0,0 +1,384 @@
+package gen
+
+import (
+	"fmt"
+	"hash/fnv"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config configures the synthetic traffic of Generate. All volumes are per WAF.
+type Config struct {
+	Seed  int64
+	Start time.Time
+	End   time.Time
+	WAFs  []query.WAF
+
+	RequestsPerMinute    int // regular browser traffic at the daily peak
+	AppRequestsPerMinute int // traffic of the mobile apps at the daily peak
+	BotRequestsPerHour   int // verified search engine bots
+
+	Floods          int // clients flooding the WAF, blocked by the rate limit rule
+	FloodRequests   int // requests per flood, sent within 10 minutes
+	Scrapers        int // APC1 like sessions rotating user agents
+	ScraperRequests int // requests per scraper session
+
+	RateLimit int // requests per IP in 5 minutes before the rate limit rule blocks
+}
+
+// DefaultConfig returns a config for one day of traffic from start, with
+// enough scraper traffic for the APC1 report to find it
+func DefaultConfig(start time.Time) Config {
+	return Config{
+		Seed:                 1,
+		Start:                start,
+		End:                  start.Add(24 * time.Hour),
+		WAFs:                 []query.WAF{query.WafBC, query.WafECP},
+		RequestsPerMinute:    60,
+		AppRequestsPerMinute: 10,
+		BotRequestsPerHour:   120,
+		Floods:               2,
+		FloodRequests:        3000,
+		Scrapers:             1,
+		ScraperRequests:      10000,
+		RateLimit:            1000,
+	}
+}
+
+func (c Config) Validate() error {
+	if !c.End.After(c.Start) {
+		return fmt.Errorf("end %s must be after start %s", c.End, c.Start)
+	}
+	if c.RateLimit < 1 {
+		return fmt.Errorf("rate limit must be positive, got %d", c.RateLimit)
+	}
+	for _, n := range []int{c.RequestsPerMinute, c.AppRequestsPerMinute, c.BotRequestsPerHour, c.Floods, c.FloodRequests, c.Scrapers, c.ScraperRequests} {
+		if n < 0 {
+			return fmt.Errorf("volumes must not be negative, got %d", n)
+		}
+	}
+
+	return nil
+}
+
+// Generate returns the records of each WAF of the config sorted by time. The
+// same config always yields the same records.
+func Generate(cfg Config) (map[query.WAF][]*waflog.Record, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	out := map[query.WAF][]*waflog.Record{}
+	for _, waf := range cfg.WAFs {
+		g := &generator{
+			cfg: cfg,
+			waf: waf,
+			rng: rand.New(rand.NewSource(wafSeed(cfg.Seed, waf))),
+		}
+		out[waf] = g.generate()
+	}
+
+	return out, nil
+}
+
+// wafSeed derives the seed of the records of a WAF from its name, so a WAF
+// gets the same records whichever other WAFs are generated along
+func wafSeed(seed int64, waf query.WAF) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(waf.String()))
+	return seed ^ int64(h.Sum64())
+}
+
+type generator struct {
+	cfg     Config
+	waf     query.WAF
+	rng     *rand.Rand
+	records []*waflog.Record
+}
+
+func (g *generator) generate() []*waflog.Record {
+	g.browsers()
+	g.apps()
+	g.bots()
+	for i := 0; i < g.cfg.Floods; i++ {
+		g.flood(i)
+	}
+	for i := 0; i < g.cfg.Scrapers; i++ {
+		g.scraper(i)
+	}
+
+	sort.SliceStable(g.records, func(i, j int) bool {
+		return g.records[i].Timestamp < g.records[j].Timestamp
+	})
+	g.rateLimit()
+
+	return g.records
+}
+
+// perMinute calls fn for the times of about peak requests per minute, less
+// at night like the traffic of a shop
+func (g *generator) perMinute(peak int, fn func(at time.Time)) {
+	for m := g.cfg.Start.Truncate(time.Minute); m.Before(g.cfg.End); m = m.Add(time.Minute) {
+		hour := float64(m.Hour()) + float64(m.Minute())/60
+		load := 0.55 - 0.45*math.Cos((hour-3)/24*2*math.Pi) // lowest at 3am, highest at 3pm
+
+		n := int(float64(peak)*load + g.rng.Float64())
+		for i := 0; i < n; i++ {
+			at := m.Add(time.Duration(g.rng.Int63n(int64(time.Minute))))
+			if !at.Before(g.cfg.Start) && at.Before(g.cfg.End) {
+				fn(at)
+			}
+		}
+	}
+}
+
+// spread returns n times between start and end in random order
+func (g *generator) spread(n int, start time.Time, end time.Time) []time.Time {
+	out := make([]time.Time, n)
+	for i := range out {
+		out[i] = start.Add(time.Duration(g.rng.Int63n(int64(end.Sub(start)))))
+	}
+	return out
+}
+
+func (g *generator) browsers() {
+	clients := make([]client, 10*g.cfg.RequestsPerMinute+1)
+	for i := range clients {
+		clients[i] = client{
+			ip:        g.ip(residentialNets),
+			country:   g.country(),
+			userAgent: pick(g.rng, browserUserAgents),
+			session:   g.hex(16),
+		}
+	}
+
+	g.perMinute(g.cfg.RequestsPerMinute, func(at time.Time) {
+		c := clients[g.rng.Intn(len(clients))]
+		uri, args := g.shopPage()
+		r := g.record(at, c, "GET", uri, args)
+
+		// some large forms match the body size rule, which is in COUNT mode
+		if g.rng.Intn(50) == 0 {
+			r.RuleGroupList = []waflog.RuleGroup{{
+				RuleGroupID:                 "AWS#AWSManagedRulesCommonRuleSet",
+				NonTerminatingMatchingRules: []waflog.RuleMatch{{RuleID: "SizeRestrictions_BODY", Action: "COUNT"}},
+			}}
+		}
+	})
+}
+
+func (g *generator) apps() {
+	clients := make([]client, 2*g.cfg.AppRequestsPerMinute+1)
+	for i := range clients {
+		clients[i] = client{
+			ip:        g.ip(mobileNets),
+			country:   "DE",
+			userAgent: pick(g.rng, appUserAgents),
+			labels:    []string{labelNonBrowser},
+		}
+	}
+
+	g.perMinute(g.cfg.AppRequestsPerMinute, func(at time.Time) {
+		c := clients[g.rng.Intn(len(clients))]
+		g.record(at, c, "GET", pick(g.rng, appURIs), "")
+	})
+}
+
+func (g *generator) bots() {
+	hours := g.cfg.End.Sub(g.cfg.Start).Hours()
+	for _, at := range g.spread(int(float64(g.cfg.BotRequestsPerHour)*hours), g.cfg.Start, g.cfg.End) {
+		bot := verifiedBots[g.rng.Intn(len(verifiedBots))]
+		c := client{
+			ip:        g.ip([]string{bot.net}),
+			country:   "US",
+			userAgent: bot.userAgent,
+			labels: []string{
+				labelVerified,
+				labelBotName + bot.name,
+				labelBotCategory + "search_engine",
+			},
+		}
+		uri, args := g.shopPage()
+		r := g.record(at, c, "GET", uri, args)
+		r.TerminatingRuleID = "seo-crawler"
+	}
+}
+
+// flood sends FloodRequests within 10 minutes from one IP, either with an
+// HTTP library Bot Control detects or with a browser user agent
+func (g *generator) flood(i int) {
+	c := client{
+		ip:        g.ip(datacenterNets),
+		country:   pick(g.rng, []string{"US", "NL", "RU", "CN"}),
+		userAgent: pick(g.rng, browserUserAgents),
+	}
+	if i%2 == 0 {
+		lib := httpLibraries[g.rng.Intn(len(httpLibraries))]
+		c.userAgent = lib.userAgent
+		c.labels = []string{labelNonBrowser, labelBotName + lib.name, labelBotCategory + "http_library"}
+	}
+
+	start, end := g.window(10 * time.Minute)
+	for _, at := range g.spread(g.cfg.FloodRequests, start, end) {
+		uri, args := g.shopPage()
+		g.record(at, c, "GET", uri, args)
+	}
+}
+
+// scraper is an APC1 like session: the same session cookie from rotating IPs,
+// with more than 9 user agents of which one dominates, fetching products
+func (g *generator) scraper(i int) {
+	session := g.hex(16)
+	ips := make([]string, 20)
+	for j := range ips {
+		ips[j] = g.ip(residentialNets)
+	}
+	uas := make([]string, 12)
+	for j := range uas {
+		uas[j] = fmt.Sprintf("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.%d Safari/537.36", 100+i, 5000+j, g.rng.Intn(200))
+	}
+
+	for _, at := range g.spread(g.cfg.ScraperRequests, g.cfg.Start, g.cfg.End) {
+		c := client{
+			ip:        pick(g.rng, ips),
+			country:   "DE",
+			userAgent: uas[0],
+			session:   session,
+		}
+		if g.rng.Intn(10) >= 6 {
+			c.userAgent = pick(g.rng, uas)
+		}
+		g.record(at, c, "GET", "/artikeldetails", fmt.Sprintf("search=%d", 100000+g.rng.Intn(5000)))
+	}
+}
+
+// rateLimit blocks the requests of IPs sending more than RateLimit requests
+// within 5 minutes, like a rate-based rule
+func (g *generator) rateLimit() {
+	recent := map[string][]int64{}
+	for _, r := range g.records {
+		ip := r.HTTPRequest.ClientIP
+		ts := recent[ip]
+		for len(ts) > 0 && ts[0] <= r.Timestamp-5*60*1000 {
+			ts = ts[1:]
+		}
+		ts = append(ts, r.Timestamp)
+		recent[ip] = ts
+
+		if len(ts) > g.cfg.RateLimit && r.TerminatingRuleID == "Default_Action" {
+			r.TerminatingRuleID = "rate-limit"
+			r.TerminatingRuleType = "RATE_BASED"
+			r.Action = "BLOCK"
+		}
+	}
+}
+
+// window returns a random time window of length d within the config's range
+func (g *generator) window(d time.Duration) (time.Time, time.Time) {
+	span := g.cfg.End.Sub(g.cfg.Start) - d
+	if span <= 0 {
+		return g.cfg.Start, g.cfg.End
+	}
+	start := g.cfg.Start.Add(time.Duration(g.rng.Int63n(int64(span))))
+	return start, start.Add(d)
+}
+
+type client struct {
+	ip        string
+	country   string
+	userAgent string
+	session   string
+	labels    []string
+}
+
+func (g *generator) record(at time.Time, c client, method string, uri string, args string) *waflog.Record {
+	headers := []waflog.Header{
+		{Name: "host", Value: hosts[g.waf]},
+		{Name: "user-agent", Value: c.userAgent},
+	}
+	if c.session != "" {
+		headers = append(headers, waflog.Header{Name: "cookie", Value: "consent=1; session=" + c.session})
+	}
+
+	r := &waflog.Record{
+		Timestamp:           at.UnixMilli(),
+		FormatVersion:       1,
+		WebACLID:            webACLs[g.waf],
+		TerminatingRuleID:   "Default_Action",
+		TerminatingRuleType: "REGULAR",
+		Action:              "ALLOW",
+		HTTPSourceName:      "CF",
+		HTTPSourceID:        "E" + g.hex(6),
+		HTTPRequest: waflog.HTTPRequest{
+			ClientIP:    c.ip,
+			Country:     c.country,
+			Headers:     headers,
+			URI:         uri,
+			Args:        args,
+			HTTPVersion: "HTTP/2.0",
+			HTTPMethod:  method,
+			RequestID:   g.hex(24),
+		},
+	}
+	for _, l := range c.labels {
+		r.Labels = append(r.Labels, waflog.Label{Name: l})
+	}
+
+	g.records = append(g.records, r)
+
+	return r
+}
+
+func (g *generator) shopPage() (string, string) {
+	switch n := g.rng.Intn(10); {
+	case n < 4:
+		return "/artikeldetails", fmt.Sprintf("search=%d", 100000+g.rng.Intn(5000))
+	case n < 6:
+		return "/ersatzteile-verschleissteile/" + pick(g.rng, categories), ""
+	case n < 7:
+		return "/suche", "q=" + pick(g.rng, categories)
+	default:
+		return pick(g.rng, pages), ""
+	}
+}
+
+// ip returns an address of one of the networks, given by their first two
+// or three octets
+func (g *generator) ip(nets []string) string {
+	net := pick(g.rng, nets)
+	if strings.Count(net, ".") == 2 {
+		return fmt.Sprintf("%s.%d", net, 1+g.rng.Intn(254))
+	}
+	return fmt.Sprintf("%s.%d.%d", net, g.rng.Intn(256), 1+g.rng.Intn(254))
+}
+
+func (g *generator) country() string {
+	switch n := g.rng.Intn(100); {
+	case n < 75:
+		return "DE"
+	case n < 88:
+		return "AT"
+	case n < 95:
+		return "CH"
+	default:
+		return pick(g.rng, []string{"NL", "PL", "FR", "IT"})
+	}
+}
+
+func (g *generator) hex(n int) string {
+	const digits = "0123456789abcdef"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = digits[g.rng.Intn(len(digits))]
+	}
+	return string(b)
+}
+
+func pick(rng *rand.Rand, values []string) string {
+	return values[rng.Intn(len(values))]
+}
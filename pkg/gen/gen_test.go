@@ -0,0 +1,158 @@
+package gen
+
+import (
+	"encoding/json"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	cfg := DefaultConfig(time.Date(2023, 2, 21, 0, 0, 0, 0, time.UTC))
+	cfg.End = cfg.Start.Add(2 * time.Hour)
+	cfg.RequestsPerMinute = 10
+	cfg.ScraperRequests = 1000
+	cfg.FloodRequests = 1500
+	cfg.RateLimit = 300
+	return cfg
+}
+
+func TestGenerateIsSeeded(t *testing.T) {
+	encode := func(cfg Config) string {
+		wafs, err := Generate(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(wafs[query.WafECP])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	cfg := testConfig()
+	if encode(cfg) != encode(cfg) {
+		t.Errorf("same seed generated different records")
+	}
+
+	other := cfg
+	other.Seed++
+	if encode(cfg) == encode(other) {
+		t.Errorf("different seeds generated the same records")
+	}
+
+	alone := cfg
+	alone.WAFs = []query.WAF{query.WafECP}
+	if encode(cfg) != encode(alone) {
+		t.Errorf("generating a WAF alone generated different records")
+	}
+}
+
+func TestGenerateScenarios(t *testing.T) {
+	cfg := testConfig()
+	wafs, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, waf := range cfg.WAFs {
+		records := wafs[waf]
+
+		blocked := map[string]int{}
+		sessionUAs := map[string]map[string]bool{}
+		var verified, apps int
+		var last int64
+		for _, r := range records {
+			if r.Timestamp < last {
+				t.Fatalf("%s: records not sorted by time", waf)
+			}
+			last = r.Timestamp
+			if r.Time().Before(cfg.Start) || !r.Time().Before(cfg.End) {
+				t.Errorf("%s: record at %s out of range", waf, r.Time())
+			}
+
+			if r.TerminatingRuleID == "rate-limit" {
+				blocked[r.HTTPRequest.ClientIP]++
+			}
+			if r.BotVerified() {
+				verified++
+				if ip := r.HTTPRequest.ClientIP; !strings.HasPrefix(ip, "192.0.2.") && !strings.HasPrefix(ip, "203.0.113.") {
+					t.Errorf("%s: verified bot at %s outside of the documentation networks", waf, ip)
+				}
+			}
+			if ua, _ := r.UserAgent(); ua == "ios-de-1.0.0" && r.SignalNoBrowser() {
+				apps++
+			}
+			if session, ok := r.Session(); ok {
+				if sessionUAs[session] == nil {
+					sessionUAs[session] = map[string]bool{}
+				}
+				ua, _ := r.UserAgent()
+				sessionUAs[session][ua] = true
+			}
+		}
+
+		if len(blocked) != cfg.Floods {
+			t.Errorf("%s: %d IPs blocked by the rate limit, want %d", waf, len(blocked), cfg.Floods)
+		}
+		for ip, n := range blocked {
+			if n == 0 || n > cfg.FloodRequests-cfg.RateLimit {
+				t.Errorf("%s: %d requests of %s blocked, want at most %d", waf, n, ip, cfg.FloodRequests-cfg.RateLimit)
+			}
+		}
+		if verified == 0 {
+			t.Errorf("%s: no verified bots", waf)
+		}
+		if apps == 0 {
+			t.Errorf("%s: no mobile app traffic", waf)
+		}
+
+		var scrapers int
+		for _, uas := range sessionUAs {
+			if len(uas) > 9 {
+				scrapers++
+			}
+		}
+		if scrapers != cfg.Scrapers {
+			t.Errorf("%s: %d sessions with more than 9 user agents, want %d", waf, scrapers, cfg.Scrapers)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cfg := testConfig()
+	cfg.WAFs = []query.WAF{query.WafECP}
+	wafs, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	paths, err := Write(dir, query.WafECP, wafs[query.WafECP])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "ECP", "2023", "02", "21", "00", "aws-waf-logs-ecp-1-2023-02-21-00-00-00-gen.gz"),
+		filepath.Join(dir, "ECP", "2023", "02", "21", "01", "aws-waf-logs-ecp-1-2023-02-21-01-00-00-gen.gz"),
+	}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("got objects %v, want %v", paths, want)
+	}
+
+	var n int
+	err = waflog.ReadDir(dir, func(r *waflog.Record) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(wafs[query.WafECP]) {
+		t.Errorf("read %d records, want %d", n, len(wafs[query.WafECP]))
+	}
+}
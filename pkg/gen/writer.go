@@ -0,0 +1,14 @@
+package gen
+
+import (
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+)
+
+// Write stores the records of a WAF below dir in the layout Firehose writes
+// to S3, i.e. one gzip object of concatenated JSON records per hour at
+// <dir>/<WAF>/YYYY/MM/DD/HH/. It returns the paths of the objects written.
+func Write(dir string, waf query.WAF, records []*waflog.Record) ([]string, error) {
+	return offline.Write(dir, waf, "gen", records)
+}
@@ -0,0 +1,221 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"kfzteile24/waflogs/pkg/waflog"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FollowFile calls fn for each record appended to the log file at path until
+// ctx is done, like tail -f. Records already in the file are read first if
+// fromStart is set. The file is read from the start again if it's truncated.
+func FollowFile(ctx context.Context, path string, fromStart bool, poll time.Duration, fn func(*waflog.Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening file: %s", err)
+	}
+	defer f.Close()
+
+	if !fromStart {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			return fmt.Errorf("seeking end of file: %s", err)
+		}
+	}
+
+	err = waflog.Decode(&followReader{ctx: ctx, f: f, poll: poll}, fn)
+	if ctx.Err() != nil {
+		return nil // stopped, possibly within a record
+	}
+	return err
+}
+
+// followReader reads a file and waits for more data at its end until ctx is
+// done
+type followReader struct {
+	ctx  context.Context
+	f    *os.File
+	poll time.Duration
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.f.Read(p)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(r.poll):
+		}
+
+		if err := r.rewindIfTruncated(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (r *followReader) rewindIfTruncated() error {
+	offset, err := r.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	info, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < offset {
+		_, err = r.f.Seek(0, io.SeekStart)
+	}
+	return err
+}
+
+// stablePolls is the number of polls the size of a file in a followed
+// directory has to stay the same before it's skipped if it can't be read
+const stablePolls = 5
+
+// FollowDir calls fn for each record of the log files landing below dir
+// until ctx is done, e.g. Firehose objects synced from S3. Files already in
+// dir are read first if fromStart is set. Files are read once their size
+// didn't change for a poll interval and they can be read completely, in order
+// of their names. Files still unreadable after their size stayed the same for
+// stablePolls polls are skipped and logged.
+func FollowDir(ctx context.Context, dir string, fromStart bool, poll time.Duration, fn func(*waflog.Record) error) error {
+	seen := map[string]bool{}
+	pending := pendingFiles{} // files not read yet
+	if !fromStart {
+		paths, err := listFiles(dir)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			seen[path] = true
+		}
+	}
+
+	for {
+		paths, err := listFiles(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+
+			// a file still being written may end with complete records
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			stable := pending.stable(path, info.Size())
+			if stable < 1 {
+				continue
+			}
+
+			var records []*waflog.Record
+			err = waflog.ReadFile(path, func(r *waflog.Record) error {
+				records = append(records, r)
+				return nil
+			})
+			if err != nil && stable < stablePolls {
+				continue // still being written, try again later
+			}
+			seen[path] = true
+			delete(pending, path)
+			if err != nil {
+				log.Printf("Skipping %s, unreadable after its size stayed the same for %d polls: %s", path, stable, err)
+				continue
+			}
+
+			for _, r := range records {
+				if err := fn(r); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(poll):
+		}
+	}
+}
+
+// pendingFiles are the sizes of the files of a followed directory not read
+// yet and the number of polls they stayed the same
+type pendingFiles map[string]*pendingFile
+
+type pendingFile struct {
+	size   int64
+	stable int
+}
+
+// stable records the size of the file at path and returns the number of
+// polls before this one its size stayed the same for, 0 for new files or if
+// it changed
+func (p pendingFiles) stable(path string, size int64) int {
+	f, ok := p[path]
+	if !ok || f.size != size {
+		p[path] = &pendingFile{size: size}
+		return 0
+	}
+
+	f.stable++
+	return f.stable
+}
+
+// FollowReader calls fn for each record read from r, e.g. stdin, until the
+// input ends or ctx is done
+func FollowReader(ctx context.Context, r io.Reader, fn func(*waflog.Record) error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- waflog.Decode(r, func(record *waflog.Record) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fn(record)
+		})
+	}()
+
+	// a blocked read can't be interrupted, the decoder stops with its next record
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// listFiles returns the paths of the log files below dir ordered by name,
+// skipping hidden files like the temporary files of aws s3 sync
+func listFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %s", dir, err)
+	}
+
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+	return paths, nil
+}
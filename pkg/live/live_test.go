@@ -0,0 +1,227 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2023, 2, 21, 10, 0, 0, 0, time.UTC)
+
+func record(at time.Time, ip string, ua string, rule string, action string) *waflog.Record {
+	return &waflog.Record{
+		Timestamp:         at.UnixMilli(),
+		TerminatingRuleID: rule,
+		Action:            action,
+		HTTPRequest: waflog.HTTPRequest{
+			ClientIP: ip,
+			Country:  "DE",
+			Headers:  []waflog.Header{{Name: "user-agent", Value: ua}},
+		},
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := NewStats(2)
+
+	for i := 0; i < 30; i++ { // minute 0
+		s.Add(record(start.Add(time.Duration(i)*time.Second), "1.2.3.4", "curl/7.0", "Default_Action", "ALLOW"))
+	}
+	for i := 0; i < 20; i++ { // minute 1
+		s.Add(record(start.Add(time.Minute+time.Duration(i)*time.Second), "5.6.7.8", "Mozilla/5.0", "rate-limit", "BLOCK"))
+	}
+	s.Add(record(start.Add(time.Minute+30*time.Second), "1.2.3.4", "curl/7.0", "Default_Action", "ALLOW"))
+
+	if got := fmt.Sprint(s.FastestIPs(10)); got != "[{[1.2.3.4 DE] 31 30} {[5.6.7.8 DE] 20 20}]" {
+		t.Errorf("fastest IPs of minutes 0 and 1: %s", got)
+	}
+	if got := fmt.Sprint(s.FastestUserAgents(1)); got != "[{[  curl/7.0] 31 30}]" {
+		t.Errorf("fastest user agent of minutes 0 and 1: %s", got)
+	}
+	if got := fmt.Sprint(s.RateLimitBlocks(10)); got != "[{[5.6.7.8 DE] 20 20}]" {
+		t.Errorf("rate limit blocks of minutes 0 and 1: %s", got)
+	}
+
+	// minute 2 moves the window past minute 0, late requests are ignored
+	s.Add(record(start.Add(2*time.Minute), "9.9.9.9", "curl/7.0", "Default_Action", "ALLOW"))
+	s.Add(record(start.Add(10*time.Second), "1.2.3.4", "curl/7.0", "Default_Action", "ALLOW"))
+
+	if got := fmt.Sprint(s.FastestIPs(10)); got != "[{[5.6.7.8 DE] 20 20} {[1.2.3.4 DE] 1 1} {[9.9.9.9 DE] 1 1}]" {
+		t.Errorf("fastest IPs of minutes 1 and 2: %s", got)
+	}
+	if s.Records != 53 || !s.Latest.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("got %d records up to %s", s.Records, s.Latest)
+	}
+}
+
+// collector collects the records followed in the background
+type collector struct {
+	mu      sync.Mutex
+	records []*waflog.Record
+}
+
+func (c *collector) add(r *waflog.Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, r)
+	return nil
+}
+
+// waitFor waits until n records were collected
+func (c *collector) waitFor(t *testing.T, n int) []*waflog.Record {
+	t.Helper()
+
+	for i := 0; i < 200; i++ {
+		c.mu.Lock()
+		got := append([]*waflog.Record{}, c.records...)
+		c.mu.Unlock()
+		if len(got) >= n {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("timed out waiting for %d records", n)
+	return nil
+}
+
+func appendRecords(t *testing.T, path string, ips ...string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, ip := range ips {
+		b, _ := json.Marshal(record(start, ip, "curl/7.0", "Default_Action", "ALLOW"))
+		f.Write(append(b, '\n'))
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "waf.log")
+	appendRecords(t, path, "0.0.0.0") // before following
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &collector{}
+	done := make(chan error)
+	go func() { done <- FollowFile(ctx, path, false, 5*time.Millisecond, c.add) }()
+
+	time.Sleep(20 * time.Millisecond)
+	appendRecords(t, path, "1.2.3.4", "5.6.7.8")
+	got := c.waitFor(t, 2)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("following file: %s", err)
+	}
+
+	if len(got) != 2 || got[0].HTTPRequest.ClientIP != "1.2.3.4" || got[1].HTTPRequest.ClientIP != "5.6.7.8" {
+		t.Errorf("got %d records, want those appended", len(got))
+	}
+}
+
+func TestFollowDir(t *testing.T) {
+	dir := t.TempDir()
+	appendRecords(t, filepath.Join(dir, "a.log"), "0.0.0.0") // before following
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &collector{}
+	done := make(chan error)
+	go func() { done <- FollowDir(ctx, dir, false, 5*time.Millisecond, c.add) }()
+
+	time.Sleep(20 * time.Millisecond)
+	os.MkdirAll(filepath.Join(dir, "10"), 0755)
+	appendRecords(t, filepath.Join(dir, ".b.log.tmp"), "9.9.9.9") // being synced
+	appendRecords(t, filepath.Join(dir, "10", "b.log"), "1.2.3.4", "5.6.7.8")
+	got := c.waitFor(t, 2)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("following directory: %s", err)
+	}
+
+	if len(got) != 2 || got[0].HTTPRequest.ClientIP != "1.2.3.4" || got[1].HTTPRequest.ClientIP != "5.6.7.8" {
+		t.Errorf("got %d records, want those of the new file", len(got))
+	}
+}
+
+func TestPendingFilesStable(t *testing.T) {
+	p := pendingFiles{}
+
+	var got []int
+	for _, size := range []int64{10, 10, 20, 20, 20, 20} {
+		got = append(got, p.stable("a.log", size))
+	}
+	if fmt.Sprint(got) != "[0 1 0 1 2 3]" {
+		t.Errorf("got polls the size stayed the same %v", got)
+	}
+	if p.stable("b.log", 20) != 0 {
+		t.Errorf("new file stable")
+	}
+}
+
+func TestFollowDirSkipsUnreadableFile(t *testing.T) {
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &collector{}
+	done := make(chan error)
+	go func() { done <- FollowDir(ctx, dir, false, 5*time.Millisecond, c.add) }()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "a.log"), []byte("{\"timestamp\": 1, \"httpRequest\": {"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	appendRecords(t, filepath.Join(dir, "b.log"), "1.2.3.4")
+	got := c.waitFor(t, 1)
+
+	// the next file is read once the truncated one is given up on
+	time.Sleep(time.Duration(stablePolls+2) * 5 * time.Millisecond)
+	appendRecords(t, filepath.Join(dir, "c.log"), "5.6.7.8")
+	got = c.waitFor(t, 2)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("following directory: %s", err)
+	}
+
+	if len(got) != 2 || got[0].HTTPRequest.ClientIP != "1.2.3.4" || got[1].HTTPRequest.ClientIP != "5.6.7.8" {
+		t.Errorf("got %d records, want those of the readable files", len(got))
+	}
+}
+
+func TestFollowReaderStopsWithContext(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &collector{}
+	done := make(chan error)
+	go func() { done <- FollowReader(ctx, r, c.add) }()
+
+	b, _ := json.Marshal(record(start, "1.2.3.4", "curl/7.0", "Default_Action", "ALLOW"))
+	w.Write(append(b, '\n'))
+	c.waitFor(t, 1)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("following reader: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("still following after ctx is done")
+	}
+}
@@ -0,0 +1,209 @@
+package live
+
+import (
+	"fmt"
+	"io"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Stats keeps rolling counts of requests per identity over the last minutes.
+// The window ends with the latest request seen, so logs replayed from files
+// are counted like logs arriving live.
+type Stats struct {
+	Minutes int       // length of the window
+	Records int       // seen in total
+	Latest  time.Time // time of the latest request
+
+	ips    *counter
+	uas    *counter
+	blocks *counter
+}
+
+// Rate is the number of requests of an identity in the window
+type Rate struct {
+	Identity []string
+	Requests int
+	Peak     int // most requests within a minute
+}
+
+func NewStats(minutes int) *Stats {
+	blocked := func(r *waflog.Record) bool {
+		return r.Action == "BLOCK" && r.TerminatingRuleID == query.TerminatingRuleRateLimit.String()
+	}
+
+	return &Stats{
+		Minutes: minutes,
+		ips:     newCounter(query.IdentityColumnsIP, nil),
+		uas:     newCounter(query.IdentityColumnsUserAgent, nil),
+		blocks:  newCounter(query.IdentityColumnsIP, blocked),
+	}
+}
+
+// Add counts a request, unless it was received before the window
+func (s *Stats) Add(r *waflog.Record) {
+	s.Records++
+
+	if t := r.Time(); t.After(s.Latest) {
+		first := s.first()
+		s.Latest = t
+		if s.first() != first { // the window moved on to the next minute
+			for _, c := range []*counter{s.ips, s.uas, s.blocks} {
+				c.prune(s.first())
+			}
+		}
+	}
+
+	m := minute(r.Time())
+	if m < s.first() {
+		return // arrived too late
+	}
+	for _, c := range []*counter{s.ips, s.uas, s.blocks} {
+		c.add(m, r)
+	}
+}
+
+// first returns the first minute of the window
+func (s *Stats) first() int64 {
+	return minute(s.Latest) - int64(s.Minutes) + 1
+}
+
+// FastestIPs returns the n IPs with the most requests in the window
+func (s *Stats) FastestIPs(n int) []Rate {
+	return s.ips.top(n)
+}
+
+// FastestUserAgents returns the n user agents with the most requests in the
+// window
+func (s *Stats) FastestUserAgents(n int) []Rate {
+	return s.uas.top(n)
+}
+
+// RateLimitBlocks returns the n IPs with the most requests blocked by the
+// rate limit rule in the window
+func (s *Stats) RateLimitBlocks(n int) []Rate {
+	return s.blocks.top(n)
+}
+
+// Print writes the top n identities of the window as tables to w
+func (s *Stats) Print(w io.Writer, n int) {
+	latest := "-"
+	if !s.Latest.IsZero() {
+		latest = s.Latest.Format("2006-01-02 15:04:05") + " UTC"
+	}
+	fmt.Fprintf(w, "[+] %d records, latest %s, last %d minutes\n", s.Records, latest, s.Minutes)
+
+	printRates(w, "Fastest IPs", query.IdentityColumnsIP, s.FastestIPs(n), s.Minutes)
+	printRates(w, "Fastest User-Agents", query.IdentityColumnsUserAgent, s.FastestUserAgents(n), s.Minutes)
+	printRates(w, "Blocked by rate limit", query.IdentityColumnsIP, s.RateLimitBlocks(n), s.Minutes)
+}
+
+func printRates(w io.Writer, title string, identityCols query.IdentityColumns, rates []Rate, minutes int) {
+	fmt.Fprintf(w, "\n%s\n", title)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(append(identityCols.Columns(), "num_requests", "per_minute", "peak_minute"), "\t"))
+	for _, r := range rates {
+		values := append(append([]string{}, r.Identity...), strconv.Itoa(r.Requests), strconv.FormatFloat(float64(r.Requests)/float64(minutes), 'f', 1, 64), strconv.Itoa(r.Peak))
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	tw.Flush()
+}
+
+// counter counts the requests per identity and minute
+type counter struct {
+	identityCols query.IdentityColumns
+	match        func(*waflog.Record) bool // requests to count, all if nil
+
+	minutes map[int64]map[string]int // requests per minute and identity
+	ids     map[string][]string      // values of the identities by key
+}
+
+func newCounter(identityCols query.IdentityColumns, match func(*waflog.Record) bool) *counter {
+	return &counter{
+		identityCols: identityCols,
+		match:        match,
+		minutes:      map[int64]map[string]int{},
+		ids:          map[string][]string{},
+	}
+}
+
+func (c *counter) add(m int64, r *waflog.Record) {
+	if c.match != nil && !c.match(r) {
+		return
+	}
+
+	id := offline.Identity(r, c.identityCols)
+	key := strings.Join(id, "\x00")
+	if _, ok := c.ids[key]; !ok {
+		c.ids[key] = id
+	}
+	if _, ok := c.minutes[m]; !ok {
+		c.minutes[m] = map[string]int{}
+	}
+	c.minutes[m][key]++
+}
+
+// prune drops the counts of minutes before first
+func (c *counter) prune(first int64) {
+	for m := range c.minutes {
+		if m < first {
+			delete(c.minutes, m)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, counts := range c.minutes {
+		for key := range counts {
+			seen[key] = true
+		}
+	}
+	for key := range c.ids {
+		if !seen[key] {
+			delete(c.ids, key)
+		}
+	}
+}
+
+func (c *counter) top(n int) []Rate {
+	rates := map[string]*Rate{}
+	for _, counts := range c.minutes {
+		for key, count := range counts {
+			r, ok := rates[key]
+			if !ok {
+				r = &Rate{Identity: c.ids[key]}
+				rates[key] = r
+			}
+			r.Requests += count
+			if count > r.Peak {
+				r.Peak = count
+			}
+		}
+	}
+
+	var out []Rate
+	for _, r := range rates {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		return strings.Join(out[i].Identity, "\x00") < strings.Join(out[j].Identity, "\x00")
+	})
+
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+func minute(t time.Time) int64 {
+	return t.Unix() / 60
+}
@@ -0,0 +1,225 @@
+package local
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// rewrite replaces a Trino construct with its DuckDB equivalent
+type rewrite struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+func function(trino string, duckdb string) rewrite {
+	return rewrite{
+		re:   regexp.MustCompile(`(?i)\b` + trino + `\s*\(`),
+		repl: duckdb + "(",
+	}
+}
+
+// rewrites translate the Trino dialect of the templates in pkg/query to
+// DuckDB. They only cover the functions and clauses the templates use.
+var rewrites = []rewrite{
+	// CTAS options of Athena, the local engine keeps tables in memory
+	{re: regexp.MustCompile(`(?is)\bWITH\s*\(\s*format\s*=.*?\)\s*AS\b`), repl: "AS"},
+
+	// single column VALUES lists in IN predicates, e.g. x IN (VALUES 'a', 'b')
+	{re: regexp.MustCompile(`(?i)\bIN\s*\(\s*VALUES\b`), repl: "IN ("},
+
+	// DuckDB returns NULL for out of range list indexes instead of failing
+	function("try", ""),
+
+	function("transform", "list_transform"),
+	function("filter", "list_filter"),
+	function("array_sort", "list_sort"),
+	function("array_distinct", "list_distinct"),
+	function("array_join", "array_to_string"),
+	function("element_at", "list_extract"),
+	function("sequence", "generate_series"),
+	function("to_unixtime", "epoch"),
+	function("regexp_like", "regexp_matches"),
+}
+
+// calls rewrite function calls whose arguments change
+var calls = map[string]func(args []string) string{
+	// any_match(array, lambda)
+	"any_match": func(args []string) string {
+		return fmt.Sprintf("(len(list_filter(%s)) > 0)", strings.Join(args, ","))
+	},
+	// cardinality(array) is 0 for the arrays the logs omit when empty, like
+	// labels, which DuckDB reads as NULL
+	"cardinality": func(args []string) string {
+		return fmt.Sprintf("COALESCE(len(%s), 0)", strings.Join(args, ","))
+	},
+	// from_unixtime(seconds) returns a timestamp without time zone in Athena
+	"from_unixtime": func(args []string) string {
+		return fmt.Sprintf("CAST(to_timestamp(%s) AS TIMESTAMP)", strings.Join(args, ","))
+	},
+	// ip_prefix(CAST(ip AS IPADDRESS), bits) rendered as text, DuckDB has IP
+	// types only in the inet extension
+	"ip_prefix": func(args []string) string {
+		if len(args) != 2 {
+			return "ip_prefix(" + strings.Join(args, ",") + ")"
+		}
+		ip := reCastIPAddress.ReplaceAllString(args[0], "$1")
+		return fmt.Sprintf(ipPrefix, ip, strings.TrimSpace(args[1]))
+	},
+	// regexp_extract returns an empty string instead of NULL without a match
+	"regexp_extract": func(args []string) string {
+		return fmt.Sprintf("NULLIF(regexp_extract(%s), '')", strings.Join(args, ","))
+	},
+	// regexp_replace replaces all matches in Athena, only the first in DuckDB
+	"regexp_replace": func(args []string) string {
+		if len(args) != 3 {
+			return "regexp_replace(" + strings.Join(args, ",") + ")"
+		}
+		return fmt.Sprintf("regexp_replace(%s, 'g')", strings.Join(args, ","))
+	},
+	// slice(array, start, length) while list_slice(list, begin, end) is inclusive
+	"slice": func(args []string) string {
+		if len(args) != 3 {
+			return "slice(" + strings.Join(args, ",") + ")"
+		}
+		start, length := strings.TrimSpace(args[1]), strings.TrimSpace(args[2])
+		return fmt.Sprintf("list_slice(%s, %s, (%s) + (%s) - 1)", args[0], start, start, length)
+	},
+}
+
+var reCastIPAddress = regexp.MustCompile(`(?is)^\s*CAST\s*\((.*)\s+AS\s+IPADDRESS\s*\)\s*$`)
+
+// ipPrefix renders the prefix of an IP address like Athena renders an
+// IPPREFIX, e.g. 1.2.3.0/24 or 2001:db8::/48. The lambdas bind the address
+// and the groups of IPv6 addresses before and after :: to evaluate them once.
+// Only prefixes of whole octets of IPv4 and of whole groups of IPv6 addresses
+// of up to 64 bits are supported.
+const ipPrefix = `list_transform([{ip: CAST(%s AS VARCHAR), bits: %s}], p -> CASE
+    WHEN strpos(p.ip, ':') = 0
+    THEN array_to_string(list_transform(range(1, 5), i -> CASE WHEN i * 8 <= p.bits THEN string_split(p.ip, '.')[i] ELSE '0' END), '.') || '/' || CAST(p.bits AS VARCHAR)
+    ELSE list_transform([{
+        head: list_filter(string_split(split_part(p.ip, '::', 1), ':'), g -> g <> ''),
+        tail: CASE WHEN strpos(p.ip, '::') > 0 THEN list_filter(string_split(split_part(p.ip, '::', 2), ':'), g -> g <> '') ELSE [] END
+    }], h -> regexp_replace(array_to_string(list_transform(
+        list_slice(list_concat(list_concat(h.head, list_transform(range(8 - len(h.head) - len(h.tail)), i -> '0')), h.tail), 1, p.bits // 16),
+        g -> COALESCE(NULLIF(ltrim(lower(g), '0'), ''), '0')
+    ), ':'), '(^|:)0(:0)*$', '', 'g') || '::/' || CAST(p.bits AS VARCHAR))[1]
+END)[1]`
+
+// Translate rewrites a query rendered for Athena to the DuckDB dialect
+func Translate(sql string) string {
+	for name, fn := range calls {
+		sql = rewriteCalls(sql, name, fn)
+	}
+	sql = rewriteUnnest(sql)
+	for _, r := range rewrites {
+		sql = r.re.ReplaceAllString(sql, r.repl)
+	}
+	return sql
+}
+
+// rewriteCalls replaces all calls of the function called name by the result
+// of fn for their arguments, which are split at top level commas
+func rewriteCalls(sql string, name string, fn func(args []string) string) string {
+	re := regexp.MustCompile(`(?i)\b` + name + `\s*\(`)
+
+	for pos := 0; ; {
+		loc := re.FindStringIndex(sql[pos:])
+		if loc == nil {
+			return sql
+		}
+		begin, open := pos+loc[0], pos+loc[1]
+
+		args, end := splitArgs(sql, open)
+		if end < 0 {
+			return sql // unbalanced, leave it to the engine to complain
+		}
+
+		// nested calls first, the replacement may call the function itself
+		for i := range args {
+			args[i] = rewriteCalls(args[i], name, fn)
+		}
+		repl := fn(args)
+
+		sql = sql[:begin] + repl + sql[end+1:]
+		pos = begin + len(repl)
+	}
+}
+
+// splitArgs splits the arguments of the call whose parenthesis opens before
+// sql[open] at top level commas and returns them with the position of the
+// closing parenthesis, -1 if it is unbalanced
+func splitArgs(sql string, open int) ([]string, int) {
+	var args []string
+	depth, start := 0, open
+	var quote byte
+	for i := open; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			if depth == 0 {
+				return append(args, sql[start:i]), i
+			}
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, sql[start:i])
+			start = i + 1
+		}
+	}
+
+	return nil, -1
+}
+
+var (
+	reUnnest           = regexp.MustCompile(`(?i)\bUNNEST\s*\(`)
+	reUnnestAlias      = regexp.MustCompile(`(?i)^\s*AS\s+\w+\s*\(\s*\w+\s*(,\s*\w+\s*)+\)`)
+	reUnnestOrdinality = regexp.MustCompile(`(?i)^\s*WITH\s+ORDINALITY(\s+AS\s+\w+\s*\(\s*\w+\s*,\s*\w+\s*\))`)
+)
+
+// rewriteUnnest expands the fields of the rows of arrays unnested into the
+// columns of the alias, e.g. UNNEST(ARRAY[ROW(a, b)]) AS t(x, y). Athena
+// expands rows implicitly, DuckDB unnests them as a single struct column. It
+// also numbers the values of arrays unnested WITH ORDINALITY, which DuckDB
+// doesn't support.
+func rewriteUnnest(sql string) string {
+	for pos := 0; ; {
+		loc := reUnnest.FindStringIndex(sql[pos:])
+		if loc == nil {
+			return sql
+		}
+		begin, open := pos+loc[0], pos+loc[1]
+
+		args, end := splitArgs(sql, open)
+		if end < 0 {
+			return sql // unbalanced, leave it to the engine to complain
+		}
+		if len(args) != 1 {
+			pos = open
+			continue
+		}
+
+		rest := sql[end+1:]
+		var repl string
+		switch m := reUnnestOrdinality.FindStringSubmatchIndex(rest); {
+		case m != nil:
+			arg := rewriteUnnest(args[0])
+			repl = fmt.Sprintf("(SELECT UNNEST(%s), generate_subscripts(%s, 1))", arg, arg)
+			rest = rest[m[2]:]
+		case reUnnestAlias.MatchString(rest):
+			repl = fmt.Sprintf("(SELECT UNNEST(%s, max_depth := 2))", rewriteUnnest(args[0]))
+		default:
+			pos = open
+			continue
+		}
+		sql = sql[:begin] + repl + rest
+		pos = begin + len(repl)
+	}
+}
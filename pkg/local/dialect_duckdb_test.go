@@ -0,0 +1,40 @@
+//go:build duckdb
+
+package local
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+func TestTranslateIPPrefix(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the prefixes as Athena renders them
+	tests := []struct {
+		ip   string
+		bits int
+		want string
+	}{
+		{ip: "1.2.3.4", bits: 24, want: "1.2.3.0/24"},
+		{ip: "2001:0DB8:0001:0002::1", bits: 48, want: "2001:db8:1::/48"},
+		{ip: "2001:db8:0:1::", bits: 48, want: "2001:db8::/48"},
+		{ip: "::1", bits: 48, want: "::/48"},
+		{ip: "2001:0:5:6:7:8:9:10", bits: 48, want: "2001:0:5::/48"},
+	}
+	for _, tc := range tests {
+		var got string
+		stmt := Translate(fmt.Sprintf("SELECT CAST(ip_prefix(CAST(? AS IPADDRESS), %d) AS VARCHAR)", tc.bits))
+		if err := db.QueryRow(stmt, tc.ip).Scan(&got); err != nil {
+			t.Fatalf("%s: %s", tc.ip, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.ip, got, tc.want)
+		}
+	}
+}
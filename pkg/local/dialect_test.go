@@ -0,0 +1,101 @@
+package local
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "lambdas",
+			sql:  "CARDINALITY(FILTER(labels, label -> label.name = 'a')) > 0",
+			want: "COALESCE(len(list_filter(labels, label -> label.name = 'a')), 0) > 0",
+		},
+		{
+			name: "any_match",
+			sql:  "ANY_MATCH(labels, label -> (STARTS_WITH(label.name, 'a,b') OR TRUE))",
+			want: "(len(list_filter(labels, label -> (STARTS_WITH(label.name, 'a,b') OR TRUE))) > 0)",
+		},
+		{
+			name: "nested any_match",
+			sql:  "any_match(a, x -> any_match(x, y -> y))",
+			want: "(len(list_filter(a, x -> (len(list_filter(x, y -> y)) > 0))) > 0)",
+		},
+		{
+			name: "slice",
+			sql:  "SLICE(ARRAY_AGG(ip), 1, 5)",
+			want: "list_slice(ARRAY_AGG(ip), 1, (1) + (5) - 1)",
+		},
+		{
+			name: "regexp_extract",
+			sql:  "regexp_extract(params, 'search=([^&]+)', 1)",
+			want: "NULLIF(regexp_extract(params, 'search=([^&]+)', 1), '')",
+		},
+		{
+			name: "regexp_replace",
+			sql:  "REGEXP_REPLACE(uri, '[0-9]+', '<n>')",
+			want: "regexp_replace(uri, '[0-9]+', '<n>', 'g')",
+		},
+		{
+			name: "regexp_like",
+			sql:  "REGEXP_LIKE(uri, '(?i)/checkout')",
+			want: "regexp_matches(uri, '(?i)/checkout')",
+		},
+		{
+			name: "from_unixtime",
+			sql:  "from_unixtime(timestamp/1000)",
+			want: "CAST(to_timestamp(timestamp/1000) AS TIMESTAMP)",
+		},
+		{
+			name: "try",
+			sql:  "try(ELEMENT_AT(SPLIT(label.name, ':'), -1))",
+			want: "(list_extract(SPLIT(label.name, ':'), -1))",
+		},
+		{
+			name: "ip_prefix",
+			sql:  "ip_prefix(CAST(clientip AS IPADDRESS), 24)",
+			want: fmt.Sprintf(ipPrefix, "clientip", "24"),
+		},
+		{
+			name: "unnest rows",
+			sql:  "CROSS JOIN UNNEST(TRANSFORM(groups, g -> ROW(g.id, g.rule))) AS t(rule_group, rule)",
+			want: "CROSS JOIN (SELECT UNNEST(list_transform(groups, g -> ROW(g.id, g.rule)), max_depth := 2)) AS t(rule_group, rule)",
+		},
+		{
+			name: "unnest with ordinality",
+			sql:  "CROSS JOIN UNNEST(labels) WITH ORDINALITY AS a(label_a, pos_a)",
+			want: "CROSS JOIN (SELECT UNNEST(labels), generate_subscripts(labels, 1)) AS a(label_a, pos_a)",
+		},
+		{
+			name: "unnest values",
+			sql:  "CROSS JOIN UNNEST(labels) AS t(label)",
+			want: "CROSS JOIN UNNEST(labels) AS t(label)",
+		},
+		{
+			name: "values list",
+			sql:  "terminating_rule IN (VALUES 'a', 'b')",
+			want: "terminating_rule IN ( 'a', 'b')",
+		},
+		{
+			name: "ctas",
+			sql:  "CREATE TABLE x\nWITH (\n  format = 'Parquet',\n  write_compression = 'SNAPPY') AS\nSELECT 1",
+			want: "CREATE TABLE x\nAS\nSELECT 1",
+		},
+		{
+			name: "unbalanced",
+			sql:  "ANY_MATCH(labels, label -> TRUE",
+			want: "ANY_MATCH(labels, label -> TRUE",
+		},
+	}
+
+	for _, tc := range tests {
+		if got := Translate(tc.sql); got != tc.want {
+			t.Errorf("%s:\n got: %s\nwant: %s", tc.name, got, tc.want)
+		}
+	}
+}
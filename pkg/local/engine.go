@@ -0,0 +1,216 @@
+//go:build duckdb
+
+package local
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"kfzteile24/waflogs/pkg/query"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+// Engine runs the queries of the reports on an embedded DuckDB database, with
+// the raw log tables of Athena defined as views on local WAF log files
+type Engine struct {
+	Source string // directory of raw WAF logs, optionally with a sub directory per WAF
+
+	ctx context.Context
+	db  *sql.DB
+}
+
+// schema of the raw log files. DuckDB resolves column and field names case
+// insensitively, so the lowercase names of the Athena tables work on them.
+const recordColumns = `{
+	'timestamp': 'BIGINT',
+	'webaclId': 'VARCHAR',
+	'terminatingRuleId': 'VARCHAR',
+	'terminatingRuleType': 'VARCHAR',
+	'action': 'VARCHAR',
+	'httpSourceName': 'VARCHAR',
+	'httpSourceId': 'VARCHAR',
+	'ruleGroupList': 'STRUCT(ruleGroupId VARCHAR, terminatingRule STRUCT(ruleId VARCHAR, action VARCHAR), nonTerminatingMatchingRules STRUCT(ruleId VARCHAR, action VARCHAR)[])[]',
+	'nonTerminatingMatchingRules': 'STRUCT(ruleId VARCHAR, action VARCHAR)[]',
+	'httpRequest': 'STRUCT(clientIp VARCHAR, country VARCHAR, headers STRUCT(name VARCHAR, value VARCHAR)[], uri VARCHAR, args VARCHAR, httpVersion VARCHAR, httpMethod VARCHAR, requestId VARCHAR)',
+	'labels': 'STRUCT(name VARCHAR)[]',
+	'ja3Fingerprint': 'VARCHAR',
+	'captchaResponse': 'STRUCT(responseCode INTEGER, solveTimestamp BIGINT, failureReason VARCHAR)',
+	'challengeResponse': 'STRUCT(responseCode INTEGER, solveTimestamp BIGINT, failureReason VARCHAR)',
+	'terminatingRuleMatchDetails': 'STRUCT(conditionType VARCHAR, sensitivityLevel VARCHAR, location VARCHAR, matchedData VARCHAR[], matchedFieldName VARCHAR)[]'
+}`
+
+// NewEngine opens an in-memory database with a view per WAF on the log files below source
+func NewEngine(ctx context.Context, source string) (*Engine, error) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %s", err)
+	}
+	db.SetMaxOpenConns(1) // tables created by steps live in the connection's memory
+
+	e := &Engine{
+		Source: source,
+		ctx:    ctx,
+		db:     db,
+	}
+
+	if err := e.createViews(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *Engine) Close() error {
+	return e.db.Close()
+}
+
+func (e *Engine) createViews() error {
+	stmts := []string{"CREATE SCHEMA IF NOT EXISTS waflogs"}
+
+	for _, waf := range []query.WAF{query.WafBC, query.WafECP} {
+		dir := e.Source
+		if info, err := os.Stat(filepath.Join(e.Source, waf.String())); err == nil && info.IsDir() {
+			dir = filepath.Join(e.Source, waf.String())
+		}
+
+		glob := filepath.Join(dir, "**", "*")
+		if matches, _ := filepath.Glob(filepath.Join(dir, "*")); len(matches) == 0 {
+			continue // no logs of this WAF
+		}
+
+		// Parquet copies of the logs, e.g. converted by Firehose, have the
+		// schema of the records
+		src := fmt.Sprintf("read_json(%s, format = 'auto', columns = %s, ignore_errors = true)", quoteString(glob), recordColumns)
+		if hasParquet(dir) {
+			src = fmt.Sprintf("read_parquet(%s, union_by_name = true)", quoteString(glob+".parquet"))
+		}
+
+		stmts = append(stmts, fmt.Sprintf(
+			`CREATE VIEW %s AS
+			 SELECT *, strftime(epoch_ms(timestamp), '%%Y/%%m/%%d') AS "day"
+			 FROM %s`,
+			query.Table(waf), src,
+		))
+	}
+
+	for _, stmt := range stmts {
+		if _, err := e.db.ExecContext(e.ctx, stmt); err != nil {
+			return fmt.Errorf("creating views on %s: %s", e.Source, err)
+		}
+	}
+
+	return nil
+}
+
+// hasParquet reports whether the logs below dir are Parquet files
+func hasParquet(dir string) bool {
+	found := false
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".parquet") {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// Query executes an sql statement written for Athena, stores the result at
+// dstPath and returns the execution with the columns of the result
+func (e *Engine) Query(sql string, dstPath string) (*query.Execution, error) {
+	start := time.Now()
+
+	rows, err := e.db.QueryContext(e.ctx, Translate(sql))
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %s", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("reading columns: %s", err)
+	}
+
+	var cols []query.Column
+	var header []string
+	for _, t := range types {
+		cols = append(cols, query.Column{Name: t.Name(), Type: athenaType(t.DatabaseTypeName())})
+		header = append(header, t.Name())
+	}
+
+	f, err := os.OpenFile(dstPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening destination file path: %s", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write(header)
+
+	var n int
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("reading row: %s", err)
+		}
+
+		record := make([]string, len(cols))
+		for i, v := range values {
+			record[i] = formatAs(v, types[i].DatabaseTypeName())
+		}
+		w.Write(record)
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rows: %s", err)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("writing results: %s", err)
+	}
+
+	log.Printf("[+] Local query finished in %s, %d rows\n", time.Since(start).Round(time.Millisecond), n)
+
+	return &query.Execution{Columns: cols}, nil
+}
+
+// formatAs renders a value of a column of the DuckDB type like Athena renders
+// it in CSV results
+func formatAs(v interface{}, duckdbType string) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if duckdbType == "DATE" {
+			return v.UTC().Format("2006-01-02")
+		}
+		return v.UTC().Format("2006-01-02 15:04:05.000") + " UTC"
+	case []interface{}:
+		var out []string
+		for _, e := range v {
+			out = append(out, formatAs(e, strings.TrimSuffix(duckdbType, "[]")))
+		}
+		return "[" + strings.Join(out, ", ") + "]"
+	case float64:
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%.1f", v)
+		}
+		return fmt.Sprintf("%g", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
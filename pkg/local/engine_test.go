@@ -0,0 +1,72 @@
+//go:build duckdb
+
+package local
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngineParquetSource(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "json", "BC", "2023", "02", "21", "10", "aws-waf-logs-test-1")
+	parquetPath := filepath.Join(dir, "parquet", "BC", "2023", "02", "21", "10", "aws-waf-logs-test-1.parquet")
+	for _, p := range []string{jsonPath, parquetPath} {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := "" +
+		`{"timestamp":1676973600000,"action":"BLOCK","terminatingRuleId":"rate-limit","httpRequest":{"clientIp":"1.2.3.4","country":"DE","uri":"/","headers":[{"name":"user-agent","value":"curl/7.0"}]},"labels":[{"name":"awswaf:managed:aws:bot-control:bot:category:http_library"}]}` +
+		`{"timestamp":1676973660000,"action":"ALLOW","terminatingRuleId":"Default_Action","httpRequest":{"clientIp":"5.6.7.8","country":"FR","uri":"/cart","headers":[]}}`
+	if err := os.WriteFile(jsonPath, []byte(records), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	convert := fmt.Sprintf("COPY (SELECT * FROM read_json(%s, format = 'auto', columns = %s)) TO %s (FORMAT PARQUET)", quoteString(jsonPath), recordColumns, quoteString(parquetPath))
+	if _, err := db.Exec(convert); err != nil {
+		t.Fatalf("converting logs: %s", err)
+	}
+
+	stmt := `SELECT httprequest.clientip AS client_ip, action, day, CARDINALITY(FILTER(labels, l -> l.name LIKE '%bot-control%')) AS bot_labels,
+		ELEMENT_AT(FILTER(httprequest.headers, h -> LOWER(h.name) = 'user-agent'), 1).value AS user_agent
+		FROM "waflogs"."waf_logs_p" ORDER BY client_ip`
+
+	got := map[string]string{}
+	for _, source := range []string{"json", "parquet"} {
+		e, err := NewEngine(context.Background(), filepath.Join(dir, source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer e.Close()
+
+		dst := filepath.Join(dir, source+".csv")
+		if _, err := e.Query(stmt, dst); err != nil {
+			t.Fatalf("%s: %s", source, err)
+		}
+		b, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[source] = string(b)
+	}
+
+	want := "client_ip,action,day,bot_labels,user_agent\n" +
+		"1.2.3.4,BLOCK,2023/02/21,1,curl/7.0\n" +
+		"5.6.7.8,ALLOW,2023/02/21,0,\n"
+	for source, result := range got {
+		if result != want {
+			t.Errorf("%s:\n got: %q\nwant: %q", source, result, want)
+		}
+	}
+}
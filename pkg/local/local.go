@@ -0,0 +1,8 @@
+package local
+
+import "errors"
+
+// ErrNoDuckDB is returned by the engine, the store and the Parquet functions
+// of builds without the duckdb tag. DuckDB needs cgo, so the default build
+// leaves it out and runs reports on Athena or the offline backend only.
+var ErrNoDuckDB = errors.New("built without DuckDB, rebuild with -tags duckdb")
@@ -0,0 +1,64 @@
+//go:build !duckdb
+
+package local
+
+import (
+	"context"
+	"kfzteile24/waflogs/pkg/query"
+)
+
+// Engine runs the queries of the reports on an embedded DuckDB database, with
+// the raw log tables of Athena defined as views on local WAF log files. Builds
+// without the duckdb tag can't make one.
+type Engine struct {
+	Source string // directory of raw WAF logs, optionally with a sub directory per WAF
+}
+
+// NewEngine returns ErrNoDuckDB
+func NewEngine(ctx context.Context, source string) (*Engine, error) {
+	return nil, ErrNoDuckDB
+}
+
+func (e *Engine) Close() error {
+	return nil
+}
+
+// Query returns ErrNoDuckDB
+func (e *Engine) Query(sql string, dstPath string) (*query.Execution, error) {
+	return nil, ErrNoDuckDB
+}
+
+// Store is an embedded database of the results of report loaders across days
+// and WAFs. Builds without the duckdb tag can't open one.
+type Store struct {
+	Path string
+}
+
+// OpenStore returns ErrNoDuckDB
+func OpenStore(path string) (*Store, error) {
+	return nil, ErrNoDuckDB
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// Query returns ErrNoDuckDB
+func (s *Store) Query(stmt string, args ...interface{}) ([][]string, error) {
+	return nil, ErrNoDuckDB
+}
+
+// Sync returns ErrNoDuckDB
+func (s *Store) Sync(dataDir string) (int, error) {
+	return 0, ErrNoDuckDB
+}
+
+// WriteParquet returns ErrNoDuckDB
+func WriteParquet(csvPath string, dstPath string, cols []query.Column) error {
+	return ErrNoDuckDB
+}
+
+// ReadParquet returns ErrNoDuckDB
+func ReadParquet(path string) ([][]string, error) {
+	return nil, ErrNoDuckDB
+}
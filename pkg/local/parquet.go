@@ -0,0 +1,178 @@
+//go:build duckdb
+
+package local
+
+import (
+	"database/sql"
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"strings"
+)
+
+// WriteParquet converts the CSV result at csvPath, rendered like Athena
+// renders results, to a Parquet file at dstPath with the types of cols.
+// Timestamps are stored in UTC without time zone.
+func WriteParquet(csvPath string, dstPath string, cols []query.Column) error {
+	if len(cols) == 0 {
+		return fmt.Errorf("no columns to write")
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return fmt.Errorf("opening database: %s", err)
+	}
+	defer db.Close()
+
+	stmt := fmt.Sprintf("COPY (%s) TO %s (FORMAT parquet)", selectCSV(csvPath, cols), quoteString(dstPath))
+	if _, err := db.Exec(stmt); err != nil {
+		return fmt.Errorf("converting %s: %s", csvPath, err)
+	}
+
+	return nil
+}
+
+// ReadParquet returns the header and rows of a Parquet file, rendered like
+// Athena renders results
+func ReadParquet(path string) ([][]string, error) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %s", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM read_parquet(%s)", quoteString(path)))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %s", path, err)
+	}
+	defer rows.Close()
+
+	return render(rows)
+}
+
+// selectCSV returns a query selecting the values of the CSV result at
+// csvPath with the types of cols
+func selectCSV(csvPath string, cols []query.Column) string {
+	var schema, exprs []string
+	for i, c := range cols {
+		name := fmt.Sprintf("c%d", i) // the header may repeat names
+		schema = append(schema, fmt.Sprintf("'%s': 'VARCHAR'", name))
+		exprs = append(exprs, fmt.Sprintf("%s AS %s", castExpr(name, c), QuoteIdent(c.Name)))
+	}
+
+	return fmt.Sprintf(
+		`SELECT %s FROM read_csv(%s, header = true, auto_detect = false, quote = '"', escape = '"', columns = {%s})`,
+		strings.Join(exprs, ", "), quoteString(csvPath), strings.Join(schema, ", "),
+	)
+}
+
+// render returns the header and rows, rendered like Athena renders results
+func render(rows *sql.Rows) ([][]string, error) {
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("reading columns: %s", err)
+	}
+
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.Name()
+	}
+	out := [][]string{header}
+
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("reading row: %s", err)
+		}
+
+		record := make([]string, len(cols))
+		for i, v := range values {
+			record[i] = formatAs(v, cols[i].DatabaseTypeName())
+		}
+		out = append(out, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rows: %s", err)
+	}
+
+	return out, nil
+}
+
+// castExpr returns an expression converting the rendered values of the
+// VARCHAR column col to the type of c. NULL is rendered as empty string.
+func castExpr(col string, c query.Column) string {
+	switch c.BaseType() {
+	case "array":
+		t := duckdbType(c.ElementType())
+		return fmt.Sprintf("CASE WHEN %[1]s = '[]' THEN CAST([] AS %[2]s[]) WHEN %[1]s <> '' THEN CAST(string_split(%[1]s[2:-2], ', ') AS %[2]s[]) END", col, t)
+	case "timestamp":
+		// Athena renders the zone of timestamps with time zone, e.g. "2023-02-21 10:00:00.000 UTC"
+		return fmt.Sprintf("CAST(regexp_replace(NULLIF(%s, ''), ' [A-Za-z][A-Za-z/_+-]*$', '') AS TIMESTAMP)", col)
+	case "varchar", "char", "string", "json":
+		return col
+	default:
+		return fmt.Sprintf("CAST(NULLIF(%s, '') AS %s)", col, duckdbType(c.Type))
+	}
+}
+
+// duckdbType returns the DuckDB type of scalar Athena types, VARCHAR for
+// types without equivalent like map and row
+func duckdbType(athenaType string) string {
+	switch (query.Column{Type: athenaType}).BaseType() {
+	case "boolean":
+		return "BOOLEAN"
+	case "tinyint", "smallint", "integer", "int":
+		return "INTEGER"
+	case "bigint":
+		return "BIGINT"
+	case "double", "float", "real", "decimal":
+		return "DOUBLE"
+	case "date":
+		return "DATE"
+	case "timestamp":
+		return "TIMESTAMP"
+	default:
+		return "VARCHAR"
+	}
+}
+
+// athenaType returns the Athena type of a DuckDB column. All timestamps of
+// the local engine come from from_unixtime, which returns timestamps with
+// time zone in Athena.
+func athenaType(duckdbType string) string {
+	if strings.HasSuffix(duckdbType, "[]") {
+		return "array(" + athenaType(strings.TrimSuffix(duckdbType, "[]")) + ")"
+	}
+
+	switch duckdbType {
+	case "BOOLEAN":
+		return "boolean"
+	case "TINYINT", "SMALLINT", "INTEGER", "UTINYINT", "USMALLINT":
+		return "integer"
+	case "BIGINT", "HUGEINT", "UINTEGER", "UBIGINT":
+		return "bigint"
+	case "FLOAT", "DOUBLE":
+		return "double"
+	case "DATE":
+		return "date"
+	case "TIMESTAMP", "TIMESTAMPTZ", "TIMESTAMP WITH TIME ZONE":
+		return "timestamp with time zone"
+	default:
+		if strings.HasPrefix(duckdbType, "DECIMAL") {
+			return "double"
+		}
+		return "varchar"
+	}
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// QuoteIdent quotes s as an identifier of DuckDB, e.g. a column name
+func QuoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
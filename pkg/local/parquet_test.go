@@ -0,0 +1,97 @@
+//go:build duckdb
+
+package local
+
+import (
+	"database/sql"
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteParquet(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "result.csv")
+	parquetPath := filepath.Join(dir, "result.parquet")
+
+	records := "" +
+		"client_ip,num_requests,share,verified,time_window,days,hours\n" +
+		"1.2.3.4,450,0.5,true,2023-02-21 10:00:00.000 UTC,\"[2023/02/21, 2023/02/22]\",\"[10, 11]\"\n" +
+		"\"5.6.7.8, 9.9.9.9\",,,false,,[],[]\n"
+	if err := os.WriteFile(csvPath, []byte(records), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cols := []query.Column{
+		{Name: "client_ip", Type: "varchar"},
+		{Name: "num_requests", Type: "bigint"},
+		{Name: "share", Type: "double"},
+		{Name: "verified", Type: "boolean"},
+		{Name: "time_window", Type: "timestamp(3) with time zone"},
+		{Name: "days", Type: "array"},
+		{Name: "hours", Type: "array(integer)"},
+	}
+	if err := WriteParquet(csvPath, parquetPath, cols); err != nil {
+		t.Fatalf("writing parquet: %s", err)
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM read_parquet('%s')", parquetPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	var got []string
+	for _, c := range types {
+		got = append(got, c.DatabaseTypeName())
+	}
+	want := "[VARCHAR BIGINT DOUBLE BOOLEAN TIMESTAMP VARCHAR[] INTEGER[]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got types %v, want %s", got, want)
+	}
+
+	// reading renders values like the CSV written by Athena
+	result, err := ReadParquet(parquetPath)
+	if err != nil {
+		t.Fatalf("reading parquet: %s", err)
+	}
+	wantResult := "[" +
+		"[client_ip num_requests share verified time_window days hours] " +
+		"[1.2.3.4 450 0.5 true 2023-02-21 10:00:00.000 UTC [2023/02/21, 2023/02/22] [10, 11]] " +
+		"[5.6.7.8, 9.9.9.9   false  [] []]" +
+		"]"
+	if fmt.Sprint(result) != wantResult {
+		t.Errorf("got result\n%v\nwant\n%s", result, wantResult)
+	}
+}
+
+func TestAthenaType(t *testing.T) {
+	tests := map[string]string{
+		"BIGINT":        "bigint",
+		"INTEGER":       "integer",
+		"DOUBLE":        "double",
+		"DECIMAL(18,3)": "double",
+		"TIMESTAMP":     "timestamp with time zone",
+		"VARCHAR[]":     "array(varchar)",
+		"BIGINT[][]":    "array(array(bigint))",
+		"STRUCT(a INT)": "varchar",
+	}
+
+	for in, want := range tests {
+		if got := athenaType(in); got != want {
+			t.Errorf("athenaType(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+//go:build duckdb
+
+package local
+
+import (
+	"database/sql"
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/offline"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Store is an embedded database of the results of report loaders across days
+// and WAFs. Each report has a schema and each result a table in it, e.g. the
+// result ./data/BC/rate-limit-report/2023-02-21/ips-blocked-by-rate-limit.csv
+// becomes the rows of rate_limit_report.ips_blocked_by_rate_limit with waf
+// 'BC' and day 2023-02-21.
+type Store struct {
+	Path string
+
+	db *sql.DB
+}
+
+// catalog lists the results in the store and when their files were ingested
+const catalog = `CREATE TABLE IF NOT EXISTS main.results (
+	waf      VARCHAR,
+	report   VARCHAR,
+	result   VARCHAR,
+	day      DATE,
+	"table"  VARCHAR,
+	path     VARCHAR,
+	num_rows BIGINT,
+	modified TIMESTAMP,
+	PRIMARY KEY (waf, report, result, day)
+)`
+
+// status is the column of the catalog with the state of the run of a result
+// from its manifest, "unknown" for runs without manifest
+const status = `ALTER TABLE main.results ADD COLUMN IF NOT EXISTS status VARCHAR DEFAULT 'unknown'`
+
+// OpenStore opens the store at path, creating it if it doesn't exist
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating directory: %s", err)
+	}
+
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %s", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{catalog, status} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating catalog: %s", err)
+		}
+	}
+
+	return &Store{Path: path, db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Query runs an SQL statement on the store and returns the header and rows
+// of its result, rendered like Athena renders results
+func (s *Store) Query(stmt string, args ...interface{}) ([][]string, error) {
+	rows, err := s.db.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %s", err)
+	}
+	defer rows.Close()
+
+	return render(rows)
+}
+
+// resultFile is a result written by a report loader
+type resultFile struct {
+	waf      string
+	report   string
+	result   string
+	day      time.Time
+	path     string
+	modified time.Time // of the result or, if later, the manifest of its run
+	status   string    // of the run of the result
+}
+
+var reDay = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// Sync ingests the results below dataDir, laid out like
+// <dataDir>/<WAF>/<report>/<yyyy-mm-dd>/<result>.csv or .parquet, which
+// changed since they were ingested last, or whose run changed. It returns the
+// number of results ingested.
+func (s *Store) Sync(dataDir string) (int, error) {
+	files, err := listResults(dataDir)
+	if err != nil {
+		return 0, fmt.Errorf("listing results: %s", err)
+	}
+
+	ingested, err := s.ingested()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, f := range files {
+		if modified, ok := ingested[f.key()]; ok && !f.modified.After(modified) {
+			continue
+		}
+
+		if err := s.ingest(f); err != nil {
+			return n, fmt.Errorf("ingesting %s: %s", f.path, err)
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+func (f resultFile) key() string {
+	return strings.Join([]string{f.waf, f.report, f.result, f.day.Format("2006-01-02")}, "/")
+}
+
+// listResults returns the result files below dataDir, preferring the typed
+// Parquet file if a result was written in both formats
+func listResults(dataDir string) ([]resultFile, error) {
+	var out []resultFile
+	index := map[string]int{}
+	runs := map[string]run{}
+
+	err := filepath.WalkDir(dataDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if ext != ".csv" && ext != ".parquet" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 4 || !reDay.MatchString(parts[2]) {
+			return nil // not a result of a report loader
+		}
+
+		day, err := time.Parse("2006-01-02", parts[2])
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Dir(path)
+		r, ok := runs[dir]
+		if !ok {
+			r = readRun(dir)
+			runs[dir] = r
+		}
+
+		f := resultFile{
+			waf:      parts[0],
+			report:   parts[1],
+			result:   strings.TrimSuffix(parts[3], ext),
+			day:      day,
+			path:     path,
+			modified: info.ModTime().UTC().Truncate(time.Microsecond), // precision of the catalog
+			status:   r.status,
+		}
+		if r.modified.After(f.modified) {
+			f.modified = r.modified
+		}
+
+		if i, ok := index[f.key()]; ok {
+			if ext == ".parquet" {
+				out[i] = f
+			}
+			return nil
+		}
+		index[f.key()] = len(out)
+		out = append(out, f)
+
+		return nil
+	})
+
+	return out, err
+}
+
+// run is the state of the run of results from its manifest
+type run struct {
+	status   string
+	modified time.Time
+}
+
+func readRun(dir string) run {
+	m, err := data.ReadManifest(dir)
+	if err != nil {
+		return run{status: "unknown"}
+	}
+
+	r := run{status: m.State()}
+	if info, err := os.Stat(filepath.Join(dir, data.ManifestFile)); err == nil {
+		r.modified = info.ModTime().UTC().Truncate(time.Microsecond)
+	}
+	return r
+}
+
+// ingested returns when the results in the store were modified
+func (s *Store) ingested() (map[string]time.Time, error) {
+	rows, err := s.db.Query(`SELECT waf, report, result, day, modified FROM main.results`)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %s", err)
+	}
+	defer rows.Close()
+
+	out := map[string]time.Time{}
+	for rows.Next() {
+		var f resultFile
+		if err := rows.Scan(&f.waf, &f.report, &f.result, &f.day, &f.modified); err != nil {
+			return nil, fmt.Errorf("reading catalog: %s", err)
+		}
+		out[f.key()] = f.modified
+	}
+
+	return out, rows.Err()
+}
+
+// ingest replaces the rows of the WAF and day of the result's table by the
+// rows of the file
+func (s *Store) ingest(f resultFile) error {
+	src, err := selectResult(f.path)
+	if err != nil {
+		return err
+	}
+	if src == "" {
+		return nil // nothing to ingest, e.g. a statement creating a table
+	}
+
+	schema, table := QuoteIdent(Ident(f.report)), QuoteIdent(Ident(f.result))
+	name := schema + "." + table
+	src = fmt.Sprintf("SELECT %s AS waf, DATE %s AS day, * FROM (%s)", quoteString(f.waf), quoteString(f.day.Format("2006-01-02")), src)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", schema),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS %s LIMIT 0", name, src),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// results may gain columns and change types over time
+	if err := alignColumns(tx, name, src); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE waf = ? AND day = ?", name), f.waf, f.day); err != nil {
+		return err
+	}
+	res, err := tx.Exec(fmt.Sprintf("INSERT INTO %s BY NAME %s", name, src))
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+
+	_, err = tx.Exec(
+		`INSERT OR REPLACE INTO main.results (waf, report, result, day, "table", path, num_rows, modified, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.waf, f.report, f.result, f.day, Ident(f.report)+"."+Ident(f.result), f.path, n, f.modified, f.status,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// selectResult returns a query selecting the rows of a result file, with the
+// types of a Parquet file or inferred from the values of a CSV file
+func selectResult(path string) (string, error) {
+	if filepath.Ext(path) == ".parquet" {
+		return fmt.Sprintf("SELECT * FROM read_parquet(%s)", quoteString(path)), nil
+	}
+
+	t, err := offline.ReadCSV(path)
+	if err != nil {
+		return "", err
+	}
+	if len(t.Header) == 0 {
+		return "", nil
+	}
+
+	return selectCSV(path, t.Columns()), nil
+}
+
+// alignColumns adds the columns of the source missing in the table and
+// widens the columns whose type differs to the type DuckDB unifies both to,
+// e.g. BIGINT and DOUBLE to DOUBLE or BIGINT and VARCHAR to VARCHAR, since
+// the types inferred from CSV files may change between days
+func alignColumns(tx *sql.Tx, table string, src string) error {
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT s.column_name, s.column_type, t.column_type
+		FROM (DESCRIBE %s) s LEFT JOIN (DESCRIBE %s) t USING (column_name)
+		WHERE t.column_type IS DISTINCT FROM s.column_type`, src, table))
+	if err != nil {
+		return fmt.Errorf("comparing columns: %s", err)
+	}
+
+	type change struct {
+		name, typ string
+		current   sql.NullString
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.name, &c.typ, &c.current); err != nil {
+			rows.Close()
+			return err
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+
+	for _, c := range changes {
+		col := QuoteIdent(c.name)
+		if !c.current.Valid {
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, col, c.typ)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var typ string
+		err := tx.QueryRow(fmt.Sprintf("SELECT typeof(c) FROM (SELECT CAST(NULL AS %s) AS c UNION ALL SELECT CAST(NULL AS %s)) LIMIT 1", c.current.String, c.typ)).Scan(&typ)
+		if err != nil {
+			return fmt.Errorf("unifying types of column %s: %s", c.name, err)
+		}
+		if typ == c.current.String {
+			continue // the values of the source are cast when inserted
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s", table, col, typ)); err != nil {
+			return fmt.Errorf("widening column %s to %s: %s", c.name, typ, err)
+		}
+	}
+
+	return nil
+}
+
+var reNonIdent = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// Ident returns the name of the schema or table of a report or result in the
+// store, e.g. rate_limit_report for rate-limit-report
+func Ident(name string) string {
+	return reNonIdent.ReplaceAllString(strings.ToLower(name), "_")
+}
@@ -0,0 +1,123 @@
+//go:build duckdb
+
+package local
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/query"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeResult writes a CSV result like a report loader below dataDir
+func writeResult(t *testing.T, dataDir string, waf string, day string, records string) string {
+	t.Helper()
+
+	path := filepath.Join(dataDir, waf, "rate-limit-report", day, "fastest-ips-not-black-or-whitelisted.csv")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(records), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStoreSync(t *testing.T) {
+	dataDir := t.TempDir()
+
+	header := "client_ip,country,time_window,num_requests\n"
+	writeResult(t, dataDir, "BC", "2023-02-21", header+"1.2.3.4,DE,2023-02-21 10:00:00.000 UTC,450\n5.6.7.8,AT,2023-02-21 10:05:00.000 UTC,300\n")
+	writeResult(t, dataDir, "ECP", "2023-02-21", header+"1.2.3.4,DE,2023-02-21 11:00:00.000 UTC,200\n")
+	path := writeResult(t, dataDir, "BC", "2023-02-22", header)
+
+	// typed results of a day are preferred over the CSV
+	if err := WriteParquet(path, filepath.Join(filepath.Dir(path), "fastest-ips-not-black-or-whitelisted.parquet"), []query.Column{
+		{Name: "client_ip", Type: "varchar"},
+		{Name: "country", Type: "varchar"},
+		{Name: "time_window", Type: "timestamp"},
+		{Name: "num_requests", Type: "bigint"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dataDir, "BC", "rate-limit-report", "2023-02-22", "fastest-ips-not-black-or-whitelisted.sql"), []byte("SELECT 1"), 0644)
+
+	s, err := OpenStore(filepath.Join(dataDir, data.StoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if n, err := s.Sync(dataDir); err != nil || n != 3 {
+		t.Fatalf("first sync ingested %d results, error %v, want 3", n, err)
+	}
+	if n, err := s.Sync(dataDir); err != nil || n != 0 {
+		t.Fatalf("second sync ingested %d results, error %v, want 0", n, err)
+	}
+
+	got, err := s.Query(`
+		SELECT waf, day, time_window, num_requests + 1
+		FROM rate_limit_report.fastest_ips_not_black_or_whitelisted
+		WHERE client_ip = ?
+		ORDER BY waf`, "1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[[waf day time_window (num_requests + 1)] [BC 2023-02-21 2023-02-21 10:00:00.000 UTC 451] [ECP 2023-02-21 2023-02-21 11:00:00.000 UTC 201]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	// a changed result replaces the rows of its day, new columns are added
+	path = writeResult(t, dataDir, "BC", "2023-02-21", "client_ip,country,time_window,num_requests,num_blocked\n9.9.9.9,DE,2023-02-21 12:00:00.000 UTC,100,10\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Sync(dataDir); err != nil || n != 1 {
+		t.Fatalf("sync after change ingested %d results, error %v, want 1", n, err)
+	}
+
+	got, err = s.Query(`
+		SELECT waf, day, client_ip, num_blocked
+		FROM rate_limit_report.fastest_ips_not_black_or_whitelisted
+		ORDER BY waf, day`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "[[waf day client_ip num_blocked] [BC 2023-02-21 9.9.9.9 10] [ECP 2023-02-21 1.2.3.4 ]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	// columns are widened when the types inferred from a day differ
+	writeResult(t, dataDir, "ECP", "2023-02-22", "client_ip,country,time_window,num_requests,num_blocked\n1.2.3.4,DE,unknown,12.5,few\n")
+	if n, err := s.Sync(dataDir); err != nil || n != 1 {
+		t.Fatalf("sync of other types ingested %d results, error %v, want 1", n, err)
+	}
+
+	got, err = s.Query(`
+		SELECT waf, day, time_window, num_requests, num_blocked
+		FROM rate_limit_report.fastest_ips_not_black_or_whitelisted
+		WHERE client_ip = ?
+		ORDER BY waf, day`, "1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "[[waf day time_window num_requests num_blocked] [ECP 2023-02-21 2023-02-21 11:00:00 200.0 ] [ECP 2023-02-22 unknown 12.5 few]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	got, err = s.Query(`SELECT "table", COUNT(*), SUM(num_rows) FROM main.results GROUP BY ALL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "[[table count_star() sum(num_rows)] [rate_limit_report.fastest_ips_not_black_or_whitelisted 4 3]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package offline
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+)
+
+// column returns the value of the column called name of the SQL templates
+// for a record, false for NULL
+func column(r *waflog.Record, name string) (string, bool) {
+	switch name {
+	case "client_ip":
+		return r.HTTPRequest.ClientIP, true
+	case "forwarded_ip":
+		return r.ForwardedIP()
+	case "country":
+		return r.HTTPRequest.Country, true
+	case "uri":
+		return r.HTTPRequest.URI, true
+	case "user_agent":
+		return r.UserAgent()
+	case "bot_name":
+		return r.BotName()
+	case "bot_category":
+		return r.BotCategory()
+	case "terminating_rule":
+		return r.TerminatingRuleID, true
+	default:
+		panic(fmt.Sprintf("unreachable code reached: column %s unknown", name))
+	}
+}
+
+// Identity returns the values of the identity columns of a record, the
+// identity the queries group requests by
+func Identity(r *waflog.Record, identityCols query.IdentityColumns) []string {
+	var out []string
+	for _, col := range identityCols.Columns() {
+		v, _ := column(r, col)
+		out = append(out, v)
+	}
+	return out
+}
+
+// hasLabel returns true if the record carries a label selected by the filter
+func hasLabel(r *waflog.Record, labels query.LabelFilter) bool {
+	if len(labels) == 0 {
+		return true
+	}
+
+	for _, l := range r.Labels {
+		if labels.MatchesName(l.Name) {
+			return true
+		}
+	}
+	return false
+}
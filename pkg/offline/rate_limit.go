@@ -0,0 +1,113 @@
+package offline
+
+import (
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"sort"
+	"time"
+)
+
+// RequestsBlockedBy computes the result of query.GetRequestsBlockedBy
+func RequestsBlockedBy(records []*waflog.Record, identityCols query.IdentityColumns, terminatingRules query.TerminatingRules, labels query.LabelFilter, limit int) *Table {
+	rules := map[string]bool{}
+	for _, t := range terminatingRules {
+		rules[t.String()] = true
+	}
+
+	g := newGroups()
+	for _, r := range records {
+		if r.Action != "BLOCK" || !rules[r.TerminatingRuleID] || !hasLabel(r, labels) {
+			continue
+		}
+		g.add(append(Identity(r, identityCols), r.TerminatingRuleID), 1)
+	}
+
+	var rows [][]string
+	for _, v := range g.sorted() {
+		rows = append(rows, append(v.key, itoa(v.count)))
+	}
+
+	return &Table{
+		Header: append(identityCols.Columns(), "terminating_rule", "num_requests"),
+		Rows:   limitRows(rows, limit),
+	}
+}
+
+// FastestIdentities computes the result of query.GetFastestIdentities, with
+// filter in place of the custom where clause
+func FastestIdentities(records []*waflog.Record, identityCols query.IdentityColumns, window query.Window, labels query.LabelFilter, minRate int, filter func(*waflog.Record) bool, limit int) (*Table, error) {
+	if err := window.Validate(); err != nil {
+		return nil, err
+	}
+
+	size := int64(window.Minutes) * 60 * 1000
+	if window.Sliding {
+		size = 60 * 1000
+	}
+
+	// requests per identity and bucket, keyed by the start of the bucket in ms
+	buckets := map[string]map[int64]int{}
+	ids := map[string][]string{}
+	for _, r := range records {
+		if !hasLabel(r, labels) || (filter != nil && !filter(r)) {
+			continue
+		}
+
+		id := Identity(r, identityCols)
+		key := groupKey(id)
+		if _, ok := buckets[key]; !ok {
+			buckets[key] = map[int64]int{}
+			ids[key] = id
+		}
+		buckets[key][r.Timestamp/size*size]++
+	}
+
+	g := newGroups()
+	for key, counts := range buckets {
+		peak, peakStart := 0, int64(0)
+		for start, n := range counts {
+			if window.Sliding {
+				// sum of the minutes of the window ending with this minute
+				n = 0
+				for i := 0; i < window.Minutes; i++ {
+					n += counts[start-int64(i)*size]
+				}
+				start -= int64(window.Minutes-1) * size
+			}
+			if n <= minRate {
+				continue
+			}
+			if window.Sliding && limit > 0 {
+				// the peak window only, its overlapping windows would fill the top list
+				if n > peak || (n == peak && start < peakStart) {
+					peak, peakStart = n, start
+				}
+				continue
+			}
+			g.add(append(append([]string{}, ids[key]...), formatTimestamp(time.UnixMilli(start))), n)
+		}
+		if peak > 0 {
+			g.add(append(append([]string{}, ids[key]...), formatTimestamp(time.UnixMilli(peakStart))), peak)
+		}
+	}
+
+	var rows [][]string
+	for _, v := range g.sorted() {
+		rows = append(rows, append(v.key, itoa(v.count)))
+	}
+
+	return &Table{
+		Header: append(identityCols.Columns(), "time_window", "num_requests"),
+		Rows:   limitRows(rows, limit),
+	}, nil
+}
+
+// distinctSorted returns the distinct values in ascending order
+func distinctSorted(values map[string]bool) []string {
+	var out []string
+	for v := range values {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
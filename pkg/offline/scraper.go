@@ -0,0 +1,286 @@
+package offline
+
+import (
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"regexp"
+	"sort"
+	"time"
+)
+
+var (
+	reAPC1SparePartsURI = regexp.MustCompile(`^/ersatzteile-verschleissteile/.*`)
+	reAPC1RMArg         = regexp.MustCompile(`^(rm=[a-zA-Z0-9]+)`)
+)
+
+// apc1Row holds the columns of the APC1 materialized view used by the queries
+type apc1Row struct {
+	time      time.Time
+	day       string
+	uri       string
+	uriC      string
+	params    string
+	userAgent string
+	hasUA     bool
+	session   string
+}
+
+// scrapers mirrors the scraper_sessions and scraper_user_agents CTEs of the
+// queries of a scraper campaign
+type scrapers struct {
+	rows       []apc1Row
+	sessions   map[string]bool
+	userAgents map[string]bool
+}
+
+func newScrapers(records []*waflog.Record, c query.Campaign) *scrapers {
+	out := &scrapers{
+		sessions:   map[string]bool{},
+		userAgents: map[string]bool{},
+	}
+
+	allowlisted := map[string]bool{}
+	for _, rule := range c.AllowlistRules {
+		allowlisted[rule] = true
+	}
+
+	sessionUAs := map[string]map[string]bool{}
+	for _, r := range records {
+		row := apc1Row{
+			time:   r.Time(),
+			day:    r.Day(),
+			uri:    r.HTTPRequest.URI,
+			uriC:   reAPC1SparePartsURI.ReplaceAllString(r.HTTPRequest.URI, "/ersatzteile-verschleissteile/...") + "&" + reAPC1RMArg.FindString(r.HTTPRequest.Args),
+			params: r.HTTPRequest.Args,
+		}
+		row.userAgent, row.hasUA = r.UserAgent()
+		row.session, _ = r.Session()
+		out.rows = append(out.rows, row)
+
+		// only sessions of clients that don't reveal themselves as bots
+		if row.session == "" || allowlisted[r.TerminatingRuleID] || r.SignalNoBrowser() {
+			continue
+		}
+		if _, ok := sessionUAs[row.session]; !ok {
+			sessionUAs[row.session] = map[string]bool{}
+		}
+		sessionUAs[row.session][nullable(row.userAgent, row.hasUA)] = true
+	}
+
+	for session, uas := range sessionUAs {
+		if len(uas) > c.MinUserAgents {
+			out.sessions[session] = true
+		}
+	}
+
+	uaRequests := map[string]int{}
+	for _, row := range out.rows {
+		if out.sessions[row.session] && row.hasUA {
+			uaRequests[row.userAgent]++
+		}
+	}
+	for ua, n := range uaRequests {
+		if n > c.MinRequests {
+			out.userAgents[ua] = true
+		}
+	}
+
+	return out
+}
+
+// ScraperURLs computes the result of query.GetScraperURLs
+func ScraperURLs(records []*waflog.Record, c query.Campaign, limit int) *Table {
+	s := newScrapers(records, c)
+	reURI := regexp.MustCompile(c.URIPattern)
+
+	type stats struct {
+		num        int
+		days       map[string]bool
+		userAgents map[string]bool
+		uris       map[string]bool
+		sessions   map[string]bool
+		target     bool
+		first      time.Time
+		last       time.Time
+	}
+
+	urls := map[string]*stats{}
+	for _, row := range s.rows {
+		if !s.sessions[row.session] {
+			continue
+		}
+
+		u, ok := urls[row.uriC]
+		if !ok {
+			u = &stats{
+				days:       map[string]bool{},
+				userAgents: map[string]bool{},
+				uris:       map[string]bool{},
+				sessions:   map[string]bool{},
+				first:      row.time,
+				last:       row.time,
+			}
+			urls[row.uriC] = u
+		}
+
+		u.num++
+		u.days[row.day] = true
+		u.userAgents[nullable(row.userAgent, row.hasUA)] = true
+		u.uris[row.uri] = true
+		u.sessions[row.session] = true
+		u.target = u.target || reURI.MatchString(row.uri)
+		if row.time.Before(u.first) {
+			u.first = row.time
+		}
+		if row.time.After(u.last) {
+			u.last = row.time
+		}
+	}
+
+	var uriCs []string
+	for uriC := range urls {
+		uriCs = append(uriCs, uriC)
+	}
+	sort.Slice(uriCs, func(i, j int) bool {
+		if urls[uriCs[i]].num != urls[uriCs[j]].num {
+			return urls[uriCs[i]].num > urls[uriCs[j]].num
+		}
+		return uriCs[i] < uriCs[j]
+	})
+
+	var rows [][]string
+	for _, uriC := range uriCs {
+		u := urls[uriC]
+		rows = append(rows, []string{
+			uriC,
+			itoa(u.num),
+			formatArray(distinctSorted(u.days)),
+			itoa(len(u.userAgents)),
+			itoa(len(u.uris)),
+			itoa(len(u.sessions)),
+			formatBool(u.target),
+			itoa(u.first.Hour()),
+			itoa(u.last.Hour() + 1),
+		})
+	}
+
+	return &Table{
+		Header: []string{"uri_c", "num_requests", "days", "user_agents", "full_uris", "sessions", "target", "first_hour", "last_hour"},
+		Rows:   limitRows(rows, limit),
+	}
+}
+
+// ScraperUserAgents computes the result of query.GetScraperUserAgents
+func ScraperUserAgents(records []*waflog.Record, c query.Campaign, limit int) *Table {
+	s := newScrapers(records, c)
+
+	type stats struct {
+		num     int
+		scraped int
+		first   time.Time
+		last    time.Time
+	}
+
+	uas := map[string]*stats{}
+	for _, row := range s.rows {
+		if !row.hasUA || !s.userAgents[row.userAgent] {
+			continue
+		}
+
+		u, ok := uas[row.userAgent]
+		if !ok {
+			u = &stats{first: row.time, last: row.time}
+			uas[row.userAgent] = u
+		}
+
+		u.num++
+		if s.sessions[row.session] {
+			u.scraped++
+		}
+		if row.time.Before(u.first) {
+			u.first = row.time
+		}
+		if row.time.After(u.last) {
+			u.last = row.time
+		}
+	}
+
+	names := distinctSorted(s.userAgents)
+	sort.SliceStable(names, func(i, j int) bool { return uas[names[i]].num > uas[names[j]].num })
+
+	var rows [][]string
+	for _, ua := range names {
+		u := uas[ua]
+		rows = append(rows, []string{
+			ua,
+			itoa(u.num),
+			itoa(u.scraped),
+			itoa(u.num - u.scraped),
+			itoa(int(u.last.Sub(u.first).Hours()) % 24), // HOUR() of an interval is its hour field
+		})
+	}
+
+	return &Table{
+		Header: []string{"user_agent", "num_requests", "num_scraped", "diff", "window_in_hours"},
+		Rows:   limitRows(rows, limit),
+	}
+}
+
+// ScraperProducts computes the result of query.GetScraperProducts
+func ScraperProducts(records []*waflog.Record, c query.Campaign, limit int) *Table {
+	s := newScrapers(records, c)
+	reURI, reProduct := regexp.MustCompile(c.URIPattern), regexp.MustCompile(c.ProductPattern)
+
+	type stats struct {
+		num        int
+		userAgents map[string]bool
+	}
+
+	products := map[string]*stats{}
+	for _, row := range s.rows {
+		if !s.sessions[row.session] || !row.hasUA || !s.userAgents[row.userAgent] || !reURI.MatchString(row.uri) {
+			continue
+		}
+
+		m := reProduct.FindStringSubmatch(row.uri + "?" + row.params)
+		if m == nil || m[1] == "" {
+			continue // NULL, an empty group is NULL locally
+		}
+		p, ok := products[m[1]]
+		if !ok {
+			p = &stats{userAgents: map[string]bool{}}
+			products[m[1]] = p
+		}
+		p.num++
+		p.userAgents[row.userAgent] = true
+	}
+
+	var names []string
+	for name := range products {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if products[names[i]].num != products[names[j]].num {
+			return products[names[i]].num > products[names[j]].num
+		}
+		return names[i] < names[j]
+	})
+
+	var rows [][]string
+	for _, name := range names {
+		rows = append(rows, []string{name, itoa(products[name].num), itoa(len(products[name].userAgents))})
+	}
+
+	return &Table{
+		Header: []string{"product", "num_requests", "user_agents"},
+		Rows:   limitRows(rows, limit),
+	}
+}
+
+// nullable maps NULL to a value distinct from all strings, for DISTINCT counts
+func nullable(v string, ok bool) string {
+	if !ok {
+		return "\x00null"
+	}
+	return v
+}
@@ -0,0 +1,56 @@
+package offline
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"path/filepath"
+)
+
+// Source reads raw WAF logs from a local directory, e.g. Firehose objects
+// copied from S3. If the directory has a sub directory per WAF (BC, ECP),
+// the logs of a WAF are read from there.
+type Source struct {
+	Dir string
+
+	days map[query.WAF]map[string][]*waflog.Record // records per day partition, once read
+}
+
+func NewSource(dir string) *Source {
+	return &Source{
+		Dir:  dir,
+		days: map[query.WAF]map[string][]*waflog.Record{},
+	}
+}
+
+// Records returns the records of the day of the scope
+func (s *Source) Records(scope query.Scope) ([]*waflog.Record, error) {
+	if _, ok := s.days[scope.Waf]; !ok {
+		if err := s.load(scope.Waf); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.days[scope.Waf][scope.Partition()], nil
+}
+
+func (s *Source) load(waf query.WAF) error {
+	dir := s.Dir
+	if info, err := os.Stat(filepath.Join(s.Dir, waf.String())); err == nil && info.IsDir() {
+		dir = filepath.Join(s.Dir, waf.String())
+	}
+
+	days := map[string][]*waflog.Record{}
+	err := waflog.ReadDir(dir, func(r *waflog.Record) error {
+		days[r.Day()] = append(days[r.Day()], r)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading logs of %s: %s", waf, err)
+	}
+
+	s.days[waf] = days
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package offline
+
+import (
+	"encoding/csv"
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Table is the result of a query, rendered like Athena renders results: all
+// values are strings and NULL is the empty string. Statements without
+// results, like CREATE TABLE, have an empty table.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// WriteCSV writes the header and the rows to path
+func (t *Table) WriteCSV(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("opening destination file path: %s", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if len(t.Header) > 0 {
+		if err := w.Write(t.Header); err != nil {
+			return fmt.Errorf("writing header: %s", err)
+		}
+	}
+	if err := w.WriteAll(t.Rows); err != nil {
+		return fmt.Errorf("writing rows: %s", err)
+	}
+
+	return nil
+}
+
+// ReadCSV reads a table written by WriteCSV or an SQL backend
+func ReadCSV(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %s", err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %s", path, err)
+	}
+
+	t := &Table{}
+	if len(records) > 0 {
+		t.Header, t.Rows = records[0], records[1:]
+	}
+	return t, nil
+}
+
+// Columns infers the Athena types of the columns from their rendered values
+func (t *Table) Columns() []query.Column {
+	var out []query.Column
+	for i, name := range t.Header {
+		var values []string
+		for _, row := range t.Rows {
+			if i < len(row) && row[i] != "" {
+				values = append(values, row[i])
+			}
+		}
+		out = append(out, query.Column{Name: name, Type: inferType(values)})
+	}
+
+	return out
+}
+
+var reTimestamp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(\.\d+)?( UTC)?$`)
+
+// inferType returns the narrowest Athena type of the non-empty values
+func inferType(values []string) string {
+	if len(values) == 0 {
+		return "varchar"
+	}
+
+	all := func(ok func(v string) bool) bool {
+		for _, v := range values {
+			if !ok(v) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case all(func(v string) bool { _, err := strconv.ParseInt(v, 10, 64); return err == nil }):
+		return "bigint"
+	case all(func(v string) bool { _, err := strconv.ParseFloat(v, 64); return err == nil }):
+		return "double"
+	case all(func(v string) bool { return v == "true" || v == "false" }):
+		return "boolean"
+	case all(reTimestamp.MatchString):
+		return "timestamp with time zone"
+	case all(func(v string) bool { return strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") }):
+		var elements []string
+		for _, v := range values {
+			if v = v[1 : len(v)-1]; v != "" {
+				elements = append(elements, strings.Split(v, ", ")...)
+			}
+		}
+		return "array(" + inferType(elements) + ")"
+	default:
+		return "varchar"
+	}
+}
+
+// group counts rows by their key columns
+type group struct {
+	key   []string
+	count int
+}
+
+type groups struct {
+	index map[string]*group
+}
+
+func newGroups() *groups {
+	return &groups{index: map[string]*group{}}
+}
+
+// groupKey joins the values of the key columns of a row to a map key
+func groupKey(values []string) string {
+	return strings.Join(values, "\x00")
+}
+
+func (g *groups) add(key []string, n int) {
+	k := groupKey(key)
+	if _, ok := g.index[k]; !ok {
+		g.index[k] = &group{key: key}
+	}
+	g.index[k].count += n
+}
+
+// sorted returns the groups by count in descending order, ties by key
+func (g *groups) sorted() []*group {
+	var out []*group
+	for _, v := range g.index {
+		out = append(out, v)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].count != out[j].count {
+			return out[i].count > out[j].count
+		}
+		return groupKey(out[i].key) < groupKey(out[j].key)
+	})
+
+	return out
+}
+
+func limitRows(rows [][]string, limit int) [][]string {
+	if len(rows) > limit {
+		return rows[:limit]
+	}
+	return rows
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
+
+// formatTimestamp renders t like Athena renders from_unixtime()
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.000") + " UTC"
+}
+
+// formatArray renders values like Athena renders arrays
+func formatArray(values []string) string {
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// formatBool renders b like Athena renders booleans
+func formatBool(b bool) string {
+	return strconv.FormatBool(b)
+}
@@ -0,0 +1,143 @@
+package offline
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Write stores the records of a WAF below dir in the layout Firehose writes
+// to S3, i.e. one gzip object of concatenated JSON records per hour at
+// <dir>/<WAF>/YYYY/MM/DD/HH/, so a Source reads them. The objects are named
+// after the producer of the records, e.g. "gen", and replace objects of the
+// same producer and hour. It returns the paths of the objects written.
+func Write(dir string, waf query.WAF, producer string, records []*waflog.Record) ([]string, error) {
+	sorted := append([]*waflog.Record(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp < sorted[j].Timestamp })
+
+	w := NewWriter(dir, waf, producer)
+	for _, r := range sorted {
+		if err := w.Write(r); err != nil {
+			return w.Paths, err
+		}
+	}
+	err := w.Close()
+
+	return w.Paths, err
+}
+
+// Writer stores records like Write while they are read, keeping only the
+// records of the current hour in memory. Records of an hour whose object the
+// writer wrote before, e.g. of an export not ordered by time, are merged into
+// the object. Objects written by earlier writers of the producer are
+// replaced.
+type Writer struct {
+	Dir      string
+	Waf      query.WAF
+	Producer string
+	Paths    []string // objects written
+	Records  int      // records written
+
+	hour    string
+	records []*waflog.Record
+	written map[string]bool
+}
+
+func NewWriter(dir string, waf query.WAF, producer string) *Writer {
+	return &Writer{
+		Dir:      dir,
+		Waf:      waf,
+		Producer: producer,
+		written:  map[string]bool{},
+	}
+}
+
+// Write adds a record, writing the records of the hour before if the record
+// is of another hour
+func (w *Writer) Write(r *waflog.Record) error {
+	if h := r.Time().Format("2006/01/02/15"); h != w.hour {
+		if err := w.flush(); err != nil {
+			return err
+		}
+		w.hour = h
+	}
+	w.records = append(w.records, r)
+	return nil
+}
+
+// Close writes the records of the current hour
+func (w *Writer) Close() error {
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	if len(w.records) == 0 {
+		return nil
+	}
+	records := w.records
+	w.Records += len(records)
+	w.records = nil
+
+	path := filepath.Join(w.Dir, w.Waf.String(), filepath.FromSlash(w.hour), objectName(w.Waf, w.Producer, records[0]))
+	if w.written[path] {
+		var merged []*waflog.Record
+		err := waflog.ReadFile(path, func(r *waflog.Record) error {
+			merged = append(merged, r)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("reading object: %s", err)
+		}
+		records = append(merged, records...)
+	} else {
+		w.Paths = append(w.Paths, path)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp < records[j].Timestamp })
+
+	if err := writeObject(path, records); err != nil {
+		return err
+	}
+	w.written[path] = true
+
+	return nil
+}
+
+func writeObject(path string, records []*waflog.Record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory: %s", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating object: %s", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("encoding record: %s", err)
+		}
+		if _, err := gz.Write(b); err != nil { // concatenated like Firehose does
+			return fmt.Errorf("writing record: %s", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("closing gzip writer: %s", err)
+	}
+
+	return f.Close()
+}
+
+// objectName returns a Firehose object name for the hour of r, e.g.
+// aws-waf-logs-bc-1-2023-02-21-10-00-00-gen.gz
+func objectName(waf query.WAF, producer string, r *waflog.Record) string {
+	return fmt.Sprintf("aws-waf-logs-%s-1-%s-00-00-%s.gz", strings.ToLower(waf.String()), r.Time().Format("2006-01-02-15"), producer)
+}
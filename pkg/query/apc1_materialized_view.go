@@ -10,6 +10,11 @@ import (
 //go:embed statements/apc1_materialized_view.sql
 var APC1MaterializedViewQuery string
 
+// SessionCookie extracts the value of the first cookie whose name starts with
+// "session" from the headers of a request, the c_session of the APC1
+// materialized view
+const SessionCookie = `TRY(TRANSFORM(FILTER(SPLIT(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'cookie'), header -> header.value))[1]), ';'), kv -> SUBSTR(TRIM(LOWER(kv)), 1, 7) = 'session'), kv -> SPLIT(TRIM(kv), '=')[2])[1])`
+
 func CreateAPC1MaterializedView(scope Scope) (string, error) {
 	tpl, err := template.New("query").Parse(APC1MaterializedViewQuery)
 	if err != nil {
@@ -17,17 +22,19 @@ func CreateAPC1MaterializedView(scope Scope) (string, error) {
 	}
 
 	data := struct {
-		WAF      string
-		WafTable string
-		Year     string
-		Month    string
-		Day      string
+		WAF           string
+		WafTable      string
+		Year          string
+		Month         string
+		Day           string
+		SessionCookie string
 	}{
-		WAF:      fmt.Sprintf("%s", scope.Waf),
-		WafTable: getTable(scope.Waf),
-		Year:     fmt.Sprintf("%d", scope.Year),
-		Month:    fmt.Sprintf("%02d", scope.Month),
-		Day:      fmt.Sprintf("%02d", scope.Day),
+		WAF:           fmt.Sprintf("%s", scope.Waf),
+		WafTable:      Table(scope.Waf),
+		Year:          fmt.Sprintf("%d", scope.Year),
+		Month:         fmt.Sprintf("%02d", scope.Month),
+		Day:           fmt.Sprintf("%02d", scope.Day),
+		SessionCookie: SessionCookie,
 	}
 
 	var out bytes.Buffer
@@ -37,3 +44,8 @@ func CreateAPC1MaterializedView(scope Scope) (string, error) {
 
 	return out.String(), nil
 }
+
+// APC1MaterializedViewName returns the name of the table created by CreateAPC1MaterializedView
+func APC1MaterializedViewName(scope Scope) string {
+	return fmt.Sprintf("waflog_%s_%04d_%02d_%02d", scope.Waf, scope.Year, scope.Month, scope.Day)
+}
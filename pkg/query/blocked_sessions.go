@@ -0,0 +1,56 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+//go:embed statements/blocked_sessions.sql
+var BlockedSessionsQuery string
+
+// DefaultCartPattern matches the URIs of the cart and the checkout
+const DefaultCartPattern = `(?i)/(warenkorb|cart|checkout|kasse|bestellung)`
+
+// GetBlockedSessions returns the blocks of browser sessions that made at
+// least minAllowed allowed requests before their first block, per rule and
+// URI pattern, with up to examples request ids each. Sessions that visited
+// a URI matching cartPattern, a regular expression, are counted separately
+// as they hurt customers most.
+func GetBlockedSessions(scope Scope, minAllowed int, cartPattern string, examples int, limit int) (string, error) {
+	tpl, err := template.New("query").Parse(BlockedSessionsQuery)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable      string
+		Year          string
+		Month         string
+		Day           string
+		SessionCookie string
+		MinAllowed    string
+		CartPattern   string
+		Examples      string
+		Limit         string
+	}{
+		WafTable:      Table(scope.Waf),
+		Year:          fmt.Sprintf("%d", scope.Year),
+		Month:         fmt.Sprintf("%02d", scope.Month),
+		Day:           fmt.Sprintf("%02d", scope.Day),
+		SessionCookie: SessionCookie,
+		MinAllowed:    fmt.Sprintf("%d", minAllowed),
+		CartPattern:   strings.ReplaceAll(cartPattern, "'", "''"),
+		Examples:      fmt.Sprintf("%d", examples),
+		Limit:         fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,87 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+//go:embed statements/bot_traffic.sql
+var BotTrafficQuery string
+
+//go:embed statements/spoofed_bots.sql
+var SpoofedBotsQuery string
+
+// Crawler is a search engine crawler whose User-Agent impostors copy
+type Crawler struct {
+	Name    string // of the crawler
+	Pattern string // regular expression of the User-Agents it sends
+}
+
+// SearchEngineCrawlers are the crawlers Bot Control verifies by their IPs
+// and reverse DNS
+var SearchEngineCrawlers = []Crawler{
+	{Name: "googlebot", Pattern: `(?i)googlebot|google-inspectiontool|adsbot-google|mediapartners-google|storebot-google`},
+	{Name: "bingbot", Pattern: `(?i)bingbot|adidxbot|bingpreview|msnbot`},
+	{Name: "applebot", Pattern: `(?i)applebot`},
+	{Name: "duckduckbot", Pattern: `(?i)duckduckbot`},
+	{Name: "yandexbot", Pattern: `(?i)yandexbot`},
+	{Name: "baiduspider", Pattern: `(?i)baiduspider`},
+}
+
+// DefaultCrawlerRules are the rules allowing the search engine crawlers
+var DefaultCrawlerRules = []string{"seo-crawler", "seo-crawler-vpn"}
+
+// GetBotTraffic returns the requests per bot category, bot name and verified
+// status of the Bot Control labels, with their IPs, blocks and terminating
+// rules
+func GetBotTraffic(scope Scope, limit int) (string, error) {
+	return renderBotControl(BotTrafficQuery, scope, nil, limit)
+}
+
+// GetSpoofedBots returns the IPs whose User-Agent claims to be one of the
+// search engine crawlers without the verified label of Bot Control, with the
+// requests allowed by one of the crawlerRules, the rules meant for the real
+// crawlers
+func GetSpoofedBots(scope Scope, crawlerRules []string, limit int) (string, error) {
+	return renderBotControl(SpoofedBotsQuery, scope, crawlerRules, limit)
+}
+
+func renderBotControl(text string, scope Scope, crawlerRules []string, limit int) (string, error) {
+	tpl, err := template.New("query").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var rules []string
+	for _, r := range crawlerRules {
+		rules = append(rules, "'"+strings.ReplaceAll(r, "'", "''")+"'")
+	}
+
+	data := struct {
+		WafTable     string
+		Year         string
+		Month        string
+		Day          string
+		Crawlers     []Crawler
+		CrawlerRules string
+		Limit        string
+	}{
+		WafTable:     Table(scope.Waf),
+		Year:         fmt.Sprintf("%d", scope.Year),
+		Month:        fmt.Sprintf("%02d", scope.Month),
+		Day:          fmt.Sprintf("%02d", scope.Day),
+		Crawlers:     SearchEngineCrawlers,
+		CrawlerRules: strings.Join(rules, ", "),
+		Limit:        fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,80 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"text/template"
+)
+
+// challengeCTEs defines the common table expressions the challenge queries
+// share: outcomes, the responses to CAPTCHAs and challenges, and latencies,
+// the seconds from the puzzle to its solve
+//
+//go:embed statements/challenge_ctes.sql
+var challengeCTEs string
+
+//go:embed statements/challenge_outcomes.sql
+var ChallengeOutcomesQuery string
+
+//go:embed statements/challenge_solve_latency.sql
+var ChallengeSolveLatencyQuery string
+
+//go:embed statements/challenge_solvers.sql
+var ChallengeSolversQuery string
+
+// GetChallengeOutcomes returns the CAPTCHAs and challenges issued, solved
+// and failed per rule, country, IP and User-Agent, the limit with most
+// requests per dimension and kind
+func GetChallengeOutcomes(scope Scope, limit int) (string, error) {
+	return renderChallenges(ChallengeOutcomesQuery, scope, 0, 0, limit)
+}
+
+// GetChallengeSolveLatency returns the solves of CAPTCHAs and challenges per
+// seconds from the puzzle sent to the solve
+func GetChallengeSolveLatency(scope Scope) (string, error) {
+	return renderChallenges(ChallengeSolveLatencyQuery, scope, 0, 0, 0)
+}
+
+// GetChallengeSolvers returns the IPs and User-Agents solving at least
+// minSolves CAPTCHAs or challenges, solving CAPTCHAs within fastSeconds or
+// passing with tokens of puzzles not sent to them, the pattern of CAPTCHA
+// farms
+func GetChallengeSolvers(scope Scope, minSolves int, fastSeconds int, limit int) (string, error) {
+	return renderChallenges(ChallengeSolversQuery, scope, minSolves, fastSeconds, limit)
+}
+
+func renderChallenges(text string, scope Scope, minSolves int, fastSeconds int, limit int) (string, error) {
+	tpl, err := template.New("query").Parse(challengeCTEs)
+	if err != nil {
+		return "", err
+	}
+	if tpl, err = tpl.Parse(text); err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable    string
+		Year        string
+		Month       string
+		Day         string
+		MinSolves   string
+		FastSeconds string
+		Limit       string
+	}{
+		WafTable:    Table(scope.Waf),
+		Year:        fmt.Sprintf("%d", scope.Year),
+		Month:       fmt.Sprintf("%02d", scope.Month),
+		Day:         fmt.Sprintf("%02d", scope.Day),
+		MinSolves:   fmt.Sprintf("%d", minSolves),
+		FastSeconds: fmt.Sprintf("%d", fastSeconds),
+		Limit:       fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,30 @@
+package query
+
+import "strings"
+
+// Column describes a column of a query result by its Athena type, e.g.
+// "bigint", "varchar", "timestamp with time zone" or "array(varchar)"
+type Column struct {
+	Name string
+	Type string
+}
+
+// BaseType returns the type without parameters in lower case, e.g. "array"
+// for "array(varchar)" or "timestamp" for "timestamp(3) with time zone"
+func (c Column) BaseType() string {
+	t := strings.ToLower(strings.TrimSpace(c.Type))
+	if i := strings.IndexAny(t, "( "); i >= 0 {
+		t = t[:i]
+	}
+	return t
+}
+
+// ElementType returns the type of the elements of array columns, varchar if
+// unknown, as Athena only reports "array" in the metadata of results
+func (c Column) ElementType() string {
+	t := strings.ToLower(strings.TrimSpace(c.Type))
+	if !strings.HasPrefix(t, "array(") || !strings.HasSuffix(t, ")") {
+		return "varchar"
+	}
+	return t[len("array(") : len(t)-1]
+}
@@ -10,10 +10,17 @@ import (
 type IdentityColumns string
 
 const (
-	IdentityColumnsIP        IdentityColumns = "client_ip, country"
-	IdentityColumnsUserAgent IdentityColumns = "bot_name, bot_category, user_agent"
+	IdentityColumnsIP          IdentityColumns = "client_ip, country"
+	IdentityColumnsForwardedIP IdentityColumns = "forwarded_ip, country"
+	IdentityColumnsUserAgent   IdentityColumns = "bot_name, bot_category, user_agent"
+	IdentityColumnsCustomKey   IdentityColumns = "custom_key"
 )
 
+// Columns returns the names of the columns, e.g. [client_ip country]
+func (c IdentityColumns) Columns() []string {
+	return strings.Split(string(c), ", ")
+}
+
 // ###########################
 
 type WAF int
@@ -34,7 +41,8 @@ func (w WAF) String() string {
 	}
 }
 
-func getTable(waf WAF) string {
+// Table returns the fully qualified name of the raw log table of the WAF
+func Table(waf WAF) string {
 	switch waf {
 	case WafBC:
 		return "\"waflogs\".\"waf_logs_p\""
@@ -47,6 +55,34 @@ func getTable(waf WAF) string {
 
 // ###########################
 
+// Window describes how requests are aggregated when computing request rates.
+// AWS WAF rate-based rules evaluate sliding windows of 1, 2, 5 or 10 minutes.
+type Window struct {
+	Minutes int
+	Sliding bool // sliding windows evaluated per minute instead of tumbling windows
+}
+
+var DefaultWindow = Window{Minutes: 5}
+
+// Validate returns an error if the window size isn't supported by AWS WAF
+func (w Window) Validate() error {
+	switch w.Minutes {
+	case 1, 2, 5, 10:
+		return nil
+	default:
+		return fmt.Errorf("window of %d minutes unsupported, must be one of 1, 2, 5, 10", w.Minutes)
+	}
+}
+
+func (w Window) String() string {
+	if w.Sliding {
+		return fmt.Sprintf("%dm sliding", w.Minutes)
+	}
+	return fmt.Sprintf("%dm", w.Minutes)
+}
+
+// ###########################
+
 type TerminatingRule int
 
 const (
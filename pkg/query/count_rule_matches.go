@@ -0,0 +1,84 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"text/template"
+)
+
+//go:embed statements/count_rule_matches.sql
+var CountRuleMatchesQuery string
+
+//go:embed statements/count_rule_top.sql
+var CountRuleTopQuery string
+
+// GetCountRuleMatches summarises the requests matched by each rule in COUNT mode,
+// including how many of them were allowed by the default action afterwards
+func GetCountRuleMatches(scope Scope, limit int) (string, error) {
+	tpl, err := template.New("query").Parse(CountRuleMatchesQuery)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable string
+		Year     string
+		Month    string
+		Day      string
+		Limit    string
+	}{
+		WafTable: Table(scope.Waf),
+		Year:     fmt.Sprintf("%d", scope.Year),
+		Month:    fmt.Sprintf("%02d", scope.Month),
+		Day:      fmt.Sprintf("%02d", scope.Day),
+		Limit:    fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// GetCountRuleTopIdentities returns the identities with most requests per rule in COUNT mode
+func GetCountRuleTopIdentities(scope Scope, identityCols IdentityColumns, limitPerRule int) (string, error) {
+	return getCountRuleTop(scope, string(identityCols), limitPerRule)
+}
+
+// GetCountRuleTopURIs returns the URIs with most requests per rule in COUNT mode
+func GetCountRuleTopURIs(scope Scope, limitPerRule int) (string, error) {
+	return getCountRuleTop(scope, "uri", limitPerRule)
+}
+
+func getCountRuleTop(scope Scope, columns string, limitPerRule int) (string, error) {
+	tpl, err := template.New("query").Parse(CountRuleTopQuery)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable     string
+		Year         string
+		Month        string
+		Day          string
+		Columns      string
+		LimitPerRule string
+	}{
+		WafTable:     Table(scope.Waf),
+		Year:         fmt.Sprintf("%d", scope.Year),
+		Month:        fmt.Sprintf("%02d", scope.Month),
+		Day:          fmt.Sprintf("%02d", scope.Day),
+		Columns:      columns,
+		LimitPerRule: fmt.Sprintf("%d", limitPerRule),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,142 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+//go:embed statements/login_daily_traffic.sql
+var LoginDailyTrafficQuery string
+
+//go:embed statements/login_clusters.sql
+var LoginClustersQuery string
+
+//go:embed statements/login_low_and_slow.sql
+var LoginLowAndSlowQuery string
+
+// DefaultLoginPattern matches the URIs of the login, registration and
+// account endpoints
+const DefaultLoginPattern = `(?i)^/(login|anmelden|logout|register|registrierung|account|mein-konto|kundenkonto|password|passwort)`
+
+// GetLoginDailyTraffic returns the requests, POST requests, blocks and ATP
+// labels per day on the URIs matching pathPattern, a regular expression, of
+// the days up to and including the day of the scope
+func GetLoginDailyTraffic(scope Scope, days int, pathPattern string) (string, error) {
+	if days < 1 {
+		return "", fmt.Errorf("number of days must be positive, got %d", days)
+	}
+
+	tpl, err := template.New("query").Parse(LoginDailyTrafficQuery)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := scope.LastDays(days)
+
+	data := struct {
+		WafTable    string
+		FirstDay    string
+		LastDay     string
+		PathPattern string
+	}{
+		WafTable:    Table(scope.Waf),
+		FirstDay:    scopes[0].Partition(),
+		LastDay:     scopes[len(scopes)-1].Partition(),
+		PathPattern: strings.ReplaceAll(pathPattern, "'", "''"),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// GetLoginClusters returns the POST requests to the URIs matching
+// pathPattern per IP, network prefix, session and TLS fingerprint with at
+// least minPosts requests, with their peak per window, their IPs, User-Agents
+// and ATP labels. It returns the limit clusters with most requests per
+// dimension.
+func GetLoginClusters(scope Scope, pathPattern string, window Window, minPosts int, limit int) (string, error) {
+	if err := window.Validate(); err != nil {
+		return "", err
+	}
+
+	tpl, err := template.New("query").Parse(LoginClustersQuery)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable      string
+		Year          string
+		Month         string
+		Day           string
+		SessionCookie string
+		PathPattern   string
+		WindowMinutes string
+		MinPosts      string
+		Limit         string
+	}{
+		WafTable:      Table(scope.Waf),
+		Year:          fmt.Sprintf("%d", scope.Year),
+		Month:         fmt.Sprintf("%02d", scope.Month),
+		Day:           fmt.Sprintf("%02d", scope.Day),
+		SessionCookie: SessionCookie,
+		PathPattern:   strings.ReplaceAll(pathPattern, "'", "''"),
+		WindowMinutes: fmt.Sprintf("%d", window.Minutes),
+		MinPosts:      fmt.Sprintf("%d", minPosts),
+		Limit:         fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// GetLowAndSlowLogins returns the User-Agents and TLS fingerprints shared by
+// at least minIPs IPs sending POST requests to the URIs matching pathPattern,
+// none of them more than maxPostsPerIP, the pattern of distributed credential
+// stuffing staying below rate limits
+func GetLowAndSlowLogins(scope Scope, pathPattern string, minIPs int, maxPostsPerIP int, limit int) (string, error) {
+	tpl, err := template.New("query").Parse(LoginLowAndSlowQuery)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable      string
+		Year          string
+		Month         string
+		Day           string
+		SessionCookie string
+		PathPattern   string
+		MinIPs        string
+		MaxPostsPerIP string
+		Limit         string
+	}{
+		WafTable:      Table(scope.Waf),
+		Year:          fmt.Sprintf("%d", scope.Year),
+		Month:         fmt.Sprintf("%02d", scope.Month),
+		Day:           fmt.Sprintf("%02d", scope.Day),
+		SessionCookie: SessionCookie,
+		PathPattern:   strings.ReplaceAll(pathPattern, "'", "''"),
+		MinIPs:        fmt.Sprintf("%d", minIPs),
+		MaxPostsPerIP: fmt.Sprintf("%d", maxPostsPerIP),
+		Limit:         fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
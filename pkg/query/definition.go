@@ -0,0 +1,44 @@
+package query
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// RenderDefinition renders the template of a step of a report defined in
+// YAML. Besides the scope, the template gets the thresholds and parameters of
+// the step as .Params and the tables created by earlier steps as .Tables, by
+// step name with underscores for dashes. Referring to a missing parameter or
+// table is an error.
+func RenderDefinition(text string, scope Scope, params map[string]string, tables map[string]string) (string, error) {
+	tpl, err := template.New("query").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WAF      string
+		WafTable string
+		Year     string
+		Month    string
+		Day      string
+		Params   map[string]string
+		Tables   map[string]string
+	}{
+		WAF:      scope.Waf.String(),
+		WafTable: Table(scope.Waf),
+		Year:     fmt.Sprintf("%d", scope.Year),
+		Month:    fmt.Sprintf("%02d", scope.Month),
+		Day:      fmt.Sprintf("%02d", scope.Day),
+		Params:   params,
+		Tables:   tables,
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
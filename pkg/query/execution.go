@@ -0,0 +1,8 @@
+package query
+
+// Execution describes how the result of a query was obtained
+type Execution struct {
+	ID           string   // query execution ID of Athena, empty for other engines
+	BytesScanned int64    // bytes scanned by Athena, 0 for other engines
+	Columns      []Column // columns of the result, nil if unknown
+}
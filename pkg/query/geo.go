@@ -0,0 +1,95 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"text/template"
+)
+
+//go:embed statements/geo_country_hourly.sql
+var GeoCountryHourlyQuery string
+
+//go:embed statements/geo_country_shares.sql
+var GeoCountrySharesQuery string
+
+// GetGeoCountryHourly returns the number of requests, blocks and rate limit
+// blocks per country, day and hour of the days up to and including the day
+// of the scope
+func GetGeoCountryHourly(scope Scope, days int) (string, error) {
+	if days < 1 {
+		return "", fmt.Errorf("number of days must be positive, got %d", days)
+	}
+
+	tpl, err := template.New("query").Parse(GeoCountryHourlyQuery)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := scope.LastDays(days)
+
+	data := struct {
+		WafTable      string
+		FirstDay      string
+		LastDay       string
+		RateLimitRule string
+	}{
+		WafTable:      Table(scope.Waf),
+		FirstDay:      scopes[0].Partition(),
+		LastDay:       scopes[len(scopes)-1].Partition(),
+		RateLimitRule: TerminatingRuleRateLimit.String(),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// GetGeoCountryShares compares the share of the requests of each country on
+// the day of the scope to its average share during the days with requests of
+// the baselineDays before. Countries are flagged as new without requests
+// during the baseline, as surge if their share grew by at least factor and as
+// drop if it shrank by it. Countries with fewer than minRequests requests,
+// actual or expected, aren't flagged.
+func GetGeoCountryShares(scope Scope, baselineDays int, factor float64, minRequests int) (string, error) {
+	if baselineDays < 1 {
+		return "", fmt.Errorf("number of baseline days must be positive, got %d", baselineDays)
+	}
+	if factor <= 1 {
+		return "", fmt.Errorf("share factor must be greater than 1, got %g", factor)
+	}
+
+	tpl, err := template.New("query").Parse(GeoCountrySharesQuery)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := scope.LastDays(baselineDays + 1)
+
+	data := struct {
+		WafTable      string
+		FirstDay      string
+		LastDay       string
+		RateLimitRule string
+		ShareFactor   string
+		MinRequests   string
+	}{
+		WafTable:      Table(scope.Waf),
+		FirstDay:      scopes[0].Partition(),
+		LastDay:       scopes[len(scopes)-1].Partition(),
+		RateLimitRule: TerminatingRuleRateLimit.String(),
+		ShareFactor:   strconv.FormatFloat(factor, 'f', -1, 64),
+		MinRequests:   fmt.Sprintf("%d", minRequests),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -4,36 +4,74 @@ import (
 	"bytes"
 	_ "embed"
 	"fmt"
+	"strings"
 	"text/template"
 )
 
 //go:embed statements/get_fastest_identities.sql
 var GetFastestIdentitiesQuery string
 
-func GetFastestIdentities(scope Scope, identityCols IdentityColumns, minRate int, customWhereClause string, limit int) (string, error) {
+func GetFastestIdentities(scope Scope, identityCols IdentityColumns, window Window, labels LabelFilter, minRate int, customWhereClause string, limit int) (string, error) {
+	return renderFastestIdentities(scope, 1, identityCols, "", window, labels, minRate, customWhereClause, "", limit)
+}
+
+// renderFastestIdentities renders the windows of the days up to and including
+// the day of the scope in which identities made more than minRate requests.
+// customKey is the SQL expression of the custom_key column, if any. If
+// knownGood, the SQL predicate of requests of known good clients, is set,
+// the windows also count the requests past minRate a rate limit of minRate
+// would block, and of them the ones already blocked and the ones of known
+// good clients. A limit of 0
+// returns all windows, unordered.
+func renderFastestIdentities(scope Scope, days int, identityCols IdentityColumns, customKey string, window Window, labels LabelFilter, minRate int, customWhereClause string, knownGood string, limit int) (string, error) {
+	if err := window.Validate(); err != nil {
+		return "", err
+	}
+	if days < 1 {
+		return "", fmt.Errorf("number of days must be positive, got %d", days)
+	}
+
 	tpl, err := template.New("query").Parse(GetFastestIdentitiesQuery)
 	if err != nil {
 		return "", err
 	}
 
+	dayPredicate := fmt.Sprintf("day = '%s'", scope.Partition())
+	if days > 1 {
+		scopes := scope.LastDays(days)
+		dayPredicate = fmt.Sprintf("day BETWEEN '%s' AND '%s'", scopes[0].Partition(), scope.Partition())
+	}
+
 	data := struct {
 		WafTable          string
-		Year              string
-		Month             string
-		Day               string
+		DayPredicate      string
 		IdentityCols      IdentityColumns
+		ForwardedIP       bool
+		CustomKey         string
+		KnownGood         string
+		LabelPredicate    string
+		WindowMinutes     string
+		WindowOffset      string
+		Sliding           bool
 		MinRate           string
 		CustomWhereClause string
 		Limit             string
 	}{
-		WafTable:          getTable(scope.Waf),
-		Year:              fmt.Sprintf("%d", scope.Year),
-		Month:             fmt.Sprintf("%02d", scope.Month),
-		Day:               fmt.Sprintf("%02d", scope.Day),
+		WafTable:          Table(scope.Waf),
+		DayPredicate:      dayPredicate,
 		IdentityCols:      identityCols,
+		ForwardedIP:       strings.Contains(string(identityCols), "forwarded_ip"),
+		CustomKey:         customKey,
+		KnownGood:         knownGood,
+		LabelPredicate:    labels.Predicate(),
+		WindowMinutes:     fmt.Sprintf("%d", window.Minutes),
+		WindowOffset:      fmt.Sprintf("%d", window.Minutes-1),
+		Sliding:           window.Sliding,
 		MinRate:           fmt.Sprintf("%d", minRate),
 		CustomWhereClause: customWhereClause,
-		Limit:             fmt.Sprintf("%d", limit),
+	}
+	if limit > 0 {
+		data.Limit = fmt.Sprintf("%d", limit)
 	}
 
 	var out bytes.Buffer
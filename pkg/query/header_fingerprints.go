@@ -0,0 +1,92 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// headerCTEs defines the common table expressions the header queries share:
+// checked, the requests claiming a browser with the anomalies of their
+// headers
+//
+//go:embed statements/header_ctes.sql
+var headerCTEs string
+
+//go:embed statements/header_fingerprints.sql
+var HeaderFingerprintsQuery string
+
+//go:embed statements/header_anomalies.sql
+var HeaderAnomaliesQuery string
+
+// VolatileHeaders are left out of the header order of a fingerprint, since
+// the same browser sends them or not depending on the request
+var VolatileHeaders = []string{
+	"cookie",
+	"referer",
+	"origin",
+	"content-length",
+	"content-type",
+	"cache-control",
+	"pragma",
+	"if-none-match",
+	"if-modified-since",
+	"x-forwarded-for",
+	"x-requested-with",
+	"via",
+}
+
+// GetHeaderFingerprints returns the fingerprints of the requests whose
+// User-Agent claims a browser: the order of their headers, the browser and
+// the anomalies of their headers compared to the browser claimed, e.g. a
+// Chrome without sec-ch-ua, with their IPs and the requests Bot Control did
+// not find to come from a non-browser User-Agent
+func GetHeaderFingerprints(scope Scope, limit int) (string, error) {
+	return renderHeaders(HeaderFingerprintsQuery, scope, limit)
+}
+
+// GetHeaderAnomalies returns the IPs and User-Agents claiming a browser with
+// headers no browser sends, those Bot Control missed first
+func GetHeaderAnomalies(scope Scope, limit int) (string, error) {
+	return renderHeaders(HeaderAnomaliesQuery, scope, limit)
+}
+
+func renderHeaders(text string, scope Scope, limit int) (string, error) {
+	tpl, err := template.New("query").Parse(headerCTEs)
+	if err != nil {
+		return "", err
+	}
+	if tpl, err = tpl.Parse(text); err != nil {
+		return "", err
+	}
+
+	var volatile []string
+	for _, h := range VolatileHeaders {
+		volatile = append(volatile, "'"+strings.ReplaceAll(h, "'", "''")+"'")
+	}
+
+	data := struct {
+		WafTable        string
+		Year            string
+		Month           string
+		Day             string
+		VolatileHeaders string
+		Limit           string
+	}{
+		WafTable:        Table(scope.Waf),
+		Year:            fmt.Sprintf("%d", scope.Year),
+		Month:           fmt.Sprintf("%02d", scope.Month),
+		Day:             fmt.Sprintf("%02d", scope.Day),
+		VolatileHeaders: strings.Join(volatile, ", "),
+		Limit:           fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,103 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"text/template"
+)
+
+//go:embed statements/label_counts.sql
+var LabelCountsQuery string
+
+//go:embed statements/label_prefix_counts.sql
+var LabelPrefixCountsQuery string
+
+//go:embed statements/label_cooccurrence.sql
+var LabelCooccurrenceQuery string
+
+//go:embed statements/label_trends.sql
+var LabelTrendsQuery string
+
+// GetLabelCounts returns the number of requests per label
+func GetLabelCounts(scope Scope, labels LabelFilter, limit int) (string, error) {
+	return getLabelQuery(LabelCountsQuery, scope, labels, limit)
+}
+
+// GetLabelPrefixCounts returns the number of requests per level of the label
+// hierarchy, e.g. awswaf:managed:aws:bot-control, awswaf:managed:aws:bot-control:bot, ...
+func GetLabelPrefixCounts(scope Scope, labels LabelFilter, limit int) (string, error) {
+	return getLabelQuery(LabelPrefixCountsQuery, scope, labels, limit)
+}
+
+// GetLabelCooccurrence returns the number of requests per pair of labels
+// added to the same request
+func GetLabelCooccurrence(scope Scope, labels LabelFilter, limit int) (string, error) {
+	return getLabelQuery(LabelCooccurrenceQuery, scope, labels, limit)
+}
+
+func getLabelQuery(statement string, scope Scope, labels LabelFilter, limit int) (string, error) {
+	tpl, err := template.New("query").Parse(statement)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable     string
+		Year         string
+		Month        string
+		Day          string
+		LabelMatches string
+		Limit        string
+	}{
+		WafTable:     Table(scope.Waf),
+		Year:         fmt.Sprintf("%d", scope.Year),
+		Month:        fmt.Sprintf("%02d", scope.Month),
+		Day:          fmt.Sprintf("%02d", scope.Day),
+		LabelMatches: labels.Matches("label"),
+		Limit:        fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// GetLabelTrends returns the number of requests per day of the limit most
+// frequent labels during the days up to and including the day of the scope
+func GetLabelTrends(scope Scope, days int, labels LabelFilter, limit int) (string, error) {
+	if days < 1 {
+		return "", fmt.Errorf("number of days must be positive, got %d", days)
+	}
+
+	tpl, err := template.New("query").Parse(LabelTrendsQuery)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := scope.LastDays(days)
+
+	data := struct {
+		WafTable     string
+		FirstDay     string
+		LastDay      string
+		LabelMatches string
+		Limit        string
+	}{
+		WafTable:     Table(scope.Waf),
+		FirstDay:     scopes[0].Partition(),
+		LastDay:      scopes[len(scopes)-1].Partition(),
+		LabelMatches: labels.Matches("label"),
+		Limit:        fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
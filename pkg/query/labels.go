@@ -0,0 +1,110 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LabelNamespaces maps short names to the prefixes of the labels added by
+// AWS managed rule groups
+var LabelNamespaces = map[string]string{
+	"bot-control":   "awswaf:managed:aws:bot-control:",
+	"atp":           "awswaf:managed:aws:atp:",
+	"acfp":          "awswaf:managed:aws:acfp:",
+	"anonymous-ip":  "awswaf:managed:aws:anonymous-ip-list:",
+	"ip-reputation": "awswaf:managed:aws:amazon-ip-list:",
+}
+
+// LabelNamespaceCustom selects the labels added by our own rules, i.e. all
+// labels not in the awswaf:managed: namespace
+const LabelNamespaceCustom = "custom"
+
+const managedLabelPrefix = "awswaf:managed:"
+
+// LabelFilter selects labels by namespace or name prefix. An empty filter
+// selects all labels.
+type LabelFilter []string
+
+// ParseLabelFilter accepts the names of LabelNamespaces, LabelNamespaceCustom
+// and raw label prefixes, e.g. "awswaf:managed:aws:bot-control:signal:"
+func ParseLabelFilter(values []string) (LabelFilter, error) {
+	var out LabelFilter
+	for _, v := range values {
+		if v == LabelNamespaceCustom {
+			out = append(out, v)
+			continue
+		}
+
+		if prefix, ok := LabelNamespaces[v]; ok {
+			out = append(out, prefix)
+			continue
+		}
+
+		if !strings.HasPrefix(v, "awswaf:") {
+			return nil, fmt.Errorf("label %s unknown, must be a label prefix starting with 'awswaf:' or one of %s", v, strings.Join(labelNamespaceNames(), ", "))
+		}
+		out = append(out, v)
+	}
+
+	return out, nil
+}
+
+// Matches returns an SQL predicate on the label name expression col, e.g. "label.name"
+func (f LabelFilter) Matches(col string) string {
+	if len(f) == 0 {
+		return "TRUE"
+	}
+
+	var preds []string
+	for _, prefix := range f {
+		if prefix == LabelNamespaceCustom {
+			preds = append(preds, fmt.Sprintf("NOT STARTS_WITH(%s, '%s')", col, managedLabelPrefix))
+			continue
+		}
+		preds = append(preds, fmt.Sprintf("STARTS_WITH(%s, '%s')", col, strings.ReplaceAll(prefix, "'", "''")))
+	}
+
+	return "(" + strings.Join(preds, " OR ") + ")"
+}
+
+// MatchesName is the Go equivalent of Matches for a single label name
+func (f LabelFilter) MatchesName(name string) bool {
+	if len(f) == 0 {
+		return true
+	}
+
+	for _, prefix := range f {
+		if prefix == LabelNamespaceCustom {
+			if !strings.HasPrefix(name, managedLabelPrefix) {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Predicate returns an SQL predicate selecting requests carrying at least one
+// of the labels of the filter, or an empty string if the filter is empty
+func (f LabelFilter) Predicate() string {
+	if len(f) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("ANY_MATCH(labels, label -> %s)", f.Matches("label.name"))
+}
+
+func labelNamespaceNames() []string {
+	out := []string{LabelNamespaceCustom}
+	for name := range LabelNamespaces {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+
+	return out
+}
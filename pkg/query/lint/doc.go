@@ -0,0 +1,5 @@
+// Package lint parses the rendered SQL templates of package query, kept as
+// golden files in pkg/query/testdata, with the ANTLR grammar of Trino, the
+// engine of Athena. It is a module of its own, since the parser needs a
+// newer Go than waflogs itself. Run its tests with `make test`.
+package lint
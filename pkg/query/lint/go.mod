@@ -0,0 +1,10 @@
+module kfzteile24/waflogs/pkg/query/lint
+
+go 1.24.5
+
+require (
+	github.com/antlr4-go/antlr/v4 v4.13.1
+	github.com/bytebase/parser v0.0.0-20251201062756-17b16190b32d
+)
+
+require golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842 // indirect
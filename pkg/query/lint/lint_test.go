@@ -0,0 +1,121 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/bytebase/parser/trino"
+)
+
+// syntaxErrors collects the syntax errors of the lexer and the parser
+type syntaxErrors struct {
+	*antlr.DefaultErrorListener
+	errs []string
+}
+
+func (l *syntaxErrors) SyntaxError(_ antlr.Recognizer, _ interface{}, line int, column int, msg string, _ antlr.RecognitionException) {
+	l.errs = append(l.errs, fmt.Sprintf("line %d:%d: %s", line, column, msg))
+}
+
+// lintSQL parses sql with the ANTLR grammar of Trino, the engine of Athena,
+// and returns an error if it isn't exactly one valid statement. It checks the
+// syntax only, not whether tables, columns or functions exist.
+func lintSQL(sql string) error {
+	if strings.Contains(sql, "<no value>") {
+		return fmt.Errorf("template rendered a missing value")
+	}
+
+	// the grammar ends statements with a semicolon, Athena doesn't need one
+	if !strings.HasSuffix(strings.TrimSpace(sql), ";") {
+		sql += "\n;"
+	}
+
+	errs := &syntaxErrors{DefaultErrorListener: antlr.NewDefaultErrorListener()}
+	lexer := trino.NewTrinoLexer(antlr.NewInputStream(sql))
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(errs)
+	parser := trino.NewTrinoParser(antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(errs)
+
+	tree := parser.Parse()
+	if len(errs.errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs.errs, ", "))
+	}
+
+	switch statements := tree.AllStatements(); {
+	case len(statements) != 1:
+		return fmt.Errorf("%d statements, want 1", len(statements))
+	case statements[0].SingleStatement() == nil:
+		return fmt.Errorf("not a statement")
+	}
+
+	return nil
+}
+
+func TestLintSQL(t *testing.T) {
+	valid := []string{
+		"SELECT a, b FROM t WHERE x IN (VALUES 'a', 'b') LIMIT 10;",
+		"WITH c AS (SELECT TRY(TRANSFORM(FILTER(l, x -> x.name = 'it''s'), x -> x.value)[1]) AS \"v\" FROM t) SELECT * FROM c",
+		"SELECT 1 /* comment with 'quote */ FROM t -- trailing",
+		"CREATE TABLE t WITH (format = 'PARQUET') AS SELECT * FROM s CROSS JOIN UNNEST(labels) WITH ORDINALITY AS a(label, pos)",
+		"SELECT ip_prefix(CAST(ip AS IPADDRESS), 24) FROM t",
+	}
+	for _, sql := range valid {
+		if err := lintSQL(sql); err != nil {
+			t.Errorf("%q: unexpected error: %s", sql, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"FROM t",
+		"SELECT a, FROM t",
+		"SELECT a FROM t WHERE",
+		"SELECT a FROM t WHERE x = 'open",
+		"SELECT (a FROM t",
+		"SELECT a) FROM t",
+		"SELECT a[1) FROM t",
+		"SELECT a FROM t LIMIT <no value>",
+		"SELECT a FROM t WHERE x IN (VALUES )",
+		"SELECT a FROM t; SELECT b FROM t",
+		"SELECT a FROM t WHERE x AND AND y",
+		"SELECT {{.Col}} FROM t",
+		"SELECT a /* unterminated FROM t",
+		"SELECT a FROM t GROUP a",
+		"SELECT a FROM t ORDER BY a DESC LIMIT",
+		"SELECT CASE WHEN a THEN 1 FROM t",
+		"1 + 1",
+	}
+	for _, sql := range invalid {
+		if err := lintSQL(sql); err == nil {
+			t.Errorf("%q: expected error", sql)
+		}
+	}
+}
+
+// TestGoldenFiles parses the rendered templates, which TestTemplates of
+// package query keeps in sync with the golden files
+func TestGoldenFiles(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "testdata", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no golden files")
+	}
+
+	for _, path := range paths {
+		sql, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := lintSQL(string(sql)); err != nil {
+			t.Errorf("%s: invalid sql: %s", filepath.Base(path), err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"text/template"
+)
+
+//go:embed statements/managed_rule_matches.sql
+var ManagedRuleMatchesQuery string
+
+//go:embed statements/managed_rule_top.sql
+var ManagedRuleTopQuery string
+
+//go:embed statements/managed_rule_samples.sql
+var ManagedRuleSamplesQuery string
+
+// GetManagedRuleMatches summarises the requests blocked or counted by each
+// rule of the managed rule groups, ranked by how likely the matches are
+// false positives
+func GetManagedRuleMatches(scope Scope, limit int) (string, error) {
+	tpl, err := template.New("query").Parse(ManagedRuleMatchesQuery)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable string
+		Year     string
+		Month    string
+		Day      string
+		Limit    string
+	}{
+		WafTable: Table(scope.Waf),
+		Year:     fmt.Sprintf("%d", scope.Year),
+		Month:    fmt.Sprintf("%02d", scope.Month),
+		Day:      fmt.Sprintf("%02d", scope.Day),
+		Limit:    fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// managedRuleTopColumns are the columns of the requests the top values per
+// rule of the managed rule groups can be selected from
+var managedRuleTopColumns = map[string]bool{
+	"client_ip":    true,
+	"country":      true,
+	"uri":          true,
+	"args":         true,
+	"user_agent":   true,
+	"bot_name":     true,
+	"bot_category": true,
+}
+
+// GetManagedRuleTopIdentities returns the identities with most requests per
+// rule of the managed rule groups. Identities of forwarded IPs or custom keys
+// are unsupported.
+func GetManagedRuleTopIdentities(scope Scope, identityCols IdentityColumns, limitPerRule int) (string, error) {
+	for _, c := range identityCols.Columns() {
+		if !managedRuleTopColumns[c] {
+			return "", fmt.Errorf("identity column %s unsupported for managed rules", c)
+		}
+	}
+
+	return getManagedRuleTop(scope, string(identityCols), limitPerRule)
+}
+
+// GetManagedRuleTopURIs returns the URIs with most requests per rule of the
+// managed rule groups
+func GetManagedRuleTopURIs(scope Scope, limitPerRule int) (string, error) {
+	return getManagedRuleTop(scope, "uri", limitPerRule)
+}
+
+// GetManagedRuleTopArgs returns the query strings with most requests per
+// rule of the managed rule groups
+func GetManagedRuleTopArgs(scope Scope, limitPerRule int) (string, error) {
+	return getManagedRuleTop(scope, "args", limitPerRule)
+}
+
+func getManagedRuleTop(scope Scope, columns string, limitPerRule int) (string, error) {
+	tpl, err := template.New("query").Parse(ManagedRuleTopQuery)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable     string
+		Year         string
+		Month        string
+		Day          string
+		Columns      string
+		LimitPerRule string
+	}{
+		WafTable:     Table(scope.Waf),
+		Year:         fmt.Sprintf("%d", scope.Year),
+		Month:        fmt.Sprintf("%02d", scope.Month),
+		Day:          fmt.Sprintf("%02d", scope.Day),
+		Columns:      columns,
+		LimitPerRule: fmt.Sprintf("%d", limitPerRule),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// GetManagedRuleSamples returns a few requests per rule of the managed rule
+// groups with what the rule matched, if logged
+func GetManagedRuleSamples(scope Scope, samplesPerRule int) (string, error) {
+	tpl, err := template.New("query").Parse(ManagedRuleSamplesQuery)
+	if err != nil {
+		return "", err
+	}
+
+	data := struct {
+		WafTable       string
+		Year           string
+		Month          string
+		Day            string
+		SamplesPerRule string
+	}{
+		WafTable:       Table(scope.Waf),
+		Year:           fmt.Sprintf("%d", scope.Year),
+		Month:          fmt.Sprintf("%02d", scope.Month),
+		Day:            fmt.Sprintf("%02d", scope.Day),
+		SamplesPerRule: fmt.Sprintf("%d", samplesPerRule),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
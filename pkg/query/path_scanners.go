@@ -0,0 +1,156 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed statements/path_scanners.sql
+var PathScannersQuery string
+
+//go:embed statements/probed_paths.sql
+var ProbedPathsQuery string
+
+// Probe is a kind of path vulnerability scanners request and the shop never
+// serves, e.g. WordPress admin pages on a shop not running WordPress
+type Probe struct {
+	Name    string // of the kind of probe
+	Pattern string // regular expression of the URIs of the probe
+}
+
+// DefaultProbes are the paths scanners probe most, none of them served by
+// the shop
+var DefaultProbes = []Probe{
+	{Name: "wordpress", Pattern: `(?i)/(wp-admin|wp-login|wp-content|wp-includes|xmlrpc\.php)`},
+	{Name: "secrets", Pattern: `(?i)/\.(env|aws|ssh|npmrc|htpasswd|htaccess|DS_Store)|/(config|credentials|secrets|settings)\.(json|ya?ml|ini|xml)$`},
+	{Name: "vcs", Pattern: `(?i)/\.(git|svn|hg|bzr)(/|$)`},
+	{Name: "admin", Pattern: `(?i)/(phpmyadmin|pma|adminer|manager/html|actuator|server-status|solr|jenkins|console)(/|$)`},
+	{Name: "backup", Pattern: `(?i)\.(bak|old|orig|sql|tar|tgz|gz|zip|swp)$`},
+	{Name: "traversal", Pattern: `(?i)\.\./|%2e%2e|/etc/passwd|win\.ini`},
+	{Name: "php", Pattern: `(?i)\.php[0-9]?$`},
+	{Name: "cgi", Pattern: `(?i)/cgi-bin/|\.(cgi|asp|aspx|jsp)$`},
+}
+
+var probeName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// ParseProbe parses a probe given as <name>=<regular expression>
+func ParseProbe(s string) (Probe, error) {
+	name, pattern, ok := strings.Cut(s, "=")
+	if !ok {
+		return Probe{}, fmt.Errorf("probe %s must be <name>=<regular expression>", s)
+	}
+
+	p := Probe{Name: name, Pattern: pattern}
+	if err := p.Validate(); err != nil {
+		return Probe{}, err
+	}
+
+	return p, nil
+}
+
+// Validate checks the name and the regular expression of the probe
+func (p Probe) Validate() error {
+	if !probeName.MatchString(p.Name) {
+		return fmt.Errorf("probe name %q must be lower case letters, digits and dashes", p.Name)
+	}
+	if _, err := regexp.Compile(p.Pattern); err != nil || p.Pattern == "" {
+		return fmt.Errorf("probe %s: pattern %q invalid: %v", p.Name, p.Pattern, err)
+	}
+
+	return nil
+}
+
+func (p Probe) String() string {
+	return p.Name + "=" + p.Pattern
+}
+
+// ScannerThresholds select the identities of the path scanners report
+type ScannerThresholds struct {
+	MinProbeURIs  int     // distinct URIs matching a probe from which an identity probes
+	MinProbeRatio float64 // share of the requests of an identity matching a probe from which it is a scanner
+	MinURIs       int     // distinct URIs from which an identity hitting each of them once or twice enumerates paths
+}
+
+// DefaultScannerThresholds are the thresholds of the path scanners report
+var DefaultScannerThresholds = ScannerThresholds{
+	MinProbeURIs:  3,
+	MinProbeRatio: 0.5,
+	MinURIs:       500,
+}
+
+// GetPathScanners returns the IPs probing at least MinProbeURIs distinct URIs
+// matching one of the probes, or enumerating at least MinURIs distinct URIs,
+// with their distinct URIs, the share of their requests matching a probe and
+// the kinds of probes. It classifies them as scanner, if the share is at
+// least MinProbeRatio, as mixed, if their probes hide among regular requests,
+// or as enumerator.
+func GetPathScanners(scope Scope, probes []Probe, thresholds ScannerThresholds, limit int) (string, error) {
+	return renderProbes(PathScannersQuery, scope, probes, thresholds, limit)
+}
+
+// GetProbedPaths returns the URIs matching one of the probes with the IPs
+// requesting them and how many of their requests the WAF blocked
+func GetProbedPaths(scope Scope, probes []Probe, limit int) (string, error) {
+	return renderProbes(ProbedPathsQuery, scope, probes, ScannerThresholds{}, limit)
+}
+
+func renderProbes(text string, scope Scope, probes []Probe, thresholds ScannerThresholds, limit int) (string, error) {
+	if len(probes) == 0 {
+		return "", fmt.Errorf("no probes")
+	}
+	for _, p := range probes {
+		if err := p.Validate(); err != nil {
+			return "", err
+		}
+	}
+
+	tpl, err := template.New("query").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	type probe struct {
+		Name    string
+		Pattern string
+	}
+	var quoted []probe
+	for _, p := range probes {
+		quoted = append(quoted, probe{
+			Name:    p.Name,
+			Pattern: strings.ReplaceAll(p.Pattern, "'", "''"),
+		})
+	}
+
+	data := struct {
+		WafTable      string
+		Year          string
+		Month         string
+		Day           string
+		Probes        []probe
+		MinProbeURIs  string
+		MinProbeRatio string
+		MinURIs       string
+		Limit         string
+	}{
+		WafTable:      Table(scope.Waf),
+		Year:          fmt.Sprintf("%d", scope.Year),
+		Month:         fmt.Sprintf("%02d", scope.Month),
+		Day:           fmt.Sprintf("%02d", scope.Day),
+		Probes:        quoted,
+		MinProbeURIs:  fmt.Sprintf("%d", thresholds.MinProbeURIs),
+		MinProbeRatio: fmt.Sprintf("%g", thresholds.MinProbeRatio),
+		MinURIs:       fmt.Sprintf("%d", thresholds.MinURIs),
+		Limit:         fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
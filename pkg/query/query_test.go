@@ -0,0 +1,444 @@
+package query
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata/")
+
+var testScopes = map[string]Scope{
+	"bc":  {Waf: WafBC, Year: 2023, Month: 2, Day: 21},
+	"ecp": {Waf: WafECP, Year: 2023, Month: 12, Day: 1},
+}
+
+type templateCase struct {
+	name   string
+	render func(scope Scope) (string, error)
+}
+
+var templateCases = []templateCase{
+	{
+		name: "apc1_materialized_view",
+		render: func(scope Scope) (string, error) {
+			return CreateAPC1MaterializedView(scope)
+		},
+	},
+	{
+		name: "requests_blocked_by_ip",
+		render: func(scope Scope) (string, error) {
+			return GetRequestsBlockedBy(scope, IdentityColumnsIP, TerminatingRules{TerminatingRuleRateLimit}, nil, 1000)
+		},
+	},
+	{
+		name: "requests_blocked_by_user_agent",
+		render: func(scope Scope) (string, error) {
+			return GetRequestsBlockedBy(scope, IdentityColumnsUserAgent, TerminatingRules{TerminatingRuleRateLimit}, LabelFilter{LabelNamespaces["bot-control"]}, 1000)
+		},
+	},
+	{
+		name: "get_fastest_identities_ip",
+		render: func(scope Scope) (string, error) {
+			return GetFastestIdentities(scope, IdentityColumnsIP, DefaultWindow, nil, 400, "WHERE terminating_rule IN (VALUES 'Default_Action', 'rate-limit')", 1000)
+		},
+	},
+	{
+		name: "get_fastest_identities_user_agent_sliding",
+		render: func(scope Scope) (string, error) {
+			return GetFastestIdentities(scope, IdentityColumnsUserAgent, Window{Minutes: 2, Sliding: true}, LabelFilter{LabelNamespaceCustom, "awswaf:managed:aws:bot-control:signal:"}, 20, "WHERE signal_nobrowser", 1000)
+		},
+	},
+	{
+		name: "count_rule_matches",
+		render: func(scope Scope) (string, error) {
+			return GetCountRuleMatches(scope, 100)
+		},
+	},
+	{
+		name: "count_rule_top_identities_ip",
+		render: func(scope Scope) (string, error) {
+			return GetCountRuleTopIdentities(scope, IdentityColumnsIP, 20)
+		},
+	},
+	{
+		name: "count_rule_top_uris",
+		render: func(scope Scope) (string, error) {
+			return GetCountRuleTopURIs(scope, 20)
+		},
+	},
+	{
+		name: "label_counts",
+		render: func(scope Scope) (string, error) {
+			return GetLabelCounts(scope, nil, 1000)
+		},
+	},
+	{
+		name: "label_prefix_counts_bot_control",
+		render: func(scope Scope) (string, error) {
+			return GetLabelPrefixCounts(scope, LabelFilter{LabelNamespaces["bot-control"]}, 1000)
+		},
+	},
+	{
+		name: "label_cooccurrence_custom",
+		render: func(scope Scope) (string, error) {
+			return GetLabelCooccurrence(scope, LabelFilter{LabelNamespaceCustom}, 1000)
+		},
+	},
+	{
+		name: "label_trends",
+		render: func(scope Scope) (string, error) {
+			return GetLabelTrends(scope, 7, nil, 50)
+		},
+	},
+	{
+		name: "geo_country_hourly",
+		render: func(scope Scope) (string, error) {
+			return GetGeoCountryHourly(scope, 8)
+		},
+	},
+	{
+		name: "geo_country_shares",
+		render: func(scope Scope) (string, error) {
+			return GetGeoCountryShares(scope, 7, 3, 100)
+		},
+	},
+	{
+		name: "managed_rule_matches",
+		render: func(scope Scope) (string, error) {
+			return GetManagedRuleMatches(scope, 1000)
+		},
+	},
+	{
+		name: "managed_rule_top_identities_ip",
+		render: func(scope Scope) (string, error) {
+			return GetManagedRuleTopIdentities(scope, IdentityColumnsIP, 20)
+		},
+	},
+	{
+		name: "managed_rule_top_args",
+		render: func(scope Scope) (string, error) {
+			return GetManagedRuleTopArgs(scope, 20)
+		},
+	},
+	{
+		name: "managed_rule_samples",
+		render: func(scope Scope) (string, error) {
+			return GetManagedRuleSamples(scope, 5)
+		},
+	},
+	{
+		name: "blocked_sessions",
+		render: func(scope Scope) (string, error) {
+			return GetBlockedSessions(scope, 3, DefaultCartPattern, 5, 1000)
+		},
+	},
+	{
+		name: "rate_limit_simulation",
+		render: func(scope Scope) (string, error) {
+			return GetRateLimitSimulation(scope, 7, []RateLimitCandidate{
+				{Key: RateKey{Kind: "ip"}, Window: DefaultWindow, Limit: 400},
+				{Key: RateKey{Kind: "forwarded-ip"}, Window: Window{Minutes: 2, Sliding: true}, Limit: 100},
+				{Key: RateKey{Kind: "cookie", Name: "session"}, Window: DefaultWindow, Limit: 200},
+			}, nil, []string{"ios-de-1.0.0"})
+		},
+	},
+	{
+		name: "scraper_urls",
+		render: func(scope Scope) (string, error) {
+			return GetScraperURLs(scope, APC1Campaign, 100)
+		},
+	},
+	{
+		name: "scraper_user_agents",
+		render: func(scope Scope) (string, error) {
+			return GetScraperUserAgents(scope, APC1Campaign, 1000)
+		},
+	},
+	{
+		name: "scraper_products",
+		render: func(scope Scope) (string, error) {
+			c := APC1Campaign
+			c.Name, c.URIPattern, c.ProductPattern, c.AllowlistRules = "shop-p", `^/p/[0-9]+$`, `^/p/([0-9]+)`, nil
+			return GetScraperProducts(scope, c, 200000)
+		},
+	},
+	{
+		name: "login_daily_traffic",
+		render: func(scope Scope) (string, error) {
+			return GetLoginDailyTraffic(scope, 7, DefaultLoginPattern)
+		},
+	},
+	{
+		name: "login_clusters",
+		render: func(scope Scope) (string, error) {
+			return GetLoginClusters(scope, DefaultLoginPattern, DefaultWindow, 20, 50)
+		},
+	},
+	{
+		name: "login_low_and_slow",
+		render: func(scope Scope) (string, error) {
+			return GetLowAndSlowLogins(scope, `^/api/login$`, 20, 5, 100)
+		},
+	},
+	{
+		name: "path_scanners",
+		render: func(scope Scope) (string, error) {
+			return GetPathScanners(scope, DefaultProbes, DefaultScannerThresholds, 1000)
+		},
+	},
+	{
+		name: "probed_paths",
+		render: func(scope Scope) (string, error) {
+			return GetProbedPaths(scope, []Probe{{Name: "magento", Pattern: `(?i)^/(magento_version|downloader)`}}, 1000)
+		},
+	},
+	{
+		name: "bot_traffic",
+		render: func(scope Scope) (string, error) {
+			return GetBotTraffic(scope, 1000)
+		},
+	},
+	{
+		name: "spoofed_bots",
+		render: func(scope Scope) (string, error) {
+			return GetSpoofedBots(scope, DefaultCrawlerRules, 1000)
+		},
+	},
+	{
+		name: "challenge_outcomes",
+		render: func(scope Scope) (string, error) {
+			return GetChallengeOutcomes(scope, 20)
+		},
+	},
+	{
+		name: "challenge_solve_latency",
+		render: func(scope Scope) (string, error) {
+			return GetChallengeSolveLatency(scope)
+		},
+	},
+	{
+		name: "challenge_solvers",
+		render: func(scope Scope) (string, error) {
+			return GetChallengeSolvers(scope, 10, 3, 1000)
+		},
+	},
+	{
+		name: "header_fingerprints",
+		render: func(scope Scope) (string, error) {
+			return GetHeaderFingerprints(scope, 1000)
+		},
+	},
+	{
+		name: "header_anomalies",
+		render: func(scope Scope) (string, error) {
+			return GetHeaderAnomalies(scope, 1000)
+		},
+	},
+}
+
+// TestTemplates renders every template for each test scope and compares the
+// result to the golden file, which the module in lint parses with the Trino
+// grammar of Athena. Run `go test ./pkg/query -update` after intended changes
+// to a template.
+func TestTemplates(t *testing.T) {
+	for _, tc := range templateCases {
+		for scopeName, scope := range testScopes {
+			name := tc.name + "_" + scopeName
+			t.Run(name, func(t *testing.T) {
+				sql, err := tc.render(scope)
+				if err != nil {
+					t.Fatalf("rendering template: %s", err)
+				}
+
+				if strings.Contains(sql, "<no value>") {
+					t.Errorf("template rendered a missing value:\n%s", sql)
+				}
+
+				golden := filepath.Join("testdata", name+".sql")
+				if *update {
+					if err := os.WriteFile(golden, []byte(sql), 0644); err != nil {
+						t.Fatalf("writing golden file: %s", err)
+					}
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("reading golden file (run with -update to create it): %s", err)
+				}
+
+				if sql != string(want) {
+					t.Errorf("rendered sql differs from %s (run with -update if intended):\n%s", golden, diffLines(string(want), sql))
+				}
+			})
+		}
+	}
+}
+
+func TestWindowValidate(t *testing.T) {
+	for _, m := range []int{1, 2, 5, 10} {
+		if err := (Window{Minutes: m}).Validate(); err != nil {
+			t.Errorf("window of %d minutes: unexpected error: %s", m, err)
+		}
+	}
+
+	for _, m := range []int{0, 3, 15, -5} {
+		if err := (Window{Minutes: m}).Validate(); err == nil {
+			t.Errorf("window of %d minutes: expected error", m)
+		}
+	}
+
+	scope := testScopes["bc"]
+	if _, err := GetFastestIdentities(scope, IdentityColumnsIP, Window{Minutes: 3}, nil, 1, "", 1); err == nil {
+		t.Errorf("expected error rendering query with unsupported window")
+	}
+}
+
+func diffLines(want string, got string) string {
+	wl := strings.Split(want, "\n")
+	gl := strings.Split(got, "\n")
+
+	var out strings.Builder
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			out.WriteString("line " + strconv.Itoa(i+1) + ":\n  - " + w + "\n  + " + g + "\n")
+		}
+	}
+
+	return out.String()
+}
+
+func TestAPC1MaterializedViewName(t *testing.T) {
+	for scopeName, scope := range testScopes {
+		sql, err := CreateAPC1MaterializedView(scope)
+		if err != nil {
+			t.Fatalf("%s: rendering template: %s", scopeName, err)
+		}
+
+		name := APC1MaterializedViewName(scope)
+		if !strings.HasPrefix(sql, "CREATE TABLE IF NOT EXISTS "+name+"\n") {
+			t.Errorf("%s: view is not created as %s", scopeName, name)
+		}
+	}
+}
+
+func TestManagedRuleTopIdentities(t *testing.T) {
+	scope := testScopes["bc"]
+	if _, err := GetManagedRuleTopIdentities(scope, IdentityColumnsUserAgent, 3); err != nil {
+		t.Errorf("User-Agent identities: %s", err)
+	}
+	for _, cols := range []IdentityColumns{IdentityColumnsForwardedIP, IdentityColumnsCustomKey} {
+		if _, err := GetManagedRuleTopIdentities(scope, cols, 3); err == nil {
+			t.Errorf("identities of %s rendered, want error", cols)
+		}
+	}
+}
+
+func TestParseLabelFilter(t *testing.T) {
+	f, err := ParseLabelFilter([]string{"atp", "custom", "awswaf:managed:aws:bot-control:signal:"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := "(STARTS_WITH(label.name, 'awswaf:managed:aws:atp:') OR NOT STARTS_WITH(label.name, 'awswaf:managed:') OR STARTS_WITH(label.name, 'awswaf:managed:aws:bot-control:signal:'))"
+	if got := f.Matches("label.name"); got != want {
+		t.Errorf("unexpected predicate:\n got: %s\nwant: %s", got, want)
+	}
+
+	if _, err := ParseLabelFilter([]string{"bot-contrl"}); err == nil {
+		t.Errorf("expected error for unknown namespace")
+	}
+
+	if got := LabelFilter(nil).Predicate(); got != "" {
+		t.Errorf("expected empty predicate for empty filter, got %s", got)
+	}
+}
+
+func TestScopeLastDays(t *testing.T) {
+	scopes := Scope{Waf: WafECP, Year: 2023, Month: 3, Day: 2}.LastDays(3)
+
+	var got []string
+	for _, s := range scopes {
+		got = append(got, s.Partition())
+	}
+
+	if want := "2023/02/28,2023/03/01,2023/03/02"; strings.Join(got, ",") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestParseRateKey(t *testing.T) {
+	for in, want := range map[string]string{
+		"ip":                  "ip",
+		"forwarded-ip":        "forwarded-ip",
+		"header:X-Api-Key":    "header:x-api-key",
+		"cookie:session":      "cookie:session",
+		"query-arg:search_id": "query-arg:search_id",
+	} {
+		k, err := ParseRateKey(in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", in, err)
+			continue
+		}
+		if k.String() != want {
+			t.Errorf("%s: got %s, want %s", in, k, want)
+		}
+	}
+
+	for _, in := range []string{"ip:1", "header", "cookie:", "query-arg:q'--", "asn"} {
+		if _, err := ParseRateKey(in); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+func TestCampaignValidate(t *testing.T) {
+	if err := APC1Campaign.Validate(); err != nil {
+		t.Errorf("APC1: unexpected error: %s", err)
+	}
+
+	for name, edit := range map[string]func(c *Campaign){
+		"name":            func(c *Campaign) { c.Name = "APC 2" },
+		"min user agents": func(c *Campaign) { c.MinUserAgents = 0 },
+		"uri pattern":     func(c *Campaign) { c.URIPattern = "^/(artikel" },
+		"no group":        func(c *Campaign) { c.ProductPattern = "search=[^&]+" },
+	} {
+		c := APC1Campaign
+		edit(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	p, err := ParseProbe(`wordpress=(?i)/wp-(admin|login)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Name != "wordpress" || p.Pattern != `(?i)/wp-(admin|login)` {
+		t.Errorf("got %+v", p)
+	}
+
+	for _, in := range []string{"wordpress", "=/wp-admin", "Word Press=/wp-admin", "wordpress=", "wordpress=/wp-(admin"} {
+		if _, err := ParseProbe(in); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+
+	for _, p := range DefaultProbes {
+		if err := p.Validate(); err != nil {
+			t.Errorf("default probe %s: %s", p.Name, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed statements/rate_limit_simulation.sql
+var RateLimitSimulationQuery string
+
+// RateKey is what a rate-based rule aggregates requests by: the IP, the
+// forwarded IP, or a custom key of a header, cookie or query argument
+type RateKey struct {
+	Kind string // ip, forwarded-ip, header, cookie or query-arg
+	Name string // of the header, cookie or query argument of custom keys
+}
+
+var rateKeyName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ParseRateKey parses keys like ip, forwarded-ip, header:x-api-key,
+// cookie:session or query-arg:q
+func ParseRateKey(s string) (RateKey, error) {
+	kind, name, _ := strings.Cut(s, ":")
+
+	switch kind {
+	case "ip", "forwarded-ip":
+		if name != "" {
+			return RateKey{}, fmt.Errorf("rate key %s takes no name", kind)
+		}
+	case "header", "cookie", "query-arg":
+		if !rateKeyName.MatchString(name) {
+			return RateKey{}, fmt.Errorf("rate key %s needs a name of letters, digits, '_', '.' or '-', e.g. %s:name", kind, kind)
+		}
+		if kind == "header" {
+			name = strings.ToLower(name)
+		}
+	default:
+		return RateKey{}, fmt.Errorf("rate key %s unknown, must be one of ip, forwarded-ip, header:<name>, cookie:<name>, query-arg:<name>", s)
+	}
+
+	return RateKey{Kind: kind, Name: name}, nil
+}
+
+func (k RateKey) String() string {
+	if k.Name == "" {
+		return k.Kind
+	}
+	return k.Kind + ":" + k.Name
+}
+
+// Columns returns the identity columns of the key
+func (k RateKey) Columns() IdentityColumns {
+	switch k.Kind {
+	case "ip":
+		return IdentityColumnsIP
+	case "forwarded-ip":
+		return IdentityColumnsForwardedIP
+	default:
+		return IdentityColumnsCustomKey
+	}
+}
+
+// expression returns the SQL expression of the custom_key column of custom
+// keys, empty for the others
+func (k RateKey) expression() string {
+	switch k.Kind {
+	case "header":
+		return fmt.Sprintf("try((transform(filter(httprequest.headers, header -> LOWER(header.name) = '%s'), header -> header.value))[1])", k.Name)
+	case "cookie":
+		return fmt.Sprintf("TRY(TRANSFORM(FILTER(SPLIT(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'cookie'), header -> header.value))[1]), ';'), kv -> TRIM(SPLIT(kv, '=')[1]) = '%s'), kv -> SPLIT(TRIM(kv), '=')[2])[1])", k.Name)
+	case "query-arg":
+		return fmt.Sprintf("TRY(TRANSFORM(FILTER(SPLIT(httprequest.args, '&'), kv -> SPLIT(kv, '=')[1] = '%s'), kv -> SPLIT(kv, '=')[2])[1])", k.Name)
+	default:
+		return ""
+	}
+}
+
+// whereClause returns the clause dropping the requests without a value of
+// the key, which rate-based rules don't count
+func (k RateKey) whereClause() string {
+	if k.Kind == "ip" {
+		return ""
+	}
+	return fmt.Sprintf("WHERE %s IS NOT NULL", k.Columns().Columns()[0])
+}
+
+// RateLimitCandidate is a setting of the rate-based rule to simulate
+type RateLimitCandidate struct {
+	Key    RateKey
+	Window Window
+	Limit  int // number of requests per window above which requests are blocked
+}
+
+// GetRateLimitSimulation replays the requests of the days up to and
+// including the day of the scope against each candidate, in order. For each
+// it returns the number of identities in windows above the limit and of
+// their requests past the limit, which the rule would have blocked, how many
+// of them were already blocked and how many came from known good clients:
+// verified bots and the User-Agents knownGoodUserAgents.
+func GetRateLimitSimulation(scope Scope, days int, candidates []RateLimitCandidate, labels LabelFilter, knownGoodUserAgents []string) (string, error) {
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no candidates to simulate")
+	}
+
+	tpl, err := template.New("query").Parse(RateLimitSimulationQuery)
+	if err != nil {
+		return "", err
+	}
+
+	knownGood := "CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:bot:verified')) > 0"
+	if len(knownGoodUserAgents) > 0 {
+		var values []string
+		for _, ua := range knownGoodUserAgents {
+			values = append(values, "'"+strings.ReplaceAll(ua, "'", "''")+"'")
+		}
+		knownGood = fmt.Sprintf(
+			"(%s OR try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) IN (%s))",
+			knownGood,
+			strings.Join(values, ", "),
+		)
+	}
+
+	type candidate struct {
+		Key      string
+		Window   string
+		Limit    string
+		Identity string
+		Windows  string
+	}
+
+	var data struct {
+		Candidates []candidate
+	}
+	for _, c := range candidates {
+		if c.Limit < 1 {
+			return "", fmt.Errorf("rate limit must be positive, got %d", c.Limit)
+		}
+
+		windows, err := renderFastestIdentities(scope, days, c.Key.Columns(), c.Key.expression(), c.Window, labels, c.Limit, c.Key.whereClause(), knownGood, 0)
+		if err != nil {
+			return "", fmt.Errorf("rendering windows of %s: %s", c.Key, err)
+		}
+
+		data.Candidates = append(data.Candidates, candidate{
+			Key:      c.Key.String(),
+			Window:   c.Window.String(),
+			Limit:    fmt.Sprintf("%d", c.Limit),
+			Identity: c.Key.Columns().Columns()[0],
+			Windows:  strings.TrimSuffix(strings.TrimSpace(windows), ";"),
+		})
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -10,7 +10,7 @@ import (
 //go:embed statements/requests_blocked_by.sql
 var RequestsBlockedByQuery string
 
-func GetRequestsBlockedBy(scope Scope, identityCols IdentityColumns, terminatingRules TerminatingRules, limit int) (string, error) {
+func GetRequestsBlockedBy(scope Scope, identityCols IdentityColumns, terminatingRules TerminatingRules, labels LabelFilter, limit int) (string, error) {
 	tpl, err := template.New("query").Parse(RequestsBlockedByQuery)
 	if err != nil {
 		return "", err
@@ -22,14 +22,16 @@ func GetRequestsBlockedBy(scope Scope, identityCols IdentityColumns, terminating
 		Month            string
 		Day              string
 		IdentityCols     IdentityColumns
+		LabelPredicate   string
 		TerminatingRules string
 		Limit            string
 	}{
-		WafTable:         getTable(scope.Waf),
+		WafTable:         Table(scope.Waf),
 		Year:             fmt.Sprintf("%d", scope.Year),
 		Month:            fmt.Sprintf("%02d", scope.Month),
 		Day:              fmt.Sprintf("%02d", scope.Day),
 		IdentityCols:     identityCols,
+		LabelPredicate:   labels.Predicate(),
 		TerminatingRules: terminatingRules.Values(),
 		Limit:            fmt.Sprintf("%d", limit),
 	}
@@ -1,8 +1,37 @@
 package query
 
+import (
+	"fmt"
+	"time"
+)
+
 type Scope struct {
 	Waf   WAF
 	Year  int
 	Month int
 	Day   int
 }
+
+// Partition returns the value of the day partition of the raw log tables
+func (s Scope) Partition() string {
+	return fmt.Sprintf("%04d/%02d/%02d", s.Year, s.Month, s.Day)
+}
+
+// LastDays returns the scopes of the n days up to and including the day of s,
+// oldest first
+func (s Scope) LastDays(n int) []Scope {
+	last := time.Date(s.Year, time.Month(s.Month), s.Day, 0, 0, 0, 0, time.UTC)
+
+	var out []Scope
+	for i := n - 1; i >= 0; i-- {
+		year, month, day := last.AddDate(0, 0, -i).Date()
+		out = append(out, Scope{
+			Waf:   s.Waf,
+			Year:  year,
+			Month: int(month),
+			Day:   day,
+		})
+	}
+
+	return out
+}
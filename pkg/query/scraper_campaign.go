@@ -0,0 +1,135 @@
+package query
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed statements/scraper_urls.sql
+var ScraperURLsQuery string
+
+//go:embed statements/scraper_user_agents.sql
+var ScraperUserAgentsQuery string
+
+//go:embed statements/scraper_products.sql
+var ScraperProductsQuery string
+
+// Campaign is the fingerprint of a scraper rotating User-Agents within its
+// sessions, like APC1
+type Campaign struct {
+	Name           string   `yaml:"name"`            // of the outputs of the campaign
+	MinUserAgents  int      `yaml:"min_user_agents"` // distinct User-Agents above which a session scrapes
+	MinRequests    int      `yaml:"min_requests"`    // of a User-Agent in scraping sessions above which it belongs to the campaign
+	URIPattern     string   `yaml:"uri_pattern"`     // regular expression of the URIs of the pages scraped
+	ProductPattern string   `yaml:"product_pattern"` // regular expression extracting the product id from <uri>?<query string> as first group
+	AllowlistRules []string `yaml:"allowlist_rules"` // rules of known bots, whose requests are ignored
+}
+
+// APC1Campaign is the fingerprint of the scraper APC1
+var APC1Campaign = Campaign{
+	Name:           "apc1",
+	MinUserAgents:  9,
+	MinRequests:    5000,
+	URIPattern:     `^/artikeldetails$`,
+	ProductPattern: `[?&]search=([^&]+)`,
+	AllowlistRules: []string{"waf-whitelist", "bot-label-whitelist", "seo-crawler", "seo-crawler-vpn", "allow-newrelic-header-check"},
+}
+
+var reCampaignName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Validate checks the name, thresholds and regular expressions of the campaign
+func (c Campaign) Validate() error {
+	if !reCampaignName.MatchString(c.Name) {
+		return fmt.Errorf("name %q must be lower case letters, digits and dashes", c.Name)
+	}
+	if c.MinUserAgents < 1 {
+		return fmt.Errorf("campaign %s: min_user_agents must be positive, got %d", c.Name, c.MinUserAgents)
+	}
+	if c.MinRequests < 0 {
+		return fmt.Errorf("campaign %s: min_requests must not be negative, got %d", c.Name, c.MinRequests)
+	}
+	if _, err := regexp.Compile(c.URIPattern); err != nil || c.URIPattern == "" {
+		return fmt.Errorf("campaign %s: uri_pattern %q invalid: %v", c.Name, c.URIPattern, err)
+	}
+	re, err := regexp.Compile(c.ProductPattern)
+	if err != nil {
+		return fmt.Errorf("campaign %s: product_pattern %q invalid: %s", c.Name, c.ProductPattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return fmt.Errorf("campaign %s: product_pattern %q has no group extracting the product id", c.Name, c.ProductPattern)
+	}
+
+	return nil
+}
+
+// GetScraperURLs returns the URLs requested by the sessions of the campaign
+// with their request counts, read from the APC1 materialized view
+func GetScraperURLs(scope Scope, campaign Campaign, limit int) (string, error) {
+	return renderCampaign(ScraperURLsQuery, scope, campaign, limit)
+}
+
+// GetScraperUserAgents returns the User-Agents of the campaign with their
+// requests in and outside of its sessions, read from the APC1 materialized
+// view
+func GetScraperUserAgents(scope Scope, campaign Campaign, limit int) (string, error) {
+	return renderCampaign(ScraperUserAgentsQuery, scope, campaign, limit)
+}
+
+// GetScraperProducts returns the products scraped by the campaign with their
+// request counts, read from the APC1 materialized view
+func GetScraperProducts(scope Scope, campaign Campaign, limit int) (string, error) {
+	return renderCampaign(ScraperProductsQuery, scope, campaign, limit)
+}
+
+func renderCampaign(text string, scope Scope, campaign Campaign, limit int) (string, error) {
+	if err := campaign.Validate(); err != nil {
+		return "", err
+	}
+
+	tpl, err := template.New("query").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var rules []string
+	for _, r := range campaign.AllowlistRules {
+		rules = append(rules, "'"+strings.ReplaceAll(r, "'", "''")+"'")
+	}
+
+	data := struct {
+		WAF            string
+		Year           string
+		Month          string
+		Day            string
+		Campaign       string
+		MinUserAgents  string
+		MinRequests    string
+		URIPattern     string
+		ProductPattern string
+		AllowlistRules string
+		Limit          string
+	}{
+		WAF:            scope.Waf.String(),
+		Year:           fmt.Sprintf("%d", scope.Year),
+		Month:          fmt.Sprintf("%02d", scope.Month),
+		Day:            fmt.Sprintf("%02d", scope.Day),
+		Campaign:       campaign.Name,
+		MinUserAgents:  fmt.Sprintf("%d", campaign.MinUserAgents),
+		MinRequests:    fmt.Sprintf("%d", campaign.MinRequests),
+		URIPattern:     strings.ReplaceAll(campaign.URIPattern, "'", "''"),
+		ProductPattern: strings.ReplaceAll(campaign.ProductPattern, "'", "''"),
+		AllowlistRules: strings.Join(rules, ", "),
+		Limit:          fmt.Sprintf("%d", limit),
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,60 @@
+WITH tmptable AS (
+    SELECT timestamp AS "unixtime",
+           httprequest.requestid AS "request_id",
+           action,
+           /* rules inside managed rule groups are named <rule group>/<rule> */
+           COALESCE(
+               TRY(TRANSFORM(FILTER(rulegrouplist, grp -> grp.terminatingrule IS NOT NULL), grp -> CONCAT(terminatingruleid, '/', grp.terminatingrule.ruleid))[1]),
+               terminatingruleid
+           ) AS "rule",
+           httprequest.uri AS "uri",
+           REGEXP_REPLACE(
+               REGEXP_REPLACE(httprequest.uri, '^/ersatzteile-verschleissteile/.*', '/ersatzteile-verschleissteile/...'),
+               '[0-9]+', '<n>'
+           ) AS "uri_pattern",
+           {{.SessionCookie}} AS "c_session",
+           COALESCE(CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent' OR label.name LIKE 'awswaf:managed:aws:bot-control:bot:%')), 0) > 0 AS "bot"
+    FROM {{.WafTable}}
+    WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+), sessions AS (
+    /* sessions of browsers blocked at least once */
+    SELECT c_session,
+           MIN(CASE WHEN action = 'BLOCK' THEN unixtime END) AS "first_block"
+    FROM tmptable
+    WHERE c_session IS NOT NULL
+      AND c_session != ''
+    GROUP BY c_session
+    HAVING COUNT_IF(action = 'BLOCK') > 0
+       AND COUNT_IF(bot) = 0
+), browsing AS (
+    /* sessions browsing normally before they were blocked */
+    SELECT sessions.c_session,
+           COUNT_IF(REGEXP_LIKE(tmptable.uri, '{{.CartPattern}}')) > 0 AS "cart_before_block"
+    FROM sessions
+    INNER JOIN tmptable ON tmptable.c_session = sessions.c_session
+    WHERE tmptable.action = 'ALLOW'
+      AND tmptable.unixtime < sessions.first_block
+    GROUP BY sessions.c_session
+    HAVING COUNT(*) >= {{.MinAllowed}}
+), blocks AS (
+    SELECT tmptable.rule,
+           tmptable.uri_pattern,
+           tmptable.c_session,
+           tmptable.request_id,
+           browsing.cart_before_block OR REGEXP_LIKE(tmptable.uri, '{{.CartPattern}}') AS "cart"
+    FROM tmptable
+    INNER JOIN browsing ON browsing.c_session = tmptable.c_session
+    WHERE tmptable.action = 'BLOCK'
+)
+
+SELECT rule,
+       uri_pattern,
+       COUNT(*) AS "num_blocked",
+       COUNT(DISTINCT c_session) AS "num_sessions",
+       COUNT(DISTINCT CASE WHEN cart THEN c_session END) AS "num_cart_sessions",
+       SLICE(ARRAY_SORT(ARRAY_AGG(request_id)), 1, {{.Examples}}) AS "example_request_ids"
+FROM blocks
+GROUP BY rule,
+         uri_pattern
+ORDER BY num_cart_sessions DESC, num_sessions DESC, num_blocked DESC, rule ASC, uri_pattern ASC
+LIMIT {{.Limit}};
@@ -0,0 +1,47 @@
+WITH {{template "outcomes" .}}, keyed AS (
+    SELECT 'rule' AS "dimension", rule AS "identity", kind, outcome, solve_timestamp, client_ip
+    FROM outcomes
+    UNION ALL
+    SELECT 'country' AS "dimension", country AS "identity", kind, outcome, solve_timestamp, client_ip
+    FROM outcomes
+    UNION ALL
+    SELECT 'ip' AS "dimension", client_ip AS "identity", kind, outcome, solve_timestamp, client_ip
+    FROM outcomes
+    UNION ALL
+    SELECT 'user_agent' AS "dimension", user_agent AS "identity", kind, outcome, solve_timestamp, client_ip
+    FROM outcomes
+), totals AS (
+    SELECT dimension,
+           identity,
+           kind,
+           COUNT(*) AS "num_requests",
+           COUNT_IF(outcome = 'issued') AS "num_issued",
+           COUNT_IF(outcome = 'solved') AS "num_solved",
+           COUNT_IF(outcome = 'failed') AS "num_failed",
+           /* the requests passing with the token of one solved puzzle share its solve timestamp */
+           COUNT(DISTINCT CASE WHEN solve_timestamp > 0 THEN solve_timestamp END) AS "num_solves",
+           COUNT(DISTINCT client_ip) AS "num_ips"
+    FROM keyed
+    GROUP BY dimension,
+             identity,
+             kind
+), ranked AS (
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY dimension, kind ORDER BY num_requests DESC, identity ASC) AS "rank"
+    FROM totals
+)
+
+SELECT dimension,
+       identity,
+       kind,
+       num_requests,
+       num_issued,
+       num_solved,
+       num_failed,
+       num_solves,
+       /* every request issued or failing gets a puzzle */
+       ROUND(CAST(num_solves AS DOUBLE) / NULLIF(num_issued + num_failed, 0), 4) AS "solve_rate",
+       num_ips
+FROM ranked
+WHERE rank <= {{.Limit}}
+ORDER BY dimension ASC, kind ASC, num_requests DESC, identity ASC;
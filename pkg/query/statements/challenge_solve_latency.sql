@@ -0,0 +1,24 @@
+WITH {{template "outcomes" .}}, {{template "latencies" .}}, buckets AS (
+    SELECT kind,
+           client_ip,
+           CASE
+               WHEN latency IS NULL THEN -1
+               WHEN latency < 2 THEN 0
+               WHEN latency < 5 THEN 2
+               WHEN latency < 10 THEN 5
+               WHEN latency < 30 THEN 10
+               WHEN latency < 60 THEN 30
+               ELSE 60
+           END AS "from_seconds"
+    FROM latencies
+)
+
+/* solves per latency, those of tokens without a puzzle sent to the IP and User-Agent from -1 */
+SELECT kind,
+       from_seconds,
+       COUNT(*) AS "num_solves",
+       COUNT(DISTINCT client_ip) AS "num_ips"
+FROM buckets
+GROUP BY kind,
+         from_seconds
+ORDER BY kind ASC, from_seconds ASC;
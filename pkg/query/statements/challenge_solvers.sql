@@ -0,0 +1,35 @@
+WITH {{template "outcomes" .}}, {{template "latencies" .}}, solvers AS (
+    SELECT kind,
+           client_ip,
+           country,
+           user_agent,
+           COUNT(*) AS "num_solves",
+           /* only humans solving a CAPTCHA take time, browsers solve challenges silently */
+           COUNT_IF(kind = 'captcha' AND latency < {{.FastSeconds}}) AS "num_fast_solves",
+           /* tokens solved elsewhere, e.g. handed out by a CAPTCHA farm */
+           COUNT_IF(latency IS NULL) AS "num_foreign_solves",
+           MIN(latency) AS "min_latency",
+           ROUND(AVG(latency), 1) AS "avg_latency"
+    FROM latencies
+    GROUP BY kind,
+             client_ip,
+             country,
+             user_agent
+)
+
+/* IPs and User-Agents solving often, fast or with tokens solved elsewhere */
+SELECT kind,
+       client_ip,
+       country,
+       user_agent,
+       num_solves,
+       num_fast_solves,
+       num_foreign_solves,
+       min_latency,
+       avg_latency
+FROM solvers
+WHERE num_solves >= {{.MinSolves}}
+   OR num_fast_solves > 0
+   OR num_foreign_solves > 0
+ORDER BY num_fast_solves + num_foreign_solves DESC, num_solves DESC, client_ip ASC
+LIMIT {{.Limit}};
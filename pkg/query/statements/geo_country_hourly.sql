@@ -0,0 +1,12 @@
+SELECT httprequest.country AS "country",
+       day AS "date",
+       HOUR(from_unixtime(timestamp/1000)) AS "hour",
+       COUNT(*) AS "num_requests",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       COUNT_IF(action = 'BLOCK' AND terminatingruleid = '{{.RateLimitRule}}') AS "num_rate_limited"
+FROM {{.WafTable}}
+WHERE day BETWEEN '{{.FirstDay}}' AND '{{.LastDay}}'
+GROUP BY httprequest.country,
+         day,
+         HOUR(from_unixtime(timestamp/1000))
+ORDER BY date ASC, hour ASC, num_requests DESC;
@@ -0,0 +1,90 @@
+WITH daily AS (
+    SELECT httprequest.country AS "country",
+           day,
+           COUNT(*) AS "num_requests",
+           COUNT_IF(action = 'BLOCK') AS "num_blocked",
+           COUNT_IF(action = 'BLOCK' AND terminatingruleid = '{{.RateLimitRule}}') AS "num_rate_limited"
+    FROM {{.WafTable}}
+    WHERE day BETWEEN '{{.FirstDay}}' AND '{{.LastDay}}'
+    GROUP BY httprequest.country,
+             day
+), shares AS (
+    SELECT country,
+           day,
+           num_requests,
+           num_blocked,
+           num_rate_limited,
+           CAST(num_requests AS DOUBLE) / SUM(num_requests) OVER (PARTITION BY day) AS "share"
+    FROM daily
+), baseline_days AS (
+    /* days of the baseline loaded, missing partitions don't count */
+    SELECT COUNT(DISTINCT day) AS "num_days"
+    FROM shares
+    WHERE day < '{{.LastDay}}'
+), baseline AS (
+    /* days of the baseline without requests of a country count as a share of 0 */
+    SELECT shares.country,
+           SUM(shares.share) / baseline_days.num_days AS "share"
+    FROM shares
+    CROSS JOIN baseline_days
+    WHERE shares.day < '{{.LastDay}}'
+    GROUP BY shares.country,
+             baseline_days.num_days
+), current AS (
+    SELECT country,
+           num_requests,
+           num_blocked,
+           num_rate_limited,
+           share,
+           SUM(num_requests) OVER () AS "total_requests"
+    FROM shares
+    WHERE day = '{{.LastDay}}'
+), compared AS (
+    SELECT current.country,
+           current.num_requests,
+           current.num_blocked,
+           current.num_rate_limited,
+           current.share,
+           COALESCE(baseline.share, 0) AS "baseline_share",
+           COALESCE(baseline.share, 0) * current.total_requests AS "expected_requests",
+           baseline.share IS NULL AS "first_seen"
+    FROM current
+    /* requests without a country are compared like a country of their own */
+    LEFT JOIN baseline ON baseline.country IS NOT DISTINCT FROM current.country
+    UNION ALL
+    SELECT baseline.country,
+           0,
+           0,
+           0,
+           0,
+           baseline.share,
+           baseline.share * (SELECT MAX(total_requests) FROM current),
+           false
+    FROM baseline
+    WHERE NOT EXISTS (
+        SELECT 1
+        FROM current
+        WHERE current.country IS NOT DISTINCT FROM baseline.country
+    )
+), baseline_size AS (
+    SELECT COUNT(*) AS "num_countries"
+    FROM baseline
+)
+
+SELECT country,
+       num_requests,
+       num_blocked,
+       num_rate_limited,
+       ROUND(share, 6) AS "share",
+       ROUND(baseline_share, 6) AS "baseline_share",
+       ROUND(expected_requests) AS "expected_requests",
+       ROUND(share / NULLIF(baseline_share, 0), 2) AS "share_ratio",
+       CASE
+           /* without a baseline, e.g. on the first day loaded, every country would be new */
+           WHEN first_seen AND baseline_size.num_countries > 0 AND num_requests >= {{.MinRequests}} THEN 'new'
+           WHEN NOT first_seen AND num_requests >= {{.MinRequests}} AND share >= baseline_share * {{.ShareFactor}} THEN 'surge'
+           WHEN NOT first_seen AND expected_requests >= {{.MinRequests}} AND share * {{.ShareFactor}} <= baseline_share THEN 'drop'
+       END AS "flag"
+FROM compared
+CROSS JOIN baseline_size
+ORDER BY flag IS NULL, num_requests DESC, country ASC;
@@ -1,24 +1,120 @@
 WITH tmptable AS (
     SELECT httprequest.clientip AS "client_ip",
+{{- if .ForwardedIP}}
+           TRY(TRIM(SPLIT(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'x-forwarded-for'), header -> header.value))[1]), ',')[1])) AS "forwarded_ip",
+{{- end}}
            httprequest.country AS "country",
            try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
-           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> SUBSTR(label.name, 41))[1]) AS "bot_name",
-           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> SUBSTR(label.name, 45))[1]) AS "bot_category",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
            CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent')) > 0 AS "signal_nobrowser",
+{{- with .CustomKey}}
+           {{.}} AS "custom_key",
+{{- end}}
+{{- with .KnownGood}}
+           action = 'BLOCK' AS "blocked",
+           {{.}} AS "known_good",
+{{- end}}
            terminatingruleid AS "terminating_rule",
-           from_unixtime(FLOOR(timestamp/(1000*60*5))*60*5) as "time_window",
+{{- if .Sliding}}
+           FLOOR(timestamp/(1000*60)) AS "minute",
+{{- else}}
+           from_unixtime(FLOOR(timestamp/(1000*60*{{.WindowMinutes}}))*60*{{.WindowMinutes}}) as "time_window",
+{{- end}}
            timestamp
     FROM {{.WafTable}}
-    WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+    WHERE {{.DayPredicate}}
+{{- with .LabelPredicate}}
+      AND {{.}}
+{{- end}}
+{{- if .Sliding}}
+), minutes AS (
+    SELECT {{.IdentityCols}},
+           minute,
+{{- if .KnownGood}}
+           COUNT_IF(blocked) AS "num_blocked",
+           COUNT_IF(known_good) AS "num_known_good",
+{{- end}}
+           COUNT(*) AS "num_requests"
+    FROM tmptable
+    {{.CustomWhereClause}}
+    GROUP BY {{.IdentityCols}},
+             minute
+), windows AS (
+    /* approximates the sliding window of WAF rate-based rules with a resolution of one minute */
+    SELECT {{.IdentityCols}},
+           from_unixtime((minute-{{.WindowMinutes}}+1)*60) AS "time_window",
+{{- if .KnownGood}}
+           num_requests AS "num_minute_requests",
+           num_blocked,
+           num_known_good,
+{{- end}}
+           SUM(num_requests) OVER (
+               PARTITION BY {{.IdentityCols}}
+               ORDER BY minute
+               RANGE BETWEEN {{.WindowOffset}} PRECEDING AND CURRENT ROW
+           ) AS "num_requests"
+    FROM minutes
+{{- if .Limit}}
+), peaks AS (
+    /* the peak window of each identity, its overlapping windows would fill the top list */
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY {{.IdentityCols}} ORDER BY num_requests DESC, time_window ASC) AS "peak"
+    FROM windows
+    WHERE num_requests > {{.MinRate}}
+{{- end}}
 )
 
 SELECT {{.IdentityCols}},
        time_window,
+{{- if .KnownGood}}
+       /* the requests of the last minute of the window past the limit are the ones a rate limit blocks */
+       LEAST(num_minute_requests, num_requests - {{.MinRate}}) AS "num_affected",
+       LEAST(num_blocked, num_minute_requests, num_requests - {{.MinRate}}) AS "num_blocked",
+       LEAST(num_known_good, num_minute_requests, num_requests - {{.MinRate}}) AS "num_known_good",
+{{- end}}
+       num_requests
+{{- with .Limit}}
+FROM peaks
+WHERE peak = 1
+ORDER BY num_requests DESC
+LIMIT {{.}}
+{{- else}}
+FROM windows
+WHERE num_requests > {{.MinRate}}
+{{- end}};
+{{- else}}
+{{- if .KnownGood}}
+), ranked AS (
+    /* the requests of a window past the limit are the ones a rate limit blocks */
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY {{.IdentityCols}}, time_window ORDER BY timestamp) > {{.MinRate}} AS "affected"
+    FROM tmptable
+{{- with .CustomWhereClause}}
+    {{.}}
+{{- end}}
+{{- end}}
+)
+
+SELECT {{.IdentityCols}},
+       time_window,
+{{- if .KnownGood}}
+       COUNT_IF(affected) AS "num_affected",
+       COUNT_IF(affected AND blocked) AS "num_blocked",
+       COUNT_IF(affected AND known_good) AS "num_known_good",
+{{- end}}
        COUNT(*) AS "num_requests"
+{{- if .KnownGood}}
+FROM ranked
+{{- else}}
 FROM tmptable
 {{.CustomWhereClause}}
+{{- end}}
 GROUP BY {{.IdentityCols}},
          time_window
 HAVING COUNT(*) > {{.MinRate}}
+{{- with .Limit}}
 ORDER BY num_requests DESC
-LIMIT {{.Limit}};
+LIMIT {{.}}
+{{- end}};
+{{- end}}
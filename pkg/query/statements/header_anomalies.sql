@@ -0,0 +1,21 @@
+WITH {{template "checked" .}}
+
+/* clients claiming a browser with headers no browser sends, those Bot Control missed first */
+SELECT client_ip,
+       country,
+       user_agent,
+       browser,
+       COUNT(*) AS "num_requests",
+       COUNT_IF(NOT signal_nobrowser) AS "num_missed",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       COUNT(DISTINCT header_order) AS "num_header_orders",
+       ARRAY_JOIN(ARRAY_SORT(ARRAY_AGG(DISTINCT anomalies)), ' ') AS "anomalies",
+       MIN(header_order) AS "header_order"
+FROM checked
+WHERE anomalies != ''
+GROUP BY client_ip,
+         country,
+         user_agent,
+         browser
+ORDER BY num_missed DESC, num_requests DESC, client_ip ASC
+LIMIT {{.Limit}};
@@ -0,0 +1,17 @@
+WITH {{template "checked" .}}
+
+SELECT browser,
+       header_order,
+       anomalies,
+       COUNT(*) AS "num_requests",
+       COUNT(DISTINCT client_ip) AS "num_ips",
+       COUNT(DISTINCT user_agent) AS "num_user_agents",
+       COUNT_IF(NOT signal_nobrowser) AS "num_missed",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       MIN(user_agent) AS "user_agent"
+FROM checked
+GROUP BY browser,
+         header_order,
+         anomalies
+ORDER BY num_ips DESC, num_requests DESC, browser ASC, header_order ASC
+LIMIT {{.Limit}};
@@ -0,0 +1,24 @@
+WITH tmptable AS (
+    SELECT action,
+           labels
+    FROM {{.WafTable}}
+    WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+), request_prefixes AS (
+    /* all prefixes of the labels of a request, e.g. awswaf, awswaf:managed, ..., awswaf:managed:aws:bot-control:bot:verified */
+    SELECT action,
+           ARRAY_DISTINCT(FLATTEN(TRANSFORM(
+               FILTER(TRANSFORM(labels, label -> label.name), label -> {{.LabelMatches}}),
+               label -> TRANSFORM(SEQUENCE(1, CARDINALITY(SPLIT(label, ':'))), depth -> ARRAY_JOIN(SLICE(SPLIT(label, ':'), 1, depth), ':'))
+           ))) AS "prefixes"
+    FROM tmptable
+)
+
+SELECT prefix,
+       CARDINALITY(SPLIT(prefix, ':')) AS "depth",
+       COUNT(*) AS "num_requests",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked"
+FROM request_prefixes
+CROSS JOIN UNNEST(prefixes) AS t(prefix)
+GROUP BY prefix
+ORDER BY prefix ASC
+LIMIT {{.Limit}};
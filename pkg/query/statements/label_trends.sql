@@ -0,0 +1,26 @@
+WITH tmptable AS (
+    SELECT day,
+           action,
+           labels
+    FROM {{.WafTable}}
+    WHERE day BETWEEN '{{.FirstDay}}' AND '{{.LastDay}}'
+), top_labels AS (
+    SELECT label
+    FROM tmptable
+    CROSS JOIN UNNEST(ARRAY_DISTINCT(TRANSFORM(labels, label -> label.name))) AS t(label)
+    WHERE {{.LabelMatches}}
+    GROUP BY label
+    ORDER BY COUNT(*) DESC
+    LIMIT {{.Limit}}
+)
+
+SELECT label,
+       day,
+       COUNT(*) AS "num_requests",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked"
+FROM tmptable
+CROSS JOIN UNNEST(ARRAY_DISTINCT(TRANSFORM(labels, label -> label.name))) AS t(label)
+WHERE label IN (SELECT label FROM top_labels)
+GROUP BY label,
+         day
+ORDER BY label ASC, day ASC;
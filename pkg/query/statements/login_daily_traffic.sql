@@ -0,0 +1,22 @@
+WITH logins AS (
+    SELECT day AS "date",
+           httprequest.clientip AS "client_ip",
+           httprequest.httpmethod = 'POST' AS "post",
+           action,
+           COALESCE(CARDINALITY(FILTER(labels, label -> STARTS_WITH(label.name, 'awswaf:managed:aws:atp:'))), 0) > 0 AS "atp",
+           COALESCE(CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:atp:signal:credential_compromised')), 0) > 0 AS "compromised"
+    FROM {{.WafTable}}
+    WHERE day BETWEEN '{{.FirstDay}}' AND '{{.LastDay}}'
+      AND REGEXP_LIKE(httprequest.uri, '{{.PathPattern}}')
+)
+
+SELECT date,
+       COUNT(*) AS "num_requests",
+       COUNT_IF(post) AS "num_posts",
+       COUNT(DISTINCT CASE WHEN post THEN client_ip END) AS "num_post_ips",
+       COUNT_IF(post AND action = 'BLOCK') AS "num_posts_blocked",
+       COUNT_IF(atp) AS "num_atp_labeled",
+       COUNT_IF(compromised) AS "num_compromised"
+FROM logins
+GROUP BY date
+ORDER BY date ASC;
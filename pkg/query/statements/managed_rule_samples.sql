@@ -0,0 +1,60 @@
+WITH tmptable AS (
+    SELECT from_unixtime(timestamp/1000) AS "timestamp",
+           httprequest.requestid AS "request_id",
+           httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           httprequest.httpmethod AS "method",
+           httprequest.uri AS "uri",
+           httprequest.args AS "args",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           /* what the terminating rule of the request matched */
+           ARRAY_JOIN(TRANSFORM(
+               COALESCE(terminatingrulematchdetails, ARRAY[]),
+               detail -> CONCAT(detail.conditiontype, ' in ', detail.location, ': ', ARRAY_JOIN(detail.matcheddata, ' '))
+           ), '; ') AS "terminating_match_details",
+           action,
+           rulegrouplist AS "rule_groups"
+    FROM {{.WafTable}}
+    WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+      AND rulegrouplist IS NOT NULL
+), matches AS (
+    /* rules inside rule groups terminating the request */
+    SELECT tmptable.*, action AS "match", rule_group, rule,
+           terminating_match_details AS "match_details"
+    FROM tmptable
+    CROSS JOIN UNNEST(TRANSFORM(
+        FILTER(rule_groups, grp -> grp.terminatingrule IS NOT NULL),
+        grp -> ROW(grp.rulegroupid, grp.terminatingrule.ruleid)
+    )) AS t(rule_group, rule)
+
+    UNION ALL
+
+    /* rules inside rule groups in COUNT mode */
+    SELECT tmptable.*, 'COUNT' AS "match", rule_group, rule,
+           '' AS "match_details"
+    FROM tmptable
+    CROSS JOIN UNNEST(FLATTEN(TRANSFORM(
+        FILTER(rule_groups, grp -> grp.nonterminatingmatchingrules IS NOT NULL),
+        grp -> TRANSFORM(FILTER(grp.nonterminatingmatchingrules, r -> r.action = 'COUNT'), r -> ROW(grp.rulegroupid, r.ruleid))
+    ))) AS t(rule_group, rule)
+), ranked AS (
+    /* the order of request ids is arbitrary but stable, unlike a random sample */
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY rule_group, rule, match ORDER BY request_id, timestamp) AS "sample"
+    FROM matches
+)
+
+SELECT rule_group,
+       rule,
+       match,
+       timestamp,
+       client_ip,
+       country,
+       method,
+       uri,
+       args,
+       user_agent,
+       match_details
+FROM ranked
+WHERE sample <= {{.SamplesPerRule}}
+ORDER BY rule_group ASC, rule ASC, match ASC, timestamp ASC;
@@ -0,0 +1,53 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           httprequest.uri AS "uri",
+           httprequest.args AS "args",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           action,
+           rulegrouplist AS "rule_groups"
+    FROM {{.WafTable}}
+    WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+      AND rulegrouplist IS NOT NULL
+), matches AS (
+    /* rules inside rule groups terminating the request */
+    SELECT {{.Columns}}, action AS "match", rule_group, rule
+    FROM tmptable
+    CROSS JOIN UNNEST(TRANSFORM(
+        FILTER(rule_groups, grp -> grp.terminatingrule IS NOT NULL),
+        grp -> ROW(grp.rulegroupid, grp.terminatingrule.ruleid)
+    )) AS t(rule_group, rule)
+
+    UNION ALL
+
+    /* rules inside rule groups in COUNT mode */
+    SELECT {{.Columns}}, 'COUNT' AS "match", rule_group, rule
+    FROM tmptable
+    CROSS JOIN UNNEST(FLATTEN(TRANSFORM(
+        FILTER(rule_groups, grp -> grp.nonterminatingmatchingrules IS NOT NULL),
+        grp -> TRANSFORM(FILTER(grp.nonterminatingmatchingrules, r -> r.action = 'COUNT'), r -> ROW(grp.rulegroupid, r.ruleid))
+    ))) AS t(rule_group, rule)
+), ranked AS (
+    SELECT rule_group,
+           rule,
+           match,
+           {{.Columns}},
+           COUNT(*) AS "num_requests",
+           ROW_NUMBER() OVER (PARTITION BY rule_group, rule, match ORDER BY COUNT(*) DESC) AS "rule_rank"
+    FROM matches
+    GROUP BY rule_group,
+             rule,
+             match,
+             {{.Columns}}
+)
+
+SELECT rule_group,
+       rule,
+       match,
+       {{.Columns}},
+       num_requests
+FROM ranked
+WHERE rule_rank <= {{.LimitPerRule}}
+ORDER BY rule_group ASC, rule ASC, match ASC, num_requests DESC;
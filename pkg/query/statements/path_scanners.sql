@@ -0,0 +1,54 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           httprequest.uri AS "uri",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           action,
+           /* the first probe the URI matches */
+           CASE
+{{- range .Probes}}
+               WHEN REGEXP_LIKE(httprequest.uri, '{{.Pattern}}') THEN '{{.Name}}'
+{{- end}}
+           END AS "probe"
+    FROM {{.WafTable}}
+    WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+), identities AS (
+    SELECT client_ip,
+           country,
+           COUNT(*) AS "num_requests",
+           COUNT(DISTINCT uri) AS "num_uris",
+           COUNT_IF(probe IS NOT NULL) AS "num_probes",
+           COUNT(DISTINCT CASE WHEN probe IS NOT NULL THEN uri END) AS "num_probe_uris",
+           COUNT_IF(probe IS NOT NULL AND action != 'BLOCK') AS "num_probes_allowed",
+           COUNT_IF(action = 'BLOCK') AS "num_blocked",
+           COUNT(DISTINCT user_agent) AS "num_user_agents",
+           MIN(user_agent) AS "user_agent",
+           ARRAY_JOIN(ARRAY_SORT(ARRAY_DISTINCT(FILTER(ARRAY_AGG(probe), p -> p IS NOT NULL))), ',') AS "probes"
+    FROM tmptable
+    GROUP BY client_ip,
+             country
+)
+
+/* IPs probing paths the shop never serves, or enumerating paths hitting each once or twice */
+SELECT client_ip,
+       country,
+       CASE
+           WHEN num_probe_uris >= {{.MinProbeURIs}} AND CAST(num_probes AS DOUBLE) / num_requests >= {{.MinProbeRatio}} THEN 'scanner'
+           WHEN num_probe_uris >= {{.MinProbeURIs}} THEN 'mixed'
+           ELSE 'enumerator'
+       END AS "class",
+       num_requests,
+       num_uris,
+       num_probes,
+       num_probe_uris,
+       ROUND(CAST(num_probes AS DOUBLE) / num_requests, 4) AS "probe_ratio",
+       probes,
+       num_probes_allowed,
+       num_blocked,
+       num_user_agents,
+       user_agent
+FROM identities
+WHERE num_probe_uris >= {{.MinProbeURIs}}
+   OR (num_uris >= {{.MinURIs}} AND num_requests <= 2 * num_uris)
+ORDER BY num_probe_uris DESC, num_uris DESC, client_ip ASC
+LIMIT {{.Limit}};
@@ -0,0 +1,27 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.uri AS "uri",
+           action,
+           /* the first probe the URI matches */
+           CASE
+{{- range .Probes}}
+               WHEN REGEXP_LIKE(httprequest.uri, '{{.Pattern}}') THEN '{{.Name}}'
+{{- end}}
+           END AS "probe"
+    FROM {{.WafTable}}
+    WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+)
+
+/* the probed URIs, those allowed through first */
+SELECT probe,
+       uri,
+       COUNT(*) AS "num_requests",
+       COUNT(DISTINCT client_ip) AS "num_ips",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       COUNT_IF(action != 'BLOCK') AS "num_allowed"
+FROM tmptable
+WHERE probe IS NOT NULL
+GROUP BY probe,
+         uri
+ORDER BY num_allowed DESC, num_ips DESC, uri ASC
+LIMIT {{.Limit}};
@@ -0,0 +1,28 @@
+WITH
+{{- range $i, $c := .Candidates}}
+{{- if $i}},{{end}}
+candidate_{{$i}} AS (
+    /* windows of {{$c.Key}} with more than {{$c.Limit}} requests per {{$c.Window}} */
+{{$c.Windows}}
+)
+{{- end}}
+
+{{- range $i, $c := .Candidates}}
+{{- if $i}}
+
+UNION ALL
+{{- end}}
+
+SELECT {{$i}} AS "candidate",
+       '{{$c.Key}}' AS "key",
+       '{{$c.Window}}' AS "window_size",
+       {{$c.Limit}} AS "rate_limit",
+       COUNT(DISTINCT {{$c.Identity}}) AS "num_identities",
+       COALESCE(SUM(num_affected), 0) AS "num_requests",
+       COALESCE(SUM(num_blocked), 0) AS "num_already_blocked",
+       COALESCE(SUM(num_affected - num_blocked), 0) AS "num_newly_blocked",
+       COUNT(DISTINCT CASE WHEN num_known_good > 0 THEN {{$c.Identity}} END) AS "num_known_good_identities",
+       COALESCE(SUM(num_known_good), 0) AS "num_known_good"
+FROM candidate_{{$i}}
+{{- end}}
+ORDER BY candidate ASC;
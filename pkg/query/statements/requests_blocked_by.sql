@@ -2,12 +2,15 @@ WITH tmptable AS (
     SELECT httprequest.clientip AS "client_ip",
            httprequest.country AS "country",
            try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
-           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> SUBSTR(label.name, 41))[1]) AS "bot_name",
-           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> SUBSTR(label.name, 45))[1]) AS "bot_category",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
            terminatingruleid AS "terminating_rule",
            timestamp
     FROM {{.WafTable}}
     WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+{{- with .LabelPredicate}}
+      AND {{.}}
+{{- end}}
       AND action = 'BLOCK'
 )
 
@@ -0,0 +1,42 @@
+WITH waflog AS (
+
+SELECT * FROM waflog_{{.WAF}}_{{.Year}}_{{.Month}}_{{.Day}}
+
+), scraper_sessions AS (
+
+/* sessions of campaign {{.Campaign}}, rotating through more than {{.MinUserAgents}} User-Agents */
+SELECT DISTINCT c_session
+FROM waflog
+WHERE c_session != ''
+{{- with .AllowlistRules}}
+  AND terminating_rule NOT IN (VALUES {{.}}) /* ignore known bots */
+{{- end}}
+  AND signal_nobrowser = False /* only user_agents that don't reveal themselves as bots */
+GROUP BY c_session
+HAVING CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) > {{.MinUserAgents}}
+
+), scraper_user_agents AS (
+
+SELECT DISTINCT user_agent, COUNT(*) AS "num_requests"
+FROM waflog INNER JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
+GROUP BY user_agent
+HAVING COUNT(*) > {{.MinRequests}}
+
+), scraped_products AS (
+
+SELECT REGEXP_EXTRACT(CONCAT(uri, '?', COALESCE(params, '')), '{{.ProductPattern}}', 1) AS "product",
+       user_agent
+FROM waflog INNER JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
+WHERE user_agent IN (SELECT user_agent FROM scraper_user_agents)
+  AND REGEXP_LIKE(uri, '{{.URIPattern}}')
+
+)
+
+SELECT product,
+       COUNT(*) AS "num_requests",
+       CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) AS "user_agents"
+FROM scraped_products
+WHERE product IS NOT NULL
+GROUP BY product
+ORDER BY num_requests DESC, product ASC
+LIMIT {{.Limit}}
@@ -0,0 +1,37 @@
+WITH waflog AS (
+
+SELECT * FROM waflog_{{.WAF}}_{{.Year}}_{{.Month}}_{{.Day}}
+
+), scraper_sessions AS (
+
+/* sessions of campaign {{.Campaign}}, rotating through more than {{.MinUserAgents}} User-Agents */
+SELECT DISTINCT c_session
+FROM waflog
+WHERE c_session != ''
+{{- with .AllowlistRules}}
+  AND terminating_rule NOT IN (VALUES {{.}}) /* ignore known bots */
+{{- end}}
+  AND signal_nobrowser = False /* only user_agents that don't reveal themselves as bots */
+GROUP BY c_session
+HAVING CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) > {{.MinUserAgents}}
+
+), scraped_urls AS (
+
+SELECT uri_c,
+       COUNT(*) AS "num_requests",
+       ARRAY_SORT(ARRAY_AGG(DISTINCT day)) AS "days",
+       CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) AS "user_agents",
+       CARDINALITY(ARRAY_AGG(DISTINCT uri)) AS "full_uris",
+       CARDINALITY(ARRAY_AGG(DISTINCT waflog.c_session)) AS "sessions",
+       COUNT_IF(REGEXP_LIKE(uri, '{{.URIPattern}}')) > 0 AS "target",
+       HOUR(FROM_UNIXTIME(MIN(TO_UNIXTIME(timestamp)))) AS "first_hour",
+       HOUR(FROM_UNIXTIME(MAX(TO_UNIXTIME(timestamp))))+1 AS "last_hour"
+FROM waflog INNER JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
+GROUP BY uri_c
+
+)
+
+SELECT *
+FROM scraped_urls
+ORDER BY num_requests DESC, uri_c ASC
+LIMIT {{.Limit}}
@@ -0,0 +1,35 @@
+WITH waflog AS (
+
+SELECT * FROM waflog_{{.WAF}}_{{.Year}}_{{.Month}}_{{.Day}}
+
+), scraper_sessions AS (
+
+/* sessions of campaign {{.Campaign}}, rotating through more than {{.MinUserAgents}} User-Agents */
+SELECT DISTINCT c_session
+FROM waflog
+WHERE c_session != ''
+{{- with .AllowlistRules}}
+  AND terminating_rule NOT IN (VALUES {{.}}) /* ignore known bots */
+{{- end}}
+  AND signal_nobrowser = False /* only user_agents that don't reveal themselves as bots */
+GROUP BY c_session
+HAVING CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) > {{.MinUserAgents}}
+
+), scraper_user_agents AS (
+
+SELECT DISTINCT user_agent, COUNT(*) AS "num_requests"
+FROM waflog INNER JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
+GROUP BY user_agent
+HAVING COUNT(*) > {{.MinRequests}}
+)
+
+SELECT user_agent,
+       COUNT(*) AS "num_requests",
+       COUNT(scraper_sessions.c_session) AS "num_scraped",
+       COUNT(*) - COUNT(scraper_sessions.c_session) AS "diff",
+       HOUR(FROM_UNIXTIME(MAX(TO_UNIXTIME(timestamp))) - FROM_UNIXTIME(MIN(TO_UNIXTIME(timestamp)))) AS "window_in_hours"
+FROM waflog LEFT JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
+WHERE user_agent IN (SELECT user_agent FROM scraper_user_agents)
+GROUP BY user_agent
+ORDER BY num_requests DESC, user_agent ASC
+LIMIT {{.Limit}}
@@ -0,0 +1,46 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           COALESCE(CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:bot:verified')), 0) > 0 AS "bot_verified",
+           terminatingruleid AS "terminating_rule",
+           action
+    FROM {{.WafTable}}
+    WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+), claimed AS (
+    SELECT *,
+           /* the search engine crawler the User-Agent claims to be */
+           CASE
+{{- range .Crawlers}}
+               WHEN REGEXP_LIKE(user_agent, '{{.Pattern}}') THEN '{{.Name}}'
+{{- end}}
+           END AS "claimed_bot"
+    FROM tmptable
+    WHERE NOT bot_verified
+)
+
+/* impostors of search engine crawlers, Bot Control did not verify their IPs */
+SELECT claimed_bot,
+       client_ip,
+       country,
+       user_agent,
+       COALESCE(bot_name, '') AS "bot_name",
+       COUNT(*) AS "num_requests",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       COUNT_IF(action != 'BLOCK') AS "num_allowed",
+{{- if .CrawlerRules}}
+       COUNT_IF(terminating_rule IN (VALUES {{.CrawlerRules}})) AS "num_allowed_as_crawler",
+{{- else}}
+       0 AS "num_allowed_as_crawler",
+{{- end}}
+       ARRAY_JOIN(ARRAY_SORT(ARRAY_AGG(DISTINCT terminating_rule)), ',') AS "terminating_rules"
+FROM claimed
+WHERE claimed_bot IS NOT NULL
+GROUP BY claimed_bot,
+         client_ip,
+         country,
+         user_agent,
+         bot_name
+ORDER BY num_allowed_as_crawler DESC, num_requests DESC, client_ip ASC
+LIMIT {{.Limit}};
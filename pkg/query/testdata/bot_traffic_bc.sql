@@ -0,0 +1,37 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           COALESCE(CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:bot:verified')), 0) > 0 AS "bot_verified",
+           terminatingruleid AS "terminating_rule",
+           action
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+), bots AS (
+    SELECT client_ip,
+           COALESCE(bot_category, '') AS "bot_category",
+           COALESCE(bot_name, '') AS "bot_name",
+           CASE
+               WHEN bot_verified THEN 'verified'
+               WHEN bot_name IS NOT NULL OR bot_category IS NOT NULL THEN 'unverified'
+               ELSE 'none'
+           END AS "status",
+           terminating_rule,
+           action
+    FROM tmptable
+)
+
+SELECT bot_category,
+       bot_name,
+       status,
+       COUNT(*) AS "num_requests",
+       COUNT(DISTINCT client_ip) AS "num_ips",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       COUNT_IF(action != 'BLOCK') AS "num_allowed",
+       ARRAY_JOIN(ARRAY_SORT(ARRAY_AGG(DISTINCT terminating_rule)), ',') AS "terminating_rules"
+FROM bots
+GROUP BY bot_category,
+         bot_name,
+         status
+ORDER BY num_requests DESC, bot_category ASC, bot_name ASC, status ASC
+LIMIT 1000;
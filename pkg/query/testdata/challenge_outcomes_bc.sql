@@ -0,0 +1,100 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           COALESCE(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]), '') AS "user_agent",
+           terminatingruleid AS "terminating_rule",
+           action,
+           nonterminatingmatchingrules,
+           captcharesponse,
+           challengeresponse,
+           CAST(FLOOR(timestamp/1000) AS BIGINT) AS "seconds"
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+      AND (captcharesponse IS NOT NULL OR challengeresponse IS NOT NULL)
+), responses AS (
+    /* the rule with the CAPTCHA or Challenge action is terminating when it sends a puzzle, else non-terminating */
+    SELECT 'captcha' AS "kind",
+           client_ip,
+           country,
+           user_agent,
+           CASE
+               WHEN action = 'CAPTCHA' THEN terminating_rule
+               ELSE COALESCE(try((transform(filter(nonterminatingmatchingrules, r -> r.action = 'CAPTCHA'), r -> r.ruleid))[1]), terminating_rule)
+           END AS "rule",
+           captcharesponse.responsecode AS "response_code",
+           captcharesponse.solvetimestamp AS "solve_timestamp",
+           captcharesponse.failurereason AS "failure_reason",
+           seconds
+    FROM tmptable
+    WHERE captcharesponse IS NOT NULL
+    UNION ALL
+    SELECT 'challenge' AS "kind",
+           client_ip,
+           country,
+           user_agent,
+           CASE
+               WHEN action = 'CHALLENGE' THEN terminating_rule
+               ELSE COALESCE(try((transform(filter(nonterminatingmatchingrules, r -> r.action = 'CHALLENGE'), r -> r.ruleid))[1]), terminating_rule)
+           END AS "rule",
+           challengeresponse.responsecode AS "response_code",
+           challengeresponse.solvetimestamp AS "solve_timestamp",
+           challengeresponse.failurereason AS "failure_reason",
+           seconds
+    FROM tmptable
+    WHERE challengeresponse IS NOT NULL
+), outcomes AS (
+    SELECT *,
+           /* a valid token passes, a missing one gets a puzzle, an expired or invalid one fails and gets a new puzzle */
+           CASE
+               WHEN response_code = 0 THEN 'solved'
+               WHEN COALESCE(failure_reason, 'TOKEN_MISSING') = 'TOKEN_MISSING' THEN 'issued'
+               ELSE 'failed'
+           END AS "outcome"
+    FROM responses
+), keyed AS (
+    SELECT 'rule' AS "dimension", rule AS "identity", kind, outcome, solve_timestamp, client_ip
+    FROM outcomes
+    UNION ALL
+    SELECT 'country' AS "dimension", country AS "identity", kind, outcome, solve_timestamp, client_ip
+    FROM outcomes
+    UNION ALL
+    SELECT 'ip' AS "dimension", client_ip AS "identity", kind, outcome, solve_timestamp, client_ip
+    FROM outcomes
+    UNION ALL
+    SELECT 'user_agent' AS "dimension", user_agent AS "identity", kind, outcome, solve_timestamp, client_ip
+    FROM outcomes
+), totals AS (
+    SELECT dimension,
+           identity,
+           kind,
+           COUNT(*) AS "num_requests",
+           COUNT_IF(outcome = 'issued') AS "num_issued",
+           COUNT_IF(outcome = 'solved') AS "num_solved",
+           COUNT_IF(outcome = 'failed') AS "num_failed",
+           /* the requests passing with the token of one solved puzzle share its solve timestamp */
+           COUNT(DISTINCT CASE WHEN solve_timestamp > 0 THEN solve_timestamp END) AS "num_solves",
+           COUNT(DISTINCT client_ip) AS "num_ips"
+    FROM keyed
+    GROUP BY dimension,
+             identity,
+             kind
+), ranked AS (
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY dimension, kind ORDER BY num_requests DESC, identity ASC) AS "rank"
+    FROM totals
+)
+
+SELECT dimension,
+       identity,
+       kind,
+       num_requests,
+       num_issued,
+       num_solved,
+       num_failed,
+       num_solves,
+       /* every request issued or failing gets a puzzle */
+       ROUND(CAST(num_solves AS DOUBLE) / NULLIF(num_issued + num_failed, 0), 4) AS "solve_rate",
+       num_ips
+FROM ranked
+WHERE rank <= 20
+ORDER BY dimension ASC, kind ASC, num_requests DESC, identity ASC;
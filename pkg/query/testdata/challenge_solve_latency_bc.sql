@@ -0,0 +1,105 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           COALESCE(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]), '') AS "user_agent",
+           terminatingruleid AS "terminating_rule",
+           action,
+           nonterminatingmatchingrules,
+           captcharesponse,
+           challengeresponse,
+           CAST(FLOOR(timestamp/1000) AS BIGINT) AS "seconds"
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+      AND (captcharesponse IS NOT NULL OR challengeresponse IS NOT NULL)
+), responses AS (
+    /* the rule with the CAPTCHA or Challenge action is terminating when it sends a puzzle, else non-terminating */
+    SELECT 'captcha' AS "kind",
+           client_ip,
+           country,
+           user_agent,
+           CASE
+               WHEN action = 'CAPTCHA' THEN terminating_rule
+               ELSE COALESCE(try((transform(filter(nonterminatingmatchingrules, r -> r.action = 'CAPTCHA'), r -> r.ruleid))[1]), terminating_rule)
+           END AS "rule",
+           captcharesponse.responsecode AS "response_code",
+           captcharesponse.solvetimestamp AS "solve_timestamp",
+           captcharesponse.failurereason AS "failure_reason",
+           seconds
+    FROM tmptable
+    WHERE captcharesponse IS NOT NULL
+    UNION ALL
+    SELECT 'challenge' AS "kind",
+           client_ip,
+           country,
+           user_agent,
+           CASE
+               WHEN action = 'CHALLENGE' THEN terminating_rule
+               ELSE COALESCE(try((transform(filter(nonterminatingmatchingrules, r -> r.action = 'CHALLENGE'), r -> r.ruleid))[1]), terminating_rule)
+           END AS "rule",
+           challengeresponse.responsecode AS "response_code",
+           challengeresponse.solvetimestamp AS "solve_timestamp",
+           challengeresponse.failurereason AS "failure_reason",
+           seconds
+    FROM tmptable
+    WHERE challengeresponse IS NOT NULL
+), outcomes AS (
+    SELECT *,
+           /* a valid token passes, a missing one gets a puzzle, an expired or invalid one fails and gets a new puzzle */
+           CASE
+               WHEN response_code = 0 THEN 'solved'
+               WHEN COALESCE(failure_reason, 'TOKEN_MISSING') = 'TOKEN_MISSING' THEN 'issued'
+               ELSE 'failed'
+           END AS "outcome"
+    FROM responses
+), latencies AS (
+    /* seconds from the last puzzle sent to the IP and User-Agent within 5 minutes before the solve, NULL if none was */
+    SELECT solves.kind,
+           solves.client_ip,
+           solves.country,
+           solves.user_agent,
+           solves.solve_timestamp,
+           solves.solve_timestamp - MAX(puzzles.seconds) AS "latency"
+    FROM (
+        SELECT DISTINCT kind, client_ip, country, user_agent, solve_timestamp
+        FROM outcomes
+        WHERE solve_timestamp > 0
+    ) AS solves
+    LEFT JOIN (
+        SELECT kind, client_ip, user_agent, seconds
+        FROM outcomes
+        WHERE outcome != 'solved'
+    ) AS puzzles
+      ON puzzles.kind = solves.kind
+     AND puzzles.client_ip = solves.client_ip
+     AND puzzles.user_agent = solves.user_agent
+     AND puzzles.seconds <= solves.solve_timestamp
+     AND puzzles.seconds > solves.solve_timestamp - 300
+    GROUP BY solves.kind,
+             solves.client_ip,
+             solves.country,
+             solves.user_agent,
+             solves.solve_timestamp
+), buckets AS (
+    SELECT kind,
+           client_ip,
+           CASE
+               WHEN latency IS NULL THEN -1
+               WHEN latency < 2 THEN 0
+               WHEN latency < 5 THEN 2
+               WHEN latency < 10 THEN 5
+               WHEN latency < 30 THEN 10
+               WHEN latency < 60 THEN 30
+               ELSE 60
+           END AS "from_seconds"
+    FROM latencies
+)
+
+/* solves per latency, those of tokens without a puzzle sent to the IP and User-Agent from -1 */
+SELECT kind,
+       from_seconds,
+       COUNT(*) AS "num_solves",
+       COUNT(DISTINCT client_ip) AS "num_ips"
+FROM buckets
+GROUP BY kind,
+         from_seconds
+ORDER BY kind ASC, from_seconds ASC;
@@ -0,0 +1,116 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           COALESCE(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]), '') AS "user_agent",
+           terminatingruleid AS "terminating_rule",
+           action,
+           nonterminatingmatchingrules,
+           captcharesponse,
+           challengeresponse,
+           CAST(FLOOR(timestamp/1000) AS BIGINT) AS "seconds"
+    FROM "waflogs"."waf_logs_ecp_p"
+    WHERE day = '2023/12/01'
+      AND (captcharesponse IS NOT NULL OR challengeresponse IS NOT NULL)
+), responses AS (
+    /* the rule with the CAPTCHA or Challenge action is terminating when it sends a puzzle, else non-terminating */
+    SELECT 'captcha' AS "kind",
+           client_ip,
+           country,
+           user_agent,
+           CASE
+               WHEN action = 'CAPTCHA' THEN terminating_rule
+               ELSE COALESCE(try((transform(filter(nonterminatingmatchingrules, r -> r.action = 'CAPTCHA'), r -> r.ruleid))[1]), terminating_rule)
+           END AS "rule",
+           captcharesponse.responsecode AS "response_code",
+           captcharesponse.solvetimestamp AS "solve_timestamp",
+           captcharesponse.failurereason AS "failure_reason",
+           seconds
+    FROM tmptable
+    WHERE captcharesponse IS NOT NULL
+    UNION ALL
+    SELECT 'challenge' AS "kind",
+           client_ip,
+           country,
+           user_agent,
+           CASE
+               WHEN action = 'CHALLENGE' THEN terminating_rule
+               ELSE COALESCE(try((transform(filter(nonterminatingmatchingrules, r -> r.action = 'CHALLENGE'), r -> r.ruleid))[1]), terminating_rule)
+           END AS "rule",
+           challengeresponse.responsecode AS "response_code",
+           challengeresponse.solvetimestamp AS "solve_timestamp",
+           challengeresponse.failurereason AS "failure_reason",
+           seconds
+    FROM tmptable
+    WHERE challengeresponse IS NOT NULL
+), outcomes AS (
+    SELECT *,
+           /* a valid token passes, a missing one gets a puzzle, an expired or invalid one fails and gets a new puzzle */
+           CASE
+               WHEN response_code = 0 THEN 'solved'
+               WHEN COALESCE(failure_reason, 'TOKEN_MISSING') = 'TOKEN_MISSING' THEN 'issued'
+               ELSE 'failed'
+           END AS "outcome"
+    FROM responses
+), latencies AS (
+    /* seconds from the last puzzle sent to the IP and User-Agent within 5 minutes before the solve, NULL if none was */
+    SELECT solves.kind,
+           solves.client_ip,
+           solves.country,
+           solves.user_agent,
+           solves.solve_timestamp,
+           solves.solve_timestamp - MAX(puzzles.seconds) AS "latency"
+    FROM (
+        SELECT DISTINCT kind, client_ip, country, user_agent, solve_timestamp
+        FROM outcomes
+        WHERE solve_timestamp > 0
+    ) AS solves
+    LEFT JOIN (
+        SELECT kind, client_ip, user_agent, seconds
+        FROM outcomes
+        WHERE outcome != 'solved'
+    ) AS puzzles
+      ON puzzles.kind = solves.kind
+     AND puzzles.client_ip = solves.client_ip
+     AND puzzles.user_agent = solves.user_agent
+     AND puzzles.seconds <= solves.solve_timestamp
+     AND puzzles.seconds > solves.solve_timestamp - 300
+    GROUP BY solves.kind,
+             solves.client_ip,
+             solves.country,
+             solves.user_agent,
+             solves.solve_timestamp
+), solvers AS (
+    SELECT kind,
+           client_ip,
+           country,
+           user_agent,
+           COUNT(*) AS "num_solves",
+           /* only humans solving a CAPTCHA take time, browsers solve challenges silently */
+           COUNT_IF(kind = 'captcha' AND latency < 3) AS "num_fast_solves",
+           /* tokens solved elsewhere, e.g. handed out by a CAPTCHA farm */
+           COUNT_IF(latency IS NULL) AS "num_foreign_solves",
+           MIN(latency) AS "min_latency",
+           ROUND(AVG(latency), 1) AS "avg_latency"
+    FROM latencies
+    GROUP BY kind,
+             client_ip,
+             country,
+             user_agent
+)
+
+/* IPs and User-Agents solving often, fast or with tokens solved elsewhere */
+SELECT kind,
+       client_ip,
+       country,
+       user_agent,
+       num_solves,
+       num_fast_solves,
+       num_foreign_solves,
+       min_latency,
+       avg_latency
+FROM solvers
+WHERE num_solves >= 10
+   OR num_fast_solves > 0
+   OR num_foreign_solves > 0
+ORDER BY num_fast_solves + num_foreign_solves DESC, num_solves DESC, client_ip ASC
+LIMIT 1000;
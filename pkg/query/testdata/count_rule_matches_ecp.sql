@@ -0,0 +1,38 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.uri AS "uri",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           action,
+           terminatingruleid AS "terminating_rule",
+           nonterminatingmatchingrules AS "nonterminating_rules",
+           rulegrouplist AS "rule_groups"
+    FROM "waflogs"."waf_logs_ecp_p"
+    WHERE day = '2023/12/01'
+), count_matches AS (
+    /* rules of the web ACL in COUNT mode */
+    SELECT client_ip, uri, user_agent, action, terminating_rule, count_rule
+    FROM tmptable
+    CROSS JOIN UNNEST(TRANSFORM(FILTER(nonterminating_rules, rule -> rule.action = 'COUNT'), rule -> rule.ruleid)) AS t(count_rule)
+
+    UNION ALL
+
+    /* rules inside rule groups in COUNT mode, named <rule group>/<rule> */
+    SELECT client_ip, uri, user_agent, action, terminating_rule, count_rule
+    FROM tmptable
+    CROSS JOIN UNNEST(FLATTEN(TRANSFORM(
+        FILTER(rule_groups, grp -> grp.nonterminatingmatchingrules IS NOT NULL),
+        grp -> TRANSFORM(FILTER(grp.nonterminatingmatchingrules, rule -> rule.action = 'COUNT'), rule -> CONCAT(grp.rulegroupid, '/', rule.ruleid))
+    ))) AS t(count_rule)
+)
+
+SELECT count_rule,
+       COUNT(*) AS "num_requests",
+       COUNT_IF(terminating_rule = 'Default_Action') AS "num_allowed_by_default_action",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked_by_other_rules",
+       COUNT(DISTINCT client_ip) AS "num_ips",
+       COUNT(DISTINCT user_agent) AS "num_user_agents",
+       COUNT(DISTINCT uri) AS "num_uris"
+FROM count_matches
+GROUP BY count_rule
+ORDER BY num_requests DESC
+LIMIT 100;
@@ -0,0 +1,45 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           httprequest.uri AS "uri",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           terminatingruleid AS "terminating_rule",
+           nonterminatingmatchingrules AS "nonterminating_rules",
+           rulegrouplist AS "rule_groups"
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+), count_matches AS (
+    /* rules of the web ACL in COUNT mode */
+    SELECT client_ip, country, terminating_rule, count_rule
+    FROM tmptable
+    CROSS JOIN UNNEST(TRANSFORM(FILTER(nonterminating_rules, rule -> rule.action = 'COUNT'), rule -> rule.ruleid)) AS t(count_rule)
+
+    UNION ALL
+
+    /* rules inside rule groups in COUNT mode, named <rule group>/<rule> */
+    SELECT client_ip, country, terminating_rule, count_rule
+    FROM tmptable
+    CROSS JOIN UNNEST(FLATTEN(TRANSFORM(
+        FILTER(rule_groups, grp -> grp.nonterminatingmatchingrules IS NOT NULL),
+        grp -> TRANSFORM(FILTER(grp.nonterminatingmatchingrules, rule -> rule.action = 'COUNT'), rule -> CONCAT(grp.rulegroupid, '/', rule.ruleid))
+    ))) AS t(count_rule)
+), ranked AS (
+    SELECT count_rule,
+           client_ip, country,
+           COUNT(*) AS "num_requests",
+           COUNT_IF(terminating_rule = 'Default_Action') AS "num_allowed_by_default_action",
+           ROW_NUMBER() OVER (PARTITION BY count_rule ORDER BY COUNT(*) DESC) AS "rule_rank"
+    FROM count_matches
+    GROUP BY count_rule,
+             client_ip, country
+)
+
+SELECT count_rule,
+       client_ip, country,
+       num_requests,
+       num_allowed_by_default_action
+FROM ranked
+WHERE rule_rank <= 20
+ORDER BY count_rule ASC, num_requests DESC;
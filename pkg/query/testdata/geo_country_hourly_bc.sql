@@ -0,0 +1,12 @@
+SELECT httprequest.country AS "country",
+       day AS "date",
+       HOUR(from_unixtime(timestamp/1000)) AS "hour",
+       COUNT(*) AS "num_requests",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       COUNT_IF(action = 'BLOCK' AND terminatingruleid = 'rate-limit') AS "num_rate_limited"
+FROM "waflogs"."waf_logs_p"
+WHERE day BETWEEN '2023/02/14' AND '2023/02/21'
+GROUP BY httprequest.country,
+         day,
+         HOUR(from_unixtime(timestamp/1000))
+ORDER BY date ASC, hour ASC, num_requests DESC;
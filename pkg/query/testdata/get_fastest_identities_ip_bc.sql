@@ -0,0 +1,24 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent')) > 0 AS "signal_nobrowser",
+           terminatingruleid AS "terminating_rule",
+           from_unixtime(FLOOR(timestamp/(1000*60*5))*60*5) as "time_window",
+           timestamp
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+)
+
+SELECT client_ip, country,
+       time_window,
+       COUNT(*) AS "num_requests"
+FROM tmptable
+WHERE terminating_rule IN (VALUES 'Default_Action', 'rate-limit')
+GROUP BY client_ip, country,
+         time_window
+HAVING COUNT(*) > 400
+ORDER BY num_requests DESC
+LIMIT 1000;
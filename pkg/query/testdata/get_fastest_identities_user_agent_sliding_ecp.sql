@@ -0,0 +1,46 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent')) > 0 AS "signal_nobrowser",
+           terminatingruleid AS "terminating_rule",
+           FLOOR(timestamp/(1000*60)) AS "minute",
+           timestamp
+    FROM "waflogs"."waf_logs_ecp_p"
+    WHERE day = '2023/12/01'
+      AND ANY_MATCH(labels, label -> (NOT STARTS_WITH(label.name, 'awswaf:managed:') OR STARTS_WITH(label.name, 'awswaf:managed:aws:bot-control:signal:')))
+), minutes AS (
+    SELECT bot_name, bot_category, user_agent,
+           minute,
+           COUNT(*) AS "num_requests"
+    FROM tmptable
+    WHERE signal_nobrowser
+    GROUP BY bot_name, bot_category, user_agent,
+             minute
+), windows AS (
+    /* approximates the sliding window of WAF rate-based rules with a resolution of one minute */
+    SELECT bot_name, bot_category, user_agent,
+           from_unixtime((minute-2+1)*60) AS "time_window",
+           SUM(num_requests) OVER (
+               PARTITION BY bot_name, bot_category, user_agent
+               ORDER BY minute
+               RANGE BETWEEN 1 PRECEDING AND CURRENT ROW
+           ) AS "num_requests"
+    FROM minutes
+), peaks AS (
+    /* the peak window of each identity, its overlapping windows would fill the top list */
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY bot_name, bot_category, user_agent ORDER BY num_requests DESC, time_window ASC) AS "peak"
+    FROM windows
+    WHERE num_requests > 20
+)
+
+SELECT bot_name, bot_category, user_agent,
+       time_window,
+       num_requests
+FROM peaks
+WHERE peak = 1
+ORDER BY num_requests DESC
+LIMIT 1000;
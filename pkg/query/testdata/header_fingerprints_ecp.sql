@@ -0,0 +1,60 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'accept'), header -> header.value))[1]) AS "accept",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'accept-language'), header -> header.value))[1]) AS "accept_language",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'accept-encoding'), header -> header.value))[1]) AS "accept_encoding",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'sec-ch-ua'), header -> header.value))[1]) AS "sec_ch_ua",
+           /* the order of the headers a client sends on every request */
+           ARRAY_JOIN(transform(filter(httprequest.headers, header -> LOWER(header.name) NOT IN ('cookie', 'referer', 'origin', 'content-length', 'content-type', 'cache-control', 'pragma', 'if-none-match', 'if-modified-since', 'x-forwarded-for', 'x-requested-with', 'via')), header -> LOWER(header.name)), ',') AS "header_order",
+           COALESCE(CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent')), 0) > 0 AS "signal_nobrowser",
+           action
+    FROM "waflogs"."waf_logs_ecp_p"
+    WHERE day = '2023/12/01'
+), claims AS (
+    SELECT *,
+           /* the browser the User-Agent claims to be */
+           CASE
+               WHEN REGEXP_LIKE(user_agent, 'Edg/[0-9]+') THEN 'edge'
+               WHEN REGEXP_LIKE(user_agent, 'OPR/[0-9]+') THEN 'opera'
+               WHEN REGEXP_LIKE(user_agent, 'SamsungBrowser/[0-9]+') THEN 'samsung'
+               WHEN REGEXP_LIKE(user_agent, 'YaBrowser/[0-9]+') THEN 'yandex'
+               WHEN REGEXP_LIKE(user_agent, 'Firefox/[0-9]+') THEN 'firefox'
+               WHEN REGEXP_LIKE(user_agent, 'Chrome/[0-9]+') THEN 'chrome'
+               WHEN REGEXP_LIKE(user_agent, 'Version/[0-9.]+( Mobile/[0-9A-Z]+)? Safari/') THEN 'safari'
+           END AS "browser",
+           REGEXP_EXTRACT(user_agent, 'Chrome/([0-9]+)', 1) AS "chrome_version"
+    FROM tmptable
+    WHERE user_agent LIKE 'Mozilla/5.0 (%'
+), checked AS (
+    SELECT *,
+           /* the headers the browser claimed always sends, or never does */
+           ARRAY_JOIN(FILTER(ARRAY[
+               CASE WHEN accept IS NULL THEN 'no_accept' END,
+               CASE WHEN accept_language IS NULL THEN 'no_accept_language' END,
+               CASE WHEN accept_encoding IS NULL OR STRPOS(accept_encoding, 'gzip') = 0 THEN 'no_gzip' END,
+               /* browsers based on Chromium send sec-ch-ua with the version of their Chromium */
+               CASE WHEN chrome_version IS NOT NULL AND sec_ch_ua IS NULL THEN 'no_sec_ch_ua' END,
+               CASE WHEN chrome_version IS NOT NULL AND STRPOS(sec_ch_ua, 'v="' || chrome_version || '"') = 0 THEN 'sec_ch_ua_mismatch' END,
+               CASE WHEN browser IN ('firefox', 'safari') AND sec_ch_ua IS NOT NULL THEN 'unexpected_sec_ch_ua' END
+           ], anomaly -> anomaly IS NOT NULL), ',') AS "anomalies"
+    FROM claims
+    WHERE browser IS NOT NULL
+)
+
+SELECT browser,
+       header_order,
+       anomalies,
+       COUNT(*) AS "num_requests",
+       COUNT(DISTINCT client_ip) AS "num_ips",
+       COUNT(DISTINCT user_agent) AS "num_user_agents",
+       COUNT_IF(NOT signal_nobrowser) AS "num_missed",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       MIN(user_agent) AS "user_agent"
+FROM checked
+GROUP BY browser,
+         header_order,
+         anomalies
+ORDER BY num_ips DESC, num_requests DESC, browser ASC, header_order ASC
+LIMIT 1000;
@@ -0,0 +1,35 @@
+WITH tmptable AS (
+    SELECT labels
+    FROM "waflogs"."waf_logs_ecp_p"
+    WHERE day = '2023/12/01'
+), request_labels AS (
+    SELECT ARRAY_SORT(ARRAY_DISTINCT(FILTER(TRANSFORM(labels, label -> label.name), label -> (NOT STARTS_WITH(label, 'awswaf:managed:'))))) AS "labels"
+    FROM tmptable
+), label_counts AS (
+    SELECT label,
+           COUNT(*) AS "num_requests"
+    FROM request_labels
+    CROSS JOIN UNNEST(labels) AS t(label)
+    GROUP BY label
+), pairs AS (
+    SELECT label_a,
+           label_b,
+           COUNT(*) AS "num_requests"
+    FROM request_labels
+    CROSS JOIN UNNEST(labels) WITH ORDINALITY AS a(label_a, pos_a)
+    CROSS JOIN UNNEST(labels) WITH ORDINALITY AS b(label_b, pos_b)
+    WHERE pos_a < pos_b
+    GROUP BY label_a,
+             label_b
+)
+
+SELECT label_a,
+       label_b,
+       pairs.num_requests,
+       ROUND(1.0 * pairs.num_requests / a.num_requests, 4) AS "share_of_a",
+       ROUND(1.0 * pairs.num_requests / b.num_requests, 4) AS "share_of_b"
+FROM pairs
+INNER JOIN label_counts a ON pairs.label_a = a.label
+INNER JOIN label_counts b ON pairs.label_b = b.label
+ORDER BY pairs.num_requests DESC
+LIMIT 1000;
@@ -0,0 +1,22 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           action,
+           labels
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+), request_labels AS (
+    SELECT client_ip, action, label
+    FROM tmptable
+    CROSS JOIN UNNEST(ARRAY_DISTINCT(TRANSFORM(labels, label -> label.name))) AS t(label)
+    WHERE TRUE
+)
+
+SELECT label,
+       COUNT(*) AS "num_requests",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked",
+       COUNT(DISTINCT client_ip) AS "num_ips",
+       ROUND(100.0 * COUNT(*) / (SELECT COUNT(*) FROM tmptable), 3) AS "percent_of_requests"
+FROM request_labels
+GROUP BY label
+ORDER BY num_requests DESC
+LIMIT 1000;
@@ -0,0 +1,26 @@
+WITH tmptable AS (
+    SELECT day,
+           action,
+           labels
+    FROM "waflogs"."waf_logs_ecp_p"
+    WHERE day BETWEEN '2023/11/25' AND '2023/12/01'
+), top_labels AS (
+    SELECT label
+    FROM tmptable
+    CROSS JOIN UNNEST(ARRAY_DISTINCT(TRANSFORM(labels, label -> label.name))) AS t(label)
+    WHERE TRUE
+    GROUP BY label
+    ORDER BY COUNT(*) DESC
+    LIMIT 50
+)
+
+SELECT label,
+       day,
+       COUNT(*) AS "num_requests",
+       COUNT_IF(action = 'BLOCK') AS "num_blocked"
+FROM tmptable
+CROSS JOIN UNNEST(ARRAY_DISTINCT(TRANSFORM(labels, label -> label.name))) AS t(label)
+WHERE label IN (SELECT label FROM top_labels)
+GROUP BY label,
+         day
+ORDER BY label ASC, day ASC;
@@ -0,0 +1,83 @@
+WITH posts AS (
+    SELECT httprequest.clientip AS "client_ip",
+           /* the /24 network of IPv4 addresses, the /48 of IPv6 addresses */
+           CAST(ip_prefix(
+               CAST(httprequest.clientip AS IPADDRESS),
+               CASE WHEN STRPOS(httprequest.clientip, ':') > 0 THEN 48 ELSE 24 END
+           ) AS VARCHAR) AS "prefix",
+           httprequest.country AS "country",
+           TRY(TRANSFORM(FILTER(SPLIT(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'cookie'), header -> header.value))[1]), ';'), kv -> SUBSTR(TRIM(LOWER(kv)), 1, 7) = 'session'), kv -> SPLIT(TRIM(kv), '=')[2])[1]) AS "session",
+           ja3fingerprint AS "fingerprint",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           action,
+           FILTER(TRANSFORM(labels, label -> label.name), name -> STARTS_WITH(name, 'awswaf:managed:aws:atp:')) AS "atp_labels",
+           FLOOR(timestamp/(1000*60*5)) AS "time_window"
+    FROM "waflogs"."waf_logs_ecp_p"
+    WHERE day = '2023/12/01'
+      AND httprequest.httpmethod = 'POST'
+      AND REGEXP_LIKE(httprequest.uri, '(?i)^/(login|anmelden|logout|register|registrierung|account|mein-konto|kundenkonto|password|passwort)')
+), keyed AS (
+    SELECT 'ip' AS "dimension", client_ip AS "identity", client_ip, user_agent, action, atp_labels, time_window
+    FROM posts
+    UNION ALL
+    SELECT 'prefix' AS "dimension", prefix AS "identity", client_ip, user_agent, action, atp_labels, time_window
+    FROM posts
+    UNION ALL
+    SELECT 'session' AS "dimension", session AS "identity", client_ip, user_agent, action, atp_labels, time_window
+    FROM posts
+    WHERE session IS NOT NULL AND session != ''
+    UNION ALL
+    SELECT 'fingerprint' AS "dimension", fingerprint AS "identity", client_ip, user_agent, action, atp_labels, time_window
+    FROM posts
+    WHERE fingerprint IS NOT NULL
+), peaks AS (
+    SELECT dimension,
+           identity,
+           MAX(num_posts) AS "peak_posts"
+    FROM (
+        SELECT dimension, identity, time_window, COUNT(*) AS "num_posts"
+        FROM keyed
+        GROUP BY dimension, identity, time_window
+    )
+    GROUP BY dimension, identity
+), atp AS (
+    SELECT dimension,
+           identity,
+           ARRAY_SORT(ARRAY_AGG(DISTINCT atp_label)) AS "atp_labels"
+    FROM keyed
+    CROSS JOIN UNNEST(atp_labels) AS t(atp_label)
+    GROUP BY dimension, identity
+), clusters AS (
+    SELECT keyed.dimension,
+           keyed.identity,
+           COUNT(*) AS "num_posts",
+           MAX(peaks.peak_posts) AS "peak_posts_per_window",
+           COUNT(DISTINCT keyed.client_ip) AS "num_ips",
+           COUNT(DISTINCT keyed.user_agent) AS "num_user_agents",
+           COUNT_IF(keyed.action = 'BLOCK') AS "num_blocked",
+           COUNT_IF(CARDINALITY(keyed.atp_labels) > 0) AS "num_atp_labeled",
+           MAX(atp.atp_labels) AS "atp_labels"
+    FROM keyed
+    INNER JOIN peaks ON peaks.dimension = keyed.dimension AND peaks.identity = keyed.identity
+    LEFT JOIN atp ON atp.dimension = keyed.dimension AND atp.identity = keyed.identity
+    GROUP BY keyed.dimension,
+             keyed.identity
+    HAVING COUNT(*) >= 20
+), ranked AS (
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY dimension ORDER BY num_posts DESC, identity ASC) AS "rank"
+    FROM clusters
+)
+
+SELECT dimension,
+       identity,
+       num_posts,
+       peak_posts_per_window,
+       num_ips,
+       num_user_agents,
+       num_blocked,
+       num_atp_labeled,
+       atp_labels
+FROM ranked
+WHERE rank <= 50
+ORDER BY dimension ASC, num_posts DESC, identity ASC;
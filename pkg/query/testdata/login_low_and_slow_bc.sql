@@ -0,0 +1,57 @@
+WITH posts AS (
+    SELECT httprequest.clientip AS "client_ip",
+           /* the /24 network of IPv4 addresses, the /48 of IPv6 addresses */
+           CAST(ip_prefix(
+               CAST(httprequest.clientip AS IPADDRESS),
+               CASE WHEN STRPOS(httprequest.clientip, ':') > 0 THEN 48 ELSE 24 END
+           ) AS VARCHAR) AS "prefix",
+           httprequest.country AS "country",
+           TRY(TRANSFORM(FILTER(SPLIT(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'cookie'), header -> header.value))[1]), ';'), kv -> SUBSTR(TRIM(LOWER(kv)), 1, 7) = 'session'), kv -> SPLIT(TRIM(kv), '=')[2])[1]) AS "session",
+           ja3fingerprint AS "fingerprint",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           action,
+           FILTER(TRANSFORM(labels, label -> label.name), name -> STARTS_WITH(name, 'awswaf:managed:aws:atp:')) AS "atp_labels"
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+      AND httprequest.httpmethod = 'POST'
+      AND REGEXP_LIKE(httprequest.uri, '^/api/login$')
+), keyed AS (
+    SELECT 'user_agent' AS "dimension", user_agent AS "identity", client_ip, prefix, country, action
+    FROM posts
+    WHERE user_agent IS NOT NULL
+    UNION ALL
+    SELECT 'fingerprint' AS "dimension", fingerprint AS "identity", client_ip, prefix, country, action
+    FROM posts
+    WHERE fingerprint IS NOT NULL
+), per_ip AS (
+    SELECT dimension,
+           identity,
+           client_ip,
+           prefix,
+           country,
+           COUNT(*) AS "num_posts",
+           COUNT_IF(action = 'BLOCK') AS "num_blocked"
+    FROM keyed
+    GROUP BY dimension,
+             identity,
+             client_ip,
+             prefix,
+             country
+)
+
+/* many IPs sharing a User-Agent or fingerprint, each staying below rate limits */
+SELECT dimension,
+       identity,
+       COUNT(*) AS "num_ips",
+       COUNT(DISTINCT prefix) AS "num_prefixes",
+       COUNT(DISTINCT country) AS "num_countries",
+       SUM(num_posts) AS "num_posts",
+       MAX(num_posts) AS "max_posts_per_ip",
+       SUM(num_blocked) AS "num_blocked"
+FROM per_ip
+GROUP BY dimension,
+         identity
+HAVING COUNT(*) >= 20
+   AND MAX(num_posts) <= 5
+ORDER BY num_ips DESC, num_posts DESC, identity ASC
+LIMIT 100;
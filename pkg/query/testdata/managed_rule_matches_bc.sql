@@ -0,0 +1,65 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           httprequest.uri AS "uri",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'cookie'), header -> header.value))[1]) AS "cookie",
+           COALESCE(CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent')) > 0, false) AS "signal_nobrowser",
+           action,
+           rulegrouplist AS "rule_groups"
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+      AND rulegrouplist IS NOT NULL
+), matches AS (
+    /* rules inside rule groups terminating the request */
+    SELECT client_ip, country, uri, user_agent, cookie, signal_nobrowser, action AS "match", rule_group, rule
+    FROM tmptable
+    CROSS JOIN UNNEST(TRANSFORM(
+        FILTER(rule_groups, grp -> grp.terminatingrule IS NOT NULL),
+        grp -> ROW(grp.rulegroupid, grp.terminatingrule.ruleid)
+    )) AS t(rule_group, rule)
+
+    UNION ALL
+
+    /* rules inside rule groups in COUNT mode */
+    SELECT client_ip, country, uri, user_agent, cookie, signal_nobrowser, 'COUNT' AS "match", rule_group, rule
+    FROM tmptable
+    CROSS JOIN UNNEST(FLATTEN(TRANSFORM(
+        FILTER(rule_groups, grp -> grp.nonterminatingmatchingrules IS NOT NULL),
+        grp -> TRANSFORM(FILTER(grp.nonterminatingmatchingrules, r -> r.action = 'COUNT'), r -> ROW(grp.rulegroupid, r.ruleid))
+    ))) AS t(rule_group, rule)
+), stats AS (
+    SELECT rule_group,
+           rule,
+           match,
+           COUNT(*) AS "num_requests",
+           COUNT(DISTINCT client_ip) AS "num_ips",
+           COUNT(DISTINCT user_agent) AS "num_user_agents",
+           COUNT(DISTINCT uri) AS "num_uris",
+           COUNT(DISTINCT country) AS "num_countries",
+           CAST(COUNT(DISTINCT client_ip) AS DOUBLE) / COUNT(*) AS "ip_spread",
+           CAST(COUNT_IF(user_agent LIKE 'Mozilla/%' AND NOT signal_nobrowser) AS DOUBLE) / COUNT(*) AS "browser_share",
+           CAST(COUNT_IF(LOWER(cookie) LIKE '%session%') AS DOUBLE) / COUNT(*) AS "session_share"
+    FROM matches
+    GROUP BY rule_group,
+             rule,
+             match
+)
+
+/* matches spread over many clients, sent by browsers with a session are
+   likely false positives, attacks come from few clients without sessions */
+SELECT rule_group,
+       rule,
+       match,
+       num_requests,
+       num_ips,
+       num_user_agents,
+       num_uris,
+       num_countries,
+       ROUND(ip_spread, 3) AS "ip_spread",
+       ROUND(browser_share, 3) AS "browser_share",
+       ROUND(session_share, 3) AS "session_share",
+       ROUND((ip_spread + browser_share + session_share) / 3, 3) AS "false_positive_score"
+FROM stats
+ORDER BY false_positive_score DESC, num_requests DESC, rule_group ASC, rule ASC
+LIMIT 1000;
@@ -0,0 +1,59 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           httprequest.uri AS "uri",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           action,
+           /* the first probe the URI matches */
+           CASE
+               WHEN REGEXP_LIKE(httprequest.uri, '(?i)/(wp-admin|wp-login|wp-content|wp-includes|xmlrpc\.php)') THEN 'wordpress'
+               WHEN REGEXP_LIKE(httprequest.uri, '(?i)/\.(env|aws|ssh|npmrc|htpasswd|htaccess|DS_Store)|/(config|credentials|secrets|settings)\.(json|ya?ml|ini|xml)$') THEN 'secrets'
+               WHEN REGEXP_LIKE(httprequest.uri, '(?i)/\.(git|svn|hg|bzr)(/|$)') THEN 'vcs'
+               WHEN REGEXP_LIKE(httprequest.uri, '(?i)/(phpmyadmin|pma|adminer|manager/html|actuator|server-status|solr|jenkins|console)(/|$)') THEN 'admin'
+               WHEN REGEXP_LIKE(httprequest.uri, '(?i)\.(bak|old|orig|sql|tar|tgz|gz|zip|swp)$') THEN 'backup'
+               WHEN REGEXP_LIKE(httprequest.uri, '(?i)\.\./|%2e%2e|/etc/passwd|win\.ini') THEN 'traversal'
+               WHEN REGEXP_LIKE(httprequest.uri, '(?i)\.php[0-9]?$') THEN 'php'
+               WHEN REGEXP_LIKE(httprequest.uri, '(?i)/cgi-bin/|\.(cgi|asp|aspx|jsp)$') THEN 'cgi'
+           END AS "probe"
+    FROM "waflogs"."waf_logs_ecp_p"
+    WHERE day = '2023/12/01'
+), identities AS (
+    SELECT client_ip,
+           country,
+           COUNT(*) AS "num_requests",
+           COUNT(DISTINCT uri) AS "num_uris",
+           COUNT_IF(probe IS NOT NULL) AS "num_probes",
+           COUNT(DISTINCT CASE WHEN probe IS NOT NULL THEN uri END) AS "num_probe_uris",
+           COUNT_IF(probe IS NOT NULL AND action != 'BLOCK') AS "num_probes_allowed",
+           COUNT_IF(action = 'BLOCK') AS "num_blocked",
+           COUNT(DISTINCT user_agent) AS "num_user_agents",
+           MIN(user_agent) AS "user_agent",
+           ARRAY_JOIN(ARRAY_SORT(ARRAY_DISTINCT(FILTER(ARRAY_AGG(probe), p -> p IS NOT NULL))), ',') AS "probes"
+    FROM tmptable
+    GROUP BY client_ip,
+             country
+)
+
+/* IPs probing paths the shop never serves, or enumerating paths hitting each once or twice */
+SELECT client_ip,
+       country,
+       CASE
+           WHEN num_probe_uris >= 3 AND CAST(num_probes AS DOUBLE) / num_requests >= 0.5 THEN 'scanner'
+           WHEN num_probe_uris >= 3 THEN 'mixed'
+           ELSE 'enumerator'
+       END AS "class",
+       num_requests,
+       num_uris,
+       num_probes,
+       num_probe_uris,
+       ROUND(CAST(num_probes AS DOUBLE) / num_requests, 4) AS "probe_ratio",
+       probes,
+       num_probes_allowed,
+       num_blocked,
+       num_user_agents,
+       user_agent
+FROM identities
+WHERE num_probe_uris >= 3
+   OR (num_uris >= 500 AND num_requests <= 2 * num_uris)
+ORDER BY num_probe_uris DESC, num_uris DESC, client_ip ASC
+LIMIT 1000;
@@ -0,0 +1,164 @@
+WITH
+candidate_0 AS (
+    /* windows of ip with more than 400 requests per 5m */
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent')) > 0 AS "signal_nobrowser",
+           action = 'BLOCK' AS "blocked",
+           (CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:bot:verified')) > 0 OR try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) IN ('ios-de-1.0.0')) AS "known_good",
+           terminatingruleid AS "terminating_rule",
+           from_unixtime(FLOOR(timestamp/(1000*60*5))*60*5) as "time_window",
+           timestamp
+    FROM "waflogs"."waf_logs_p"
+    WHERE day BETWEEN '2023/02/15' AND '2023/02/21'
+), ranked AS (
+    /* the requests of a window past the limit are the ones a rate limit blocks */
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY client_ip, country, time_window ORDER BY timestamp) > 400 AS "affected"
+    FROM tmptable
+)
+
+SELECT client_ip, country,
+       time_window,
+       COUNT_IF(affected) AS "num_affected",
+       COUNT_IF(affected AND blocked) AS "num_blocked",
+       COUNT_IF(affected AND known_good) AS "num_known_good",
+       COUNT(*) AS "num_requests"
+FROM ranked
+GROUP BY client_ip, country,
+         time_window
+HAVING COUNT(*) > 400
+),
+candidate_1 AS (
+    /* windows of forwarded-ip with more than 100 requests per 2m sliding */
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           TRY(TRIM(SPLIT(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'x-forwarded-for'), header -> header.value))[1]), ',')[1])) AS "forwarded_ip",
+           httprequest.country AS "country",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent')) > 0 AS "signal_nobrowser",
+           action = 'BLOCK' AS "blocked",
+           (CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:bot:verified')) > 0 OR try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) IN ('ios-de-1.0.0')) AS "known_good",
+           terminatingruleid AS "terminating_rule",
+           FLOOR(timestamp/(1000*60)) AS "minute",
+           timestamp
+    FROM "waflogs"."waf_logs_p"
+    WHERE day BETWEEN '2023/02/15' AND '2023/02/21'
+), minutes AS (
+    SELECT forwarded_ip, country,
+           minute,
+           COUNT_IF(blocked) AS "num_blocked",
+           COUNT_IF(known_good) AS "num_known_good",
+           COUNT(*) AS "num_requests"
+    FROM tmptable
+    WHERE forwarded_ip IS NOT NULL
+    GROUP BY forwarded_ip, country,
+             minute
+), windows AS (
+    /* approximates the sliding window of WAF rate-based rules with a resolution of one minute */
+    SELECT forwarded_ip, country,
+           from_unixtime((minute-2+1)*60) AS "time_window",
+           num_requests AS "num_minute_requests",
+           num_blocked,
+           num_known_good,
+           SUM(num_requests) OVER (
+               PARTITION BY forwarded_ip, country
+               ORDER BY minute
+               RANGE BETWEEN 1 PRECEDING AND CURRENT ROW
+           ) AS "num_requests"
+    FROM minutes
+)
+
+SELECT forwarded_ip, country,
+       time_window,
+       /* the requests of the last minute of the window past the limit are the ones a rate limit blocks */
+       LEAST(num_minute_requests, num_requests - 100) AS "num_affected",
+       LEAST(num_blocked, num_minute_requests, num_requests - 100) AS "num_blocked",
+       LEAST(num_known_good, num_minute_requests, num_requests - 100) AS "num_known_good",
+       num_requests
+FROM windows
+WHERE num_requests > 100
+),
+candidate_2 AS (
+    /* windows of cookie:session with more than 200 requests per 5m */
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:signal:non_browser_user_agent')) > 0 AS "signal_nobrowser",
+           TRY(TRANSFORM(FILTER(SPLIT(try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'cookie'), header -> header.value))[1]), ';'), kv -> TRIM(SPLIT(kv, '=')[1]) = 'session'), kv -> SPLIT(TRIM(kv), '=')[2])[1]) AS "custom_key",
+           action = 'BLOCK' AS "blocked",
+           (CARDINALITY(FILTER(labels, label -> label.name = 'awswaf:managed:aws:bot-control:bot:verified')) > 0 OR try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) IN ('ios-de-1.0.0')) AS "known_good",
+           terminatingruleid AS "terminating_rule",
+           from_unixtime(FLOOR(timestamp/(1000*60*5))*60*5) as "time_window",
+           timestamp
+    FROM "waflogs"."waf_logs_p"
+    WHERE day BETWEEN '2023/02/15' AND '2023/02/21'
+), ranked AS (
+    /* the requests of a window past the limit are the ones a rate limit blocks */
+    SELECT *,
+           ROW_NUMBER() OVER (PARTITION BY custom_key, time_window ORDER BY timestamp) > 200 AS "affected"
+    FROM tmptable
+    WHERE custom_key IS NOT NULL
+)
+
+SELECT custom_key,
+       time_window,
+       COUNT_IF(affected) AS "num_affected",
+       COUNT_IF(affected AND blocked) AS "num_blocked",
+       COUNT_IF(affected AND known_good) AS "num_known_good",
+       COUNT(*) AS "num_requests"
+FROM ranked
+GROUP BY custom_key,
+         time_window
+HAVING COUNT(*) > 200
+)
+
+SELECT 0 AS "candidate",
+       'ip' AS "key",
+       '5m' AS "window_size",
+       400 AS "rate_limit",
+       COUNT(DISTINCT client_ip) AS "num_identities",
+       COALESCE(SUM(num_affected), 0) AS "num_requests",
+       COALESCE(SUM(num_blocked), 0) AS "num_already_blocked",
+       COALESCE(SUM(num_affected - num_blocked), 0) AS "num_newly_blocked",
+       COUNT(DISTINCT CASE WHEN num_known_good > 0 THEN client_ip END) AS "num_known_good_identities",
+       COALESCE(SUM(num_known_good), 0) AS "num_known_good"
+FROM candidate_0
+
+UNION ALL
+
+SELECT 1 AS "candidate",
+       'forwarded-ip' AS "key",
+       '2m sliding' AS "window_size",
+       100 AS "rate_limit",
+       COUNT(DISTINCT forwarded_ip) AS "num_identities",
+       COALESCE(SUM(num_affected), 0) AS "num_requests",
+       COALESCE(SUM(num_blocked), 0) AS "num_already_blocked",
+       COALESCE(SUM(num_affected - num_blocked), 0) AS "num_newly_blocked",
+       COUNT(DISTINCT CASE WHEN num_known_good > 0 THEN forwarded_ip END) AS "num_known_good_identities",
+       COALESCE(SUM(num_known_good), 0) AS "num_known_good"
+FROM candidate_1
+
+UNION ALL
+
+SELECT 2 AS "candidate",
+       'cookie:session' AS "key",
+       '5m' AS "window_size",
+       200 AS "rate_limit",
+       COUNT(DISTINCT custom_key) AS "num_identities",
+       COALESCE(SUM(num_affected), 0) AS "num_requests",
+       COALESCE(SUM(num_blocked), 0) AS "num_already_blocked",
+       COALESCE(SUM(num_affected - num_blocked), 0) AS "num_newly_blocked",
+       COUNT(DISTINCT CASE WHEN num_known_good > 0 THEN custom_key END) AS "num_known_good_identities",
+       COALESCE(SUM(num_known_good), 0) AS "num_known_good"
+FROM candidate_2
+ORDER BY candidate ASC;
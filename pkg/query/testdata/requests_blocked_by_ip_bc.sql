@@ -0,0 +1,22 @@
+WITH tmptable AS (
+    SELECT httprequest.clientip AS "client_ip",
+           httprequest.country AS "country",
+           try((transform(filter(httprequest.headers, header -> LOWER(header.name) = 'user-agent'), header -> header.value))[1]) AS "user_agent",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:name:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_name",
+           try(TRANSFORM(FILTER(labels, label -> label.name LIKE 'awswaf:managed:aws:bot-control:bot:category:%'), label -> ELEMENT_AT(SPLIT(label.name, ':'), -1))[1]) AS "bot_category",
+           terminatingruleid AS "terminating_rule",
+           timestamp
+    FROM "waflogs"."waf_logs_p"
+    WHERE day = '2023/02/21'
+      AND action = 'BLOCK'
+)
+
+SELECT client_ip, country,
+       terminating_rule,
+       COUNT(*) AS "num_requests"
+FROM tmptable
+WHERE terminating_rule IN (VALUES 'rate-limit')
+GROUP BY client_ip, country,
+         terminating_rule
+ORDER BY num_requests DESC
+LIMIT 1000;
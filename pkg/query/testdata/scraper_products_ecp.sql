@@ -0,0 +1,39 @@
+WITH waflog AS (
+
+SELECT * FROM waflog_ECP_2023_12_01
+
+), scraper_sessions AS (
+
+/* sessions of campaign shop-p, rotating through more than 9 User-Agents */
+SELECT DISTINCT c_session
+FROM waflog
+WHERE c_session != ''
+  AND signal_nobrowser = False /* only user_agents that don't reveal themselves as bots */
+GROUP BY c_session
+HAVING CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) > 9
+
+), scraper_user_agents AS (
+
+SELECT DISTINCT user_agent, COUNT(*) AS "num_requests"
+FROM waflog INNER JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
+GROUP BY user_agent
+HAVING COUNT(*) > 5000
+
+), scraped_products AS (
+
+SELECT REGEXP_EXTRACT(CONCAT(uri, '?', COALESCE(params, '')), '^/p/([0-9]+)', 1) AS "product",
+       user_agent
+FROM waflog INNER JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
+WHERE user_agent IN (SELECT user_agent FROM scraper_user_agents)
+  AND REGEXP_LIKE(uri, '^/p/[0-9]+$')
+
+)
+
+SELECT product,
+       COUNT(*) AS "num_requests",
+       CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) AS "user_agents"
+FROM scraped_products
+WHERE product IS NOT NULL
+GROUP BY product
+ORDER BY num_requests DESC, product ASC
+LIMIT 200000
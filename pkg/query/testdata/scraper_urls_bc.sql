@@ -1,19 +1,15 @@
 WITH waflog AS (
 
-SELECT * FROM waflog_{{.WAF}}_{{.Year}}_{{.Month}}_{{.Day}}
+SELECT * FROM waflog_BC_2023_02_21
 
 ), scraper_sessions AS (
 
+/* sessions of campaign apc1, rotating through more than 9 User-Agents */
 SELECT DISTINCT c_session
-       /*COUNT(*) AS "num_requests",
-       CARDINALITY(ARRAY_DISTINCT(ARRAY_AGG(client_ip))) AS "ips",
-       CARDINALITY(ARRAY_DISTINCT(ARRAY_AGG(user_agent))) AS "user_agents",
-       CARDINALITY(ARRAY_DISTINCT(ARRAY_AGG(uri_c))) AS "uris",
-       ARRAY_SORT(ARRAY_DISTINCT(ARRAY_AGG(day))) AS "days"*/
 FROM waflog
 WHERE c_session != ''
   AND terminating_rule NOT IN (VALUES 'waf-whitelist', 'bot-label-whitelist', 'seo-crawler', 'seo-crawler-vpn', 'allow-newrelic-header-check') /* ignore known bots */
-  AND signal_nobrowser = False /*only user_agents that don't reveal themselves as bots*/
+  AND signal_nobrowser = False /* only user_agents that don't reveal themselves as bots */
 GROUP BY c_session
 HAVING CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) > 9
 
@@ -25,6 +21,7 @@ SELECT uri_c,
        CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) AS "user_agents",
        CARDINALITY(ARRAY_AGG(DISTINCT uri)) AS "full_uris",
        CARDINALITY(ARRAY_AGG(DISTINCT waflog.c_session)) AS "sessions",
+       COUNT_IF(REGEXP_LIKE(uri, '^/artikeldetails$')) > 0 AS "target",
        HOUR(FROM_UNIXTIME(MIN(TO_UNIXTIME(timestamp)))) AS "first_hour",
        HOUR(FROM_UNIXTIME(MAX(TO_UNIXTIME(timestamp))))+1 AS "last_hour"
 FROM waflog INNER JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
@@ -34,5 +31,5 @@ GROUP BY uri_c
 
 SELECT *
 FROM scraped_urls
-ORDER BY num_requests DESC
+ORDER BY num_requests DESC, uri_c ASC
 LIMIT 100
@@ -1,14 +1,15 @@
 WITH waflog AS (
 
-SELECT * FROM waflog_{{.WAF}}_{{.Year}}_{{.Month}}_{{.Day}}
+SELECT * FROM waflog_BC_2023_02_21
 
 ), scraper_sessions AS (
 
+/* sessions of campaign apc1, rotating through more than 9 User-Agents */
 SELECT DISTINCT c_session
 FROM waflog
 WHERE c_session != ''
   AND terminating_rule NOT IN (VALUES 'waf-whitelist', 'bot-label-whitelist', 'seo-crawler', 'seo-crawler-vpn', 'allow-newrelic-header-check') /* ignore known bots */
-  AND signal_nobrowser = False /*only user_agents that don't reveal themselves as bots*/
+  AND signal_nobrowser = False /* only user_agents that don't reveal themselves as bots */
 GROUP BY c_session
 HAVING CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) > 9
 
@@ -17,7 +18,7 @@ HAVING CARDINALITY(ARRAY_AGG(DISTINCT user_agent)) > 9
 SELECT DISTINCT user_agent, COUNT(*) AS "num_requests"
 FROM waflog INNER JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
 GROUP BY user_agent
-HAVING COUNT(*) > 5000 /* there are other small-scale scapers but only APC1 brings significant traffic in */
+HAVING COUNT(*) > 5000
 )
 
 SELECT user_agent,
@@ -28,4 +29,5 @@ SELECT user_agent,
 FROM waflog LEFT JOIN scraper_sessions ON waflog.c_session = scraper_sessions.c_session
 WHERE user_agent IN (SELECT user_agent FROM scraper_user_agents)
 GROUP BY user_agent
-LIMIT {{.Limit}}
+ORDER BY num_requests DESC, user_agent ASC
+LIMIT 1000
@@ -2,28 +2,52 @@ package report
 
 import (
 	"fmt"
-	"kfzteile24/waflogs/pkg/aws"
+	"kfzteile24/waflogs/pkg/offline"
 	"kfzteile24/waflogs/pkg/query"
 	"time"
+
+	"github.com/urfave/cli/v2"
 )
 
+func init() {
+	Register(&apc1Report{})
+}
+
+// apc1Report defines the report on the scraper called APC1
+type apc1Report struct{}
+
+func (apc1Report) Name() string      { return "apc1" }
+func (apc1Report) Aliases() []string { return nil }
+
+func (apc1Report) Description() string {
+	return "URLs, products and User-Agents of the scraper APC1"
+}
+
+func (apc1Report) Flags() []cli.Flag { return nil }
+
+func (apc1Report) NewLoader(b Backend, o Options) Loader {
+	return NewAPC1ReportLoader(b, o.Waf, o.Day)
+}
+
+func (apc1Report) NewPrinter(o Options) Printer { return nil }
+
 type APC1ReportLoader struct {
-	base *ReportLoader
+	*ReportLoader
 }
 
-func NewAPC1ReportLoader(a *aws.AthenaClient, waf query.WAF, t time.Time) *APC1ReportLoader {
+func NewAPC1ReportLoader(b Backend, waf query.WAF, t time.Time) *APC1ReportLoader {
 	year, month, day := getDay(t)
 
 	out := &APC1ReportLoader{
-		base: NewReportLoader(a, waf, "apc1", year, month, day),
+		ReportLoader: NewReportLoader(b, waf, "apc1", year, month, day),
 	}
 
+	out.steps = out.load
+
 	return out
 }
 
-func (r *APC1ReportLoader) Run() error {
-	r.base.ensureOutDirExists()
-
+func (r *APC1ReportLoader) load() error {
 	if err := r.CreateMaterializedView(); err != nil {
 		return fmt.Errorf("creating materialized view for APC1: %s", err)
 	}
@@ -44,16 +68,14 @@ func (r *APC1ReportLoader) Run() error {
 }
 
 func (r *APC1ReportLoader) CreateMaterializedView() error {
-	fmt.Println("\n[+] Creating Parquet waflog view...")
+	r.printf("\n[+] Creating Parquet waflog view...\n")
 
-	sql, err := query.CreateAPC1MaterializedView(
-		r.base.Scope,
-	)
+	step, err := materializedViewStep(r.ReportLoader)
 	if err != nil {
-		return fmt.Errorf("rendering sql: %s", err)
+		return err
 	}
 
-	if err := r.base.RunQuery(sql, "create-materialized-view"); err != nil {
+	if err := r.RunQuery(step); err != nil {
 		return fmt.Errorf("running query: %s", err)
 	}
 
@@ -61,53 +83,31 @@ func (r *APC1ReportLoader) CreateMaterializedView() error {
 }
 
 func (r *APC1ReportLoader) LoadScrapedURLs() error {
-	fmt.Println("\n[+] Loading scaped URLs with request counts...")
+	r.printf("\n[+] Loading scaped URLs with request counts...\n")
 
-	sql, err := query.GetAPC1URLs(
-		r.base.Scope,
-		100,
-	)
-	if err != nil {
-		return fmt.Errorf("rendering sql: %s", err)
-	}
-
-	if err := r.base.RunQuery(sql, "scraped-urls"); err != nil {
-		return fmt.Errorf("running query: %s", err)
-	}
-
-	return nil
+	return r.runCampaignStep("scraped-urls", query.GetScraperURLs, offline.ScraperURLs, 100)
 }
 
 func (r *APC1ReportLoader) LoadScraperUserAgents() error {
-	fmt.Println("\n[+] Loading scaper User Agents with request counts and time window...")
-
-	sql, err := query.GetAPC1UserAgents(
-		r.base.Scope,
-		1000,
-	)
-	if err != nil {
-		return fmt.Errorf("rendering sql: %s", err)
-	}
-
-	if err := r.base.RunQuery(sql, "scraper-user-agents"); err != nil {
-		return fmt.Errorf("running query: %s", err)
-	}
+	r.printf("\n[+] Loading scaper User Agents with request counts and time window...\n")
 
-	return nil
+	return r.runCampaignStep("scraper-user-agents", query.GetScraperUserAgents, offline.ScraperUserAgents, 1000)
 }
 
 func (r *APC1ReportLoader) LoadScrapedProducts() error {
-	fmt.Println("\n[+] Loading products scraped...")
+	r.printf("\n[+] Loading products scraped...\n")
+
+	return r.runCampaignStep("scraped-products", query.GetScraperProducts, offline.ScraperProducts, 200000)
+}
 
-	sql, err := query.GetAPC1ScrapedProducts(
-		r.base.Scope,
-		200000,
-	)
+// runCampaignStep runs a query of the scraper campaign APC1 as step name
+func (r *APC1ReportLoader) runCampaignStep(name string, render campaignQuery, local campaignTable, limit int) error {
+	step, err := campaignStep(r.ReportLoader, name, query.APC1Campaign, render, local, limit)
 	if err != nil {
-		return fmt.Errorf("rendering sql: %s", err)
+		return err
 	}
 
-	if err := r.base.RunQuery(sql, "scraper-user-agents"); err != nil {
+	if err := r.RunQuery(step); err != nil {
 		return fmt.Errorf("running query: %s", err)
 	}
 
@@ -0,0 +1,91 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/aws"
+	"kfzteile24/waflogs/pkg/local"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+)
+
+// Backend executes the steps of report loaders, writes their results as CSV
+// and returns how the results were obtained
+type Backend interface {
+	Run(scope query.Scope, step Step, dstPath string) (*query.Execution, error)
+}
+
+// QueryEngine executes an SQL statement written for Athena, writes the
+// result as CSV to dstPath and returns its execution. Both aws.AthenaClient
+// and local.Engine are one.
+type QueryEngine interface {
+	Query(sql string, dstPath string) (*query.Execution, error)
+}
+
+// SQLBackend runs the rendered queries of steps on a query engine
+type SQLBackend struct {
+	Engine QueryEngine
+}
+
+func NewSQLBackend(e QueryEngine) *SQLBackend {
+	return &SQLBackend{Engine: e}
+}
+
+func (b *SQLBackend) Run(scope query.Scope, step Step, dstPath string) (*query.Execution, error) {
+	e, err := b.Engine.Query(step.SQL, dstPath)
+	if err != nil {
+		return nil, fmt.Errorf("running query: %s", err)
+	}
+
+	return e, nil
+}
+
+// OfflineBackend computes the results of steps in Go from local raw logs,
+// without an SQL engine
+type OfflineBackend struct {
+	Source *offline.Source
+}
+
+func NewOfflineBackend(src *offline.Source) *OfflineBackend {
+	return &OfflineBackend{Source: src}
+}
+
+func (b *OfflineBackend) Run(scope query.Scope, step Step, dstPath string) (*query.Execution, error) {
+	if step.Local == nil {
+		return nil, fmt.Errorf("step %s can't be computed offline", step.Name)
+	}
+
+	records, err := b.Source.Records(scope)
+	if err != nil {
+		return nil, fmt.Errorf("reading records: %s", err)
+	}
+
+	fmt.Printf("[+] Computing %s from %d records\n", step.Name, len(records))
+
+	result, err := step.Local(records)
+	if err != nil {
+		return nil, fmt.Errorf("computing result offline: %s", err)
+	}
+
+	if err := result.WriteCSV(dstPath); err != nil {
+		return nil, err
+	}
+
+	return &query.Execution{Columns: result.Columns()}, nil
+}
+
+// backendName returns the name of the backend as selected on the command line
+func backendName(b Backend) string {
+	switch b := b.(type) {
+	case *OfflineBackend:
+		return "offline"
+	case *SQLBackend:
+		switch b.Engine.(type) {
+		case *aws.AthenaClient:
+			return "athena"
+		case *local.Engine:
+			return "local"
+		}
+	}
+
+	return fmt.Sprintf("%T", b)
+}
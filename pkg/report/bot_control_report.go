@@ -0,0 +1,135 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&botControlReport{crawlerRules: query.DefaultCrawlerRules})
+}
+
+// botControlReport defines the report on the bots Bot Control labels and the
+// impostors of search engine crawlers
+type botControlReport struct {
+	crawlerRules []string // rules allowing the search engine crawlers
+}
+
+func (*botControlReport) Name() string      { return "bot-control" }
+func (*botControlReport) Aliases() []string { return []string{"bots"} }
+
+func (*botControlReport) Description() string {
+	return "requests per bot category, bot name and verified status, and User-Agents claiming to be search engine crawlers without being verified"
+}
+
+func (r *botControlReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "crawler-rule",
+			Value: cli.NewStringSlice(query.DefaultCrawlerRules...),
+			Usage: "rule allowing the search engine crawlers, counting the impostors it lets through, repeatable",
+			Action: func(ctx *cli.Context, v []string) error {
+				r.crawlerRules = v
+				return nil
+			},
+		},
+	}
+}
+
+func (r *botControlReport) NewLoader(b Backend, o Options) Loader {
+	return NewBotControlReportLoader(b, o.Waf, r.crawlerRules, o.Day)
+}
+
+func (*botControlReport) NewPrinter(o Options) Printer {
+	return printer.NewSummaryPrinter(o.Data, o.Waf, "bot-control-report", []printer.Summary{
+		{
+			Result:  "bot-traffic",
+			Title:   "Requests per bot category, bot name and verified status",
+			OrderBy: "num_requests",
+			Limit:   30,
+		},
+		{
+			Result:  "spoofed-bots",
+			Title:   "Impostors of search engine crawlers, by requests allowed by the crawler rules",
+			OrderBy: "num_allowed_as_crawler",
+			Limit:   20,
+		},
+	})
+}
+
+// BotControlReportLoader loads the traffic per bot of the Bot Control labels
+// and the unverified requests claiming to be search engine crawlers
+type BotControlReportLoader struct {
+	*ReportLoader
+	crawlerRules []string
+}
+
+func NewBotControlReportLoader(b Backend, waf query.WAF, crawlerRules []string, t time.Time) *BotControlReportLoader {
+	year, month, day := getDay(t)
+
+	out := &BotControlReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "bot-control-report", year, month, day),
+		crawlerRules: crawlerRules,
+	}
+	out.Params = map[string]string{
+		"crawler_rules": strings.Join(crawlerRules, ","),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *BotControlReportLoader) load() error {
+	if err := r.LoadBotTraffic(); err != nil {
+		return fmt.Errorf("loading bot traffic: %s", err)
+	}
+
+	if err := r.LoadSpoofedBots(); err != nil {
+		return fmt.Errorf("loading spoofed bots: %s", err)
+	}
+
+	return nil
+}
+
+func (r *BotControlReportLoader) LoadBotTraffic() error {
+	r.printf("\n[+] Loading requests per bot category, bot name and verified status...\n")
+
+	sql, err := query.GetBotTraffic(
+		r.Scope,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("bot-traffic", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *BotControlReportLoader) LoadSpoofedBots() error {
+	r.printf("\n[+] Loading unverified requests claiming to be search engine crawlers...\n")
+
+	sql, err := query.GetSpoofedBots(
+		r.Scope,
+		r.crawlerRules,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("spoofed-bots", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
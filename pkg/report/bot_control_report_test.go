@@ -0,0 +1,106 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBotControlReport(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+	googlebot := "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+
+	var records []*waflog.Record
+	for i := 0; i < 4; i++ { // the real Googlebot
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "66.249.66.1", googlebot, "seo-crawler", "ALLOW",
+			"awswaf:managed:aws:bot-control:bot:verified",
+			"awswaf:managed:aws:bot-control:bot:name:googlebot",
+			"awswaf:managed:aws:bot-control:bot:category:search_engine",
+		)
+		records = append(records, r)
+	}
+	for i := 0; i < 3; i++ { // an impostor let through by the crawler rule matching its User-Agent
+		records = append(records, testRecord(at.Add(time.Duration(i)*time.Second), "6.6.6.6", googlebot, "seo-crawler", "ALLOW"))
+	}
+	records = append(records, testRecord(at, "7.7.7.7", "Mozilla/5.0 (compatible; bingbot/2.0)", "ip-rate-limit", "BLOCK",
+		"awswaf:managed:aws:bot-control:bot:name:bingbot",
+		"awswaf:managed:aws:bot-control:bot:category:search_engine",
+	))
+	for i := 0; i < 2; i++ { // an HTTP library
+		records = append(records, testRecord(at.Add(time.Duration(i)*time.Second), "10.0.0.1", "curl/8.4.0", "Default_Action", "ALLOW",
+			"awswaf:managed:aws:bot-control:bot:name:curl",
+			"awswaf:managed:aws:bot-control:bot:category:http_library",
+		))
+	}
+	records = append(records, testRecord(at, "10.0.0.2", "Mozilla/5.0", "Default_Action", "ALLOW"))
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewBotControlReportLoader(b, query.WafBC, query.DefaultCrawlerRules, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var traffic []string
+	for _, row := range readResult(t, "bot-control-report", "bot-traffic") {
+		traffic = append(traffic, fmt.Sprint(row[:7]))
+	}
+	want := "[[bot_category bot_name status num_requests num_ips num_blocked num_allowed] [  none 4 2 0 4] [search_engine googlebot verified 4 1 0 4] [http_library curl unverified 2 1 0 2] [search_engine bingbot unverified 1 1 1 0]]"
+	if fmt.Sprint(traffic) != want {
+		t.Errorf("bot traffic:\n got  %s\n want %s", traffic, want)
+	}
+
+	var spoofed []string
+	for _, row := range readResult(t, "bot-control-report", "spoofed-bots") {
+		spoofed = append(spoofed, fmt.Sprint(append(row[:3:3], row[4:9]...)))
+	}
+	want = "[[claimed_bot client_ip country bot_name num_requests num_blocked num_allowed num_allowed_as_crawler] [googlebot 6.6.6.6 DE  3 0 3 3] [bingbot 7.7.7.7 DE bingbot 1 1 0 0]]"
+	if fmt.Sprint(spoofed) != want {
+		t.Errorf("spoofed bots:\n got  %s\n want %s", spoofed, want)
+	}
+}
+
+func TestBotControlReportEdgeCases(t *testing.T) {
+	at := testDay.Add(10 * time.Hour)
+	noHeaders := testRecord(at, "2001:db8::2", "", "Default_Action", "ALLOW", "awswaf:managed:aws:bot-control:bot:category:monitoring")
+	noHeaders.HTTPRequest.Headers = nil
+	impostor := testRecord(at, "3.3.3.3", "Mozilla/5.0 (compatible; GOOGLEBOT/2.1)", "seo-crawler-vpn", "ALLOW")
+	verified := testRecord(at, "5.5.5.5", "Applebot/0.1", "Default_Action", "ALLOW", "awswaf:managed:aws:bot-control:bot:verified")
+
+	traffic := []string{"bot_category", "bot_name", "status", "num_requests", "num_allowed"}
+	spoofed := []string{"claimed_bot", "client_ip", "bot_name", "num_requests", "num_allowed_as_crawler"}
+	runReportCases(t, "bot-control-report", false, func(b Backend) error {
+		return NewBotControlReportLoader(b, query.WafBC, query.DefaultCrawlerRules, testDay).Run()
+	}, []reportCase{
+		{
+			name:    "impostor in upper case let through by the rule for crawlers behind VPNs",
+			records: []*waflog.Record{impostor},
+			result:  "spoofed-bots",
+			columns: spoofed,
+			want:    [][]string{{"googlebot", "3.3.3.3", "", "1", "1"}},
+		},
+		{
+			name:    "category without bot name from IPv6 without headers",
+			records: []*waflog.Record{noHeaders},
+			result:  "bot-traffic",
+			columns: traffic,
+			want:    [][]string{{"monitoring", "", "unverified", "1", "1"}},
+		},
+		{
+			name:    "verified bot without bot name",
+			records: []*waflog.Record{verified},
+			result:  "bot-traffic",
+			columns: traffic,
+			want:    [][]string{{"", "", "verified", "1", "1"}},
+		},
+		{
+			name:    "verified bot never an impostor",
+			records: []*waflog.Record{verified},
+			result:  "spoofed-bots",
+			columns: spoofed,
+		},
+	})
+}
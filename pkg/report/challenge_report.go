@@ -0,0 +1,151 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&challengeReport{minSolves: 10, fastSeconds: 3})
+}
+
+// challengeReport defines the report on the outcomes of CAPTCHAs and
+// challenges
+type challengeReport struct {
+	minSolves   int // of an IP and User-Agent solving often
+	fastSeconds int // below which a CAPTCHA is solved too fast for a human
+}
+
+func (*challengeReport) Name() string      { return "challenges" }
+func (*challengeReport) Aliases() []string { return []string{"captcha"} }
+
+func (*challengeReport) Description() string {
+	return "CAPTCHAs and challenges issued, solved and failed per rule, country, IP and User-Agent, solve latencies and IPs solving suspiciously fast or often"
+}
+
+func (r *challengeReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "min-solves",
+			Value:       10,
+			Usage:       "number of solved CAPTCHAs or challenges of an IP and User-Agent to load it as solver",
+			Destination: &r.minSolves,
+		},
+		&cli.IntFlag{
+			Name:        "fast-seconds",
+			Value:       3,
+			Usage:       "seconds from a CAPTCHA sent to its solve below which no human solves it",
+			Destination: &r.fastSeconds,
+		},
+	}
+}
+
+func (r *challengeReport) NewLoader(b Backend, o Options) Loader {
+	return NewChallengeReportLoader(b, o.Waf, r.minSolves, r.fastSeconds, o.Day)
+}
+
+func (*challengeReport) NewPrinter(o Options) Printer {
+	return printer.NewChallengeReportPrinter(o.Data, o.Waf)
+}
+
+// ChallengeReportLoader loads the outcomes of the CAPTCHA and Challenge
+// actions from the token responses of the logs, to spot CAPTCHA farms
+type ChallengeReportLoader struct {
+	*ReportLoader
+	minSolves   int
+	fastSeconds int
+}
+
+func NewChallengeReportLoader(b Backend, waf query.WAF, minSolves int, fastSeconds int, t time.Time) *ChallengeReportLoader {
+	year, month, day := getDay(t)
+
+	out := &ChallengeReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "challenge-report", year, month, day),
+		minSolves:    minSolves,
+		fastSeconds:  fastSeconds,
+	}
+	out.Params = map[string]string{
+		"min_solves":   strconv.Itoa(minSolves),
+		"fast_seconds": strconv.Itoa(fastSeconds),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *ChallengeReportLoader) load() error {
+	if err := r.LoadChallengeOutcomes(); err != nil {
+		return fmt.Errorf("loading challenge outcomes: %s", err)
+	}
+
+	if err := r.LoadChallengeSolveLatency(); err != nil {
+		return fmt.Errorf("loading solve latency: %s", err)
+	}
+
+	if err := r.LoadChallengeSolvers(); err != nil {
+		return fmt.Errorf("loading solvers: %s", err)
+	}
+
+	return nil
+}
+
+func (r *ChallengeReportLoader) LoadChallengeOutcomes() error {
+	r.printf("\n[+] Loading CAPTCHAs and challenges per rule, country, IP and User-Agent...\n")
+
+	sql, err := query.GetChallengeOutcomes(
+		r.Scope,
+		50,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("challenge-outcomes", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *ChallengeReportLoader) LoadChallengeSolveLatency() error {
+	r.printf("\n[+] Loading solves per latency...\n")
+
+	sql, err := query.GetChallengeSolveLatency(
+		r.Scope,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("challenge-solve-latency", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *ChallengeReportLoader) LoadChallengeSolvers() error {
+	r.printf("\n[+] Loading IPs and User-Agents solving fast or often...\n")
+
+	sql, err := query.GetChallengeSolvers(
+		r.Scope,
+		r.minSolves,
+		r.fastSeconds,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("challenge-solvers", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChallengeReport(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	var records []*waflog.Record
+	// puzzle sends the CAPTCHA of the checkout
+	puzzle := func(at time.Time, ip string, reason string) {
+		r := testRecord(at, ip, "Mozilla/5.0", "captcha-checkout", "CAPTCHA")
+		r.CaptchaResponse = &waflog.TokenResponse{ResponseCode: 405, FailureReason: reason}
+		records = append(records, r)
+	}
+	// pass passes the CAPTCHA of the checkout with a token solved at solved
+	pass := func(at time.Time, ip string, solved time.Time) {
+		r := testRecord(at, ip, "Mozilla/5.0", "Default_Action", "ALLOW")
+		r.NonTerminatingMatchingRules = []waflog.RuleMatch{{RuleID: "captcha-checkout", Action: "CAPTCHA"}}
+		r.CaptchaResponse = &waflog.TokenResponse{ResponseCode: 0, SolveTimestamp: solved.Unix()}
+		records = append(records, r)
+	}
+
+	// a customer taking 12 seconds
+	puzzle(at, "10.0.0.1", "TOKEN_MISSING")
+	pass(at.Add(13*time.Second), "10.0.0.1", at.Add(12*time.Second))
+	pass(at.Add(20*time.Second), "10.0.0.1", at.Add(12*time.Second))
+
+	// a CAPTCHA farm solving within a second
+	for i := 0; i < 3; i++ {
+		sent := at.Add(time.Duration(i) * time.Minute)
+		puzzle(sent, "6.6.6.6", "TOKEN_MISSING")
+		pass(sent.Add(2*time.Second), "6.6.6.6", sent.Add(time.Second))
+	}
+
+	// a bot passing with a token solved elsewhere
+	pass(at, "7.7.7.7", at.Add(-100*time.Second))
+
+	// a bot with a forged token
+	puzzle(at, "8.8.8.8", "TOKEN_INVALID")
+
+	// a browser solving the challenge of the login
+	r := testRecord(at, "10.0.0.1", "Mozilla/5.0", "challenge-login", "CHALLENGE")
+	r.ChallengeResponse = &waflog.TokenResponse{ResponseCode: 202, FailureReason: "TOKEN_MISSING"}
+	records = append(records, r)
+	r = testRecord(at.Add(2*time.Second), "10.0.0.1", "Mozilla/5.0", "Default_Action", "ALLOW")
+	r.NonTerminatingMatchingRules = []waflog.RuleMatch{{RuleID: "challenge-login", Action: "CHALLENGE"}}
+	r.ChallengeResponse = &waflog.TokenResponse{ResponseCode: 0, SolveTimestamp: at.Add(time.Second).Unix()}
+	records = append(records, r)
+
+	records = append(records, testRecord(at, "10.0.0.2", "Mozilla/5.0", "Default_Action", "ALLOW"))
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewChallengeReportLoader(b, query.WafBC, 10, 3, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rules []string
+	for _, row := range readResult(t, "challenge-report", "challenge-outcomes") {
+		if row[0] == "dimension" || row[0] == "rule" {
+			rules = append(rules, fmt.Sprint(row[1:10]))
+		}
+	}
+	want := "[[identity kind num_requests num_issued num_solved num_failed num_solves solve_rate num_ips] [captcha-checkout captcha 11 4 6 1 5 1.0 4] [challenge-login challenge 2 1 1 0 1 1.0 1]]"
+	if fmt.Sprint(rules) != want {
+		t.Errorf("outcomes per rule:\n got  %s\n want %s", rules, want)
+	}
+
+	latency := readResult(t, "challenge-report", "challenge-solve-latency")
+	want = "[[kind from_seconds num_solves num_ips] [captcha -1 1 1] [captcha 0 3 1] [captcha 10 1 1] [challenge 0 1 1]]"
+	if fmt.Sprint(latency) != want {
+		t.Errorf("solve latency:\n got  %s\n want %s", latency, want)
+	}
+
+	var solvers []string
+	for _, row := range readResult(t, "challenge-report", "challenge-solvers") {
+		solvers = append(solvers, fmt.Sprint(append(row[:2:2], row[4:9]...)))
+	}
+	want = "[[kind client_ip num_solves num_fast_solves num_foreign_solves min_latency avg_latency] [captcha 6.6.6.6 3 3 0 1 1.0] [captcha 7.7.7.7 1 0 1  ]]"
+	if fmt.Sprint(solvers) != want {
+		t.Errorf("solvers:\n got  %s\n want %s", solvers, want)
+	}
+
+	if err := printer.NewChallengeReportPrinter(data.NewDir(data.DefaultRoot), query.WafBC).Print(); err != nil {
+		t.Errorf("printing report: %s", err)
+	}
+}
+
+func TestChallengeReportEdgeCases(t *testing.T) {
+	at := testDay.Add(10 * time.Hour)
+	puzzle := func(at time.Time, ip string, reason string) *waflog.Record {
+		r := testRecord(at, ip, "Mozilla/5.0", "captcha-checkout", "CAPTCHA")
+		r.CaptchaResponse = &waflog.TokenResponse{ResponseCode: 405, FailureReason: reason}
+		return r
+	}
+	pass := func(at time.Time, ip string, solved time.Time) *waflog.Record {
+		r := testRecord(at, ip, "Mozilla/5.0", "Default_Action", "ALLOW")
+		r.NonTerminatingMatchingRules = []waflog.RuleMatch{{RuleID: "captcha-checkout", Action: "CAPTCHA"}}
+		r.CaptchaResponse = &waflog.TokenResponse{ResponseCode: 0, SolveTimestamp: solved.Unix()}
+		return r
+	}
+
+	// a client without any headers whose puzzle has no failure reason, solving
+	// it in 2 seconds from an IPv6 address
+	noHeaders := []*waflog.Record{
+		puzzle(at, "2001:db8::3", ""),
+		pass(at.Add(3*time.Second), "2001:db8::3", at.Add(2*time.Second)),
+	}
+	for _, r := range noHeaders {
+		r.HTTPRequest.Headers = nil
+	}
+	// an expired token, solved 6 minutes after the puzzle, too late to be its
+	// solve
+	late := []*waflog.Record{
+		puzzle(at, "9.9.9.9", "TOKEN_EXPIRED"),
+		pass(at.Add(10*time.Minute), "9.9.9.9", at.Add(6*time.Minute)),
+	}
+
+	outcomes := []string{"dimension", "identity", "num_issued", "num_solved", "num_failed"}
+	solvers := []string{"kind", "client_ip", "user_agent", "num_solves", "num_fast_solves", "num_foreign_solves", "min_latency"}
+	runReportCases(t, "challenge-report", false, func(b Backend) error {
+		return NewChallengeReportLoader(b, query.WafBC, 10, 3, testDay).Run()
+	}, []reportCase{
+		{
+			name:    "puzzle without failure reason issued",
+			records: noHeaders,
+			result:  "challenge-outcomes",
+			columns: outcomes,
+			want: [][]string{
+				{"country", "DE", "1", "1", "0"},
+				{"ip", "2001:db8::3", "1", "1", "0"},
+				{"rule", "captcha-checkout", "1", "1", "0"},
+				{"user_agent", "", "1", "1", "0"},
+			},
+		},
+		{
+			name:    "fast solve without headers",
+			records: noHeaders,
+			result:  "challenge-solvers",
+			columns: solvers,
+			want:    [][]string{{"captcha", "2001:db8::3", "", "1", "1", "0", "2"}},
+		},
+		{
+			name:    "expired token failed",
+			records: late,
+			result:  "challenge-outcomes",
+			columns: outcomes,
+			want: [][]string{
+				{"country", "DE", "0", "1", "1"},
+				{"ip", "9.9.9.9", "0", "1", "1"},
+				{"rule", "captcha-checkout", "0", "1", "1"},
+				{"user_agent", "Mozilla/5.0", "0", "1", "1"},
+			},
+		},
+		{
+			name:    "solve too late for the puzzle foreign",
+			records: late,
+			result:  "challenge-solvers",
+			columns: solvers,
+			want:    [][]string{{"captcha", "9.9.9.9", "Mozilla/5.0", "1", "0", "1", ""}},
+		},
+	})
+}
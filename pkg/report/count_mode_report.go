@@ -0,0 +1,143 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&countModeReport{})
+}
+
+// countModeReport defines the report on rules in COUNT mode
+type countModeReport struct{}
+
+func (countModeReport) Name() string      { return "count-mode" }
+func (countModeReport) Aliases() []string { return nil }
+
+func (countModeReport) Description() string {
+	return "requests matched by rules in COUNT mode with their top IPs, User-Agents and URIs"
+}
+
+func (countModeReport) Flags() []cli.Flag { return nil }
+
+func (countModeReport) NewLoader(b Backend, o Options) Loader {
+	return NewCountModeReportLoader(b, o.Waf, o.Day)
+}
+
+func (countModeReport) NewPrinter(o Options) Printer { return nil }
+
+// CountModeReportLoader loads the requests matched by rules in COUNT mode, to
+// see what would happen if they were switched to BLOCK
+type CountModeReportLoader struct {
+	*ReportLoader
+}
+
+func NewCountModeReportLoader(b Backend, waf query.WAF, t time.Time) *CountModeReportLoader {
+	year, month, day := getDay(t)
+
+	out := &CountModeReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "count-mode-report", year, month, day),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *CountModeReportLoader) load() error {
+	if err := r.LoadCountRuleMatches(); err != nil {
+		return fmt.Errorf("loading requests matched by rules in COUNT mode: %s", err)
+	}
+
+	if err := r.LoadTopIPsPerCountRule(); err != nil {
+		return fmt.Errorf("loading top IPs per rule in COUNT mode: %s", err)
+	}
+
+	if err := r.LoadTopUserAgentsPerCountRule(); err != nil {
+		return fmt.Errorf("loading top User-Agents per rule in COUNT mode: %s", err)
+	}
+
+	if err := r.LoadTopURIsPerCountRule(); err != nil {
+		return fmt.Errorf("loading top URIs per rule in COUNT mode: %s", err)
+	}
+
+	return nil
+}
+
+func (r *CountModeReportLoader) LoadCountRuleMatches() error {
+	r.printf("\n[+] Loading requests matched per rule in COUNT mode...\n")
+
+	sql, err := query.GetCountRuleMatches(
+		r.Scope,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("count-rule-matches", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *CountModeReportLoader) LoadTopIPsPerCountRule() error {
+	r.printf("\n[+] Loading top IPs per rule in COUNT mode...\n")
+
+	sql, err := query.GetCountRuleTopIdentities(
+		r.Scope,
+		query.IdentityColumnsIP,
+		50,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("count-rule-top-ips", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *CountModeReportLoader) LoadTopUserAgentsPerCountRule() error {
+	r.printf("\n[+] Loading top User-Agents per rule in COUNT mode...\n")
+
+	sql, err := query.GetCountRuleTopIdentities(
+		r.Scope,
+		query.IdentityColumnsUserAgent,
+		50,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("count-rule-top-user-agents", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *CountModeReportLoader) LoadTopURIsPerCountRule() error {
+	r.printf("\n[+] Loading top URIs per rule in COUNT mode...\n")
+
+	sql, err := query.GetCountRuleTopURIs(
+		r.Scope,
+		50,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("count-rule-top-uris", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
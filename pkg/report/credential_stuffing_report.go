@@ -0,0 +1,213 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&credentialStuffingReport{
+		loginPattern:  query.DefaultLoginPattern,
+		days:          7,
+		window:        query.DefaultWindow,
+		minPosts:      20,
+		minIPs:        20,
+		maxPostsPerIP: 5,
+	})
+}
+
+// credentialStuffingReport defines the report on credential stuffing and
+// account takeover attempts
+type credentialStuffingReport struct {
+	loginPattern  string       // regular expression of the URIs of login and account endpoints
+	days          int          // number of days of the daily trend
+	window        query.Window // to find the peak of clusters in
+	minPosts      int          // of clusters
+	minIPs        int          // sharing a User-Agent or fingerprint to be low and slow
+	maxPostsPerIP int          // of low and slow clusters
+}
+
+func (*credentialStuffingReport) Name() string      { return "credential-stuffing" }
+func (*credentialStuffingReport) Aliases() []string { return []string{"ato"} }
+
+func (*credentialStuffingReport) Description() string {
+	return "daily logins, POST clusters per IP, prefix, session and fingerprint with ATP labels, and low and slow attempts from many IPs sharing a User-Agent or fingerprint"
+}
+
+func (r *credentialStuffingReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "login-pattern",
+			Value:       query.DefaultLoginPattern,
+			Usage:       "regular expression of the URIs of the login and account endpoints",
+			Destination: &r.loginPattern,
+			Action: func(ctx *cli.Context, v string) error {
+				if _, err := regexp.Compile(v); err != nil {
+					return fmt.Errorf("login pattern %s invalid: %s", v, err)
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:        "days",
+			Value:       7,
+			Usage:       "number of days up to the timestamp of the daily trend",
+			Destination: &r.days,
+		},
+		&cli.IntFlag{
+			Name:        "min-posts",
+			Value:       20,
+			Usage:       "number of POST requests of an IP, prefix, session or fingerprint to load it as cluster",
+			Destination: &r.minPosts,
+		},
+		&cli.IntFlag{
+			Name:        "min-ips",
+			Value:       20,
+			Usage:       "number of IPs sharing a User-Agent or fingerprint to load them as low and slow",
+			Destination: &r.minIPs,
+		},
+		&cli.IntFlag{
+			Name:        "max-posts-per-ip",
+			Value:       5,
+			Usage:       "number of POST requests each IP of a low and slow cluster stays at or below",
+			Destination: &r.maxPostsPerIP,
+		},
+		windowFlag(&r.window),
+	}
+}
+
+func (r *credentialStuffingReport) NewLoader(b Backend, o Options) Loader {
+	return NewCredentialStuffingReportLoader(b, o.Waf, r.loginPattern, r.days, r.window, r.minPosts, r.minIPs, r.maxPostsPerIP, o.Day)
+}
+
+func (*credentialStuffingReport) NewPrinter(o Options) Printer {
+	return printer.NewCredentialStuffingReportPrinter(o.Data, o.Waf)
+}
+
+// CredentialStuffingReportLoader loads the requests to login and account
+// endpoints, to spot credential stuffing from single clients as well as
+// distributed over many IPs
+type CredentialStuffingReportLoader struct {
+	*ReportLoader
+	loginPattern  string
+	days          int
+	window        query.Window
+	minPosts      int
+	minIPs        int
+	maxPostsPerIP int
+}
+
+func NewCredentialStuffingReportLoader(b Backend, waf query.WAF, loginPattern string, days int, window query.Window, minPosts int, minIPs int, maxPostsPerIP int, t time.Time) *CredentialStuffingReportLoader {
+	year, month, day := getDay(t)
+
+	out := &CredentialStuffingReportLoader{
+		ReportLoader:  NewReportLoader(b, waf, "credential-stuffing-report", year, month, day),
+		loginPattern:  loginPattern,
+		days:          days,
+		window:        window,
+		minPosts:      minPosts,
+		minIPs:        minIPs,
+		maxPostsPerIP: maxPostsPerIP,
+	}
+	out.Params = map[string]string{
+		"login_pattern":    loginPattern,
+		"days":             strconv.Itoa(days),
+		"window":           window.String(),
+		"min_posts":        strconv.Itoa(minPosts),
+		"min_ips":          strconv.Itoa(minIPs),
+		"max_posts_per_ip": strconv.Itoa(maxPostsPerIP),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *CredentialStuffingReportLoader) load() error {
+	if err := r.LoadLoginDailyTraffic(); err != nil {
+		return fmt.Errorf("loading daily login traffic: %s", err)
+	}
+
+	if err := r.LoadLoginClusters(); err != nil {
+		return fmt.Errorf("loading login clusters: %s", err)
+	}
+
+	if err := r.LoadLowAndSlowLogins(); err != nil {
+		return fmt.Errorf("loading low and slow logins: %s", err)
+	}
+
+	return nil
+}
+
+func (r *CredentialStuffingReportLoader) LoadLoginDailyTraffic() error {
+	r.printf("\n[+] Loading login traffic per day of the last %d days...\n", r.days)
+
+	sql, err := query.GetLoginDailyTraffic(
+		r.Scope,
+		r.days,
+		r.loginPattern,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	step := r.rawTableStep("login-daily-traffic", sql)
+	step.Partitions = nil
+	for _, s := range r.Scope.LastDays(r.days) {
+		step.Partitions = append(step.Partitions, s.Partition())
+	}
+
+	if err := r.RunQuery(step); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *CredentialStuffingReportLoader) LoadLoginClusters() error {
+	r.printf("\n[+] Loading login POST requests per IP, prefix, session and fingerprint...\n")
+
+	sql, err := query.GetLoginClusters(
+		r.Scope,
+		r.loginPattern,
+		r.window,
+		r.minPosts,
+		50,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("login-clusters", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *CredentialStuffingReportLoader) LoadLowAndSlowLogins() error {
+	r.printf("\n[+] Loading low and slow logins from many IPs sharing a User-Agent or fingerprint...\n")
+
+	sql, err := query.GetLowAndSlowLogins(
+		r.Scope,
+		r.loginPattern,
+		r.minIPs,
+		r.maxPostsPerIP,
+		100,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("login-low-and-slow", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCredentialStuffingReport(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	login := func(at time.Time, ip string, ua string, method string, labels ...string) *waflog.Record {
+		r := testRecord(at, ip, ua, "Default_Action", "ALLOW", labels...)
+		r.HTTPRequest.URI = "/login"
+		r.HTTPRequest.HTTPMethod = method
+		return r
+	}
+
+	var records []*waflog.Record
+	for i := 0; i < 3; i++ { // customers the day before
+		records = append(records, login(at.AddDate(0, 0, -1), fmt.Sprintf("5.6.7.%d", i), "Mozilla/5.0", "GET"))
+		records = append(records, login(at.AddDate(0, 0, -1), fmt.Sprintf("5.6.7.%d", i), "Mozilla/5.0", "POST"))
+	}
+	for i := 0; i < 30; i++ { // a single client trying passwords fast
+		r := login(at.Add(time.Duration(i)*time.Second), "6.6.6.6", "Mozilla/5.0", "POST", "awswaf:managed:aws:atp:aggregate:volumetric:ip:high")
+		r.JA3Fingerprint = "ja3-fast"
+		if i >= 25 {
+			r.Action = "BLOCK"
+		}
+		records = append(records, r)
+	}
+	for i := 0; i < 25; i++ { // a botnet trying two passwords per IP
+		for j := 0; j < 2; j++ {
+			r := login(at.Add(time.Duration(i+j*60)*time.Minute), fmt.Sprintf("10.%d.0.1", i), "python-requests/2.31", "POST")
+			r.JA3Fingerprint = "ja3-botnet"
+			records = append(records, r)
+		}
+	}
+	records = append(records, testRecord(at, "6.6.6.6", "Mozilla/5.0", "Default_Action", "ALLOW")) // not a login
+	if _, err := offline.Write(filepath.Join(dir, "logs"), query.WafBC, "test", records); err != nil {
+		t.Fatal(err)
+	}
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewCredentialStuffingReportLoader(b, query.WafBC, query.DefaultLoginPattern, 2, query.DefaultWindow, 20, 20, 5, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	daily := readResult(t, "credential-stuffing-report", "login-daily-traffic")
+	if fmt.Sprint(daily) != "[[date num_requests num_posts num_post_ips num_posts_blocked num_atp_labeled num_compromised] [2023/02/20 6 3 3 0 0 0] [2023/02/21 80 80 26 5 30 0]]" {
+		t.Errorf("daily login traffic: %s", daily)
+	}
+
+	var clusters []string
+	for _, row := range readResult(t, "credential-stuffing-report", "login-clusters")[1:] {
+		clusters = append(clusters, fmt.Sprint(row[:5]))
+	}
+	want := "[[fingerprint ja3-botnet 50 5 25] [fingerprint ja3-fast 30 30 1] [ip 6.6.6.6 30 30 1] [prefix 6.6.6.0/24 30 30 1]]"
+	if fmt.Sprint(clusters) != want {
+		t.Errorf("login clusters:\n got  %s\n want %s", clusters, want)
+	}
+
+	slow := readResult(t, "credential-stuffing-report", "login-low-and-slow")
+	if len(slow) != 3 || fmt.Sprint(slow[1][:7]) != "[fingerprint ja3-botnet 25 25 1 50 2]" || fmt.Sprint(slow[2][:2]) != "[user_agent python-requests/2.31]" {
+		t.Errorf("low and slow logins: %s", slow)
+	}
+
+	if err := printer.NewCredentialStuffingReportPrinter(data.NewDir(data.DefaultRoot), query.WafBC).Print(); err != nil {
+		t.Errorf("printing report: %s", err)
+	}
+}
+
+func TestCredentialStuffingReportIPv6(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	// one network of a botnet writing its addresses differently, compressed,
+	// zero padded or in upper case
+	ips := []string{"2001:db8::1", "2001:0DB8:0000:0001::2", "2001:db8:0:ffff::3", "2001:0db8:0:0:0:0:0:4"}
+	var records []*waflog.Record
+	for i := 0; i < 24; i++ {
+		r := testRecord(at.Add(time.Duration(i)*time.Minute), ips[i%len(ips)], "python-requests/2.31", "Default_Action", "ALLOW")
+		r.HTTPRequest.URI = "/login"
+		r.HTTPRequest.HTTPMethod = "POST"
+		r.JA3Fingerprint = fmt.Sprintf("ja3-%d", i)
+		records = append(records, r)
+	}
+	if _, err := offline.Write(filepath.Join(dir, "logs"), query.WafBC, "test", records); err != nil {
+		t.Fatal(err)
+	}
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewCredentialStuffingReportLoader(b, query.WafBC, query.DefaultLoginPattern, 2, query.DefaultWindow, 20, 20, 5, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var clusters []string
+	for _, row := range readResult(t, "credential-stuffing-report", "login-clusters")[1:] {
+		clusters = append(clusters, fmt.Sprint(row[:5]))
+	}
+	if want := "[[prefix 2001:db8::/48 24 5 4]]"; fmt.Sprint(clusters) != want {
+		t.Errorf("login clusters:\n got  %s\n want %s", clusters, want)
+	}
+}
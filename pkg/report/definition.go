@@ -0,0 +1,418 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Definition is a report defined in a YAML file, e.g. by analysts writing SQL
+// but not Go. Its steps render SQL templates and run through the same loader
+// as the built-in reports. Steps read the tables created by the steps they
+// depend on as {{.Tables.step_name}}, see testdata/reports.
+//
+//	name: top-uris
+//	description: URIs with most requests
+//	thresholds:
+//	  limit: 100
+//	  min_requests: 1000
+//	steps:
+//	  - name: top-uris
+//	    template: |
+//	      SELECT httprequest.uri AS "uri", COUNT(*) AS "num_requests"
+//	      FROM {{.WafTable}}
+//	      WHERE day = '{{.Year}}/{{.Month}}/{{.Day}}'
+//	      GROUP BY 1 ORDER BY 2 DESC LIMIT {{.Params.limit}}
+//	print:
+//	  - step: top-uris
+//	    order_by: num_requests
+//	    min: {column: num_requests, value: min_requests}
+//	    limit: 10
+type Definition struct {
+	Name        string            `yaml:"name"`
+	Aliases     []string          `yaml:"aliases"`
+	Description string            `yaml:"description"`
+	Thresholds  map[string]string `yaml:"thresholds"` // defaults, overridden with --threshold name=value
+	Steps       []DefinitionStep  `yaml:"steps"`
+	Print       []DefinitionPrint `yaml:"print"`
+
+	Path string `yaml:"-"` // of the YAML file
+}
+
+// DefinitionStep is a query of a report defined in YAML. Steps without
+// dependencies read the day partition of the raw log table, the others the
+// tables created by the steps they depend on.
+type DefinitionStep struct {
+	Name      string            `yaml:"name"`       // of the output files
+	Template  string            `yaml:"template"`   // SQL template
+	File      string            `yaml:"file"`       // SQL template file relative to the YAML file, instead of template
+	Params    map[string]string `yaml:"params"`     // overriding the thresholds of the report in the template
+	DependsOn []string          `yaml:"depends_on"` // names of earlier steps
+	Creates   string            `yaml:"creates"`    // template of the name of the table created, if any
+}
+
+// DefinitionPrint defines how the printer summarises the output of a step
+type DefinitionPrint struct {
+	Step    string   `yaml:"step"`
+	Title   string   `yaml:"title"`
+	Columns []string `yaml:"columns"`  // all if empty
+	OrderBy string   `yaml:"order_by"` // descending
+	Limit   int      `yaml:"limit"`
+	Min     *struct {
+		Column string `yaml:"column"`
+		Value  string `yaml:"value"` // number or name of a threshold
+	} `yaml:"min"`
+}
+
+var reName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// ReadDefinition reads and validates the report defined in the YAML file at
+// path. The templates of steps in files are read into Template.
+func ReadDefinition(path string) (*Definition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening definition: %s", err)
+	}
+	defer f.Close()
+
+	d := &Definition{Path: path}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(d); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+
+	for i, s := range d.Steps {
+		if s.File == "" {
+			continue
+		}
+		if s.Template != "" {
+			return nil, fmt.Errorf("%s: step %s has both a template and a file", path, s.Name)
+		}
+		b, err := os.ReadFile(filepath.Join(filepath.Dir(path), s.File))
+		if err != nil {
+			return nil, fmt.Errorf("%s: reading template of step %s: %s", path, s.Name, err)
+		}
+		d.Steps[i].Template = string(b)
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return d, nil
+}
+
+// Validate checks the names, dependencies and print settings of the report
+// and renders its templates to find syntax errors and missing parameters
+func (d *Definition) Validate() error {
+	if !reName.MatchString(d.Name) {
+		return fmt.Errorf("name %q must be lower case letters, digits and dashes", d.Name)
+	}
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("report %s has no steps", d.Name)
+	}
+
+	// render the templates like a run of the day would
+	scope := query.Scope{Waf: query.WafBC, Year: 2023, Month: 2, Day: 21}
+	tables := map[string]string{}
+	for _, s := range d.Steps {
+		if !reName.MatchString(s.Name) {
+			return fmt.Errorf("step name %q must be lower case letters, digits and dashes", s.Name)
+		}
+		if _, ok := tables[s.Name]; ok {
+			return fmt.Errorf("step %s defined twice", s.Name)
+		}
+		if s.Template == "" {
+			return fmt.Errorf("step %s has no template", s.Name)
+		}
+		for _, dep := range s.DependsOn {
+			created, ok := tables[dep]
+			if !ok {
+				return fmt.Errorf("step %s depends on %s, which isn't an earlier step", s.Name, dep)
+			}
+			if created == "" {
+				return fmt.Errorf("step %s depends on %s, which creates no table", s.Name, dep)
+			}
+		}
+
+		sql, created, err := d.render(s, scope, d.Thresholds, tables)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(sql) == "" {
+			return fmt.Errorf("template of step %s is empty", s.Name)
+		}
+		tables[s.Name] = created
+	}
+
+	for _, p := range d.Print {
+		if _, ok := tables[p.Step]; !ok {
+			return fmt.Errorf("print of unknown step %s", p.Step)
+		}
+		if p.Limit < 0 {
+			return fmt.Errorf("print of step %s has a negative limit", p.Step)
+		}
+		if p.Min != nil {
+			if p.Min.Column == "" {
+				return fmt.Errorf("min of print of step %s has no column", p.Step)
+			}
+			if _, err := minValue(p.Min.Value, d.Thresholds); err != nil {
+				return fmt.Errorf("min of print of step %s: %s", p.Step, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// render returns the SQL of the step and the name of the table it creates
+func (d *Definition) render(s DefinitionStep, scope query.Scope, thresholds map[string]string, tables map[string]string) (string, string, error) {
+	params := map[string]string{}
+	for k, v := range thresholds {
+		params[k] = v
+	}
+	for k, v := range s.Params {
+		params[k] = v
+	}
+
+	// templates can't refer to names with dashes as fields
+	created := map[string]string{}
+	for name, table := range tables {
+		created[strings.ReplaceAll(name, "-", "_")] = table
+	}
+	tables = created
+
+	sql, err := query.RenderDefinition(s.Template, scope, params, tables)
+	if err != nil {
+		return "", "", fmt.Errorf("rendering template of step %s: %s", s.Name, err)
+	}
+
+	var table string
+	if s.Creates != "" {
+		table, err = query.RenderDefinition(s.Creates, scope, params, tables)
+		if err != nil {
+			return "", "", fmt.Errorf("rendering table created by step %s: %s", s.Name, err)
+		}
+	}
+
+	return sql, table, nil
+}
+
+// minValue returns the number value or the value of the threshold called so
+func minValue(value string, thresholds map[string]string) (float64, error) {
+	if v, ok := thresholds[value]; ok {
+		value = v
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither a number nor a threshold with a number", value)
+	}
+
+	return f, nil
+}
+
+// LoadDefinitions reads the reports defined in the YAML files of dir and
+// registers them next to the built-in reports. A missing dir defines no
+// reports.
+func LoadDefinitions(dir string) ([]*Definition, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	var out []*Definition
+	for _, path := range paths {
+		d, err := ReadDefinition(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range append([]string{d.Name}, d.Aliases...) {
+			if r, ok := Lookup(name); ok {
+				return nil, fmt.Errorf("%s: name %s already used by report %s", path, name, r.Name())
+			}
+		}
+
+		Register(&definitionReport{def: d})
+		out = append(out, d)
+	}
+
+	return out, nil
+}
+
+// Source returns the path of the YAML file defining the report, "built-in"
+// for the reports written in Go
+func Source(r Report) string {
+	if d, ok := r.(*definitionReport); ok {
+		return d.def.Path
+	}
+	return "built-in"
+}
+
+// definitionReport is the report of a definition
+type definitionReport struct {
+	def       *Definition
+	overrides cli.StringSlice
+}
+
+func (r *definitionReport) Name() string      { return r.def.Name }
+func (r *definitionReport) Aliases() []string { return r.def.Aliases }
+
+func (r *definitionReport) Description() string {
+	if r.def.Description == "" {
+		return "the report defined in " + r.def.Path
+	}
+	return r.def.Description
+}
+
+func (r *definitionReport) Flags() []cli.Flag {
+	if len(r.def.Thresholds) == 0 {
+		return nil
+	}
+
+	var names []string
+	for name, v := range r.def.Thresholds {
+		names = append(names, name+"="+v)
+	}
+	sort.Strings(names)
+
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "threshold",
+			Usage:       "override a threshold of the report, defaults: " + strings.Join(names, ", "),
+			Destination: &r.overrides,
+			Action: func(ctx *cli.Context, v []string) error {
+				_, err := r.thresholds()
+				return err
+			},
+		},
+	}
+}
+
+// thresholds returns the thresholds of the definition with the overrides of
+// the command line
+func (r *definitionReport) thresholds() (map[string]string, error) {
+	out := map[string]string{}
+	for k, v := range r.def.Thresholds {
+		out[k] = v
+	}
+
+	for _, o := range r.overrides.Value() {
+		name, v, ok := strings.Cut(o, "=")
+		if !ok {
+			return nil, fmt.Errorf("threshold %s must be name=value", o)
+		}
+		if _, ok := out[name]; !ok {
+			return nil, fmt.Errorf("threshold %s unknown", name)
+		}
+		out[name] = v
+	}
+
+	for _, p := range r.def.Print {
+		if p.Min == nil {
+			continue
+		}
+		if _, err := minValue(p.Min.Value, out); err != nil {
+			return nil, fmt.Errorf("min of print of step %s: %s", p.Step, err)
+		}
+	}
+
+	return out, nil
+}
+
+func (r *definitionReport) NewLoader(b Backend, o Options) Loader {
+	thresholds, _ := r.thresholds() // checked when parsing the flags
+	return NewDefinitionReportLoader(b, r.def, thresholds, o.Waf, o.Day)
+}
+
+func (r *definitionReport) NewPrinter(o Options) Printer {
+	if len(r.def.Print) == 0 {
+		return nil
+	}
+
+	thresholds, _ := r.thresholds()
+
+	var summaries []printer.Summary
+	for _, p := range r.def.Print {
+		s := printer.Summary{
+			Result:  p.Step,
+			Title:   p.Title,
+			Columns: p.Columns,
+			OrderBy: p.OrderBy,
+			Limit:   p.Limit,
+		}
+		if p.Min != nil {
+			s.MinColumn = p.Min.Column
+			s.Min, _ = minValue(p.Min.Value, thresholds)
+		}
+		summaries = append(summaries, s)
+	}
+
+	return printer.NewSummaryPrinter(o.Data, o.Waf, r.def.Name, summaries)
+}
+
+// DefinitionReportLoader loads the steps of a report defined in YAML
+type DefinitionReportLoader struct {
+	*ReportLoader
+	def        *Definition
+	thresholds map[string]string
+}
+
+func NewDefinitionReportLoader(b Backend, def *Definition, thresholds map[string]string, waf query.WAF, t time.Time) *DefinitionReportLoader {
+	year, month, day := getDay(t)
+
+	out := &DefinitionReportLoader{
+		ReportLoader: NewReportLoader(b, waf, def.Name, year, month, day),
+		def:          def,
+		thresholds:   thresholds,
+	}
+	out.Params = thresholds
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *DefinitionReportLoader) load() error {
+	tables := map[string]string{}
+	for _, s := range r.def.Steps {
+		r.printf("\n[+] Loading %s...\n", s.Name)
+
+		sql, created, err := r.def.render(s, r.Scope, r.thresholds, tables)
+		if err != nil {
+			return err
+		}
+		tables[s.Name] = created
+
+		step := r.rawTableStep(s.Name, sql)
+		if len(s.DependsOn) > 0 {
+			step = Step{Name: s.Name, SQL: sql, DependsOn: s.DependsOn}
+			for _, dep := range s.DependsOn {
+				step.Reads = append(step.Reads, tables[dep])
+			}
+		}
+		step.Creates = created
+
+		if err := r.RunQuery(step); err != nil {
+			return fmt.Errorf("running query of step %s: %s", s.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefinition(t *testing.T) {
+	def, err := ReadDefinition(filepath.Join("testdata", "reports", "busy-ips.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, []*waflog.Record{
+		testRecord(at, "1.1.1.1", "curl/7.0", "Default_Action", "ALLOW"),
+		testRecord(at, "1.1.1.1", "curl/7.0", "Default_Action", "ALLOW"),
+		testRecord(at, "1.1.1.1", "curl/7.0", "Default_Action", "ALLOW"),
+		testRecord(at, "2.2.2.2", "curl/7.0", "Default_Action", "ALLOW"),
+		testRecord(at, "2.2.2.2", "curl/7.0", "Default_Action", "ALLOW"),
+		testRecord(at, "3.3.3.3", "curl/7.0", "Default_Action", "ALLOW"),
+	})
+
+	r := &definitionReport{def: def}
+	r.overrides.Set("min_requests=3")
+	thresholds, err := r.thresholds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	l := NewDefinitionReportLoader(b, def, thresholds, query.WafBC, testDay)
+	if err := l.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(readResult(t, "busy-ips", "busy-ips")); got != "[[client_ip num_requests] [1.1.1.1 3]]" {
+		t.Errorf("busy IPs with at least 3 requests: %s", got)
+	}
+
+	m, err := data.ReadManifest(filepath.Join(data.DefaultRoot, "BC", "busy-ips", "2023-02-21"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Status != data.StatusComplete || m.Params["min_requests"] != "3" || m.Params["limit"] != "100" || len(m.Steps) != 2 {
+		t.Errorf("manifest of run: %+v", m)
+	}
+	if steps := l.Plan().Steps; steps[1].Reads[0] != "busy_ips_BC_2023_02_21" || steps[1].DependsOn[0] != "create-busy-ips" {
+		t.Errorf("plan of dependent step: %+v", steps[1])
+	}
+}
+
+func TestDefinitionValidation(t *testing.T) {
+	step := "steps:\n  - name: s\n    template: SELECT {{.Params.limit}}\n"
+
+	cases := []struct {
+		yaml string
+		err  string
+	}{
+		{"name: Bad\n" + step, "must be lower case"},
+		{"name: r\n", "has no steps"},
+		{"name: r\nunknown: 1\n" + step, "field unknown not found"},
+		{"name: r\n" + step, `map has no entry for key "limit"`},
+		{"name: r\nthresholds: {limit: 1}\nsteps:\n  - name: s\n    template: SELECT {{.Params.limit\n", "unclosed action"},
+		{"name: r\nthresholds: {limit: 1}\nsteps:\n  - name: s\n    template: SELECT 1\n  - name: s\n    template: SELECT 2\n", "defined twice"},
+		{"name: r\nthresholds: {limit: 1}\nsteps:\n  - name: s\n    template: SELECT 1\n    depends_on: [t]\n", "isn't an earlier step"},
+		{"name: r\nthresholds: {limit: 1}\nsteps:\n  - name: t\n    template: SELECT 1\n  - name: s\n    template: SELECT 1\n    depends_on: [t]\n", "creates no table"},
+		{"name: r\nthresholds: {limit: 1}\n" + step + "print:\n  - step: t\n", "print of unknown step t"},
+		{"name: r\nthresholds: {limit: 1}\n" + step + "print:\n  - step: s\n    min: {column: c, value: x}\n", "neither a number nor a threshold"},
+		{"name: r\nthresholds: {limit: 1}\n" + step + "print:\n  - step: s\n    min: {column: c, value: limit}\n", ""},
+	}
+
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "r.yaml")
+		if err := os.WriteFile(path, []byte(c.yaml), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := ReadDefinition(path)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%q: %s", c.yaml, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%q: got error %v, want %q", c.yaml, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&falsePositiveReport{minAllowed: 3, cartPattern: query.DefaultCartPattern})
+}
+
+// falsePositiveReport defines the report on blocked sessions of customers
+type falsePositiveReport struct {
+	minAllowed  int    // requests allowed before the first block of a session
+	cartPattern string // regular expression of cart and checkout URIs
+}
+
+func (*falsePositiveReport) Name() string      { return "false-positives" }
+func (*falsePositiveReport) Aliases() []string { return []string{"fp"} }
+
+func (*falsePositiveReport) Description() string {
+	return "blocks of sessions that browsed normally before, per rule and URI pattern with example request ids"
+}
+
+func (r *falsePositiveReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "min-allowed",
+			Value:       3,
+			Usage:       "number of requests a session made before its first block to count as normal browsing",
+			Destination: &r.minAllowed,
+		},
+		&cli.StringFlag{
+			Name:        "cart-pattern",
+			Value:       query.DefaultCartPattern,
+			Usage:       "regular expression of the URIs of the cart and checkout",
+			Destination: &r.cartPattern,
+			Action: func(ctx *cli.Context, v string) error {
+				if _, err := regexp.Compile(v); err != nil {
+					return fmt.Errorf("cart pattern %s invalid: %s", v, err)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+func (r *falsePositiveReport) NewLoader(b Backend, o Options) Loader {
+	return NewFalsePositiveReportLoader(b, o.Waf, r.minAllowed, r.cartPattern, o.Day)
+}
+
+func (*falsePositiveReport) NewPrinter(o Options) Printer {
+	return printer.NewSummaryPrinter(o.Data, o.Waf, "false-positive-report", []printer.Summary{{
+		Result:  "blocked-sessions",
+		Title:   "Blocks of sessions browsing normally before, by sessions in the cart or checkout",
+		OrderBy: "num_cart_sessions",
+		Limit:   20,
+	}})
+}
+
+// FalsePositiveReportLoader loads the blocks of sessions that browsed like
+// customers before, to tune the rules blocking them
+type FalsePositiveReportLoader struct {
+	*ReportLoader
+	minAllowed  int
+	cartPattern string
+}
+
+func NewFalsePositiveReportLoader(b Backend, waf query.WAF, minAllowed int, cartPattern string, t time.Time) *FalsePositiveReportLoader {
+	year, month, day := getDay(t)
+
+	out := &FalsePositiveReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "false-positive-report", year, month, day),
+		minAllowed:   minAllowed,
+		cartPattern:  cartPattern,
+	}
+	out.Params = map[string]string{
+		"min_allowed":  strconv.Itoa(minAllowed),
+		"cart_pattern": cartPattern,
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *FalsePositiveReportLoader) load() error {
+	if err := r.LoadBlockedSessions(); err != nil {
+		return fmt.Errorf("loading blocked sessions: %s", err)
+	}
+
+	return nil
+}
+
+func (r *FalsePositiveReportLoader) LoadBlockedSessions() error {
+	r.printf("\n[+] Loading blocks of sessions browsing normally before...\n")
+
+	sql, err := query.GetBlockedSessions(
+		r.Scope,
+		r.minAllowed,
+		r.cartPattern,
+		5,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("blocked-sessions", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFalsePositiveReport(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	var records []*waflog.Record
+	request := func(i int, session string, ua string, uri string, rule string, action string, labels ...string) {
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "10.0.0.1", ua, rule, action, labels...)
+		r.HTTPRequest.URI = uri
+		r.HTTPRequest.RequestID = fmt.Sprintf("%s-%d", session, i)
+		r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "Cookie", Value: "lang=de; session=" + session})
+		records = append(records, r)
+	}
+
+	// a customer blocked in the checkout after browsing the shop
+	for i, uri := range []string{"/", "/ersatzteile-verschleissteile/bremsen/123", "/warenkorb"} {
+		request(i, "customer", "Mozilla/5.0", uri, "Default_Action", "ALLOW")
+	}
+	request(3, "customer", "Mozilla/5.0", "/checkout/step/2", "AWS-AWSManagedRulesCommonRuleSet", "BLOCK")
+	request(4, "customer", "Mozilla/5.0", "/checkout/step/3", "AWS-AWSManagedRulesCommonRuleSet", "BLOCK")
+
+	// a visitor blocked after too few requests to count as browsing
+	request(0, "visitor", "Mozilla/5.0", "/", "Default_Action", "ALLOW")
+	request(1, "visitor", "Mozilla/5.0", "/search/42", "ip-rate-limit", "BLOCK")
+
+	// a bot keeping its session cookie
+	for i := 0; i < 5; i++ {
+		request(i, "bot", "python-requests/2.31", "/search/1", "Default_Action", "ALLOW", "awswaf:managed:aws:bot-control:signal:non_browser_user_agent")
+	}
+	request(5, "bot", "python-requests/2.31", "/search/1", "ip-rate-limit", "BLOCK", "awswaf:managed:aws:bot-control:signal:non_browser_user_agent")
+
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewFalsePositiveReportLoader(b, query.WafBC, 3, query.DefaultCartPattern, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	for _, row := range readResult(t, "false-positive-report", "blocked-sessions") {
+		got = append(got, row[:6])
+	}
+	want := "[[rule uri_pattern num_blocked num_sessions num_cart_sessions example_request_ids] [AWS-AWSManagedRulesCommonRuleSet /checkout/step/<n> 2 1 1 [customer-3, customer-4]]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("blocked sessions:\n got  %s\n want %s", got, want)
+	}
+}
+
+func TestFalsePositiveReportEdgeCases(t *testing.T) {
+	at := testDay.Add(10 * time.Hour)
+	request := func(i int, id string, cookie string, uri string, rule string, action string) *waflog.Record {
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "10.0.0.1", "Mozilla/5.0", rule, action)
+		r.HTTPRequest.URI = uri
+		r.HTTPRequest.RequestID = fmt.Sprintf("%s-%d", id, i)
+		if cookie != "" {
+			r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "cookie", Value: cookie})
+		}
+		return r
+	}
+	// browsedThenBlocked returns 3 browsing requests and a block of the session
+	browsedThenBlocked := func(cookie string) []*waflog.Record {
+		var records []*waflog.Record
+		for i := 0; i < 3; i++ {
+			records = append(records, request(i, "session", cookie, "/", "Default_Action", "ALLOW"))
+		}
+		return append(records, request(3, "session", cookie, "/search/1", "ip-rate-limit", "BLOCK"))
+	}
+
+	managed := request(3, "customer", "Session=abc", "/ersatzteile-verschleissteile/bremsen/99", "AWS-AWSManagedRulesCommonRuleSet", "BLOCK")
+	managed.RuleGroupList = []waflog.RuleGroup{
+		{RuleGroupID: "AWS#AWSManagedRulesKnownBadInputsRuleSet"},
+		{RuleGroupID: "AWS#AWSManagedRulesCommonRuleSet", TerminatingRule: &waflog.RuleMatch{RuleID: "SizeRestrictions_BODY", Action: "BLOCK"}},
+	}
+
+	columns := []string{"rule", "uri_pattern", "num_blocked", "num_sessions", "example_request_ids"}
+	runReportCases(t, "false-positive-report", false, func(b Backend) error {
+		return NewFalsePositiveReportLoader(b, query.WafBC, 3, query.DefaultCartPattern, testDay).Run()
+	}, []reportCase{
+		{
+			name: "rule of a managed rule group, session cookie in upper case",
+			records: []*waflog.Record{
+				request(0, "customer", "Session=abc", "/", "Default_Action", "ALLOW"),
+				request(1, "customer", "Session=abc", "/ersatzteile-verschleissteile/bremsen/1", "Default_Action", "ALLOW"),
+				request(2, "customer", "Session=abc", "/p/2", "Default_Action", "ALLOW"),
+				managed,
+			},
+			result:  "blocked-sessions",
+			columns: columns,
+			want:    [][]string{{"AWS-AWSManagedRulesCommonRuleSet/SizeRestrictions_BODY", "/ersatzteile-verschleissteile/...", "1", "1", "[customer-3]"}},
+		},
+		{
+			name: "browsing only after the block",
+			records: []*waflog.Record{
+				request(0, "late", "session=late", "/", "Default_Action", "ALLOW"),
+				request(1, "late", "session=late", "/search/1", "ip-rate-limit", "BLOCK"),
+				request(2, "late", "session=late", "/", "Default_Action", "ALLOW"),
+				request(3, "late", "session=late", "/", "Default_Action", "ALLOW"),
+				request(4, "late", "session=late", "/", "Default_Action", "ALLOW"),
+			},
+			result:  "blocked-sessions",
+			columns: columns,
+		},
+		{
+			name:    "empty session cookie",
+			records: browsedThenBlocked("session="),
+			result:  "blocked-sessions",
+			columns: columns,
+		},
+		{
+			name:    "no cookies",
+			records: browsedThenBlocked(""),
+			result:  "blocked-sessions",
+			columns: columns,
+		},
+	})
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"kfzteile24/waflogs/pkg/query"
+
+	"github.com/urfave/cli/v2"
+)
+
+// windowFlag returns the flag of the minutes of the window of the reports
+// aggregating request rates, setting w
+func windowFlag(w *query.Window) cli.Flag {
+	return &cli.IntFlag{
+		Name:  "window",
+		Value: query.DefaultWindow.Minutes,
+		Usage: "window in minutes to aggregate request rates in (1, 2, 5 or 10, like WAF rate-based rules)",
+		Action: func(ctx *cli.Context, v int) error {
+			w.Minutes = v
+			return w.Validate()
+		},
+	}
+}
+
+// slidingFlag returns the flag of the reports aggregating request rates in
+// sliding windows, setting w
+func slidingFlag(w *query.Window) cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "sliding",
+		Value: false,
+		Usage: "use sliding windows like WAF rate-based rules instead of tumbling windows",
+		Action: func(ctx *cli.Context, v bool) error {
+			w.Sliding = v
+			return nil
+		},
+	}
+}
+
+// labelFlag returns the flag of the labels the reports filtering requests by
+// labels load, setting l
+func labelFlag(l *query.LabelFilter) cli.Flag {
+	return &cli.StringSliceFlag{
+		Name:    "label",
+		Aliases: []string{"l"},
+		Usage:   "only requests with labels of the namespace (bot-control, atp, acfp, anonymous-ip, ip-reputation, custom) or label prefix, can be repeated",
+		Action: func(ctx *cli.Context, v []string) error {
+			f, err := query.ParseLabelFilter(v)
+			if err != nil {
+				return err
+			}
+
+			*l = f
+			return nil
+		},
+	}
+}
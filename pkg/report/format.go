@@ -0,0 +1,38 @@
+package report
+
+import "fmt"
+
+// Format selects the files results are written to
+type Format int
+
+const (
+	FormatCSV     Format = iota // results rendered like Athena renders them
+	FormatParquet               // typed results by the column types of the backend
+	FormatBoth
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "csv":
+		return FormatCSV, nil
+	case "parquet":
+		return FormatParquet, nil
+	case "both":
+		return FormatBoth, nil
+	default:
+		return FormatCSV, fmt.Errorf("format %s unknown, must be one of 'csv', 'parquet', 'both'", s)
+	}
+}
+
+func (f Format) String() string {
+	switch f {
+	case FormatCSV:
+		return "csv"
+	case FormatParquet:
+		return "parquet"
+	case FormatBoth:
+		return "both"
+	default:
+		panic(fmt.Sprintf("unreachable code reached: format %d unknown", f))
+	}
+}
@@ -0,0 +1,152 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&geoReport{baselineDays: 7, factor: 3, minRequests: 100})
+}
+
+// geoReport defines the report on the traffic per country
+type geoReport struct {
+	baselineDays int     // number of days before the day to compare shares to
+	factor       float64 // by which the share of a country has to change to be flagged
+	minRequests  int     // of countries to flag
+}
+
+func (*geoReport) Name() string      { return "geo" }
+func (*geoReport) Aliases() []string { return nil }
+
+func (*geoReport) Description() string {
+	return "requests, blocks and rate limit blocks per country and hour, and countries surging, dropping or new compared to the days before"
+}
+
+func (r *geoReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "baseline-days",
+			Value:       7,
+			Usage:       "number of days before the timestamp to compare the shares of countries to",
+			Destination: &r.baselineDays,
+		},
+		&cli.Float64Flag{
+			Name:        "share-factor",
+			Value:       3,
+			Usage:       "factor the share of the requests of a country has to grow or shrink by to be flagged",
+			Destination: &r.factor,
+		},
+		&cli.IntFlag{
+			Name:        "min-requests",
+			Value:       100,
+			Usage:       "number of requests, actual or expected from the baseline, below which countries aren't flagged",
+			Destination: &r.minRequests,
+		},
+	}
+}
+
+func (r *geoReport) NewLoader(b Backend, o Options) Loader {
+	return NewGeoReportLoader(b, o.Waf, r.baselineDays, r.factor, r.minRequests, o.Day)
+}
+
+func (*geoReport) NewPrinter(o Options) Printer {
+	return printer.NewGeoReportPrinter(o.Data, o.Waf)
+}
+
+// GeoReportLoader loads the traffic per country and compares the share of
+// each country to a trailing baseline, so that surges from a single country
+// stand out
+type GeoReportLoader struct {
+	*ReportLoader
+	baselineDays int
+	factor       float64
+	minRequests  int
+}
+
+func NewGeoReportLoader(b Backend, waf query.WAF, baselineDays int, factor float64, minRequests int, t time.Time) *GeoReportLoader {
+	year, month, day := getDay(t)
+
+	out := &GeoReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "geo-report", year, month, day),
+		baselineDays: baselineDays,
+		factor:       factor,
+		minRequests:  minRequests,
+	}
+	out.Params = map[string]string{
+		"baseline_days": strconv.Itoa(baselineDays),
+		"share_factor":  strconv.FormatFloat(factor, 'f', -1, 64),
+		"min_requests":  strconv.Itoa(minRequests),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *GeoReportLoader) load() error {
+	if err := r.LoadCountryHourlyTraffic(); err != nil {
+		return fmt.Errorf("loading traffic per country and hour: %s", err)
+	}
+
+	if err := r.LoadCountryShares(); err != nil {
+		return fmt.Errorf("loading shares of countries: %s", err)
+	}
+
+	return nil
+}
+
+func (r *GeoReportLoader) LoadCountryHourlyTraffic() error {
+	r.printf("\n[+] Loading traffic per country and hour of the last %d days...\n", r.baselineDays+1)
+
+	sql, err := query.GetGeoCountryHourly(
+		r.Scope,
+		r.baselineDays+1,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.baselineStep("country-hourly-traffic", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *GeoReportLoader) LoadCountryShares() error {
+	r.printf("\n[+] Comparing shares of countries to the %d days before...\n", r.baselineDays)
+
+	sql, err := query.GetGeoCountryShares(
+		r.Scope,
+		r.baselineDays,
+		r.factor,
+		r.minRequests,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.baselineStep("country-shares", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+// baselineStep returns a step reading the day and the days of the baseline
+// before it from the raw log table
+func (r *GeoReportLoader) baselineStep(name string, sql string) Step {
+	step := r.rawTableStep(name, sql)
+	step.Partitions = nil
+	for _, s := range r.Scope.LastDays(r.baselineDays + 1) {
+		step.Partitions = append(step.Partitions, s.Partition())
+	}
+
+	return step
+}
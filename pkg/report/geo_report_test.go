@@ -0,0 +1,149 @@
+package report
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeoReport(t *testing.T) {
+	dir := chdir(t)
+
+	requests := func(day time.Time, country string, n int) []*waflog.Record {
+		var out []*waflog.Record
+		for i := 0; i < n; i++ {
+			r := testRecord(day.Add(10*time.Hour+time.Duration(i)*time.Second), "1.2.3.4", "curl/7.0", "Default_Action", "ALLOW")
+			r.HTTPRequest.Country = country
+			out = append(out, r)
+		}
+		return out
+	}
+
+	var records []*waflog.Record
+	for _, day := range []time.Time{testDay.AddDate(0, 0, -2), testDay.AddDate(0, 0, -1)} {
+		records = append(records, requests(day, "DE", 90)...)
+		records = append(records, requests(day, "FR", 10)...)
+	}
+	records = append(records, requests(testDay, "DE", 20)...)  // drop
+	records = append(records, requests(testDay, "FR", 100)...) // surge
+	records = append(records, requests(testDay, "US", 50)...)  // new
+	records[len(records)-1].Action = "BLOCK"
+	records[len(records)-1].TerminatingRuleID = query.TerminatingRuleRateLimit.String()
+	if _, err := offline.Write(filepath.Join(dir, "logs"), query.WafBC, "test", records); err != nil {
+		t.Fatal(err)
+	}
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewGeoReportLoader(b, query.WafBC, 2, 3, 10, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[[country flag num_blocked num_rate_limited] [FR surge 0 0] [US new 1 1] [DE drop 0 0]]"
+	var got [][]string
+	for _, row := range readResult(t, "geo-report", "country-shares") {
+		got = append(got, []string{row[0], row[8], row[2], row[3]})
+	}
+	if fmt.Sprint(got) != want {
+		t.Errorf("country shares:\n got  %s\n want %s", got, want)
+	}
+
+	hourly := readResult(t, "geo-report", "country-hourly-traffic")
+	if len(hourly) != 8 || fmt.Sprint(hourly[len(hourly)-1]) != "[DE 2023/02/21 10 20 0 0]" {
+		t.Errorf("traffic per country and hour: %s", hourly)
+	}
+
+	if err := printer.NewGeoReportPrinter(data.NewDir(data.DefaultRoot), query.WafBC).Print(); err != nil {
+		t.Errorf("printing report: %s", err)
+	}
+}
+
+func TestGeoReportBaseline(t *testing.T) {
+	dir := chdir(t)
+
+	requests := func(day time.Time, country string, n int) []*waflog.Record {
+		var out []*waflog.Record
+		for i := 0; i < n; i++ {
+			r := testRecord(day.Add(10*time.Hour+time.Duration(i)*time.Second), "1.2.3.4", "curl/7.0", "Default_Action", "ALLOW")
+			r.HTTPRequest.Country = country
+			out = append(out, r)
+		}
+		return out
+	}
+
+	// the first day of the baseline is missing, IT has no requests on the
+	// day and some requests have no country every day
+	var records []*waflog.Record
+	for _, day := range []time.Time{testDay.AddDate(0, 0, -2), testDay.AddDate(0, 0, -1)} {
+		records = append(records, requests(day, "DE", 90)...)
+		records = append(records, requests(day, "IT", 10)...)
+		writeLogsWithoutCountry(t, filepath.Join(dir, "logs"), query.WafBC, requests(day, "", 10))
+	}
+	records = append(records, requests(testDay, "DE", 90)...)
+	writeLogsWithoutCountry(t, filepath.Join(dir, "logs"), query.WafBC, requests(testDay, "", 10))
+	if _, err := offline.Write(filepath.Join(dir, "logs"), query.WafBC, "test", records); err != nil {
+		t.Fatal(err)
+	}
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewGeoReportLoader(b, query.WafBC, 3, 3, 5, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the baseline averages the two days loaded, requests without a country
+	// are compared to their baseline instead of being new, IT is dropped
+	want := "country|flag|num_requests|baseline_share IT|drop|0|0.090909 DE||90|0.818182 ||10|0.090909"
+	var got []string
+	for _, row := range readResult(t, "geo-report", "country-shares") {
+		got = append(got, strings.Join([]string{row[0], row[8], row[1], row[5]}, "|"))
+	}
+	if strings.Join(got, " ") != want {
+		t.Errorf("country shares:\n got  %s\n want %s", strings.Join(got, " "), want)
+	}
+}
+
+// writeLogsWithoutCountry writes the records of an hour to dir like
+// offline.Write, without the country of the requests as in logs of requests
+// AWS WAF couldn't locate
+func writeLogsWithoutCountry(t *testing.T, dir string, waf query.WAF, records []*waflog.Record) {
+	t.Helper()
+
+	path := filepath.Join(dir, waf.String(), filepath.FromSlash(records[0].Time().Format("2006/01/02/15")), "aws-waf-logs-no-country.gz")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal(b, &object); err != nil {
+			t.Fatal(err)
+		}
+		delete(object["httpRequest"].(map[string]interface{}), "country")
+		if err := json.NewEncoder(gz).Encode(object); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
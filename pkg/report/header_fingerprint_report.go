@@ -0,0 +1,116 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&headerFingerprintReport{})
+}
+
+// headerFingerprintReport defines the report on the headers of clients
+// claiming to be browsers
+type headerFingerprintReport struct{}
+
+func (headerFingerprintReport) Name() string      { return "header-fingerprints" }
+func (headerFingerprintReport) Aliases() []string { return []string{"headers"} }
+
+func (headerFingerprintReport) Description() string {
+	return "header order and Accept-Language, Accept-Encoding and sec-ch-ua anomalies of clients claiming a browser, clustered by fingerprint, with the IPs Bot Control missed"
+}
+
+func (headerFingerprintReport) Flags() []cli.Flag { return nil }
+
+func (headerFingerprintReport) NewLoader(b Backend, o Options) Loader {
+	return NewHeaderFingerprintReportLoader(b, o.Waf, o.Day)
+}
+
+func (headerFingerprintReport) NewPrinter(o Options) Printer {
+	return printer.NewSummaryPrinter(o.Data, o.Waf, "header-fingerprint-report", []printer.Summary{
+		{
+			Result:  "header-fingerprints",
+			Title:   "Header fingerprints of clients claiming a browser, by IPs",
+			Columns: []string{"browser", "anomalies", "num_requests", "num_ips", "num_user_agents", "num_missed", "num_blocked", "header_order"},
+			OrderBy: "num_ips",
+			Limit:   20,
+		},
+		{
+			Result:  "header-anomalies",
+			Title:   "Clients claiming a browser with headers no browser sends, by requests Bot Control missed",
+			OrderBy: "num_missed",
+			Limit:   20,
+		},
+	})
+}
+
+// HeaderFingerprintReportLoader loads the header fingerprints of the
+// requests claiming a browser, to catch scrapers copying the User-Agent of a
+// browser but not its headers
+type HeaderFingerprintReportLoader struct {
+	*ReportLoader
+}
+
+func NewHeaderFingerprintReportLoader(b Backend, waf query.WAF, t time.Time) *HeaderFingerprintReportLoader {
+	year, month, day := getDay(t)
+
+	out := &HeaderFingerprintReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "header-fingerprint-report", year, month, day),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *HeaderFingerprintReportLoader) load() error {
+	if err := r.LoadHeaderFingerprints(); err != nil {
+		return fmt.Errorf("loading header fingerprints: %s", err)
+	}
+
+	if err := r.LoadHeaderAnomalies(); err != nil {
+		return fmt.Errorf("loading header anomalies: %s", err)
+	}
+
+	return nil
+}
+
+func (r *HeaderFingerprintReportLoader) LoadHeaderFingerprints() error {
+	r.printf("\n[+] Loading header fingerprints of clients claiming a browser...\n")
+
+	sql, err := query.GetHeaderFingerprints(
+		r.Scope,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("header-fingerprints", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *HeaderFingerprintReportLoader) LoadHeaderAnomalies() error {
+	r.printf("\n[+] Loading clients claiming a browser with headers no browser sends...\n")
+
+	sql, err := query.GetHeaderAnomalies(
+		r.Scope,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("header-anomalies", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHeaderFingerprintReport(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+	chrome := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	firefox := "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0"
+
+	var records []*waflog.Record
+	request := func(i int, ip string, headers ...waflog.Header) {
+		r := testRecord(at.Add(time.Duration(i)*time.Second), ip, "", "Default_Action", "ALLOW")
+		r.HTTPRequest.Headers = headers
+		records = append(records, r)
+	}
+
+	for i := 0; i < 3; i++ { // customers using Chrome, with and without cookie
+		headers := []waflog.Header{
+			{Name: "sec-ch-ua", Value: `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`},
+			{Name: "user-agent", Value: chrome},
+			{Name: "accept", Value: "text/html"},
+			{Name: "accept-encoding", Value: "gzip, deflate, br"},
+			{Name: "accept-language", Value: "de-DE,de;q=0.9"},
+		}
+		if i > 0 {
+			headers = append(headers, waflog.Header{Name: "cookie", Value: "session=customer"})
+		}
+		request(i, fmt.Sprintf("10.0.0.%d", i), headers...)
+	}
+	request(0, "10.0.1.1", // a customer using Firefox
+		waflog.Header{Name: "user-agent", Value: firefox},
+		waflog.Header{Name: "accept", Value: "text/html"},
+		waflog.Header{Name: "accept-language", Value: "de"},
+		waflog.Header{Name: "accept-encoding", Value: "gzip, deflate, br"},
+	)
+	for i := 0; i < 4; i++ { // a scraper copying the User-Agent of Chrome only
+		request(i, "6.6.6.6",
+			waflog.Header{Name: "User-Agent", Value: chrome},
+			waflog.Header{Name: "Accept-Encoding", Value: "identity"},
+			waflog.Header{Name: "Accept", Value: "*/*"},
+		)
+	}
+	request(0, "7.7.7.7", // a scraper with the sec-ch-ua of an older Chrome
+		waflog.Header{Name: "sec-ch-ua", Value: `"Chromium";v="99"`},
+		waflog.Header{Name: "user-agent", Value: chrome},
+		waflog.Header{Name: "accept", Value: "text/html"},
+		waflog.Header{Name: "accept-encoding", Value: "gzip"},
+		waflog.Header{Name: "accept-language", Value: "en"},
+	)
+	request(0, "10.0.0.9", waflog.Header{Name: "user-agent", Value: "curl/8.4.0"}) // no browser claimed
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewHeaderFingerprintReportLoader(b, query.WafBC, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var fingerprints []string
+	for _, row := range readResult(t, "header-fingerprint-report", "header-fingerprints") {
+		fingerprints = append(fingerprints, fmt.Sprint(row[:6]))
+	}
+	want := "[[browser header_order anomalies num_requests num_ips num_user_agents] " +
+		"[chrome sec-ch-ua,user-agent,accept,accept-encoding,accept-language  3 3 1] " +
+		"[chrome user-agent,accept-encoding,accept no_accept_language,no_gzip,no_sec_ch_ua 4 1 1] " +
+		"[chrome sec-ch-ua,user-agent,accept,accept-encoding,accept-language sec_ch_ua_mismatch 1 1 1] " +
+		"[firefox user-agent,accept,accept-language,accept-encoding  1 1 1]]"
+	if fmt.Sprint(fingerprints) != want {
+		t.Errorf("header fingerprints:\n got  %s\n want %s", fingerprints, want)
+	}
+
+	var anomalies []string
+	for _, row := range readResult(t, "header-fingerprint-report", "header-anomalies") {
+		anomalies = append(anomalies, fmt.Sprint(append(row[:1:1], row[3:9]...)))
+	}
+	want = "[[client_ip browser num_requests num_missed num_blocked num_header_orders anomalies] " +
+		"[6.6.6.6 chrome 4 4 0 1 no_accept_language,no_gzip,no_sec_ch_ua] " +
+		"[7.7.7.7 chrome 1 1 0 1 sec_ch_ua_mismatch]]"
+	if fmt.Sprint(anomalies) != want {
+		t.Errorf("header anomalies:\n got  %s\n want %s", anomalies, want)
+	}
+}
+
+func TestHeaderFingerprintReportEdgeCases(t *testing.T) {
+	at := testDay.Add(10 * time.Hour)
+	edge := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0"
+	safari := "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15"
+	opera := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0"
+	samsung := "Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36"
+	yandex := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 YaBrowser/23.11.0.0 Safari/537.36"
+
+	// browser returns a request with the headers of a browser, without
+	// sec-ch-ua if secCHUA is empty
+	browser := func(ip string, action string, ua string, secCHUA string, labels ...string) []*waflog.Record {
+		r := testRecord(at, ip, "", "Default_Action", action, labels...)
+		r.HTTPRequest.Headers = []waflog.Header{
+			{Name: "user-agent", Value: ua},
+			{Name: "accept", Value: "text/html"},
+			{Name: "accept-encoding", Value: "gzip, deflate, br"},
+			{Name: "accept-language", Value: "de"},
+		}
+		if secCHUA != "" {
+			r.HTTPRequest.Headers = append([]waflog.Header{{Name: "sec-ch-ua", Value: secCHUA}}, r.HTTPRequest.Headers...)
+		}
+		return []*waflog.Record{r}
+	}
+
+	fingerprints := []string{"browser", "anomalies", "num_requests", "num_missed"}
+	anomalies := []string{"client_ip", "browser", "num_missed", "num_blocked", "anomalies"}
+	runReportCases(t, "header-fingerprint-report", false, func(b Backend) error {
+		return NewHeaderFingerprintReportLoader(b, query.WafBC, testDay).Run()
+	}, []reportCase{
+		{
+			name:    "Edge claiming Chrome with the version of its Chromium",
+			records: browser("2001:db8::4", "ALLOW", edge, `"Microsoft Edge";v="120", "Chromium";v="120"`),
+			result:  "header-fingerprints",
+			columns: fingerprints,
+			want:    [][]string{{"edge", "", "1", "1"}},
+		},
+		{
+			name:    "Opera claiming Chrome with the version of its Chromium",
+			records: browser("6.6.6.6", "ALLOW", opera, `"Opera";v="105", "Chromium";v="119"`),
+			result:  "header-fingerprints",
+			columns: fingerprints,
+			want:    [][]string{{"opera", "", "1", "1"}},
+		},
+		{
+			name:    "Yandex without sec-ch-ua",
+			records: browser("8.8.8.8", "ALLOW", yandex, ""),
+			result:  "header-fingerprints",
+			columns: fingerprints,
+			want:    [][]string{{"yandex", "no_sec_ch_ua", "1", "1"}},
+		},
+		{
+			name:    "Samsung Internet with the sec-ch-ua of another Chromium",
+			records: browser("7.7.7.7", "ALLOW", samsung, `"Chromium";v="120"`),
+			result:  "header-anomalies",
+			columns: anomalies,
+			want:    [][]string{{"7.7.7.7", "samsung", "1", "0", "sec_ch_ua_mismatch"}},
+		},
+		{
+			name:    "Safari with sec-ch-ua caught by Bot Control",
+			records: browser("5.5.5.5", "BLOCK", safari, `"Chromium";v="120"`, "awswaf:managed:aws:bot-control:signal:non_browser_user_agent"),
+			result:  "header-anomalies",
+			columns: anomalies,
+			want:    [][]string{{"5.5.5.5", "safari", "0", "1", "unexpected_sec_ch_ua"}},
+		},
+	})
+}
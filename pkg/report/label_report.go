@@ -0,0 +1,175 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&labelReport{days: 7})
+}
+
+// labelReport defines the report on labels added by managed and custom rules
+type labelReport struct {
+	labels query.LabelFilter // only requests carrying one of the labels, all if empty
+	days   int               // number of days of the trend
+}
+
+func (*labelReport) Name() string      { return "labels" }
+func (*labelReport) Aliases() []string { return nil }
+
+func (*labelReport) Description() string {
+	return "counts, prefixes, co-occurrence and trends of the labels added to requests"
+}
+
+func (r *labelReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "days",
+			Value:       7,
+			Usage:       "number of days up to the timestamp to show label trends for",
+			Destination: &r.days,
+		},
+		labelFlag(&r.labels),
+	}
+}
+
+func (r *labelReport) NewLoader(b Backend, o Options) Loader {
+	return NewLabelReportLoader(b, o.Waf, r.labels, r.days, o.Day)
+}
+
+func (*labelReport) NewPrinter(o Options) Printer { return nil }
+
+// LabelReportLoader loads counts, hierarchy, co-occurrence and trends of the
+// labels added to requests by managed and custom rules
+type LabelReportLoader struct {
+	*ReportLoader
+	labels query.LabelFilter
+	days   int // number of days of the trend
+}
+
+func NewLabelReportLoader(b Backend, waf query.WAF, labels query.LabelFilter, days int, t time.Time) *LabelReportLoader {
+	year, month, day := getDay(t)
+
+	out := &LabelReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "label-report", year, month, day),
+		labels:       labels,
+		days:         days,
+	}
+	out.Params = map[string]string{
+		"labels": strings.Join(labels, ","),
+		"days":   strconv.Itoa(days),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *LabelReportLoader) load() error {
+	if err := r.LoadLabelCounts(); err != nil {
+		return fmt.Errorf("loading requests per label: %s", err)
+	}
+
+	if err := r.LoadLabelPrefixCounts(); err != nil {
+		return fmt.Errorf("loading requests per label prefix: %s", err)
+	}
+
+	if err := r.LoadLabelCooccurrence(); err != nil {
+		return fmt.Errorf("loading label co-occurrence: %s", err)
+	}
+
+	if err := r.LoadLabelTrends(); err != nil {
+		return fmt.Errorf("loading label trends: %s", err)
+	}
+
+	return nil
+}
+
+func (r *LabelReportLoader) LoadLabelCounts() error {
+	r.printf("\n[+] Loading requests per label...\n")
+
+	sql, err := query.GetLabelCounts(
+		r.Scope,
+		r.labels,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("label-counts", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *LabelReportLoader) LoadLabelPrefixCounts() error {
+	r.printf("\n[+] Loading requests per label prefix...\n")
+
+	sql, err := query.GetLabelPrefixCounts(
+		r.Scope,
+		r.labels,
+		5000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("label-prefix-counts", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *LabelReportLoader) LoadLabelCooccurrence() error {
+	r.printf("\n[+] Loading label co-occurrence...\n")
+
+	sql, err := query.GetLabelCooccurrence(
+		r.Scope,
+		r.labels,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("label-cooccurrence", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *LabelReportLoader) LoadLabelTrends() error {
+	r.printf("\n[+] Loading label trends of the last %d days...\n", r.days)
+
+	sql, err := query.GetLabelTrends(
+		r.Scope,
+		r.days,
+		r.labels,
+		100,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	step := r.rawTableStep("label-trends", sql)
+	step.Partitions = nil
+	for _, s := range r.Scope.LastDays(r.days) {
+		step.Partitions = append(step.Partitions, s.Partition())
+	}
+
+	if err := r.RunQuery(step); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&managedRuleReport{topPerRule: 20, samples: 5})
+}
+
+// managedRuleReport defines the report on the rules of managed rule groups
+type managedRuleReport struct {
+	topPerRule int // number of URIs, arguments and IPs per rule
+	samples    int // number of sample requests per rule
+}
+
+func (*managedRuleReport) Name() string      { return "managed-rules" }
+func (*managedRuleReport) Aliases() []string { return []string{"m"} }
+
+func (*managedRuleReport) Description() string {
+	return "blocks and COUNT matches per rule of managed rule groups with top URIs, arguments, IPs and samples, ranked by likely false positives"
+}
+
+func (r *managedRuleReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "top-per-rule",
+			Value:       20,
+			Usage:       "number of URIs, arguments and IPs to load per rule",
+			Destination: &r.topPerRule,
+		},
+		&cli.IntFlag{
+			Name:        "samples",
+			Value:       5,
+			Usage:       "number of sample requests to load per rule",
+			Destination: &r.samples,
+		},
+	}
+}
+
+func (r *managedRuleReport) NewLoader(b Backend, o Options) Loader {
+	return NewManagedRuleReportLoader(b, o.Waf, r.topPerRule, r.samples, o.Day)
+}
+
+func (*managedRuleReport) NewPrinter(o Options) Printer {
+	return printer.NewManagedRuleReportPrinter(o.Data, o.Waf)
+}
+
+// ManagedRuleReportLoader loads the requests blocked or counted by the rules
+// of AWS managed rule groups, to triage false positives before excluding
+// rules or switching them to COUNT
+type ManagedRuleReportLoader struct {
+	*ReportLoader
+	topPerRule int
+	samples    int
+}
+
+func NewManagedRuleReportLoader(b Backend, waf query.WAF, topPerRule int, samples int, t time.Time) *ManagedRuleReportLoader {
+	year, month, day := getDay(t)
+
+	out := &ManagedRuleReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "managed-rule-report", year, month, day),
+		topPerRule:   topPerRule,
+		samples:      samples,
+	}
+	out.Params = map[string]string{
+		"top_per_rule": strconv.Itoa(topPerRule),
+		"samples":      strconv.Itoa(samples),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *ManagedRuleReportLoader) load() error {
+	if err := r.LoadManagedRuleMatches(); err != nil {
+		return fmt.Errorf("loading matches per managed rule: %s", err)
+	}
+
+	if err := r.LoadTopURIsPerManagedRule(); err != nil {
+		return fmt.Errorf("loading top URIs per managed rule: %s", err)
+	}
+
+	if err := r.LoadTopArgsPerManagedRule(); err != nil {
+		return fmt.Errorf("loading top arguments per managed rule: %s", err)
+	}
+
+	if err := r.LoadTopIPsPerManagedRule(); err != nil {
+		return fmt.Errorf("loading top IPs per managed rule: %s", err)
+	}
+
+	if err := r.LoadManagedRuleSamples(); err != nil {
+		return fmt.Errorf("loading sample requests per managed rule: %s", err)
+	}
+
+	return nil
+}
+
+func (r *ManagedRuleReportLoader) LoadManagedRuleMatches() error {
+	r.printf("\n[+] Loading requests matched per managed rule...\n")
+
+	sql, err := query.GetManagedRuleMatches(
+		r.Scope,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("managed-rule-matches", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *ManagedRuleReportLoader) LoadTopURIsPerManagedRule() error {
+	r.printf("\n[+] Loading top URIs per managed rule...\n")
+
+	sql, err := query.GetManagedRuleTopURIs(
+		r.Scope,
+		r.topPerRule,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("managed-rule-top-uris", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *ManagedRuleReportLoader) LoadTopArgsPerManagedRule() error {
+	r.printf("\n[+] Loading top arguments per managed rule...\n")
+
+	sql, err := query.GetManagedRuleTopArgs(
+		r.Scope,
+		r.topPerRule,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("managed-rule-top-args", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *ManagedRuleReportLoader) LoadTopIPsPerManagedRule() error {
+	r.printf("\n[+] Loading top IPs per managed rule...\n")
+
+	sql, err := query.GetManagedRuleTopIdentities(
+		r.Scope,
+		query.IdentityColumnsIP,
+		r.topPerRule,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("managed-rule-top-ips", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *ManagedRuleReportLoader) LoadManagedRuleSamples() error {
+	r.printf("\n[+] Loading sample requests per managed rule...\n")
+
+	sql, err := query.GetManagedRuleSamples(
+		r.Scope,
+		r.samples,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("managed-rule-samples", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
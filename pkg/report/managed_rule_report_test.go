@@ -0,0 +1,113 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManagedRuleReport(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	var records []*waflog.Record
+	for i := 0; i < 20; i++ { // large forms of logged in users
+		r := testRecord(at, fmt.Sprintf("10.0.0.%d", i), "Mozilla/5.0", "Default_Action", "ALLOW")
+		r.HTTPRequest.URI = "/checkout"
+		r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "cookie", Value: "session=abc"})
+		r.RuleGroupList = []waflog.RuleGroup{{
+			RuleGroupID:                 "AWS#AWSManagedRulesCommonRuleSet",
+			NonTerminatingMatchingRules: []waflog.RuleMatch{{RuleID: "SizeRestrictions_BODY", Action: "COUNT"}},
+		}}
+		records = append(records, r)
+	}
+	for i := 0; i < 10; i++ { // a scanner probing for SQL injection
+		r := testRecord(at, "6.6.6.6", "sqlmap/1.7", "AWS-AWSManagedRulesSQLiRuleSet", "BLOCK")
+		r.HTTPRequest.URI = "/search"
+		r.HTTPRequest.Args = fmt.Sprintf("q=%d OR 1=1", i)
+		r.RuleGroupList = []waflog.RuleGroup{{
+			RuleGroupID:     "AWS#AWSManagedRulesSQLiRuleSet",
+			TerminatingRule: &waflog.RuleMatch{RuleID: "SQLi_QUERYARGUMENTS", Action: "BLOCK"},
+		}}
+		r.TerminatingRuleMatchDetails = []waflog.MatchDetail{{ConditionType: "SQL_INJECTION", Location: "QUERY_STRING", MatchedData: []string{"OR", "1=1"}}}
+		records = append(records, r)
+	}
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewManagedRuleReportLoader(b, query.WafBC, 20, 2, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	for _, row := range readResult(t, "managed-rule-report", "managed-rule-matches") {
+		got = append(got, []string{row[1], row[2], row[3], row[4], row[11]})
+	}
+	want := "[[rule match num_requests num_ips false_positive_score] [SizeRestrictions_BODY COUNT 20 20 1.0] [SQLi_QUERYARGUMENTS BLOCK 10 1 0.033]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("matches per rule:\n got  %s\n want %s", got, want)
+	}
+
+	if ips := readResult(t, "managed-rule-report", "managed-rule-top-ips"); fmt.Sprint(ips[len(ips)-1]) != "[AWS#AWSManagedRulesSQLiRuleSet SQLi_QUERYARGUMENTS BLOCK 6.6.6.6 DE 10]" {
+		t.Errorf("top IPs per rule: %s", ips)
+	}
+
+	samples := readResult(t, "managed-rule-report", "managed-rule-samples")
+	if len(samples) != 5 || samples[3][10] != "SQL_INJECTION in QUERY_STRING: OR 1=1" || samples[1][10] != "" {
+		t.Errorf("samples per rule: %s", samples)
+	}
+
+	if err := printer.NewManagedRuleReportPrinter(data.NewDir(data.DefaultRoot), query.WafBC).Print(); err != nil {
+		t.Errorf("printing report: %s", err)
+	}
+}
+
+func TestManagedRuleReportRuleGroupARN(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	// own rule groups are logged with their ARN, which contains slashes
+	arn := "arn:aws:wafv2:eu-central-1:123456789012:regional/rulegroup/shop-rules/1a2b3c"
+
+	var records []*waflog.Record
+	for i := 0; i < 3; i++ {
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "6.6.6.6", "sqlmap/1.7", "shop-rules", "BLOCK")
+		r.RuleGroupList = []waflog.RuleGroup{{
+			RuleGroupID:                 arn,
+			TerminatingRule:             &waflog.RuleMatch{RuleID: "block-sqli", Action: "BLOCK"},
+			NonTerminatingMatchingRules: []waflog.RuleMatch{{RuleID: "count-scanners", Action: "COUNT"}},
+		}}
+		r.TerminatingRuleMatchDetails = []waflog.MatchDetail{{ConditionType: "SQL_INJECTION", Location: "BODY", MatchedData: []string{"UNION"}}}
+		records = append(records, r)
+	}
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewManagedRuleReportLoader(b, query.WafBC, 20, 1, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	for _, row := range readResult(t, "managed-rule-report", "managed-rule-matches") {
+		got = append(got, row[:4])
+	}
+	want := fmt.Sprintf("[[rule_group rule match num_requests] [%s block-sqli BLOCK 3] [%s count-scanners COUNT 3]]", arn, arn)
+	if fmt.Sprint(got) != want {
+		t.Errorf("matches per rule:\n got  %s\n want %s", got, want)
+	}
+
+	// only the rule terminating the request matched the details logged
+	got = nil
+	for _, row := range readResult(t, "managed-rule-report", "managed-rule-samples") {
+		got = append(got, []string{row[0], row[1], row[10]})
+	}
+	want = fmt.Sprintf("[[rule_group rule match_details] [%s block-sqli SQL_INJECTION in BODY: UNION] [%s count-scanners ]]", arn, arn)
+	if fmt.Sprint(got) != want {
+		t.Errorf("samples per rule:\n got  %s\n want %s", got, want)
+	}
+}
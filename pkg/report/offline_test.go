@@ -0,0 +1,628 @@
+package report
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/gen"
+	"kfzteile24/waflogs/pkg/local"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testDay = time.Date(2023, 2, 21, 0, 0, 0, 0, time.UTC)
+
+func testRecord(at time.Time, ip string, ua string, rule string, action string, labels ...string) *waflog.Record {
+	r := &waflog.Record{
+		Timestamp:         at.UnixMilli(),
+		TerminatingRuleID: rule,
+		Action:            action,
+		HTTPRequest: waflog.HTTPRequest{
+			ClientIP: ip,
+			Country:  "DE",
+			URI:      "/",
+			Headers:  []waflog.Header{{Name: "user-agent", Value: ua}},
+		},
+	}
+	for _, l := range labels {
+		r.Labels = append(r.Labels, waflog.Label{Name: l})
+	}
+	return r
+}
+
+// writeLogs writes records as a gzip Firehose object below dir/waf/
+func writeLogs(t *testing.T, dir string, waf query.WAF, records []*waflog.Record) {
+	t.Helper()
+
+	path := filepath.Join(dir, waf.String(), "2023", "02", "21", "10", "aws-waf-logs-test-1.gz")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz.Write(b) // concatenated like Firehose does
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chdir changes to a temporary directory for the data directory of the test
+func chdir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return dir
+}
+
+// testBackends returns the backends computing reports from the raw logs in
+// dir, the local one only in builds with DuckDB
+func testBackends(t *testing.T, dir string) map[string]Backend {
+	t.Helper()
+
+	out := map[string]Backend{
+		"offline": NewOfflineBackend(offline.NewSource(dir)),
+	}
+
+	engine, err := local.NewEngine(context.Background(), dir)
+	switch {
+	case errors.Is(err, local.ErrNoDuckDB):
+		return out
+	case err != nil:
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine.Close() })
+
+	out["local"] = NewSQLBackend(engine)
+	return out
+}
+
+// localBackend returns the backend running the queries of the reports on the
+// raw logs in dir with DuckDB, and skips the test in builds without it
+func localBackend(t *testing.T, dir string) Backend {
+	t.Helper()
+
+	b, ok := testBackends(t, dir)["local"]
+	if !ok {
+		t.Skipf("%s, run make test to test both builds", local.ErrNoDuckDB)
+	}
+	return b
+}
+
+// skipWithoutDuckDB skips tests of features needing DuckDB, like Parquet
+// results, in builds without it
+func skipWithoutDuckDB(t *testing.T) {
+	t.Helper()
+	localBackend(t, t.TempDir())
+}
+
+func readResult(t *testing.T, report string, name string) [][]string {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join(data.DefaultRoot, "BC", report, "2023-02-21", name+".csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// resultColumns returns the header and the rows of the result of the report
+// with the columns called names, in that order
+func resultColumns(t *testing.T, report string, name string, names ...string) [][]string {
+	t.Helper()
+
+	records := readResult(t, report, name)
+	var idx []int
+	for _, n := range names {
+		i := 0
+		for i < len(records[0]) && records[0][i] != n {
+			i++
+		}
+		if i == len(records[0]) {
+			t.Fatalf("%s has no column %s: %v", name, n, records[0])
+		}
+		idx = append(idx, i)
+	}
+
+	var out [][]string
+	for _, record := range records {
+		row := make([]string, len(idx))
+		for j, i := range idx {
+			row[j] = record[i]
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+// reportCase is a scenario of a report: the records of the logs and the rows
+// a result of the report has for them, of the columns asserted
+type reportCase struct {
+	name    string
+	records []*waflog.Record
+	result  string
+	columns []string
+	want    [][]string
+}
+
+// runReportCases runs the report on the records of each case with the local
+// backend, and with the offline backend too if it implements the report, and
+// compares the columns of the result of the case
+func runReportCases(t *testing.T, report string, offline bool, run func(b Backend) error, cases []reportCase) {
+	t.Helper()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := chdir(t)
+			writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, c.records)
+
+			backends := testBackends(t, filepath.Join(dir, "logs"))
+			if !offline {
+				delete(backends, "offline")
+			}
+			if len(backends) == 0 {
+				t.Skipf("%s, run make test to test both builds", local.ErrNoDuckDB)
+			}
+
+			for name, b := range backends {
+				if err := run(b); err != nil {
+					t.Fatalf("%s: running report: %s", name, err)
+				}
+				if got := resultColumns(t, report, c.result, c.columns...)[1:]; fmt.Sprint(got) != fmt.Sprint(c.want) {
+					t.Errorf("%s: %s %v:\n got  %v\n want %v", name, c.result, c.columns, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitReportOffline(t *testing.T) {
+	dir := chdir(t)
+
+	start := testDay.Add(10 * time.Hour)
+	var records []*waflog.Record
+	for i := 0; i < 450; i++ { // flood within one 5 minute window
+		rule, action := "Default_Action", "ALLOW"
+		if i >= 400 {
+			rule, action = "rate-limit", "BLOCK"
+		}
+		records = append(records, testRecord(start.Add(time.Duration(i)*100*time.Millisecond), "1.2.3.4", "Mozilla/5.0", rule, action))
+	}
+	for i := 0; i < 60; i++ {
+		records = append(records, testRecord(start.Add(time.Duration(i)*time.Second), "5.6.7.8", "python-requests/2.28", "Default_Action", "ALLOW",
+			"awswaf:managed:aws:bot-control:signal:non_browser_user_agent",
+			"awswaf:managed:aws:bot-control:bot:name:python_requests",
+			"awswaf:managed:aws:bot-control:bot:category:http_library",
+		))
+		records = append(records, testRecord(start.Add(time.Duration(i)*time.Second), "9.9.9.9", "ios-de-1.0.0", "Default_Action", "ALLOW",
+			"awswaf:managed:aws:bot-control:signal:non_browser_user_agent",
+		))
+	}
+	records = append(records, testRecord(start.Add(25*time.Hour), "1.2.3.4", "Mozilla/5.0", "rate-limit", "BLOCK")) // next day
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	tests := []struct {
+		name string
+		want [][]string
+	}{
+		{
+			name: "ips-blocked-by-rate-limit",
+			want: [][]string{
+				{"client_ip", "country", "terminating_rule", "num_requests"},
+				{"1.2.3.4", "DE", "rate-limit", "50"},
+			},
+		},
+		{
+			name: "fastest-ips-not-black-or-whitelisted",
+			want: [][]string{
+				{"client_ip", "country", "time_window", "num_requests"},
+				{"1.2.3.4", "DE", "2023-02-21 10:00:00.000 UTC", "450"},
+			},
+		},
+		{
+			name: "fastest-bot-user-agents-not-black-or-whitelisted",
+			want: [][]string{
+				{"bot_name", "bot_category", "user_agent", "time_window", "num_requests"},
+				{"python_requests", "http_library", "python-requests/2.28", "2023-02-21 10:00:00.000 UTC", "60"},
+			},
+		},
+	}
+
+	for name, b := range testBackends(t, filepath.Join(dir, "logs")) {
+		t.Run(name, func(t *testing.T) {
+			r := NewRateLimitReportLoader(b, query.WafBC, query.DefaultWindow, nil, testDay)
+			if err := r.Run(); err != nil {
+				t.Fatalf("running report: %s", err)
+			}
+
+			for _, tc := range tests {
+				got := readResult(t, "rate-limit-report", tc.name)
+				if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+					t.Errorf("%s:\n got: %v\nwant: %v", tc.name, got, tc.want)
+				}
+			}
+
+			m, err := data.ReadManifest(filepath.Join(data.DefaultRoot, "BC", "rate-limit-report", "2023-02-21"))
+			if err != nil {
+				t.Fatalf("reading manifest: %s", err)
+			}
+			if !m.Complete() || m.Backend != name || m.Params["window"] != "5m" || len(m.Steps) != 4 || m.Rows() != 4 {
+				t.Errorf("unexpected manifest %+v", m)
+			}
+		})
+	}
+}
+
+// TestRateLimitReportSliding checks that the top lists of sliding windows
+// have the peak window of each IP only, not its overlapping windows
+func TestRateLimitReportSliding(t *testing.T) {
+	dir := chdir(t)
+	start := testDay.Add(10 * time.Hour)
+
+	var records []*waflog.Record
+	perMinute := func(ip string, minute int, n int) {
+		for i := 0; i < n; i++ {
+			at := start.Add(time.Duration(minute)*time.Minute + time.Duration(i)*time.Minute/time.Duration(n))
+			records = append(records, testRecord(at, ip, "Mozilla/5.0", "Default_Action", "ALLOW"))
+		}
+	}
+	for m := 0; m < 5; m++ {
+		perMinute("1.2.3.4", m, 100)
+	}
+	perMinute("1.2.3.4", 5, 300)
+	for m := 0; m < 3; m++ {
+		perMinute("5.6.7.8", m, 100)
+	}
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	want := [][]string{
+		{"client_ip", "country", "time_window", "num_requests"},
+		{"1.2.3.4", "DE", "2023-02-21 10:04:00.000 UTC", "400"},
+		{"5.6.7.8", "DE", "2023-02-21 10:00:00.000 UTC", "200"},
+	}
+	for name, b := range testBackends(t, filepath.Join(dir, "logs")) {
+		t.Run(name, func(t *testing.T) {
+			if err := NewRateLimitReportLoader(b, query.WafBC, query.Window{Minutes: 2, Sliding: true}, nil, testDay).Run(); err != nil {
+				t.Fatalf("running report: %s", err)
+			}
+
+			if got := readResult(t, "rate-limit-report", "fastest-ips-not-black-or-whitelisted"); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("fastest ips:\n got: %v\nwant: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPrintMigratedRuns(t *testing.T) {
+	dir := chdir(t)
+	d := data.NewDir(data.DefaultRoot)
+
+	// runs of layout version 1, without manifests
+	for day, rows := range map[int]string{20: "1.2.3.4,300\n", 21: "1.2.3.4,500\n5.6.7.8,100\n", 22: "1.2.3.4,200\n"} {
+		run := d.RunDir("rate-limit-report", query.Scope{Waf: query.WafBC, Year: 2023, Month: 2, Day: day})
+		if err := os.MkdirAll(run, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(run, "fastest-bot-user-agents-not-black-or-whitelisted.csv"), []byte("client_ip,num_requests\n"+rows), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	// a result removed after the migration
+	if err := os.Remove(filepath.Join(dir, data.DefaultRoot, "BC", "rate-limit-report", "2023-02-22", "fastest-bot-user-agents-not-black-or-whitelisted.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	err = printer.NewRateLimitReportPrinter(d, query.WafBC).Print()
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatalf("printing migrated runs: %s", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"[+] Reading the migrated run of 2023-02-20",
+		"2023-02-20: 300\n",
+		"2023-02-21: 500\n",
+		"[+] Skipping the migrated run of 2023-02-22",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output misses %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSkipExisting(t *testing.T) {
+	skipWithoutDuckDB(t)
+	dir := chdir(t)
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, []*waflog.Record{
+		testRecord(testDay.Add(10*time.Hour), "1.2.3.4", "Mozilla/5.0", "rate-limit", "BLOCK"),
+	})
+	b := testBackends(t, filepath.Join(dir, "logs"))["offline"]
+
+	run := func(f Format) *data.Manifest {
+		t.Helper()
+		r := NewRateLimitReportLoader(b, query.WafBC, query.DefaultWindow, nil, testDay)
+		r.SetFormat(f)
+		r.SetSkipExisting(true)
+		if err := r.Run(); err != nil {
+			t.Fatalf("running report: %s", err)
+		}
+		m, err := data.ReadManifest(filepath.Join(data.DefaultRoot, "BC", "rate-limit-report", "2023-02-21"))
+		if err != nil {
+			t.Fatalf("reading manifest: %s", err)
+		}
+		return m
+	}
+
+	if m := run(FormatParquet); m.Steps[0].Reused {
+		t.Errorf("reused results of the first run: %+v", m.Steps[0])
+	}
+
+	// the Parquet results are kept, though their CSV was removed
+	m := run(FormatParquet)
+	if !m.Steps[0].Reused || fmt.Sprint(m.Steps[0].Files) != "[ips-blocked-by-rate-limit.sql ips-blocked-by-rate-limit.parquet]" {
+		t.Errorf("Parquet results not kept: %+v", m.Steps[0])
+	}
+
+	// the CSV results are missing
+	if m := run(FormatCSV); m.Steps[0].Reused || m.Steps[0].Rows != 1 {
+		t.Errorf("missing CSV results kept: %+v", m.Steps[0])
+	}
+}
+
+func TestInferTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.csv")
+	if err := os.WriteFile(path, []byte("client_ip,ports,labels,num_requests\n1.2.3.4,\"[80, 443]\",\"[a, b]\",3\n5.6.7.8,[],,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Athena reports arrays without the type of their elements
+	cols, err := inferTypes(path, []query.Column{{Name: "client_ip", Type: "varchar"}, {Name: "ports", Type: "array"}, {Name: "labels", Type: "array"}, {Name: "num_requests", Type: "bigint"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(cols) != "[{client_ip varchar} {ports array(bigint)} {labels array(varchar)} {num_requests bigint}]" {
+		t.Errorf("columns with arrays: %v", cols)
+	}
+
+	cols, err = inferTypes(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(cols) != "[{client_ip varchar} {ports array(bigint)} {labels array(varchar)} {num_requests bigint}]" {
+		t.Errorf("columns without types: %v", cols)
+	}
+}
+
+func TestAPC1ReportOffline(t *testing.T) {
+	dir := chdir(t)
+
+	start := testDay.Add(8 * time.Hour)
+	var records []*waflog.Record
+	for i := 0; i < 5100; i++ {
+		// one session rotating through 10 user agents first, then sticking to one of them
+		ua := 0
+		if i < 100 {
+			ua = i % 10
+		}
+		r := testRecord(start.Add(time.Duration(i)*time.Second), "1.2.3.4", fmt.Sprintf("Mozilla/5.0 (%d)", ua), "Default_Action", "ALLOW")
+		r.HTTPRequest.URI = "/artikeldetails"
+		r.HTTPRequest.Args = fmt.Sprintf("search=%d", i%3)
+		r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "cookie", Value: "session=scraper"})
+		records = append(records, r)
+	}
+	customer := testRecord(start, "5.6.7.8", "Mozilla/5.0 (0)", "Default_Action", "ALLOW")
+	customer.HTTPRequest.Headers = append(customer.HTTPRequest.Headers, waflog.Header{Name: "cookie", Value: "session=customer"})
+	records = append(records, customer)
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	for name, b := range testBackends(t, filepath.Join(dir, "logs")) {
+		t.Run(name, func(t *testing.T) {
+			r := NewAPC1ReportLoader(b, query.WafBC, testDay)
+			if err := r.Run(); err != nil {
+				t.Fatalf("running report: %s", err)
+			}
+
+			urls := readResult(t, "apc1", "scraped-urls")
+			if len(urls) != 2 || fmt.Sprint(urls[1][:6]) != "[/artikeldetails& 5100 [2023/02/21] 10 1 1]" {
+				t.Errorf("scraped-urls: unexpected result %v", urls)
+			}
+
+			products := readResult(t, "apc1", "scraped-products")
+			if fmt.Sprint(products) != "[[product num_requests user_agents] [0 1670 1] [1 1670 1] [2 1670 1]]" {
+				t.Errorf("scraped-products: unexpected result %v", products)
+			}
+
+			// user agents with less than 5000 requests of scrapers are ignored, the
+			// customer sharing the user agent isn't counted as scraped
+			uas := readResult(t, "apc1", "scraper-user-agents")
+			if fmt.Sprint(uas[1:]) != "[[Mozilla/5.0 (0) 5011 5010 1 1]]" {
+				t.Errorf("scraper-user-agents: unexpected result %v", uas)
+			}
+		})
+	}
+}
+
+// TestBackendsAgree runs the reports with Go implementations of their steps on
+// generated logs, and compares the results of the offline and local backends
+func TestBackendsAgree(t *testing.T) {
+	skipWithoutDuckDB(t)
+	dir := chdir(t)
+
+	cfg := gen.DefaultConfig(testDay)
+	cfg.WAFs = []query.WAF{query.WafBC}
+	cfg.End = testDay.Add(3 * time.Hour)
+	cfg.ScraperRequests = 9000
+	wafs, err := gen.Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Write(filepath.Join(dir, "logs"), query.WafBC, wafs[query.WafBC]); err != nil {
+		t.Fatal(err)
+	}
+
+	reports := []struct {
+		name  string
+		steps []string
+		run   func(b Backend) error
+	}{
+		{
+			name:  "rate-limit-report",
+			steps: []string{"ips-blocked-by-rate-limit", "user-agents-blocked-by-rate-limit", "fastest-ips-not-black-or-whitelisted", "fastest-bot-user-agents-not-black-or-whitelisted"},
+			run: func(b Backend) error {
+				return NewRateLimitReportLoader(b, query.WafBC, query.Window{Minutes: 2, Sliding: true}, nil, testDay).Run()
+			},
+		},
+		{
+			name:  "apc1",
+			steps: []string{"scraped-urls", "scraper-user-agents", "scraped-products"},
+			run: func(b Backend) error {
+				return NewAPC1ReportLoader(b, query.WafBC, testDay).Run()
+			},
+		},
+	}
+
+	results := map[string]map[string]string{}
+	for name, b := range testBackends(t, filepath.Join(dir, "logs")) {
+		results[name] = map[string]string{}
+		for _, r := range reports {
+			if err := r.run(b); err != nil {
+				t.Fatalf("%s: running %s: %s", name, r.name, err)
+			}
+			for _, step := range r.steps {
+				got := readResult(t, r.name, step)
+				if len(got) < 2 {
+					t.Errorf("%s: %s/%s is empty", name, r.name, step)
+				}
+				// rows with equal sort keys may come in any order
+				sort.Slice(got[1:], func(i, j int) bool {
+					return fmt.Sprint(got[1+i]) < fmt.Sprint(got[1+j])
+				})
+				results[name][r.name+"/"+step] = fmt.Sprint(got)
+			}
+		}
+	}
+
+	for step, want := range results["offline"] {
+		if got := results["local"][step]; got != want {
+			t.Errorf("%s differs:\n local:   %s\n offline: %s", step, got, want)
+		}
+	}
+}
+
+// TestCloudWatchExport imports generated logs exported from CloudWatch Logs
+// and compares the results of the rate limit report to those of the same
+// logs delivered by Firehose
+func TestCloudWatchExport(t *testing.T) {
+	dir := chdir(t)
+
+	cfg := gen.DefaultConfig(testDay)
+	cfg.WAFs = []query.WAF{query.WafBC}
+	cfg.End = testDay.Add(2 * time.Hour)
+	wafs, err := gen.Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Write(filepath.Join(dir, "firehose"), query.WafBC, wafs[query.WafBC]); err != nil {
+		t.Fatal(err)
+	}
+
+	export := filepath.Join(dir, "000000.gz")
+	f, err := os.Create(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	for _, r := range wafs[query.WafBC] {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(gz, "%s %s\n", r.Time().Add(time.Second).Format("2006-01-02T15:04:05.000Z"), b)
+	}
+	gz.Close()
+	f.Close()
+
+	var records []*waflog.Record
+	err = waflog.ReadCloudWatchExport(export, func(r *waflog.Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := offline.Write(filepath.Join(dir, "cloudwatch"), query.WafBC, "cloudwatch", records); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []string{"ips-blocked-by-rate-limit", "user-agents-blocked-by-rate-limit", "fastest-ips-not-black-or-whitelisted", "fastest-bot-user-agents-not-black-or-whitelisted"}
+	results := map[string]string{}
+	for _, src := range []string{"firehose", "cloudwatch"} {
+		b := NewOfflineBackend(offline.NewSource(filepath.Join(dir, src)))
+		if err := NewRateLimitReportLoader(b, query.WafBC, query.DefaultWindow, nil, testDay).Run(); err != nil {
+			t.Fatalf("%s: running report: %s", src, err)
+		}
+		for _, step := range steps {
+			results[src] += fmt.Sprint(readResult(t, "rate-limit-report", step))
+		}
+	}
+
+	if results["cloudwatch"] != results["firehose"] {
+		t.Errorf("results differ:\n cloudwatch: %s\n firehose:   %s", results["cloudwatch"], results["firehose"])
+	}
+	if len(results["firehose"]) < 200 {
+		t.Errorf("results are empty: %s", results["firehose"])
+	}
+}
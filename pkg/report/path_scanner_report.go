@@ -0,0 +1,191 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&pathScannerReport{probes: query.DefaultProbes, thresholds: query.DefaultScannerThresholds})
+}
+
+// pathScannerReport defines the report on vulnerability scanners probing and
+// enumerating paths
+type pathScannerReport struct {
+	probes     []query.Probe
+	thresholds query.ScannerThresholds
+}
+
+func (*pathScannerReport) Name() string      { return "path-scanners" }
+func (*pathScannerReport) Aliases() []string { return []string{"scanners"} }
+
+func (*pathScannerReport) Description() string {
+	return "IPs probing paths the shop never serves, like /wp-admin or /.env, or enumerating paths, classified as scanner, mixed or enumerator, and the probed paths allowed through"
+}
+
+func (r *pathScannerReport) Flags() []cli.Flag {
+	var probes []string
+	for _, p := range query.DefaultProbes {
+		probes = append(probes, p.String())
+	}
+
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "probe",
+			Value: cli.NewStringSlice(probes...),
+			Usage: "kind of probe as <name>=<regular expression of its URIs>, repeatable, replaces the default probes",
+			Action: func(ctx *cli.Context, v []string) error {
+				r.probes = nil
+				names := map[string]bool{}
+				for _, s := range v {
+					p, err := query.ParseProbe(s)
+					if err != nil {
+						return err
+					}
+					if names[p.Name] {
+						return fmt.Errorf("probe %s defined twice", p.Name)
+					}
+					names[p.Name] = true
+					r.probes = append(r.probes, p)
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:        "min-probe-uris",
+			Value:       query.DefaultScannerThresholds.MinProbeURIs,
+			Usage:       "number of distinct URIs matching a probe from which an IP probes",
+			Destination: &r.thresholds.MinProbeURIs,
+		},
+		&cli.Float64Flag{
+			Name:        "min-probe-ratio",
+			Value:       query.DefaultScannerThresholds.MinProbeRatio,
+			Usage:       "share of the requests of a probing IP matching a probe from which it is a scanner rather than mixed",
+			Destination: &r.thresholds.MinProbeRatio,
+			Action: func(ctx *cli.Context, v float64) error {
+				if v < 0 || v > 1 {
+					return fmt.Errorf("min probe ratio must be between 0 and 1, got %g", v)
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:        "min-uris",
+			Value:       query.DefaultScannerThresholds.MinURIs,
+			Usage:       "number of distinct URIs, each requested once or twice, from which an IP enumerates paths",
+			Destination: &r.thresholds.MinURIs,
+		},
+	}
+}
+
+func (r *pathScannerReport) NewLoader(b Backend, o Options) Loader {
+	return NewPathScannerReportLoader(b, o.Waf, r.probes, r.thresholds, o.Day)
+}
+
+func (*pathScannerReport) NewPrinter(o Options) Printer {
+	return printer.NewSummaryPrinter(o.Data, o.Waf, "path-scanner-report", []printer.Summary{
+		{
+			Result:  "path-scanners",
+			Title:   "IPs probing or enumerating paths, by distinct probed URIs",
+			Columns: []string{"client_ip", "country", "class", "num_requests", "num_uris", "num_probe_uris", "probe_ratio", "probes", "num_probes_allowed", "user_agent"},
+			OrderBy: "num_probe_uris",
+			Limit:   20,
+		},
+		{
+			Result:  "probed-paths",
+			Title:   "Probed paths allowed through",
+			OrderBy: "num_allowed",
+			Limit:   20,
+		},
+	})
+}
+
+// PathScannerReportLoader loads the IPs probing for vulnerabilities and
+// enumerating paths, to decide which of them to block
+type PathScannerReportLoader struct {
+	*ReportLoader
+	probes     []query.Probe
+	thresholds query.ScannerThresholds
+}
+
+func NewPathScannerReportLoader(b Backend, waf query.WAF, probes []query.Probe, thresholds query.ScannerThresholds, t time.Time) *PathScannerReportLoader {
+	year, month, day := getDay(t)
+
+	out := &PathScannerReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "path-scanner-report", year, month, day),
+		probes:       probes,
+		thresholds:   thresholds,
+	}
+
+	var names []string
+	for _, p := range probes {
+		names = append(names, p.Name)
+	}
+	out.Params = map[string]string{
+		"probes":          strings.Join(names, ","),
+		"min_probe_uris":  strconv.Itoa(thresholds.MinProbeURIs),
+		"min_probe_ratio": strconv.FormatFloat(thresholds.MinProbeRatio, 'g', -1, 64),
+		"min_uris":        strconv.Itoa(thresholds.MinURIs),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *PathScannerReportLoader) load() error {
+	if err := r.LoadPathScanners(); err != nil {
+		return fmt.Errorf("loading path scanners: %s", err)
+	}
+
+	if err := r.LoadProbedPaths(); err != nil {
+		return fmt.Errorf("loading probed paths: %s", err)
+	}
+
+	return nil
+}
+
+func (r *PathScannerReportLoader) LoadPathScanners() error {
+	r.printf("\n[+] Loading IPs probing %d kinds of paths or enumerating paths...\n", len(r.probes))
+
+	sql, err := query.GetPathScanners(
+		r.Scope,
+		r.probes,
+		r.thresholds,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("path-scanners", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+func (r *PathScannerReportLoader) LoadProbedPaths() error {
+	r.printf("\n[+] Loading probed paths...\n")
+
+	sql, err := query.GetProbedPaths(
+		r.Scope,
+		r.probes,
+		1000,
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	if err := r.RunQuery(r.rawTableStep("probed-paths", sql)); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
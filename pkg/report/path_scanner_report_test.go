@@ -0,0 +1,108 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPathScannerReport(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	var records []*waflog.Record
+	request := func(i int, ip string, ua string, uri string, action string) {
+		r := testRecord(at.Add(time.Duration(i)*time.Second), ip, ua, "Default_Action", action)
+		r.HTTPRequest.URI = uri
+		records = append(records, r)
+	}
+
+	// a scanner, blocked only once it asks for the secrets
+	for i, uri := range []string{"/wp-admin/", "/wp-login.php", "/.env", "/.git/config", "/"} {
+		action := "ALLOW"
+		if uri == "/.env" || uri == "/.git/config" {
+			action = "BLOCK"
+		}
+		request(i, "6.6.6.6", "Mozilla/5.0 zgrab/0.x", uri, action)
+	}
+
+	// a customer behind a NAT shared with an infected device
+	for i := 0; i < 10; i++ {
+		request(i, "10.0.0.1", "Mozilla/5.0", fmt.Sprintf("/ersatzteile-verschleissteile/bremsen/%d", i), "ALLOW")
+	}
+	for i, uri := range []string{"/xmlrpc.php", "/index.php", "/shell.php"} {
+		request(10+i, "10.0.0.1", "Mozilla/5.0", uri, "ALLOW")
+	}
+
+	// a crawler enumerating product pages
+	for i := 0; i < 6; i++ {
+		request(i, "7.7.7.7", "curl/8.0", fmt.Sprintf("/p/%d", i), "ALLOW")
+	}
+
+	// a customer reloading the same page
+	for i := 0; i < 6; i++ {
+		request(i, "10.0.0.2", "Mozilla/5.0", "/warenkorb", "ALLOW")
+	}
+
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	thresholds := query.ScannerThresholds{MinProbeURIs: 3, MinProbeRatio: 0.5, MinURIs: 5}
+	if err := NewPathScannerReportLoader(b, query.WafBC, query.DefaultProbes, thresholds, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var scanners []string
+	for _, row := range readResult(t, "path-scanner-report", "path-scanners") {
+		scanners = append(scanners, fmt.Sprint(row[:10]))
+	}
+	want := "[[client_ip country class num_requests num_uris num_probes num_probe_uris probe_ratio probes num_probes_allowed] [6.6.6.6 DE scanner 5 5 4 4 0.8 secrets,vcs,wordpress 2] [10.0.0.1 DE mixed 13 13 3 3 0.2308 php,wordpress 3] [7.7.7.7 DE enumerator 6 6 0 0 0.0  0]]"
+	if fmt.Sprint(scanners) != want {
+		t.Errorf("path scanners:\n got  %s\n want %s", scanners, want)
+	}
+
+	paths := readResult(t, "path-scanner-report", "probed-paths")
+	if len(paths) != 8 || fmt.Sprint(paths[len(paths)-1]) != "[vcs /.git/config 1 1 1 0]" {
+		t.Errorf("probed paths: %s", paths)
+	}
+}
+
+func TestPathScannerReportEdgeCases(t *testing.T) {
+	at := testDay.Add(10 * time.Hour)
+	requests := func(ip string, uris ...string) []*waflog.Record {
+		var records []*waflog.Record
+		for i, uri := range uris {
+			r := testRecord(at.Add(time.Duration(i)*time.Second), ip, "curl/8.0", "Default_Action", "ALLOW")
+			r.HTTPRequest.URI = uri
+			records = append(records, r)
+		}
+		return records
+	}
+	noHeaders := requests("2001:db8::1", "/WP-LOGIN.PHP", "/static/%2E%2E/%2e%2e/etc/passwd", "/dump.SQL")
+	for _, r := range noHeaders {
+		r.HTTPRequest.Headers = nil
+	}
+
+	thresholds := query.ScannerThresholds{MinProbeURIs: 3, MinProbeRatio: 0.5, MinURIs: 5}
+	runReportCases(t, "path-scanner-report", false, func(b Backend) error {
+		return NewPathScannerReportLoader(b, query.WafBC, query.DefaultProbes, thresholds, testDay).Run()
+	}, []reportCase{
+		{
+			name:    "probes in upper case and URL encoded from IPv6 without headers",
+			records: noHeaders,
+			result:  "path-scanners",
+			columns: []string{"client_ip", "class", "num_probe_uris", "probes", "num_user_agents", "user_agent"},
+			want:    [][]string{{"2001:db8::1", "scanner", "3", "backup,traversal,wordpress", "0", ""}},
+		},
+		{
+			name:    "URI matching several probes counts as the first",
+			records: requests("9.9.9.9", "/.env.bak", "/.git/HEAD", "/wp-content/x.php"),
+			result:  "path-scanners",
+			columns: []string{"client_ip", "class", "num_probe_uris", "probes"},
+			want:    [][]string{{"9.9.9.9", "scanner", "3", "secrets,vcs,wordpress"}},
+		},
+	})
+}
@@ -0,0 +1,65 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"kfzteile24/waflogs/pkg/aws"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"strings"
+)
+
+// Step is a single query executed by a report loader
+type Step struct {
+	Name      string   // name of the output files, e.g. "scraped-urls"
+	SQL       string   // rendered query
+	DependsOn []string // names of steps that have to run before
+	Reads     []string // tables read by the query
+	Creates   string   // table created by the query, empty if it only returns results
+
+	// Partitions lists the day partitions of the raw log table the query
+	// scans, empty if it only reads tables created by earlier steps
+	Partitions []string
+
+	// Local computes the result of the query from the raw log records of the
+	// day in offline mode, nil if the step can only run on Athena
+	Local func(records []*waflog.Record) (*offline.Table, error)
+}
+
+// Plan lists the steps of a report run in execution order
+type Plan struct {
+	Report string
+	Scope  query.Scope
+	Steps  []Step
+}
+
+// Print writes a human readable summary of the plan to w. The cost is
+// estimated from the number of raw log partitions scanned, assuming each of
+// them is partitionBytes large.
+func (p *Plan) Print(w io.Writer, partitionBytes int64) {
+	fmt.Fprintf(w, "\n[+] Plan for %s (waf = %s, day = %s)\n", p.Report, p.Scope.Waf, p.Scope.Partition())
+
+	var totalBytes int64
+	for i, s := range p.Steps {
+		fmt.Fprintf(w, "\n    %d. %s\n", i+1, s.Name)
+		if len(s.DependsOn) > 0 {
+			fmt.Fprintf(w, "       depends on: %s\n", strings.Join(s.DependsOn, ", "))
+		}
+		fmt.Fprintf(w, "       reads:      %s\n", strings.Join(s.Reads, ", "))
+		if s.Creates != "" {
+			fmt.Fprintf(w, "       creates:    %s\n", s.Creates)
+		}
+
+		if len(s.Partitions) == 0 {
+			fmt.Fprintf(w, "       scans:      tables created by earlier steps only\n")
+			continue
+		}
+
+		bytes := int64(len(s.Partitions)) * partitionBytes
+		totalBytes += bytes
+		fmt.Fprintf(w, "       scans:      day %s (~%s, ~%s)\n", strings.Join(s.Partitions, ", "), aws.BytesToHuman(bytes), aws.EstimatedQueryCost(bytes))
+	}
+
+	fmt.Fprintf(w, "\n    Estimated total: %d queries, ~%s scanned, ~%s\n", len(p.Steps), aws.BytesToHuman(totalBytes), aws.EstimatedQueryCost(totalBytes))
+}
@@ -0,0 +1,140 @@
+package printer
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ChallengeReportPrinter struct {
+	Data     *data.Dir
+	Waf      query.WAF
+	Outcomes int // number of rows of outcomes to print per dimension and kind
+	Solvers  int // number of solvers to print
+}
+
+func NewChallengeReportPrinter(d *data.Dir, waf query.WAF) *ChallengeReportPrinter {
+	return &ChallengeReportPrinter{
+		Data:     d,
+		Waf:      waf,
+		Outcomes: 5,
+		Solvers:  20,
+	}
+}
+
+// latencyBuckets are the labels of the lower bounds of the solve latencies
+var latencyBuckets = map[string]string{
+	"-1": "no puzzle sent",
+	"0":  "0-2s",
+	"2":  "2-5s",
+	"5":  "5-10s",
+	"10": "10-30s",
+	"30": "30-60s",
+	"60": "60-300s",
+}
+
+func (cp *ChallengeReportPrinter) Print() error {
+	run, err := latestRun(cp.Data, cp.Waf, "challenge-report")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[+] CAPTCHAs and challenges of %s\n\n", run.Day)
+
+	outcomes, err := readResult(cp.Data, run, "challenge-outcomes")
+	if err != nil {
+		return err
+	}
+	if err := sortRows(outcomes, order{Column: "dimension"}, order{Column: "kind"}, order{Column: "num_requests", Desc: true, Numeric: true}, order{Column: "identity"}); err != nil {
+		return fmt.Errorf("getting challenge outcomes: %s", err)
+	}
+	if err := limitPerGroup(outcomes, cp.Outcomes, "dimension", "kind"); err != nil {
+		return fmt.Errorf("getting challenge outcomes: %s", err)
+	}
+	records, err := project(outcomes, "dimension", "identity", "kind", "num_requests", "num_issued", "num_solved", "num_failed", "num_solves", "solve_rate", "num_ips")
+	if err != nil {
+		return fmt.Errorf("getting challenge outcomes: %s", err)
+	}
+	if err := printTable(records); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n[+] Solves per seconds from the puzzle sent to the solve\n\n")
+	latency, err := readResult(cp.Data, run, "challenge-solve-latency")
+	if err != nil {
+		return err
+	}
+	if err := sortRows(latency, order{Column: "kind"}, order{Column: "from_seconds", Numeric: true}); err != nil {
+		return fmt.Errorf("getting solve latency: %s", err)
+	}
+	records, err = project(latency, "kind", "from_seconds", "num_solves", "num_ips")
+	if err != nil {
+		return fmt.Errorf("getting solve latency: %s", err)
+	}
+	most := 0.0
+	for _, record := range records[1:] { // skip header
+		n, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return fmt.Errorf("parsing solves of %s: %s", record[1], err)
+		}
+		if n > most {
+			most = n
+		}
+	}
+	rows := [][]string{{"kind", "latency", "num_solves", "num_ips", ""}}
+	for _, record := range records[1:] {
+		n, _ := strconv.ParseFloat(record[2], 64)
+		bar := strings.Repeat("#", int(40*n/most+0.5))
+		rows = append(rows, []string{record[0], latencyBuckets[record[1]], record[2], record[3], bar})
+	}
+	if err := printTable(rows); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n[+] IPs and User-Agents solving fast, often or with tokens of puzzles sent elsewhere\n\n")
+	solvers, err := readResult(cp.Data, run, "challenge-solvers")
+	if err != nil {
+		return err
+	}
+	if err := sortSolvers(solvers); err != nil {
+		return fmt.Errorf("getting solvers: %s", err)
+	}
+	limit(solvers, cp.Solvers)
+	records, err = project(solvers, "kind", "client_ip", "country", "user_agent", "num_solves", "num_fast_solves", "num_foreign_solves", "min_latency", "avg_latency")
+	if err != nil {
+		return fmt.Errorf("getting solvers: %s", err)
+	}
+
+	return printTable(records)
+}
+
+// sortSolvers sorts the solvers by their fast solves and solves with foreign
+// tokens, then by all their solves
+func sortSolvers(t *offline.Table) error {
+	var idx []int
+	for _, name := range []string{"num_fast_solves", "num_foreign_solves"} {
+		i, err := column(t, name)
+		if err != nil {
+			return err
+		}
+		idx = append(idx, i)
+	}
+
+	suspicious := func(row []string) float64 {
+		var n float64
+		for _, i := range idx {
+			v, _ := strconv.ParseFloat(row[i], 64)
+			n += v
+		}
+		return n
+	}
+	if err := sortRows(t, order{Column: "num_solves", Desc: true, Numeric: true}, order{Column: "client_ip"}); err != nil {
+		return err
+	}
+	sort.SliceStable(t.Rows, func(a, b int) bool { return suspicious(t.Rows[a]) > suspicious(t.Rows[b]) })
+	return nil
+}
@@ -0,0 +1,94 @@
+package printer
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/query"
+	"strconv"
+	"strings"
+)
+
+type CredentialStuffingReportPrinter struct {
+	Data     *data.Dir
+	Waf      query.WAF
+	Clusters int // number of clusters to print per dimension
+	Slow     int // number of low and slow clusters to print
+}
+
+func NewCredentialStuffingReportPrinter(d *data.Dir, waf query.WAF) *CredentialStuffingReportPrinter {
+	return &CredentialStuffingReportPrinter{
+		Data:     d,
+		Waf:      waf,
+		Clusters: 5,
+		Slow:     10,
+	}
+}
+
+func (cp *CredentialStuffingReportPrinter) Print() error {
+	run, err := latestRun(cp.Data, cp.Waf, "credential-stuffing-report")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[+] Logins up to %s\n\n", run.Day)
+
+	daily, err := readResult(cp.Data, run, "login-daily-traffic")
+	if err != nil {
+		return err
+	}
+	if err := sortRows(daily, order{Column: "date"}); err != nil {
+		return fmt.Errorf("getting login traffic per day: %s", err)
+	}
+	days, err := project(daily, "date", "num_requests", "num_posts", "num_post_ips", "num_posts_blocked", "num_atp_labeled", "num_compromised")
+	if err != nil {
+		return fmt.Errorf("getting login traffic per day: %s", err)
+	}
+	var posts []float64
+	for _, record := range days[1:] { // skip header
+		record[0] = strings.ReplaceAll(record[0], "/", "-")
+		n, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return fmt.Errorf("parsing POST requests of %s: %s", record[0], err)
+		}
+		posts = append(posts, n)
+	}
+	if err := printTable(days); err != nil {
+		return err
+	}
+	fmt.Printf("\nPOST requests per day: %s\n", sparkline(posts))
+
+	fmt.Printf("\n[+] Top clusters of POST requests per IP, prefix, session and fingerprint\n\n")
+	clusters, err := readResult(cp.Data, run, "login-clusters")
+	if err != nil {
+		return err
+	}
+	if err := sortRows(clusters, order{Column: "dimension"}, order{Column: "num_posts", Desc: true, Numeric: true}, order{Column: "identity"}); err != nil {
+		return fmt.Errorf("getting login clusters: %s", err)
+	}
+	if err := limitPerGroup(clusters, cp.Clusters, "dimension"); err != nil {
+		return fmt.Errorf("getting login clusters: %s", err)
+	}
+	records, err := project(clusters, "dimension", "identity", "num_posts", "peak_posts_per_window", "num_ips", "num_user_agents", "num_blocked", "num_atp_labeled", "atp_labels")
+	if err != nil {
+		return fmt.Errorf("getting login clusters: %s", err)
+	}
+	if err := printTable(records); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n[+] Low and slow: many IPs sharing a User-Agent or fingerprint, few POST requests each\n\n")
+	slow, err := readResult(cp.Data, run, "login-low-and-slow")
+	if err != nil {
+		return err
+	}
+	if err := sortRows(slow, order{Column: "num_ips", Desc: true, Numeric: true}, order{Column: "num_posts", Desc: true, Numeric: true}, order{Column: "identity"}); err != nil {
+		return fmt.Errorf("getting low and slow logins: %s", err)
+	}
+	limit(slow, cp.Slow)
+	records, err = project(slow, "dimension", "identity", "num_ips", "num_prefixes", "num_countries", "num_posts", "max_posts_per_ip", "num_blocked")
+	if err != nil {
+		return fmt.Errorf("getting low and slow logins: %s", err)
+	}
+
+	return printTable(records)
+}
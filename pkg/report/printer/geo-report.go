@@ -0,0 +1,155 @@
+package printer
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type GeoReportPrinter struct {
+	Data *data.Dir
+	Waf  query.WAF
+	Top  int // number of countries with most requests to print besides the flagged ones
+}
+
+func NewGeoReportPrinter(d *data.Dir, waf query.WAF) *GeoReportPrinter {
+	return &GeoReportPrinter{
+		Data: d,
+		Waf:  waf,
+		Top:  10,
+	}
+}
+
+func (gp *GeoReportPrinter) Print() error {
+	run, err := latestRun(gp.Data, gp.Waf, "geo-report")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[+] Countries on %s\n\n", run.Day)
+
+	shares, err := readResult(gp.Data, run, "country-shares")
+	if err != nil {
+		return err
+	}
+	records, err := countryShares(shares, gp.Top)
+	if err != nil {
+		return fmt.Errorf("getting shares of countries: %s", err)
+	}
+
+	hours, err := readResult(gp.Data, run, "country-hourly-traffic")
+	if err != nil {
+		return err
+	}
+	perHour, err := countryHours(hours, strings.ReplaceAll(run.Day, "-", "/"))
+	if err != nil {
+		return fmt.Errorf("getting traffic per hour: %s", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(append(records[0], "hours (UTC)"), "\t"))
+	for _, record := range records[1:] {
+		fmt.Fprintln(tw, strings.Join(append(record, sparkline(perHour[record[0]])), "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// countryShares returns the flagged countries and the top countries with most
+// requests, the flagged ones first
+func countryShares(t *offline.Table, top int) ([][]string, error) {
+	if err := sortRows(t, order{Column: "num_requests", Desc: true, Numeric: true}, order{Column: "country"}); err != nil {
+		return nil, err
+	}
+	flag, err := column(t, "flag")
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for rank, row := range t.Rows {
+		if row[flag] != "" || rank < top {
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(a, b int) bool { return rows[a][flag] != "" && rows[b][flag] == "" })
+	t.Rows = rows
+
+	records, err := project(t, "country", "flag", "num_requests", "num_blocked", "num_rate_limited", "share", "baseline_share", "share_ratio")
+	if err != nil {
+		return nil, err
+	}
+	records[0] = []string{"country", "flag", "num_requests", "num_blocked", "num_rate_limited", "share_pct", "baseline_pct", "ratio"}
+	for _, record := range records[1:] {
+		record[5], record[6] = percent(record[5]), percent(record[6])
+	}
+	return records, nil
+}
+
+// percent renders the share as percentage with two decimals, NULL as empty
+// string
+func percent(share string) string {
+	v, err := strconv.ParseFloat(share, 64)
+	if err != nil {
+		return share
+	}
+	return strconv.FormatFloat(100*v, 'f', 2, 64)
+}
+
+// countryHours returns the requests per hour of each country on the date,
+// e.g. 2023/02/21
+func countryHours(t *offline.Table, date string) (map[string][]float64, error) {
+	records, err := project(t, "country", "date", "hour", "num_requests")
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string][]float64{}
+	for _, record := range records[1:] { // skip header
+		if record[1] != date {
+			continue
+		}
+		hour, err := strconv.Atoi(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("parsing hour of country %s: %s", record[0], err)
+		}
+		n, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing number of requests of country %s: %s", record[0], err)
+		}
+		if _, ok := out[record[0]]; !ok {
+			out[record[0]] = make([]float64, 24)
+		}
+		out[record[0]][hour%24] = n
+	}
+	return out, nil
+}
+
+// sparkline draws the values as a line of block characters scaled to their
+// maximum
+func sparkline(values []float64) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	var out strings.Builder
+	for _, v := range values {
+		if max == 0 || v == 0 {
+			out.WriteRune(' ')
+			continue
+		}
+		out.WriteRune(blocks[int(v/max*float64(len(blocks)-1))])
+	}
+
+	return out.String()
+}
@@ -0,0 +1,159 @@
+package printer
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"strings"
+)
+
+type ManagedRuleReportPrinter struct {
+	Data    *data.Dir
+	Waf     query.WAF
+	Rules   int // number of rules to rank
+	Details int // number of the top ranked rules to print URIs, arguments, IPs and samples of
+	Top     int // number of URIs, arguments and IPs to print per rule
+}
+
+func NewManagedRuleReportPrinter(d *data.Dir, waf query.WAF) *ManagedRuleReportPrinter {
+	return &ManagedRuleReportPrinter{
+		Data:    d,
+		Waf:     waf,
+		Rules:   20,
+		Details: 5,
+		Top:     3,
+	}
+}
+
+func (mp *ManagedRuleReportPrinter) Print() error {
+	run, err := latestRun(mp.Data, mp.Waf, "managed-rule-report")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[+] Managed rules by likely false positives on %s\n\n", run.Day)
+
+	matches, err := readResult(mp.Data, run, "managed-rule-matches")
+	if err != nil {
+		return err
+	}
+	if err := sortRows(matches, order{Column: "false_positive_score", Desc: true, Numeric: true}, order{Column: "num_requests", Desc: true, Numeric: true}, order{Column: "rule_group"}, order{Column: "rule"}); err != nil {
+		return fmt.Errorf("getting matches per rule: %s", err)
+	}
+	limit(matches, mp.Rules)
+	rules, err := project(matches, "rule_group", "rule", "match", "num_requests", "num_ips", "num_uris", "browser_share", "session_share", "false_positive_score")
+	if err != nil {
+		return fmt.Errorf("getting matches per rule: %s", err)
+	}
+	if err := printTable(rules); err != nil {
+		return err
+	}
+
+	for i, rule := range rules[1:] { // skip header
+		if i >= mp.Details {
+			break
+		}
+		if err := mp.printRule(run, rule[0], rule[1], rule[2]); err != nil {
+			return fmt.Errorf("printing rule %s/%s: %s", rule[0], rule[1], err)
+		}
+	}
+
+	return nil
+}
+
+// printRule prints the top URIs, arguments and IPs and the sample requests
+// of the rule
+func (mp *ManagedRuleReportPrinter) printRule(run *data.Manifest, group string, rule string, match string) error {
+	fmt.Printf("\n[+] %s/%s (%s)\n", group, rule, match)
+
+	tops := []struct {
+		title  string
+		column string
+		result string
+	}{
+		{"URIs", "uri", "managed-rule-top-uris"},
+		{"Arguments", "args", "managed-rule-top-args"},
+		{"IPs", "client_ip", "managed-rule-top-ips"},
+	}
+	for _, top := range tops {
+		t, err := mp.readRuleResult(run, top.result, group, rule, match)
+		if err != nil {
+			return fmt.Errorf("getting top %s: %s", top.column, err)
+		}
+		if err := sortRows(t, order{Column: "num_requests", Desc: true, Numeric: true}); err != nil {
+			return fmt.Errorf("getting top %s: %s", top.column, err)
+		}
+		limit(t, mp.Top)
+		records, err := project(t, top.column, "num_requests")
+		if err != nil {
+			return fmt.Errorf("getting top %s: %s", top.column, err)
+		}
+
+		var values []string
+		for _, record := range records[1:] {
+			if record[0] == "" {
+				record[0] = "-"
+			}
+			values = append(values, fmt.Sprintf("%s (%s)", record[0], record[1]))
+		}
+		fmt.Printf("    %-10s %s\n", top.title+":", strings.Join(values, ", "))
+	}
+
+	t, err := mp.readRuleResult(run, "managed-rule-samples", group, rule, match)
+	if err != nil {
+		return fmt.Errorf("getting samples: %s", err)
+	}
+	if err := sortRows(t, order{Column: "timestamp"}); err != nil {
+		return fmt.Errorf("getting samples: %s", err)
+	}
+	samples, err := project(t, "timestamp", "client_ip", "method", "uri", "args", "user_agent", "match_details")
+	if err != nil {
+		return fmt.Errorf("getting samples: %s", err)
+	}
+
+	fmt.Println("    Samples:")
+	for _, record := range samples[1:] {
+		request := record[3]
+		if record[4] != "" {
+			request += "?" + record[4]
+		}
+		fmt.Printf("      %s %s %s %s %q", clock(record[0]), record[1], record[2], request, record[5])
+		if record[6] != "" {
+			fmt.Printf(" matched %s", record[6])
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// readRuleResult reads the rows of the result of the run for the rule
+func (mp *ManagedRuleReportPrinter) readRuleResult(run *data.Manifest, result string, group string, rule string, match string) (*offline.Table, error) {
+	t, err := readResult(mp.Data, run, result)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := project(t, "rule_group", "rule", "match")
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for i, record := range records[1:] {
+		if record[0] == group && record[1] == rule && record[2] == match {
+			rows = append(rows, t.Rows[i])
+		}
+	}
+	t.Rows = rows
+	return t, nil
+}
+
+// clock returns the time of day of a timestamp rendered by Athena, e.g.
+// 10:00:00 of 2023-02-21 10:00:00.000 UTC
+func clock(timestamp string) string {
+	if len(timestamp) < len("2006-01-02 15:04:05") {
+		return timestamp
+	}
+	return timestamp[len("2006-01-02 "):len("2006-01-02 15:04:05")]
+}
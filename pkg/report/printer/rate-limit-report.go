@@ -1,132 +1,82 @@
 package printer
 
 import (
-	"encoding/csv"
 	"fmt"
-	"io"
+	"kfzteile24/waflogs/pkg/data"
 	"kfzteile24/waflogs/pkg/query"
-	"os"
-	"path/filepath"
-	"sort"
 	"strconv"
 
 	"github.com/guptarohit/asciigraph"
 )
 
-const DataDir = "./data"
-
 type RateLimitReportPrinter struct {
-	Waf query.WAF
+	Data *data.Dir
+	Waf  query.WAF
 }
 
-func NewRateLimitReportPrinter(waf query.WAF) *RateLimitReportPrinter {
+func NewRateLimitReportPrinter(d *data.Dir, waf query.WAF) *RateLimitReportPrinter {
 	return &RateLimitReportPrinter{
-		Waf: waf,
+		Data: d,
+		Waf:  waf,
 	}
 }
 
 func (rp *RateLimitReportPrinter) Print() error {
-	days, err := rp.listDays()
+	runs, err := completeRuns(rp.Data, rp.Waf, "rate-limit-report")
 	if err != nil {
-		return fmt.Errorf("listing days: %s", err)
+		return err
 	}
 
-	var data []float64
+	var points []float64
 	var max int
-	for _, day := range days {
-		n, err := rp.getFastestBotUserAgentOf(day)
+	for _, run := range runs {
+		n, err := rp.fastestBotUserAgent(run)
 		if err != nil {
-			return fmt.Errorf("getting fastest bot user agent of day %s: %s", day, err)
+			return fmt.Errorf("getting fastest bot user agents of day %s: %s", run.Day, err)
 		}
-		data = append(data, float64(n))
+		points = append(points, float64(n))
 		if n > max {
 			max = n
 		}
-		fmt.Printf("%s: %d\n", day, n)
+		fmt.Printf("%s: %d\n", run.Day, n)
 	}
 
-	for i := range data {
-		data[i] = data[i] / float64(max) * 20.0
+	if len(points) == 0 {
+		return nil
 	}
 
-	graph := asciigraph.Plot(data)
-
-	fmt.Println(graph)
-
-	return nil
-}
-
-func (rp *RateLimitReportPrinter) listDays() ([]string, error) {
-	files, err := os.ReadDir(rp.getReportDir())
-	if err != nil {
-		return nil, fmt.Errorf("reading dir: %s", err)
+	for i := range points {
+		points[i] = points[i] / float64(max) * 20.0
 	}
 
-	var days []string
-	for _, file := range files {
-		if !file.IsDir() {
-			continue
-		}
-		days = append(days, file.Name())
-	}
-
-	sort.Strings(days) // just to be sure, should be sorted anyways
+	graph := asciigraph.Plot(points)
 
-	return days, nil
-}
+	fmt.Println(graph)
 
-func (rp *RateLimitReportPrinter) getReportDir() string {
-	return filepath.Join(DataDir, rp.Waf.String(), "rate-limit-report")
+	return nil
 }
 
-func (rp *RateLimitReportPrinter) getFastestBotUserAgentOf(day string) (int, error) {
-	path := filepath.Join(rp.getReportDir(), day, "fastest-bot-user-agents-not-black-or-whitelisted.csv")
-
-	records, err := readCsv(path, 2)
-	if err != nil {
-		return 0, fmt.Errorf("reading csv at %s: %s", path, err)
-	}
-
-	if len(records) < 2 {
-		return 0, nil
-	}
-	if len(records[1]) < 5 {
-		return 0, fmt.Errorf("unexpected csv forat of %s: %s", path, err)
-	}
-
-	out, err := strconv.Atoi(records[1][4])
+// fastestBotUserAgent returns the requests of the fastest bot user agent of
+// the run, 0 for days without bot user agents
+func (rp *RateLimitReportPrinter) fastestBotUserAgent(run *data.Manifest) (int, error) {
+	t, err := readResult(rp.Data, run, "fastest-bot-user-agents-not-black-or-whitelisted")
 	if err != nil {
-		return 0, fmt.Errorf("parsing number at records[1][4]: %s", err)
+		return 0, err
 	}
-
-	return out, nil
-}
-
-func readCsv(path string, max int) ([][]string, error) {
-	f, err := os.Open(path)
+	i, err := column(t, "num_requests")
 	if err != nil {
-		return nil, fmt.Errorf("openeing file: %s", err)
+		return 0, err
 	}
-	defer f.Close()
 
-	r := csv.NewReader(f)
-
-	var out [][]string
-	for {
-		if len(out) == max {
-			break
-		}
-
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
+	var max int
+	for _, row := range t.Rows {
+		n, err := strconv.Atoi(row[i])
 		if err != nil {
-			return nil, fmt.Errorf("reading record: %s", err)
+			return 0, fmt.Errorf("parsing number of requests: %s", err)
+		}
+		if n > max {
+			max = n
 		}
-
-		out = append(out, record)
 	}
-
-	return out, nil
+	return max, nil
 }
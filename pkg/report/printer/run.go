@@ -0,0 +1,232 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/local"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// completeRuns returns the manifests of the complete runs of the report for
+// the WAF, ordered by day. Results of runs still running, failed or written
+// with an older layout may be missing or stale. Runs migrated from a version
+// without manifests are included if the files of their steps still exist,
+// flagged as migrated in the output since their completeness is unknown.
+func completeRuns(d *data.Dir, waf query.WAF, report string) ([]*data.Manifest, error) {
+	runs, err := d.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*data.Manifest
+	var latest *data.Manifest
+	for _, m := range runs {
+		if m.WAF != waf.String() || m.Report != report {
+			continue
+		}
+		latest = m
+		switch {
+		case m.Complete():
+			out = append(out, m)
+		case m.Migrated() && hasFiles(d, m):
+			fmt.Printf("[+] Reading the migrated run of %s, written by an older version, results may be incomplete\n", m.Day)
+			out = append(out, m)
+		}
+	}
+
+	switch {
+	case latest == nil:
+		return nil, fmt.Errorf("no runs of %s for %s, load the report first", report, waf)
+	case len(out) == 0:
+		return nil, fmt.Errorf("no complete runs of %s for %s, the run of %s is %s, load the report again", report, waf, latest.Day, latest.State())
+	case latest != out[len(out)-1]:
+		fmt.Printf("[+] Skipping the %s run of %s\n", latest.State(), latest.Day)
+	}
+
+	return out, nil
+}
+
+// hasFiles reports if the run has steps and all their files exist
+func hasFiles(d *data.Dir, m *data.Manifest) bool {
+	dir := filepath.Join(d.Root, m.WAF, m.Report, m.Day)
+	for _, s := range m.Steps {
+		for _, f := range s.Files {
+			if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+				return false
+			}
+		}
+	}
+	return len(m.Steps) > 0
+}
+
+// latestRun returns the manifest of the latest complete run of the report for
+// the WAF
+func latestRun(d *data.Dir, waf query.WAF, report string) (*data.Manifest, error) {
+	runs, err := completeRuns(d, waf, report)
+	if err != nil {
+		return nil, err
+	}
+
+	return runs[len(runs)-1], nil
+}
+
+// readResult reads the result of the step of the run from its CSV file or,
+// for runs writing only Parquet, its Parquet file
+func readResult(d *data.Dir, m *data.Manifest, step string) (*offline.Table, error) {
+	path := filepath.Join(d.Root, m.WAF, m.Report, m.Day, step)
+
+	t, err := offline.ReadCSV(path + ".csv")
+	if !errors.Is(err, os.ErrNotExist) {
+		return t, err
+	}
+
+	records, err := local.ReadParquet(path + ".parquet")
+	if err != nil {
+		return nil, fmt.Errorf("reading result %s: %s", step, err)
+	}
+	return &offline.Table{Header: records[0], Rows: records[1:]}, nil
+}
+
+// column returns the index of the column called name
+func column(t *offline.Table, name string) (int, error) {
+	for i, h := range t.Header {
+		if h == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no column %s", name)
+}
+
+// project returns the header and the rows of the table with the columns
+// called names, in that order
+func project(t *offline.Table, names ...string) ([][]string, error) {
+	var idx []int
+	for _, name := range names {
+		i, err := column(t, name)
+		if err != nil {
+			return nil, err
+		}
+		idx = append(idx, i)
+	}
+
+	out := [][]string{names}
+	for _, row := range t.Rows {
+		record := make([]string, len(idx))
+		for j, i := range idx {
+			record[j] = row[i]
+		}
+		out = append(out, record)
+	}
+	return out, nil
+}
+
+// order is a column to sort rows by, numbers before other values, which
+// includes NULL
+type order struct {
+	Column  string
+	Desc    bool
+	Numeric bool
+}
+
+// sortRows sorts the rows of the table stably by the orders, the first
+// order first
+func sortRows(t *offline.Table, orders ...order) error {
+	idx := make([]int, len(orders))
+	for k, o := range orders {
+		i, err := column(t, o.Column)
+		if err != nil {
+			return err
+		}
+		idx[k] = i
+	}
+
+	sort.SliceStable(t.Rows, func(a, b int) bool {
+		for k, o := range orders {
+			if c := compare(t.Rows[a][idx[k]], t.Rows[b][idx[k]], o); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// compare returns the order of the values a and b
+func compare(a string, b string, o order) int {
+	if !o.Numeric {
+		if o.Desc {
+			return strings.Compare(b, a)
+		}
+		return strings.Compare(a, b)
+	}
+
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return 1
+	case errB != nil:
+		return -1
+	case x == y:
+		return 0
+	case (x < y) != o.Desc:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// limitPerGroup keeps the first n rows of each group of rows with the same
+// values of the columns
+func limitPerGroup(t *offline.Table, n int, columns ...string) error {
+	var idx []int
+	for _, name := range columns {
+		i, err := column(t, name)
+		if err != nil {
+			return err
+		}
+		idx = append(idx, i)
+	}
+
+	seen := map[string]int{}
+	var rows [][]string
+	for _, row := range t.Rows {
+		var key []string
+		for _, i := range idx {
+			key = append(key, row[i])
+		}
+		k := strings.Join(key, "\x00")
+		if seen[k] < n {
+			rows = append(rows, row)
+		}
+		seen[k]++
+	}
+	t.Rows = rows
+	return nil
+}
+
+// limit keeps the first n rows of the table, all if n is 0
+func limit(t *offline.Table, n int) {
+	if n > 0 && len(t.Rows) > n {
+		t.Rows = t.Rows[:n]
+	}
+}
+
+// printTable prints the records of a query result aligned in columns
+func printTable(records [][]string) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, record := range records {
+		fmt.Fprintln(tw, strings.Join(record, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,102 @@
+package printer
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"strconv"
+)
+
+// Summary selects the rows of a result to print
+type Summary struct {
+	Result  string   // name of the step writing the result
+	Title   string   // printed above the rows, the result if empty
+	Columns []string // columns to print, all if empty
+	OrderBy string   // column to order the rows by, descending
+	Limit   int      // number of rows to print, all if 0
+
+	// MinColumn and Min drop the rows with a value of MinColumn below Min
+	MinColumn string
+	Min       float64
+}
+
+// SummaryPrinter prints the results of the latest day loaded of a report as
+// tables, e.g. of reports defined in YAML
+type SummaryPrinter struct {
+	Data      *data.Dir
+	Waf       query.WAF
+	Report    string // name of the directory of the runs of the report
+	Summaries []Summary
+}
+
+func NewSummaryPrinter(d *data.Dir, waf query.WAF, report string, summaries []Summary) *SummaryPrinter {
+	return &SummaryPrinter{
+		Data:      d,
+		Waf:       waf,
+		Report:    report,
+		Summaries: summaries,
+	}
+}
+
+func (sp *SummaryPrinter) Print() error {
+	run, err := latestRun(sp.Data, sp.Waf, sp.Report)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sp.Summaries {
+		if err := sp.printSummary(run, s); err != nil {
+			return fmt.Errorf("printing %s: %s", s.Result, err)
+		}
+	}
+
+	return nil
+}
+
+func (sp *SummaryPrinter) printSummary(run *data.Manifest, s Summary) error {
+	title := s.Title
+	if title == "" {
+		title = s.Result
+	}
+	fmt.Printf("\n[+] %s, %s\n", title, run.Day)
+
+	t, err := readResult(sp.Data, run, s.Result)
+	if err != nil {
+		return err
+	}
+	records, err := summarize(t, s)
+	if err != nil {
+		return err
+	}
+
+	return printTable(records)
+}
+
+// summarize returns the header and rows of the summary of the result
+func summarize(t *offline.Table, s Summary) ([][]string, error) {
+	if s.MinColumn != "" {
+		i, err := column(t, s.MinColumn)
+		if err != nil {
+			return nil, err
+		}
+		var rows [][]string
+		for _, row := range t.Rows {
+			if v, err := strconv.ParseFloat(row[i], 64); err == nil && v >= s.Min {
+				rows = append(rows, row)
+			}
+		}
+		t.Rows = rows
+	}
+	if s.OrderBy != "" {
+		if err := sortRows(t, order{Column: s.OrderBy, Desc: true, Numeric: true}, order{Column: s.OrderBy}); err != nil {
+			return nil, err
+		}
+	}
+	limit(t, s.Limit)
+
+	if len(s.Columns) == 0 {
+		return append([][]string{t.Header}, t.Rows...), nil
+	}
+	return project(t, s.Columns...)
+}
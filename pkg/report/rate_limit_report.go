@@ -2,29 +2,71 @@ package report
 
 import (
 	"fmt"
-	"kfzteile24/waflogs/pkg/aws"
+	"kfzteile24/waflogs/pkg/offline"
 	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"kfzteile24/waflogs/pkg/waflog"
 	"strings"
 	"time"
+
+	"github.com/urfave/cli/v2"
 )
 
+func init() {
+	Register(&rateLimitReport{window: query.DefaultWindow})
+}
+
+// rateLimitReport defines the report on requests blocked by the rate limit
+// rule and the fastest clients getting through
+type rateLimitReport struct {
+	window query.Window      // to aggregate request rates in
+	labels query.LabelFilter // only requests carrying one of the labels, all if empty
+}
+
+func (*rateLimitReport) Name() string      { return "rate-limit-report" }
+func (*rateLimitReport) Aliases() []string { return []string{"rate-limit", "r"} }
+
+func (*rateLimitReport) Description() string {
+	return "IPs and User-Agents blocked by the rate limit, and the fastest IPs and bots not black- or whitelisted"
+}
+
+func (r *rateLimitReport) Flags() []cli.Flag {
+	return []cli.Flag{windowFlag(&r.window), slidingFlag(&r.window), labelFlag(&r.labels)}
+}
+
+func (r *rateLimitReport) NewLoader(b Backend, o Options) Loader {
+	return NewRateLimitReportLoader(b, o.Waf, r.window, r.labels, o.Day)
+}
+
+func (*rateLimitReport) NewPrinter(o Options) Printer {
+	return printer.NewRateLimitReportPrinter(o.Data, o.Waf)
+}
+
 type RateLimitReportLoader struct {
-	base *ReportLoader
+	*ReportLoader
+	window query.Window
+	labels query.LabelFilter // only requests carrying one of the labels, all if empty
 }
 
-func NewRateLimitReportLoader(a *aws.AthenaClient, waf query.WAF, t time.Time) *RateLimitReportLoader {
+func NewRateLimitReportLoader(b Backend, waf query.WAF, window query.Window, labels query.LabelFilter, t time.Time) *RateLimitReportLoader {
 	year, month, day := getDay(t)
 
 	out := &RateLimitReportLoader{
-		base: NewReportLoader(a, waf, "rate-limit-report", year, month, day),
+		ReportLoader: NewReportLoader(b, waf, "rate-limit-report", year, month, day),
+		window:       window,
+		labels:       labels,
+	}
+	out.Params = map[string]string{
+		"window": window.String(),
+		"labels": strings.Join(labels, ","),
 	}
 
+	out.steps = out.load
+
 	return out
 }
 
-func (r *RateLimitReportLoader) Run() error {
-	r.base.ensureOutDirExists()
-
+func (r *RateLimitReportLoader) load() error {
 	if err := r.LoadIpsBlockedByRateLimit(); err != nil {
 		return fmt.Errorf("loading requests per IP blocked by rate limit: %s", err)
 	}
@@ -45,19 +87,25 @@ func (r *RateLimitReportLoader) Run() error {
 }
 
 func (r *RateLimitReportLoader) LoadIpsBlockedByRateLimit() error {
-	fmt.Println("\n[+] Loading requests per IP blocked by rate limit...")
+	r.printf("\n[+] Loading requests per IP blocked by rate limit...\n")
 
 	sql, err := query.GetRequestsBlockedBy(
-		r.base.Scope,
+		r.Scope,
 		query.IdentityColumnsIP,
 		[]query.TerminatingRule{query.TerminatingRuleRateLimit},
+		r.labels,
 		1000,
 	)
 	if err != nil {
 		return fmt.Errorf("rendering sql: %s", err)
 	}
 
-	if err := r.base.RunQuery(sql, "ips-blocked-by-rate-limit"); err != nil {
+	step := r.rawTableStep("ips-blocked-by-rate-limit", sql)
+	step.Local = func(records []*waflog.Record) (*offline.Table, error) {
+		return offline.RequestsBlockedBy(records, query.IdentityColumnsIP, []query.TerminatingRule{query.TerminatingRuleRateLimit}, r.labels, 1000), nil
+	}
+
+	if err := r.RunQuery(step); err != nil {
 		return fmt.Errorf("running query: %s", err)
 	}
 
@@ -65,19 +113,25 @@ func (r *RateLimitReportLoader) LoadIpsBlockedByRateLimit() error {
 }
 
 func (r *RateLimitReportLoader) LoadUserAgentsBlockedByRateLimit() error {
-	fmt.Println("\n[+] Loading requests per User-Agent blocked by rate limit...")
+	r.printf("\n[+] Loading requests per User-Agent blocked by rate limit...\n")
 
 	sql, err := query.GetRequestsBlockedBy(
-		r.base.Scope,
+		r.Scope,
 		query.IdentityColumnsUserAgent,
 		[]query.TerminatingRule{query.TerminatingRuleRateLimit},
+		r.labels,
 		1000,
 	)
 	if err != nil {
 		return fmt.Errorf("rendering sql: %s", err)
 	}
 
-	if err := r.base.RunQuery(sql, "user-agents-blocked-by-rate-limit"); err != nil {
+	step := r.rawTableStep("user-agents-blocked-by-rate-limit", sql)
+	step.Local = func(records []*waflog.Record) (*offline.Table, error) {
+		return offline.RequestsBlockedBy(records, query.IdentityColumnsUserAgent, []query.TerminatingRule{query.TerminatingRuleRateLimit}, r.labels, 1000), nil
+	}
+
+	if err := r.RunQuery(step); err != nil {
 		return fmt.Errorf("running query: %s", err)
 	}
 
@@ -85,13 +139,15 @@ func (r *RateLimitReportLoader) LoadUserAgentsBlockedByRateLimit() error {
 }
 
 func (r *RateLimitReportLoader) LoadFastestIPsNotBlackOrWhitelisted() error {
-	fmt.Println("\n[+] Loading fastest IPs not black- or whitelisted...")
+	r.printf("\n[+] Loading fastest IPs not black- or whitelisted...\n")
 
-	minRate := 400
+	minRate := r.scaleRate(400)
 	limit := 1000
 	sql, err := query.GetFastestIdentities(
-		r.base.Scope,
+		r.Scope,
 		query.IdentityColumnsIP,
+		r.window,
+		r.labels,
 		minRate,
 		"WHERE terminating_rule IN (VALUES 'Default_Action', 'rate-limit')", // means requests went through the WAF without any explicit action, except possible rate-limit blocks
 		limit,
@@ -100,7 +156,12 @@ func (r *RateLimitReportLoader) LoadFastestIPsNotBlackOrWhitelisted() error {
 		return fmt.Errorf("rendering sql: %s", err)
 	}
 
-	if err := r.base.RunQuery(sql, "fastest-ips-not-black-or-whitelisted"); err != nil {
+	step := r.rawTableStep("fastest-ips-not-black-or-whitelisted", sql)
+	step.Local = func(records []*waflog.Record) (*offline.Table, error) {
+		return offline.FastestIdentities(records, query.IdentityColumnsIP, r.window, r.labels, minRate, notBlackOrWhitelisted, limit)
+	}
+
+	if err := r.RunQuery(step); err != nil {
 		return fmt.Errorf("running query: %s", err)
 	}
 
@@ -108,13 +169,15 @@ func (r *RateLimitReportLoader) LoadFastestIPsNotBlackOrWhitelisted() error {
 }
 
 func (r *RateLimitReportLoader) LoadFastestBotUserAgentsNotBlackOrWhitelisted() error {
-	fmt.Println("\n[+] Loading fastest Bot User-Agents not black- or whitelisted...")
+	r.printf("\n[+] Loading fastest Bot User-Agents not black- or whitelisted...\n")
 
-	minRate := 50 // only bot traffic that is not occasional and slow
+	minRate := r.scaleRate(50) // only bot traffic that is not occasional and slow
 	limit := 1000
 	sql, err := query.GetFastestIdentities(
-		r.base.Scope,
+		r.Scope,
 		query.IdentityColumnsUserAgent,
+		r.window,
+		r.labels,
 		minRate,
 		fmt.Sprintf(
 			"WHERE terminating_rule IN (VALUES 'Default_Action', 'rate-limit') AND signal_nobrowser AND user_agent NOT IN (VALUES %s)",
@@ -126,17 +189,49 @@ func (r *RateLimitReportLoader) LoadFastestBotUserAgentsNotBlackOrWhitelisted()
 		return fmt.Errorf("rendering sql: %s", err)
 	}
 
-	if err := r.base.RunQuery(sql, "fastest-bot-user-agents-not-black-or-whitelisted"); err != nil {
+	step := r.rawTableStep("fastest-bot-user-agents-not-black-or-whitelisted", sql)
+	step.Local = func(records []*waflog.Record) (*offline.Table, error) {
+		return offline.FastestIdentities(records, query.IdentityColumnsUserAgent, r.window, r.labels, minRate, notBlackOrWhitelistedBot, limit)
+	}
+
+	if err := r.RunQuery(step); err != nil {
 		return fmt.Errorf("running query: %s", err)
 	}
 
 	return nil
 }
 
+// scaleRate converts a minimum rate per 5 minutes to the configured window size
+func (r *RateLimitReportLoader) scaleRate(ratePer5Min int) int {
+	return ratePer5Min * r.window.Minutes / 5
+}
+
+// user agents of clients we know and don't need to see in the report
+var boringUserAgents = []string{
+	"ios-de-1.0.0",
+}
+
 func boringUserAgentValues() string {
-	boringUserAgents := []string{
-		"ios-de-1.0.0",
+	return "'" + strings.Join(boringUserAgents, "','") + "'"
+}
+
+// notBlackOrWhitelisted is the Go equivalent of the where clause of LoadFastestIPsNotBlackOrWhitelisted
+func notBlackOrWhitelisted(rec *waflog.Record) bool {
+	return rec.TerminatingRuleID == "Default_Action" || rec.TerminatingRuleID == "rate-limit"
+}
+
+// notBlackOrWhitelistedBot is the Go equivalent of the where clause of LoadFastestBotUserAgentsNotBlackOrWhitelisted
+func notBlackOrWhitelistedBot(rec *waflog.Record) bool {
+	ua, ok := rec.UserAgent()
+	if !ok || !notBlackOrWhitelisted(rec) || !rec.SignalNoBrowser() {
+		return false
 	}
 
-	return "'" + strings.Join(boringUserAgents, "','") + "'"
+	for _, boring := range boringUserAgents {
+		if ua == boring {
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,194 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	Register(&rateLimitSimulationReport{
+		keys:   []query.RateKey{{Kind: "ip"}},
+		limits: []int{100, 200, 400, 1000},
+		window: query.DefaultWindow,
+		days:   1,
+	})
+}
+
+// rateLimitSimulationReport defines the what-if simulation of settings of the
+// rate limit rule
+type rateLimitSimulationReport struct {
+	keys        []query.RateKey   // to aggregate requests by
+	limits      []int             // requests per window
+	windowSizes []int             // in minutes, the window if empty
+	window      query.Window      // of the candidates without window sizes, sliding or not
+	labels      query.LabelFilter // only requests carrying one of the labels, all if empty
+	days        int               // number of days up to the timestamp to replay
+}
+
+func (*rateLimitSimulationReport) Name() string      { return "rate-limit-simulation" }
+func (*rateLimitSimulationReport) Aliases() []string { return []string{"what-if"} }
+
+func (*rateLimitSimulationReport) Description() string {
+	return "identities and requests candidate limits, windows and keys of the rate limit rule would have blocked, their overlap with blocked traffic and hits on known good clients"
+}
+
+func (r *rateLimitSimulationReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "key",
+			Value: cli.NewStringSlice("ip"),
+			Usage: "key to aggregate requests by: ip, forwarded-ip, header:<name>, cookie:<name> or query-arg:<name>, repeatable",
+			Action: func(ctx *cli.Context, v []string) error {
+				r.keys = nil
+				for _, s := range v {
+					k, err := query.ParseRateKey(s)
+					if err != nil {
+						return err
+					}
+					r.keys = append(r.keys, k)
+				}
+				return nil
+			},
+		},
+		&cli.IntSliceFlag{
+			Name:  "limit",
+			Value: cli.NewIntSlice(100, 200, 400, 1000),
+			Usage: "number of requests per window above which the rule blocks, repeatable",
+			Action: func(ctx *cli.Context, v []int) error {
+				for _, l := range v {
+					if l < 1 {
+						return fmt.Errorf("limit must be positive, got %d", l)
+					}
+				}
+				r.limits = v
+				return nil
+			},
+		},
+		&cli.IntSliceFlag{
+			Name:  "window-size",
+			Usage: "window in minutes (1, 2, 5 or 10) to simulate, repeatable, the window of --window if not set",
+			Action: func(ctx *cli.Context, v []int) error {
+				for _, m := range v {
+					if err := (query.Window{Minutes: m}).Validate(); err != nil {
+						return err
+					}
+				}
+				r.windowSizes = v
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:        "days",
+			Value:       1,
+			Usage:       "number of days up to the timestamp to replay, e.g. 7 for a week",
+			Destination: &r.days,
+		},
+		windowFlag(&r.window),
+		slidingFlag(&r.window),
+		labelFlag(&r.labels),
+	}
+}
+
+func (r *rateLimitSimulationReport) NewLoader(b Backend, o Options) Loader {
+	var candidates []query.RateLimitCandidate
+	windowSizes := r.windowSizes
+	if len(windowSizes) == 0 {
+		windowSizes = []int{r.window.Minutes}
+	}
+	for _, k := range r.keys {
+		for _, m := range windowSizes {
+			for _, l := range r.limits {
+				candidates = append(candidates, query.RateLimitCandidate{
+					Key:    k,
+					Window: query.Window{Minutes: m, Sliding: r.window.Sliding},
+					Limit:  l,
+				})
+			}
+		}
+	}
+
+	return NewRateLimitSimulationReportLoader(b, o.Waf, candidates, r.days, r.labels, o.Day)
+}
+
+func (*rateLimitSimulationReport) NewPrinter(o Options) Printer {
+	return printer.NewSummaryPrinter(o.Data, o.Waf, "rate-limit-simulation", []printer.Summary{{
+		Result: "rate-limit-simulation",
+		Title:  "Requests blocked by candidate settings of the rate limit rule",
+	}})
+}
+
+// RateLimitSimulationReportLoader replays the logs of one or more days
+// against candidate settings of the rate limit rule, to see what a change
+// would block before making it
+type RateLimitSimulationReportLoader struct {
+	*ReportLoader
+	candidates []query.RateLimitCandidate
+	days       int
+	labels     query.LabelFilter // only requests carrying one of the labels, all if empty
+}
+
+func NewRateLimitSimulationReportLoader(b Backend, waf query.WAF, candidates []query.RateLimitCandidate, days int, labels query.LabelFilter, t time.Time) *RateLimitSimulationReportLoader {
+	year, month, day := getDay(t)
+
+	out := &RateLimitSimulationReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "rate-limit-simulation", year, month, day),
+		candidates:   candidates,
+		days:         days,
+		labels:       labels,
+	}
+
+	var settings []string
+	for _, c := range candidates {
+		settings = append(settings, fmt.Sprintf("%s/%s/%d", c.Key, c.Window, c.Limit))
+	}
+	out.Params = map[string]string{
+		"candidates": strings.Join(settings, ","),
+		"days":       strconv.Itoa(days),
+		"labels":     strings.Join(labels, ","),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *RateLimitSimulationReportLoader) load() error {
+	if err := r.LoadRateLimitSimulation(); err != nil {
+		return fmt.Errorf("simulating rate limits: %s", err)
+	}
+
+	return nil
+}
+
+func (r *RateLimitSimulationReportLoader) LoadRateLimitSimulation() error {
+	r.printf("\n[+] Replaying the last %d days against %d rate limit settings...\n", r.days, len(r.candidates))
+
+	sql, err := query.GetRateLimitSimulation(
+		r.Scope,
+		r.days,
+		r.candidates,
+		r.labels,
+		boringUserAgents, // clients we know, whose blocks would hurt
+	)
+	if err != nil {
+		return fmt.Errorf("rendering sql: %s", err)
+	}
+
+	step := r.rawTableStep("rate-limit-simulation", sql)
+	step.Partitions = nil
+	for _, s := range r.Scope.LastDays(r.days) {
+		step.Partitions = append(step.Partitions, s.Partition())
+	}
+
+	if err := r.RunQuery(step); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
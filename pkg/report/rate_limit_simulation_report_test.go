@@ -0,0 +1,72 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRateLimitSimulationReport(t *testing.T) {
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	var records []*waflog.Record
+	for i := 0; i < 30; i++ { // a scraper behind a proxy, partly blocked already
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "10.0.0.1", "Mozilla/5.0", "Default_Action", "ALLOW")
+		if i >= 20 {
+			r.TerminatingRuleID, r.Action = "rate-limit", "BLOCK"
+		}
+		r.HTTPRequest.Headers = append(r.HTTPRequest.Headers,
+			waflog.Header{Name: "X-Forwarded-For", Value: "203.0.113.7, 10.0.0.1"},
+			waflog.Header{Name: "Cookie", Value: "session=scraper"},
+		)
+		records = append(records, r)
+	}
+	for i := 0; i < 25; i++ { // the app
+		records = append(records, testRecord(at.Add(time.Duration(i)*time.Second), "10.0.0.2", "ios-de-1.0.0", "Default_Action", "ALLOW"))
+	}
+	for i := 0; i < 5; i++ { // a customer behind the same proxy
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "10.0.0.1", "Mozilla/5.0", "Default_Action", "ALLOW")
+		r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "X-Forwarded-For", Value: "198.51.100.1"})
+		records = append(records, r)
+	}
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	var candidates []query.RateLimitCandidate
+	for _, key := range []string{"ip", "forwarded-ip", "cookie:session"} {
+		k, err := query.ParseRateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		candidates = append(candidates, query.RateLimitCandidate{Key: k, Window: query.DefaultWindow, Limit: 20})
+	}
+	candidates = append(candidates,
+		query.RateLimitCandidate{Key: query.RateKey{Kind: "ip"}, Window: query.Window{Minutes: 1, Sliding: true}, Limit: 100},
+		// within a minute, sliding windows block the same requests as tumbling ones
+		query.RateLimitCandidate{Key: query.RateKey{Kind: "ip"}, Window: query.Window{Minutes: 5, Sliding: true}, Limit: 20},
+	)
+
+	b := localBackend(t, filepath.Join(dir, "logs"))
+	if err := NewRateLimitSimulationReportLoader(b, query.WafBC, candidates, 2, nil, testDay).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, row := range readResult(t, "rate-limit-simulation", "rate-limit-simulation") {
+		got = append(got, fmt.Sprint(row[1:10]))
+	}
+	want := []string{
+		"[key window_size rate_limit num_identities num_requests num_already_blocked num_newly_blocked num_known_good_identities num_known_good]",
+		"[ip 5m 20 2 20 10 10 1 5]", // only the requests past the limit
+		"[forwarded-ip 5m 20 1 10 10 0 0 0]",
+		"[cookie:session 5m 20 1 10 10 0 0 0]",
+		"[ip 1m sliding 100 0 0 0 0 0 0]",
+		"[ip 5m sliding 20 2 20 10 10 1 5]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("simulation:\n got  %s\n want %s", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/query"
+	"sort"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Report defines a report, from which the commands to load and print it are
+// generated. Reports register themselves in the init function of their file.
+type Report interface {
+	Name() string        // name of the commands of the report, e.g. "rate-limit-report"
+	Aliases() []string   // other names of the commands
+	Description() string // what the report shows
+
+	// Flags returns the flags of the parameters specific to the report, which
+	// set the values used by NewLoader and NewPrinter
+	Flags() []cli.Flag
+
+	NewLoader(b Backend, o Options) Loader
+	NewPrinter(o Options) Printer // nil if the report can't be printed
+}
+
+// Options are the parameters shared by all reports
+type Options struct {
+	Waf          query.WAF
+	Day          time.Time
+	Format       Format    // files to write results to
+	SkipExisting bool      // keep the results of steps on disk instead of running them again
+	Data         *data.Dir // data directory to write runs to and print them from
+}
+
+// Loader loads the results of a report for a WAF and day
+type Loader interface {
+	Run() error
+	Plan() *Plan
+	SetFormat(f Format)
+	SetSkipExisting(skip bool)
+	SetDataDir(d *data.Dir)
+}
+
+// Printer prints a summary of the loaded results of a report
+type Printer interface {
+	Print() error
+}
+
+var registry = map[string]Report{}
+
+// Register adds a report to the registry, it panics on duplicate names
+func Register(r Report) {
+	for _, name := range append([]string{r.Name()}, r.Aliases()...) {
+		if _, ok := Lookup(name); ok {
+			panic(fmt.Sprintf("report %s registered twice", name))
+		}
+	}
+
+	registry[r.Name()] = r
+}
+
+// Reports returns the registered reports ordered by name
+func Reports() []Report {
+	var out []Report
+	for _, r := range registry {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+
+	return out
+}
+
+// Lookup returns the report called name or one of its aliases
+func Lookup(name string) (Report, bool) {
+	for _, r := range registry {
+		if r.Name() == name {
+			return r, true
+		}
+		for _, a := range r.Aliases() {
+			if a == name {
+				return r, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// NewLoader returns the loader of the report with the format, results to
+// skip and data directory of the options
+func NewLoader(r Report, b Backend, o Options) Loader {
+	l := r.NewLoader(b, o)
+	l.SetFormat(o.Format)
+	l.SetSkipExisting(o.SkipExisting)
+	if o.Data != nil {
+		l.SetDataDir(o.Data)
+	}
+
+	return l
+}
+
+// Steps returns the names of the results the loader of the report writes with
+// the current values of its flags, in order. The loader only plans its steps,
+// without writing queries or results.
+func Steps(r Report) ([]string, error) {
+	l := r.NewLoader(nil, Options{Waf: query.WafBC, Day: time.Now()})
+	base, ok := l.(interface{ reportLoader() *ReportLoader })
+	if !ok {
+		return nil, fmt.Errorf("loader of %s can't plan its steps", r.Name())
+	}
+	base.reportLoader().planOnly = true
+
+	if err := l.Run(); err != nil {
+		return nil, fmt.Errorf("planning steps of %s: %s", r.Name(), err)
+	}
+
+	var out []string
+	for _, s := range l.Plan().Steps {
+		out = append(out, s.Name)
+	}
+	return out, nil
+}
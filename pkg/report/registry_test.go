@@ -0,0 +1,81 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/query"
+	"os"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := chdir(t)
+	t.Setenv("TMPDIR", t.TempDir())
+
+	planned := map[string][]string{}
+	for _, r := range Reports() {
+		steps, err := Steps(r)
+		if err != nil {
+			t.Fatalf("steps of %s: %s", r.Name(), err)
+		}
+		planned[r.Name()] = steps
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+		t.Errorf("planning steps wrote %v", entries)
+	}
+
+	for _, r := range Reports() {
+		l := NewLoader(r, nil, Options{Waf: query.WafBC, Day: testDay, Data: data.NewDir(dir)})
+		if err := l.Run(); err != nil {
+			t.Fatalf("dry run of %s: %s", r.Name(), err)
+		}
+
+		var steps []string
+		for _, s := range l.Plan().Steps {
+			steps = append(steps, s.Name)
+		}
+		if len(steps) == 0 || fmt.Sprint(steps) != fmt.Sprint(planned[r.Name()]) {
+			t.Errorf("%s ran steps %v dry, planned %v", r.Name(), steps, planned[r.Name()])
+		}
+
+		for _, name := range append([]string{r.Name()}, r.Aliases()...) {
+			if got, ok := Lookup(name); !ok || got != r {
+				t.Errorf("lookup of %s: got %v", name, got)
+			}
+		}
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+		t.Errorf("dry runs wrote %v to the data directory", entries)
+	}
+
+	if _, ok := Lookup("unknown"); ok {
+		t.Errorf("lookup of unknown report succeeded")
+	}
+}
+
+func TestReportFlags(t *testing.T) {
+	// the flags of windows and labels belong to the reports using them only
+	want := map[string]string{
+		"rate-limit-report":     "[window sliding label]",
+		"labels":                "[label]",
+		"rate-limit-simulation": "[window sliding label]",
+		"credential-stuffing":   "[window]",
+	}
+	for _, r := range Reports() {
+		var shared []string
+		for _, f := range r.Flags() {
+			switch name := f.Names()[0]; name {
+			case "window", "sliding", "label":
+				shared = append(shared, name)
+			}
+		}
+		w, ok := want[r.Name()]
+		if !ok {
+			w = "[]"
+		}
+		if got := fmt.Sprint(shared); got != w {
+			t.Errorf("%s has flags %s, want %s", r.Name(), got, w)
+		}
+	}
+}
@@ -3,65 +3,301 @@ package report
 import (
 	"bufio"
 	"fmt"
-	"kfzteile24/waflogs/pkg/aws"
+	"kfzteile24/waflogs/pkg/data"
+	"kfzteile24/waflogs/pkg/local"
+	"kfzteile24/waflogs/pkg/offline"
 	"kfzteile24/waflogs/pkg/query"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
-const DataDir = "./data"
-
+// ReportLoader runs the steps of a report and records them in the manifest of
+// the run. Loaders of reports embed it and set the function loading their
+// steps.
 type ReportLoader struct {
-	Athena *aws.AthenaClient
+	Backend      Backend
+	DryRun       bool      // only render and write queries to a temporary directory, don't execute them
+	SkipExisting bool      // keep the results of steps on disk in the selected format instead of running them again
+	Format       Format    // files to write results to
+	Data         *data.Dir // data directory to write runs to
+
+	Name   string
+	Scope  query.Scope
+	Params map[string]string // parameters of the report recorded in the manifest
 
-	Name  string
-	Scope query.Scope
+	steps    func() error   // loads the steps of the report with RunQuery
+	planOnly bool           // only plan the steps, don't write anything
+	dryRun   *data.Dir      // temporary directory dry runs write their queries to
+	plan     Plan           // steps executed, or only rendered in a dry run
+	manifest *data.Manifest // of the current run, nil in dry runs
 }
 
-func NewReportLoader(a *aws.AthenaClient, waf query.WAF, name string, year int, month int, day int) *ReportLoader {
+// NewReportLoader creates a loader for the report called name. Without a
+// backend, the loader runs dry.
+func NewReportLoader(b Backend, waf query.WAF, name string, year int, month int, day int) *ReportLoader {
+	scope := query.Scope{
+		Waf:   waf,
+		Year:  year,
+		Month: month,
+		Day:   day,
+	}
+
 	return &ReportLoader{
-		Athena: a,
-		Name:   name,
-		Scope: query.Scope{
-			Waf:   waf,
-			Year:  year,
-			Month: month,
-			Day:   day,
+		Backend: b,
+		DryRun:  b == nil,
+		Data:    data.NewDir(data.DefaultRoot),
+		Name:    name,
+		Scope:   scope,
+		plan: Plan{
+			Report: name,
+			Scope:  scope,
 		},
 	}
 }
 
 func (r *ReportLoader) getOutDir() string {
-	return filepath.Join(DataDir, r.Scope.Waf.String(), r.Name, fmt.Sprintf("%04d-%02d-%02d", r.Scope.Year, r.Scope.Month, r.Scope.Day))
+	if r.dryRun != nil {
+		return r.dryRun.RunDir(r.Name, r.Scope)
+	}
+	return r.Data.RunDir(r.Name, r.Scope)
+}
+
+func (r *ReportLoader) reportLoader() *ReportLoader {
+	return r
+}
+
+// Plan returns the steps executed, or only rendered in a dry run, by Run
+func (r *ReportLoader) Plan() *Plan {
+	return &r.plan
+}
+
+// SetFormat selects the files Run writes results to, CSV by default
+func (r *ReportLoader) SetFormat(f Format) {
+	r.Format = f
+}
+
+// SetSkipExisting selects if Run keeps the results of steps on disk instead
+// of running them again
+func (r *ReportLoader) SetSkipExisting(skip bool) {
+	r.SkipExisting = skip
+}
+
+// SetDataDir selects the data directory Run writes to, ./data by default
+func (r *ReportLoader) SetDataDir(d *data.Dir) {
+	r.Data = d
+}
+
+// Run loads the results of the report and records the run in the manifest
+// of the output directory. Dry runs write their queries to a temporary
+// directory instead, leaving the data directory and its runs untouched.
+func (r *ReportLoader) Run() error {
+	if r.planOnly {
+		return r.steps()
+	}
+	if r.DryRun {
+		root, err := os.MkdirTemp("", "waflogs-dry-run-")
+		if err != nil {
+			return fmt.Errorf("creating directory of dry run: %s", err)
+		}
+		r.dryRun = data.NewDir(root)
+	} else if err := r.Data.Open(); err != nil {
+		return err
+	}
+	if err := r.ensureOutDirExists(); err != nil {
+		return fmt.Errorf("creating output directory: %s", err)
+	}
+
+	if r.DryRun {
+		return r.steps()
+	}
+
+	r.manifest = data.NewManifest(r.Name, r.Scope.Waf.String(), filepath.Base(r.getOutDir()))
+	r.manifest.Params = r.Params
+	r.manifest.Backend = backendName(r.Backend)
+	r.manifest.Format = r.Format.String()
+	if err := r.manifest.Write(r.getOutDir()); err != nil {
+		return err
+	}
+
+	err := r.steps()
+	r.manifest.Finish(err)
+	if werr := r.manifest.Write(r.getOutDir()); werr != nil && err == nil {
+		return werr
+	}
+
+	return err
+}
+
+// printf prints the progress of the steps, unless the loader only plans them
+func (r *ReportLoader) printf(format string, a ...interface{}) {
+	if !r.planOnly {
+		fmt.Printf(format, a...)
+	}
+}
+
+// rawTableStep returns a step reading the day partition of the raw log table
+func (r *ReportLoader) rawTableStep(name string, sql string) Step {
+	return Step{
+		Name:       name,
+		SQL:        sql,
+		Reads:      []string{query.Table(r.Scope.Waf)},
+		Partitions: []string{r.Scope.Partition()},
+	}
 }
 
 func (r *ReportLoader) ensureOutDirExists() error {
 	return ensureDirExists(r.getOutDir())
 }
 
-func (r *ReportLoader) RunQuery(sql string, name string) error {
-	queryPath := filepath.Join(r.getOutDir(), fmt.Sprintf("%s.sql", name))
-	if err := os.WriteFile(queryPath, []byte(sql), 0644); err != nil {
+// RunQuery writes the query of the step to disk and executes it, unless the
+// loader runs dry
+func (r *ReportLoader) RunQuery(step Step) error {
+	r.plan.Steps = append(r.plan.Steps, step)
+	if r.planOnly {
+		return nil
+	}
+
+	queryPath := filepath.Join(r.getOutDir(), fmt.Sprintf("%s.sql", step.Name))
+	if err := os.WriteFile(queryPath, []byte(step.SQL), 0644); err != nil {
 		return fmt.Errorf("writing query to disk: %s", err)
 	}
 
-	resultsPath := filepath.Join(r.getOutDir(), fmt.Sprintf("%s.csv", name))
-	if err := r.Athena.Query(sql, resultsPath); err != nil {
-		return fmt.Errorf("running query: %s", err)
+	if r.DryRun {
+		log.Printf("Dry run: query written to %s\n", queryPath)
+		return nil
+	}
+
+	run := data.StepRun{Name: step.Name, Started: time.Now().UTC().Truncate(time.Millisecond), Files: []string{filepath.Base(queryPath)}}
+	err := r.runStep(step, &run)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	finished := time.Now().UTC().Truncate(time.Millisecond)
+	run.Finished = &finished
+
+	if r.manifest != nil {
+		r.manifest.Steps = append(r.manifest.Steps, run)
+		if werr := r.manifest.Write(r.getOutDir()); werr != nil && err == nil {
+			return werr
+		}
+	}
+
+	return err
+}
+
+// runStep executes the query of the step on the backend and records the
+// execution and files written in run
+func (r *ReportLoader) runStep(step Step, run *data.StepRun) error {
+	resultsPath := filepath.Join(r.getOutDir(), fmt.Sprintf("%s.csv", step.Name))
+	parquetPath := strings.TrimSuffix(resultsPath, ".csv") + ".parquet"
+
+	if r.SkipExisting {
+		kept := []string{resultsPath} // files of the selected format
+		if r.Format == FormatParquet && step.Creates == "" {
+			kept = []string{parquetPath}
+		} else if r.Format == FormatBoth && step.Creates == "" {
+			kept = append(kept, parquetPath)
+		}
+		if filesExist(kept) {
+			log.Printf("Result of an earlier run kept for %s\n", step.Name)
+			run.Reused = true
+			for _, path := range kept {
+				run.Files = append(run.Files, filepath.Base(path))
+			}
+			return nil
+		}
+	}
+
+	e, err := r.Backend.Run(r.Scope, step, resultsPath)
+	if err != nil {
+		return err
 	}
+	run.QueryID = e.ID
+	run.BytesScanned = e.BytesScanned
 
 	numLines, err := countLines(resultsPath)
 	if err != nil {
 		return fmt.Errorf("counting lines of result : %s", err)
 	}
 	if numLines > 0 {
-		log.Printf("Query done: number of lines returned: %d\n", numLines-1) // subtract header
+		run.Rows = numLines - 1 // subtract header
+		log.Printf("Query done: number of lines returned: %d\n", run.Rows)
+	}
+
+	if r.Format == FormatCSV || step.Creates != "" {
+		run.Files = append(run.Files, filepath.Base(resultsPath))
+		return nil // statements creating tables have no results worth keeping typed
+	}
+
+	if err := r.writeParquet(resultsPath, parquetPath, e.Columns); err != nil {
+		return fmt.Errorf("writing parquet: %s", err)
+	}
+
+	if r.Format == FormatParquet {
+		run.Files = append(run.Files, filepath.Base(parquetPath))
+		return os.Remove(resultsPath)
 	}
 
+	run.Files = append(run.Files, filepath.Base(resultsPath), filepath.Base(parquetPath))
 	return nil
 }
 
+// writeParquet converts the CSV result at csvPath to Parquet at dstPath. The
+// types of the columns are inferred from the values if the backend didn't
+// return them, the types of the elements of arrays if it returned only
+// "array", like Athena does.
+func (r *ReportLoader) writeParquet(csvPath string, dstPath string, cols []query.Column) error {
+	cols, err := inferTypes(csvPath, cols)
+	if err != nil {
+		return err
+	}
+
+	return local.WriteParquet(csvPath, dstPath, cols)
+}
+
+// inferTypes returns the columns of the CSV result at csvPath with the types
+// missing from cols inferred from the values
+func inferTypes(csvPath string, cols []query.Column) ([]query.Column, error) {
+	untyped := cols == nil
+	for _, c := range cols {
+		untyped = untyped || strings.EqualFold(strings.TrimSpace(c.Type), "array")
+	}
+	if !untyped {
+		return cols, nil
+	}
+
+	t, err := offline.ReadCSV(csvPath)
+	if err != nil {
+		return nil, err
+	}
+	inferred := t.Columns()
+	if cols == nil {
+		return inferred, nil
+	}
+
+	out := make([]query.Column, len(cols))
+	for i, c := range cols {
+		out[i] = c
+		if strings.EqualFold(strings.TrimSpace(c.Type), "array") && i < len(inferred) && inferred[i].BaseType() == "array" {
+			out[i].Type = inferred[i].Type
+		}
+	}
+	return out, nil
+}
+
+// filesExist reports if all files at paths exist
+func filesExist(paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
 func countLines(filename string) (int, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -0,0 +1,251 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/offline"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/report/printer"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	Register(&scraperCampaignReport{})
+}
+
+// ReadCampaigns reads and validates the list of scraper campaigns in the
+// YAML file at path, e.g.
+//
+//   - name: apc1
+//     min_user_agents: 9
+//     min_requests: 5000
+//     uri_pattern: ^/artikeldetails$
+//     product_pattern: '[?&]search=([^&]+)'
+//     allowlist_rules: [waf-whitelist, seo-crawler]
+func ReadCampaigns(path string) ([]query.Campaign, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening campaigns: %s", err)
+	}
+	defer f.Close()
+
+	var out []query.Campaign
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s: no campaigns", path)
+	}
+	names := map[string]bool{}
+	for _, c := range out {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("%s: campaign %s defined twice", path, c.Name)
+		}
+		names[c.Name] = true
+	}
+
+	return out, nil
+}
+
+// scraperCampaignReport defines the report on scrapers rotating User-Agents,
+// tracking several campaigns of them
+type scraperCampaignReport struct {
+	tracked []query.Campaign // read from the file of the flags, APC1 if nil
+}
+
+// campaigns returns the campaigns of the file of the flags, APC1 without one
+func (r *scraperCampaignReport) campaigns() []query.Campaign {
+	if r.tracked == nil {
+		return []query.Campaign{query.APC1Campaign}
+	}
+	return r.tracked
+}
+
+func (*scraperCampaignReport) Name() string      { return "scraper-campaigns" }
+func (*scraperCampaignReport) Aliases() []string { return []string{"scrapers"} }
+
+func (*scraperCampaignReport) Description() string {
+	return "URLs, products and User-Agents of scraper campaigns rotating User-Agents, APC1 unless configured with --campaigns"
+}
+
+func (r *scraperCampaignReport) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "campaigns",
+			Usage: "YAML file of the campaigns to track with their thresholds, target URI patterns and product id patterns, APC1 if not set",
+			Action: func(ctx *cli.Context, v string) error {
+				campaigns, err := ReadCampaigns(v)
+				if err != nil {
+					return err
+				}
+
+				r.tracked = campaigns
+				return nil
+			},
+		},
+	}
+}
+
+func (r *scraperCampaignReport) NewLoader(b Backend, o Options) Loader {
+	return NewScraperCampaignReportLoader(b, o.Waf, r.campaigns(), o.Day)
+}
+
+func (r *scraperCampaignReport) NewPrinter(o Options) Printer {
+	var summaries []printer.Summary
+	for _, c := range r.campaigns() {
+		steps := campaignSteps(c)
+		summaries = append(summaries,
+			printer.Summary{Result: steps[0], Title: fmt.Sprintf("URLs scraped by %s", c.Name), OrderBy: "num_requests", Limit: 10},
+			printer.Summary{Result: steps[1], Title: fmt.Sprintf("User-Agents of %s", c.Name), OrderBy: "num_requests", Limit: 10},
+			printer.Summary{Result: steps[2], Title: fmt.Sprintf("Products scraped by %s", c.Name), OrderBy: "num_requests", Limit: 10},
+		)
+	}
+
+	return printer.NewSummaryPrinter(o.Data, o.Waf, "scraper-campaigns", summaries)
+}
+
+// campaignSteps returns the names of the results of a campaign
+func campaignSteps(c query.Campaign) []string {
+	return []string{c.Name + "-scraped-urls", c.Name + "-scraper-user-agents", c.Name + "-scraped-products"}
+}
+
+// ScraperCampaignReportLoader loads the URLs, User-Agents and products of
+// scrapers rotating User-Agents within their sessions, with a fingerprint of
+// thresholds and patterns per campaign
+type ScraperCampaignReportLoader struct {
+	*ReportLoader
+	campaigns []query.Campaign
+}
+
+func NewScraperCampaignReportLoader(b Backend, waf query.WAF, campaigns []query.Campaign, t time.Time) *ScraperCampaignReportLoader {
+	year, month, day := getDay(t)
+
+	out := &ScraperCampaignReportLoader{
+		ReportLoader: NewReportLoader(b, waf, "scraper-campaigns", year, month, day),
+		campaigns:    campaigns,
+	}
+
+	var names []string
+	for _, c := range campaigns {
+		names = append(names, c.Name)
+	}
+	out.Params = map[string]string{
+		"campaigns": strings.Join(names, ","),
+	}
+
+	out.steps = out.load
+
+	return out
+}
+
+func (r *ScraperCampaignReportLoader) load() error {
+	if err := r.CreateMaterializedView(); err != nil {
+		return fmt.Errorf("creating materialized view: %s", err)
+	}
+
+	for _, c := range r.campaigns {
+		if err := r.LoadCampaign(c); err != nil {
+			return fmt.Errorf("loading campaign %s: %s", c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *ScraperCampaignReportLoader) CreateMaterializedView() error {
+	r.printf("\n[+] Creating Parquet waflog view...\n")
+
+	step, err := materializedViewStep(r.ReportLoader)
+	if err != nil {
+		return err
+	}
+
+	if err := r.RunQuery(step); err != nil {
+		return fmt.Errorf("running query: %s", err)
+	}
+
+	return nil
+}
+
+// LoadCampaign loads the scraped URLs, the User-Agents and the scraped
+// products of the campaign
+func (r *ScraperCampaignReportLoader) LoadCampaign(c query.Campaign) error {
+	r.printf("\n[+] Loading URLs, User-Agents and products of campaign %s...\n", c.Name)
+
+	steps := campaignSteps(c)
+	queries := []struct {
+		step   string
+		render campaignQuery
+		local  campaignTable
+		limit  int
+	}{
+		{steps[0], query.GetScraperURLs, offline.ScraperURLs, 100},
+		{steps[1], query.GetScraperUserAgents, offline.ScraperUserAgents, 1000},
+		{steps[2], query.GetScraperProducts, offline.ScraperProducts, 200000},
+	}
+
+	for _, q := range queries {
+		step, err := campaignStep(r.ReportLoader, q.step, c, q.render, q.local, q.limit)
+		if err != nil {
+			return err
+		}
+		if err := r.RunQuery(step); err != nil {
+			return fmt.Errorf("running query of %s: %s", q.step, err)
+		}
+	}
+
+	return nil
+}
+
+// campaignQuery renders a query of a scraper campaign
+type campaignQuery func(query.Scope, query.Campaign, int) (string, error)
+
+// campaignTable computes the result of a campaignQuery from the raw records
+type campaignTable func([]*waflog.Record, query.Campaign, int) *offline.Table
+
+// materializedViewStep returns the step creating the APC1 materialized view
+// the queries of scraper campaigns read
+func materializedViewStep(r *ReportLoader) (Step, error) {
+	sql, err := query.CreateAPC1MaterializedView(r.Scope)
+	if err != nil {
+		return Step{}, fmt.Errorf("rendering sql: %s", err)
+	}
+
+	step := r.rawTableStep("create-materialized-view", sql)
+	step.Creates = query.APC1MaterializedViewName(r.Scope)
+	step.Local = func(records []*waflog.Record) (*offline.Table, error) {
+		return &offline.Table{}, nil // the offline queries read the raw records directly
+	}
+
+	return step, nil
+}
+
+// campaignStep returns the step called name of the campaign reading the
+// materialized view
+func campaignStep(r *ReportLoader, name string, c query.Campaign, render campaignQuery, local campaignTable, limit int) (Step, error) {
+	sql, err := render(r.Scope, c, limit)
+	if err != nil {
+		return Step{}, fmt.Errorf("rendering sql of %s: %s", name, err)
+	}
+
+	return Step{
+		Name:      name,
+		SQL:       sql,
+		DependsOn: []string{"create-materialized-view"},
+		Reads:     []string{query.APC1MaterializedViewName(r.Scope)},
+		Local: func(records []*waflog.Record) (*offline.Table, error) {
+			return local(records, c, limit), nil
+		},
+	}, nil
+}
@@ -0,0 +1,189 @@
+package report
+
+import (
+	"fmt"
+	"kfzteile24/waflogs/pkg/query"
+	"kfzteile24/waflogs/pkg/waflog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestScraperCampaignReport(t *testing.T) {
+	campaigns, err := ReadCampaigns(filepath.Join("testdata", "campaigns.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := chdir(t)
+	at := testDay.Add(10 * time.Hour)
+
+	var records []*waflog.Record
+	for i := 0; i < 30; i++ { // rotating through 3 User-Agents on product pages
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "1.2.3.4", fmt.Sprintf("Mozilla/5.0 (%d)", i%3), "Default_Action", "ALLOW")
+		r.HTTPRequest.URI = fmt.Sprintf("/p/%d", i%4)
+		r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "cookie", Value: "session=shop-scraper"})
+		records = append(records, r)
+	}
+	for i := 0; i < 5; i++ { // a customer looking at products
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "5.6.7.8", "Mozilla/5.0 (0)", "Default_Action", "ALLOW")
+		r.HTTPRequest.URI = "/p/1"
+		r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "cookie", Value: "session=customer"})
+		records = append(records, r)
+	}
+	writeLogs(t, filepath.Join(dir, "logs"), query.WafBC, records)
+
+	for name, b := range testBackends(t, filepath.Join(dir, "logs")) {
+		t.Run(name, func(t *testing.T) {
+			if err := NewScraperCampaignReportLoader(b, query.WafBC, campaigns, testDay).Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// each campaign has its own results, APC1 found no scrapers
+			if got := readResult(t, "scraper-campaigns", "apc1-scraped-products"); len(got) != 1 {
+				t.Errorf("apc1 products: %v", got)
+			}
+
+			if got := fmt.Sprint(readResult(t, "scraper-campaigns", "shop-pages-scraped-products")); got != "[[product num_requests user_agents] [0 8 3] [1 8 3] [2 7 3] [3 7 3]]" {
+				t.Errorf("shop-pages products: %s", got)
+			}
+
+			uas := readResult(t, "scraper-campaigns", "shop-pages-scraper-user-agents")
+			if fmt.Sprint(uas[1][:4]) != "[Mozilla/5.0 (0) 15 10 5]" {
+				t.Errorf("shop-pages user agents: %v", uas)
+			}
+
+			urls := readResult(t, "scraper-campaigns", "shop-pages-scraped-urls")
+			if len(urls) != 5 || fmt.Sprint(urls[1][:2]) != "[/p/0& 8]" || urls[1][6] != "true" {
+				t.Errorf("shop-pages urls: %v", urls)
+			}
+		})
+	}
+}
+
+func TestScraperCampaignReportEdgeCases(t *testing.T) {
+	campaigns := []query.Campaign{{
+		Name:           "search",
+		MinUserAgents:  2,
+		MinRequests:    2,
+		URIPattern:     `^/search$`,
+		ProductPattern: `[?&]id=([0-9]+)`,
+		AllowlistRules: []string{"seo-crawler"},
+	}}
+
+	at := testDay.Add(10 * time.Hour)
+	// rotate returns 12 searches for the product id rotating through 3
+	// User-Agents, with the session cookie if any
+	rotate := func(ip string, session string, rule string, id func(i int) int, labels ...string) []*waflog.Record {
+		var records []*waflog.Record
+		for i := 0; i < 12; i++ {
+			r := testRecord(at.Add(time.Duration(i)*time.Second), ip, fmt.Sprintf("Mozilla/5.0 (%d)", i%3), rule, "ALLOW", labels...)
+			r.HTTPRequest.URI = "/search"
+			r.HTTPRequest.Args = fmt.Sprintf("q=bremse&id=%d", id(i))
+			if session != "" {
+				r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "cookie", Value: "session=" + session})
+			}
+			records = append(records, r)
+		}
+		return records
+	}
+
+	// a scraper, whose requests to other pages count for its User-Agents only
+	scraper := rotate("1.2.3.4", "scraper", "Default_Action", func(i int) int { return i % 2 })
+	for i := 0; i < 3; i++ {
+		r := testRecord(at.Add(time.Duration(i)*time.Second), "1.2.3.4", "Mozilla/5.0 (0)", "Default_Action", "ALLOW")
+		r.HTTPRequest.URI = "/warenkorb"
+		r.HTTPRequest.Args = "id=5"
+		r.HTTPRequest.Headers = append(r.HTTPRequest.Headers, waflog.Header{Name: "cookie", Value: "session=scraper"})
+		scraper = append(scraper, r)
+	}
+
+	products := []string{"product", "num_requests", "user_agents"}
+	runReportCases(t, "scraper-campaigns", true, func(b Backend) error {
+		return NewScraperCampaignReportLoader(b, query.WafBC, campaigns, testDay).Run()
+	}, []reportCase{
+		{
+			name:    "scraper rotating User-Agents",
+			records: scraper,
+			result:  "search-scraped-products",
+			columns: products,
+			want:    [][]string{{"0", "6", "3"}, {"1", "6", "3"}},
+		},
+		{
+			name:    "requests of the scraper to other pages",
+			records: scraper,
+			result:  "search-scraper-user-agents",
+			columns: []string{"user_agent", "num_requests", "num_scraped"},
+			want:    [][]string{{"Mozilla/5.0 (0)", "7", "7"}, {"Mozilla/5.0 (1)", "4", "4"}, {"Mozilla/5.0 (2)", "4", "4"}},
+		},
+		{
+			name:    "crawler allowed by an allowlisted rule",
+			records: rotate("66.249.66.1", "crawler", "seo-crawler", func(int) int { return 9 }),
+			result:  "search-scraped-products",
+			columns: products,
+		},
+		{
+			name:    "bot revealing itself",
+			records: rotate("6.6.6.6", "bot", "Default_Action", func(int) int { return 8 }, "awswaf:managed:aws:bot-control:signal:non_browser_user_agent"),
+			result:  "search-scraped-products",
+			columns: products,
+		},
+		{
+			name:    "clients without session cookie",
+			records: rotate("7.7.7.7", "", "Default_Action", func(int) int { return 7 }),
+			result:  "search-scraped-products",
+			columns: products,
+		},
+	})
+}
+
+func TestScraperCampaignReportCampaigns(t *testing.T) {
+	r := &scraperCampaignReport{}
+	steps, err := Steps(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(steps); got != "[create-materialized-view apc1-scraped-urls apc1-scraper-user-agents apc1-scraped-products]" {
+		t.Errorf("steps without campaigns: %s", got)
+	}
+
+	// the campaigns of the flag are read when the flags are parsed
+	flag := r.Flags()[0].(*cli.StringFlag)
+	if err := flag.Action(nil, filepath.Join("testdata", "campaigns.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := flag.Action(nil, filepath.Join("testdata", "missing.yaml")); err == nil {
+		t.Errorf("expected error of missing campaigns")
+	}
+	steps, err = Steps(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(steps[len(steps)-3:]); got != "[shop-pages-scraped-urls shop-pages-scraper-user-agents shop-pages-scraped-products]" {
+		t.Errorf("steps with campaigns: %s", got)
+	}
+	l := r.NewLoader(nil, Options{Waf: query.WafBC, Day: testDay}).(*ScraperCampaignReportLoader)
+	if got := l.Params["campaigns"]; got != "apc1,shop-pages" {
+		t.Errorf("campaigns of the loader: %s", got)
+	}
+}
+
+func TestReadCampaignsErrors(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"empty":      "[]",
+		"unknown":    "- {name: a, min_user_agents: 2, uri_pattern: ^/, product_pattern: (x), max_requests: 1}",
+		"duplicate":  "- {name: a, min_user_agents: 2, uri_pattern: ^/, product_pattern: (x)}\n- {name: a, min_user_agents: 2, uri_pattern: ^/, product_pattern: (x)}",
+		"no pattern": "- {name: a, min_user_agents: 2, product_pattern: (x)}",
+	} {
+		path := filepath.Join(t.TempDir(), "campaigns.yaml")
+		if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadCampaigns(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
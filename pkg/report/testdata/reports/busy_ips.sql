@@ -0,0 +1,5 @@
+SELECT client_ip,
+       num_requests
+FROM {{.Tables.create_busy_ips}}
+ORDER BY num_requests DESC, client_ip
+LIMIT {{.Params.limit}}
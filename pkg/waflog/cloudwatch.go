@@ -0,0 +1,59 @@
+package waflog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DecodeCloudWatchExport calls fn for each record of a log group exported
+// from CloudWatch Logs to S3. Each line of an export is an event, its
+// ingestion time followed by the message, e.g.
+//
+//	2023-02-21T10:00:00.000Z {"timestamp":1676973600000,...}
+//
+// Lines of messages only, e.g. from 'aws logs tail', are read as well.
+func DecodeCloudWatchExport(r io.Reader, fn func(*Record) error) error {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading line %d: %s", n, err)
+		}
+
+		if i := strings.IndexByte(line, '{'); i >= 0 {
+			if derr := Decode(strings.NewReader(line[i:]), fn); derr != nil {
+				return fmt.Errorf("line %d: %s", n, derr)
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// ReadCloudWatchExport calls fn for each record of an exported log file,
+// which is decompressed if its name ends with .gz
+func ReadCloudWatchExport(path string, fn func(*Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening file: %s", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("opening gzip reader: %s", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return DecodeCloudWatchExport(r, fn)
+}
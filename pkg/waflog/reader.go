@@ -0,0 +1,73 @@
+package waflog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Decode calls fn for each record in r. Records may be separated by
+// newlines or, like in Firehose objects, simply be concatenated.
+func Decode(r io.Reader, fn func(*Record) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decoding record: %s", err)
+		}
+
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadFile calls fn for each record of a log file, which is decompressed if
+// its name ends with .gz
+func ReadFile(path string, fn func(*Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening file: %s", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("opening gzip reader: %s", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return Decode(r, fn)
+}
+
+// ReadDir calls fn for each record of all log files below dir, e.g. a local
+// copy of the Firehose objects of a WAF as laid out in S3
+func ReadDir(dir string, fn func(*Record) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		if err := ReadFile(path, fn); err != nil {
+			return fmt.Errorf("reading %s: %s", path, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,186 @@
+package waflog
+
+import (
+	"strings"
+	"time"
+)
+
+// Record is a single entry of an AWS WAF log, see
+// https://docs.aws.amazon.com/waf/latest/developerguide/logging-fields.html
+type Record struct {
+	Timestamp                   int64       `json:"timestamp"` // milliseconds since epoch
+	FormatVersion               int         `json:"formatVersion"`
+	WebACLID                    string      `json:"webaclId"`
+	TerminatingRuleID           string      `json:"terminatingRuleId"`
+	TerminatingRuleType         string      `json:"terminatingRuleType"`
+	Action                      string      `json:"action"`
+	HTTPSourceName              string      `json:"httpSourceName"`
+	HTTPSourceID                string      `json:"httpSourceId"`
+	RuleGroupList               []RuleGroup `json:"ruleGroupList"`
+	NonTerminatingMatchingRules []RuleMatch `json:"nonTerminatingMatchingRules"`
+	HTTPRequest                 HTTPRequest `json:"httpRequest"`
+	Labels                      []Label     `json:"labels,omitempty"`
+
+	// JA3Fingerprint is the hash of the TLS client hello, logged for HTTPS
+	// requests to CloudFront and Application Load Balancers
+	JA3Fingerprint string `json:"ja3Fingerprint,omitempty"`
+
+	// CaptchaResponse and ChallengeResponse describe the token of a request a
+	// rule with a CAPTCHA or Challenge action inspected
+	CaptchaResponse   *TokenResponse `json:"captchaResponse,omitempty"`
+	ChallengeResponse *TokenResponse `json:"challengeResponse,omitempty"`
+
+	// TerminatingRuleMatchDetails describes what the terminating rule matched,
+	// logged for SQL injection and cross-site scripting rules
+	TerminatingRuleMatchDetails []MatchDetail `json:"terminatingRuleMatchDetails,omitempty"`
+}
+
+type RuleGroup struct {
+	RuleGroupID                 string      `json:"ruleGroupId"`
+	TerminatingRule             *RuleMatch  `json:"terminatingRule"`
+	NonTerminatingMatchingRules []RuleMatch `json:"nonTerminatingMatchingRules"`
+}
+
+type RuleMatch struct {
+	RuleID string `json:"ruleId"`
+	Action string `json:"action"`
+}
+
+type MatchDetail struct {
+	ConditionType    string   `json:"conditionType"` // e.g. SQL_INJECTION
+	SensitivityLevel string   `json:"sensitivityLevel,omitempty"`
+	Location         string   `json:"location"` // e.g. QUERY_STRING, HEADER
+	MatchedData      []string `json:"matchedData"`
+	MatchedFieldName string   `json:"matchedFieldName,omitempty"`
+}
+
+type TokenResponse struct {
+	ResponseCode   int    `json:"responseCode"`             // 0 for a valid token, 405 for a CAPTCHA, 202 for a challenge sent instead
+	SolveTimestamp int64  `json:"solveTimestamp,omitempty"` // seconds since epoch the puzzle of the token was solved
+	FailureReason  string `json:"failureReason,omitempty"`  // e.g. TOKEN_MISSING, TOKEN_EXPIRED
+}
+
+type HTTPRequest struct {
+	ClientIP    string   `json:"clientIp"`
+	Country     string   `json:"country"`
+	Headers     []Header `json:"headers"`
+	URI         string   `json:"uri"`
+	Args        string   `json:"args"`
+	HTTPVersion string   `json:"httpVersion"`
+	HTTPMethod  string   `json:"httpMethod"`
+	RequestID   string   `json:"requestId"`
+}
+
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Label struct {
+	Name string `json:"name"`
+}
+
+const (
+	botNameLabelPrefix     = "awswaf:managed:aws:bot-control:bot:name:"
+	botCategoryLabelPrefix = "awswaf:managed:aws:bot-control:bot:category:"
+	botVerifiedLabel       = "awswaf:managed:aws:bot-control:bot:verified"
+	nonBrowserLabel        = "awswaf:managed:aws:bot-control:signal:non_browser_user_agent"
+)
+
+// Time returns the time the request was received
+func (r *Record) Time() time.Time {
+	return time.UnixMilli(r.Timestamp).UTC()
+}
+
+// Day returns the day of the request in the format of the day partition, e.g. 2023/02/21
+func (r *Record) Day() string {
+	return r.Time().Format("2006/01/02")
+}
+
+// Header returns the value of the first header called name, ignoring case
+func (r *Record) Header(name string) (string, bool) {
+	for _, h := range r.HTTPRequest.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value, true
+		}
+	}
+	return "", false
+}
+
+// UserAgent returns the User-Agent header, if any
+func (r *Record) UserAgent() (string, bool) {
+	return r.Header("user-agent")
+}
+
+// ForwardedIP returns the first address of the X-Forwarded-For header, the
+// forwarded IP rate-based rules aggregate by, if any
+func (r *Record) ForwardedIP() (string, bool) {
+	forwarded, ok := r.Header("x-forwarded-for")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSpace(strings.Split(forwarded, ",")[0]), true
+}
+
+// Session returns the value of the first cookie whose name starts with
+// "session", like c_session of the APC1 materialized view
+func (r *Record) Session() (string, bool) {
+	cookie, ok := r.Header("cookie")
+	if !ok {
+		return "", false
+	}
+
+	for _, kv := range strings.Split(cookie, ";") {
+		kv = strings.TrimSpace(kv)
+		if !strings.HasPrefix(strings.ToLower(kv), "session") {
+			continue
+		}
+
+		parts := strings.Split(kv, "=")
+		if len(parts) < 2 {
+			return "", false
+		}
+		return parts[1], true
+	}
+
+	return "", false
+}
+
+// HasLabel returns true if the request carries the label called name
+func (r *Record) HasLabel(name string) bool {
+	for _, l := range r.Labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelValue returns the last segment of the first label starting with
+// prefix, e.g. "googlebot" for awswaf:managed:aws:bot-control:bot:name:googlebot
+func (r *Record) LabelValue(prefix string) (string, bool) {
+	for _, l := range r.Labels {
+		if strings.HasPrefix(l.Name, prefix) {
+			parts := strings.Split(l.Name, ":")
+			return parts[len(parts)-1], true
+		}
+	}
+	return "", false
+}
+
+func (r *Record) BotName() (string, bool) {
+	return r.LabelValue(botNameLabelPrefix)
+}
+
+func (r *Record) BotCategory() (string, bool) {
+	return r.LabelValue(botCategoryLabelPrefix)
+}
+
+func (r *Record) BotVerified() bool {
+	return r.HasLabel(botVerifiedLabel)
+}
+
+// SignalNoBrowser returns true if Bot Control found the User-Agent not to be a browser's
+func (r *Record) SignalNoBrowser() bool {
+	return r.HasLabel(nonBrowserLabel)
+}
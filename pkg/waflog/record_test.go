@@ -0,0 +1,91 @@
+package waflog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeConcatenatedRecords(t *testing.T) {
+	// Firehose concatenates records without a separator
+	in := `{"timestamp":1676973600000,"action":"ALLOW","httpRequest":{"clientIp":"1.2.3.4"}}{"timestamp":1676973601000,"action":"BLOCK","httpRequest":{"clientIp":"5.6.7.8"}}
+{"timestamp":1676973602000,"action":"ALLOW","httpRequest":{"clientIp":"9.9.9.9"}}`
+
+	var ips []string
+	err := Decode(strings.NewReader(in), func(r *Record) error {
+		ips = append(ips, r.HTTPRequest.ClientIP)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := strings.Join(ips, ","); got != "1.2.3.4,5.6.7.8,9.9.9.9" {
+		t.Errorf("unexpected records: %s", got)
+	}
+}
+
+func TestRecordFields(t *testing.T) {
+	r := &Record{
+		Timestamp: 1676973600000,
+		HTTPRequest: HTTPRequest{
+			Headers: []Header{
+				{Name: "User-Agent", Value: "curl/7.0"},
+				{Name: "cookie", Value: "lang=de; SESSION=abc123; other=1"},
+				{Name: "X-Forwarded-For", Value: "203.0.113.7, 10.0.0.1"},
+			},
+		},
+		Labels: []Label{
+			{Name: "awswaf:managed:aws:bot-control:bot:category:http_library"},
+			{Name: "awswaf:managed:aws:bot-control:bot:name:curl"},
+			{Name: "awswaf:managed:aws:bot-control:signal:non_browser_user_agent"},
+		},
+	}
+
+	if got := r.Day(); got != "2023/02/21" {
+		t.Errorf("day: got %s", got)
+	}
+	if got, _ := r.UserAgent(); got != "curl/7.0" {
+		t.Errorf("user agent: got %s", got)
+	}
+	if got, _ := r.Session(); got != "abc123" {
+		t.Errorf("session: got %s", got)
+	}
+	if got, _ := r.ForwardedIP(); got != "203.0.113.7" {
+		t.Errorf("forwarded ip: got %s", got)
+	}
+	if got, _ := r.BotName(); got != "curl" {
+		t.Errorf("bot name: got %s", got)
+	}
+	if got, _ := r.BotCategory(); got != "http_library" {
+		t.Errorf("bot category: got %s", got)
+	}
+	if !r.SignalNoBrowser() || r.BotVerified() {
+		t.Errorf("unexpected signals")
+	}
+}
+
+func TestDecodeCloudWatchExport(t *testing.T) {
+	// events prefixed with their ingestion time, messages may contain spaces
+	in := `2023-02-21T10:00:00.123Z {"timestamp":1676973600000,"action":"ALLOW","httpRequest":{"clientIp":"1.2.3.4","uri":"/a b"}}
+2023-02-21T10:00:01.456Z {"timestamp":1676973601000,"action":"BLOCK","httpRequest":{"clientIp":"5.6.7.8"}}
+
+{"timestamp":1676973602000,"action":"ALLOW","httpRequest":{"clientIp":"9.9.9.9"}}`
+
+	var ips []string
+	err := DecodeCloudWatchExport(strings.NewReader(in), func(r *Record) error {
+		ips = append(ips, r.HTTPRequest.ClientIP)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := strings.Join(ips, ","); got != "1.2.3.4,5.6.7.8,9.9.9.9" {
+		t.Errorf("unexpected records: %s", got)
+	}
+
+	err = DecodeCloudWatchExport(strings.NewReader("2023-02-21T10:00:00.123Z {\"timestamp\":"), func(r *Record) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected error of line 1, got %v", err)
+	}
+}